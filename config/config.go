@@ -15,16 +15,152 @@ type Config struct {
 type Net struct {
 	// Enabled toggles whether the net server is enabled.
 	Enabled bool
+	// Network is the kind of socket the net server listens on: 'tcp' (the default) or 'unix'.
+	// With 'unix', Host, and each list's Host, are socket paths rather than host:port strings.
+	Network string
 	// Host is the TCP host:port string for the net server.
+	// IPv6 hosts go in brackets, with any zone: for instance, "[fe80::1%eth0]:1350".
+	// The IPv6 wildcard, "[::]:1350", accepts both IPv4 and IPv6 clients where the system allows it.
 	Host string
+	// SocketActivation toggles whether the net server adopts listeners passed to it by systemd, one per list in
+	// order, instead of listening on the configured hosts; see netsrv.Server.SocketActivation.
+	SocketActivation bool
 	// Log toggles whether the net server logs to stderr.
 	Log bool
+	// MaxWordLen, if positive, is the maximum length in bytes of any word a client may send.
+	// It defaults to unlimited.
+	MaxWordLen int
+	// MaxClients, if positive, is the most clients the net server serves at once, over all lists.
+	// It defaults to unlimited.
+	MaxClients int
+	// KeepAliveSecs is the TCP keepalive period for client connections, in seconds.
+	// It defaults to 15 seconds; a negative value disables keepalive.
+	KeepAliveSecs int
+	// HandshakeTimeoutSecs, if positive, is how long, in seconds, a client has to send its first line after connecting.
+	// It defaults to no timeout.
+	HandshakeTimeoutSecs int
+	// IdleTimeoutSecs, if positive, is how long, in seconds, a client may go without sending a line after its first.
+	// It defaults to no timeout.
+	IdleTimeoutSecs int
+	// LingerMillis, if positive, is how long, in milliseconds, the net server lets each closing connection linger,
+	// half-closed, so that the client gets the last messages sent to it.
+	// It defaults to closing straight away.
+	LingerMillis int
+	// WriteTimeoutMillis, if positive, is how long, in milliseconds, each write to a client may take before the net
+	// server retries the rest of it.
+	// It defaults to no timeout.
+	WriteTimeoutMillis int
+	// WriteRetries is how many times the net server retries a timed-out write before disconnecting the client.
+	WriteRetries int
+	// WriteRetryDelayMillis is how long, in milliseconds, the net server waits before each write retry.
+	WriteRetryDelayMillis int
+	// SendBuffer, if positive, is the number of outbound messages the net server queues for each client.
+	// It defaults to 1024.
+	SendBuffer int
+	// RequestRate, if positive, is the number of requests per second each client may send, on average.
+	// It defaults to unlimited.
+	RequestRate float64
+	// RequestBurst, if positive, is the number of requests a client may send in one burst, under RequestRate.
+	// It defaults to 1024.
+	RequestBurst int
+	// ErrorHistory, if positive, is how many of its latest errors each client can ask the net server for, with an
+	// 'errors' request.
+	// It defaults to none.
+	ErrorHistory int
+	// MaxInflatedInput, if positive, is the most bytes any compressed block of lines a client sends can decompress to.
+	// It defaults to 1MiB.
+	MaxInflatedInput int
+	// BatchInput toggles whether the net server passes lines that arrive together to the list as one batch.
+	BatchInput bool
+	// StrictInput toggles whether the net server rejects lines with control characters in their words.
+	StrictInput bool
+	// AllowTabs toggles whether, under StrictInput, words may contain tabs.
+	AllowTabs bool
+	// TLSCertFile and TLSKeyFile, if both set, are the PEM files holding the certificate and private key with which
+	// the net server encrypts every connection with TLS.
+	// They default to plain TCP.
+	TLSCertFile string
+	TLSKeyFile  string
+	// TLSClientCAFile, if set alongside TLSCertFile and TLSKeyFile, is the PEM file of certificate authorities whose
+	// client certificates the net server trusts; clients must then present one (mutual TLS).
+	TLSClientCAFile string
 }
 
 // List is the configuration struct for a baps3d list node.
+// Each list is a separate channel, with its own clients and broadcasts.
 type List struct {
+	// Name is the name of the list, which must be unique.
+	Name string
+	// Host is the TCP host:port string on which the net server serves this list.
+	// If there is only one list, this can be empty, in which case the list uses the net server's Host.
+	Host string
+	// File, if non-empty, is the path of a saved list to load into this list at startup, and to go back to on
+	// 'revert' requests.
+	File string
 	// Player is the TCP host:port string for the mounted playd instance.
 	Player string
+	// SendPolicy is what the net server does when a client of this list falls behind on its messages: 'disconnect'
+	// (the default) or 'drop-oldest'.
+	SendPolicy string
+	// OutputRate, if positive, is the most bytes per second, on average, the net server writes to each of this list's
+	// clients, for presenters on metered links.
+	// It defaults to unlimited.
+	OutputRate int
+	// ByteBudget, if positive, is the most bytes each of this list's clients may send and receive, together, over
+	// the life of its connection, after which the net server hangs it up.
+	// It defaults to unlimited.
+	ByteBudget int64
+	// Sequence toggles whether the net server appends a per-connection sequence number to each message it sends to
+	// this list's clients, so that they can detect missed messages.
+	Sequence bool
+	// MarkOwn toggles whether the net server tags broadcasts caused by a client's own requests '!!', rather than '!',
+	// when sending them to that client.
+	MarkOwn bool
+	// CompressDumps toggles whether the net server sends each dump to this list's clients as one gzipped ZDUMP
+	// message, for clients on slow links.
+	CompressDumps bool
+	// CompressedInput toggles whether the net server accepts gzipped blocks of lines, in 'zlines' requests, from this
+	// list's clients.
+	CompressedInput bool
+	// SelectionOnly holds the IP addresses of clients of this list, such as now-playing displays, to which the net
+	// server sends only compact NOW notifications of the selection and its state, in place of the usual messages.
+	SelectionOnly []string
+	// PlayStateOnly holds the IP addresses of clients of this list, such as on-air lights, to which the net server
+	// sends only PLAYSTATE notifications of whether the selection is cued, playing, or finished.
+	PlayStateOnly []string
+	// CommandOnly holds the IP addresses of clients of this list, such as scheduled task runners, to which the net
+	// server sends only replies to their own requests, and no broadcasts.
+	CommandOnly []string
+	// Encoding is how the net server writes messages to this list's clients: 'line' (the default), as packed
+	// Bifrost lines, or 'json', as one JSON object per line.
+	Encoding string
+	// DumpAirTimes toggles whether dumps of this list include each upcoming item's projected time-to-air.
+	DumpAirTimes bool
+	// StaleHashes, if positive, makes this list lenient towards clients giving an item's hash from just before a
+	// recent update, by remembering up to this many superseded hashes.
+	// Such requests go ahead, with a STALE warning, rather than failing; if zero, they fail.
+	StaleHashes int
+	// PlayHistory, if positive, is how many of the items this list has most recently advanced past it remembers, for
+	// 'history' requests; if zero, it uses the default of 50, and if negative, it remembers none.
+	PlayHistory int
+	// AdvanceOnPlayed toggles whether a 'played' request for this list's selected item also advances the list, as
+	// 'advance' would, for playout engines that mark items played as they end.
+	AdvanceOnPlayed bool
+	// Normalize toggles whether this list tidies the payloads of items going into it, including those loaded from
+	// File, by stripping control characters and needless whitespace; see list.Normalize.
+	Normalize bool
+	// DumpLimit, if positive, is the most dumps this list's controller serves in a row while other requests wait, so
+	// that a flood of reconnecting clients doesn't starve them; excess dumps queue, with a QUEUED notice.
+	// It defaults to 4.
+	DumpLimit int
+	// DeadmanSecs, if positive, is how long, in seconds, this list can go without changing before its controller
+	// takes DeadmanAction and broadcasts a DEADMAN alert, to head off dead air when a show gets stuck unattended.
+	// It defaults to never.
+	DeadmanSecs int
+	// DeadmanAction, if non-empty, is the request, as a Bifrost command word and its arguments (such as
+	// ["sel", "0", "fallback"]), that this list's controller handles when its deadman switch fires.
+	// If empty, the switch only alerts.
+	DeadmanAction []string
 }
 
 // Console is the configuration struct for the baps3d console.
@@ -4,7 +4,10 @@ package controller
 
 import (
 	"context"
+	"errors"
 	"fmt"
+	"strconv"
+	"strings"
 
 	"github.com/UniversityRadioYork/bifrost-go/core"
 
@@ -12,13 +15,53 @@ import (
 	"github.com/UniversityRadioYork/bifrost-go/message"
 )
 
-// sversion is the Baps3D semantic server version.
-const sversion = "baps3d-0.0.0"
+// Version is the Baps3D semantic server version, as given in OHAI.
+// Release builds set it to the release's version at link time, with
+// '-ldflags "-X github.com/UniversityRadioYork/baps3d/controller.Version=baps3d-X.Y.Z"'.
+var Version = "baps3d-0.0.0"
 
-// UnknownWord returns an error for when a Bifrost parser doesn't understand the
+// RsStatus is the response word of compact status summaries, sent in reply to a state's status request.
+// Servers relaying a STATUS reply to a client append their own fields, such as the number of connected clients, to
+// its end.
+const RsStatus = "STATUS"
+
+// TagOwnBcast is the tag that Bifrost adapters with MarkOwn set give broadcasts caused by their own client's requests,
+// in place of message.TagBcast.
+const TagOwnBcast = "!!"
+
+// IsBroadcastTag gets whether tag is one of the tags Bifrost adapters give broadcasts.
+func IsBroadcastTag(tag string) bool {
+	return tag == message.TagBcast || tag == TagOwnBcast
+}
+
+// ErrUnknownWord is the error Bifrost parsers give, through UnknownWord, for requests with a command word they don't
+// understand.
+// Such requests only fail themselves, with a WHAT reply, leaving the client connected, so clients can probe for
+// commands that newer servers understand.
+var ErrUnknownWord = errors.New("unknown word")
+
+// UnknownWord returns an error, wrapping ErrUnknownWord, for when a Bifrost parser doesn't understand the
 // word w.
 func UnknownWord(w string) error {
-	return fmt.Errorf("unknown word: %s", w)
+	return fmt.Errorf("%w: %s", ErrUnknownWord, w)
+}
+
+// RestOfLineParser is the interface of Bifrost parsers with requests whose last argument takes up the rest of the
+// line, verbatim.
+// Parsers that don't implement it have every request tokenised as usual.
+type RestOfLineParser interface {
+	// RestOfLine returns, for requests with the command word word, how many arguments come before the rest-of-line
+	// argument.
+	// It returns ok = false if word's requests don't have a rest-of-line argument.
+	RestOfLine(word string) (fixed int, ok bool)
+}
+
+// LatestWinsParser is the interface of Bifrost parsers with broadcasts that are superseded by the next broadcast with
+// the same word, such as announcements of the current state of some setting.
+// Clients that fall behind may have superseded broadcasts dropped; parsers that don't implement it never do.
+type LatestWinsParser interface {
+	// IsLatestWins returns whether broadcasts with the response word word are superseded by later ones.
+	IsLatestWins(word string) bool
 }
 
 // Bifrost is the type of adapters from Controller clients to Bifrost.
@@ -36,6 +79,52 @@ type Bifrost struct {
 
 	// reply is the channel this adapter uses to service replies to requests it sends to the client.
 	reply chan Response
+
+	// batch is the channel on which SendBatch passes batches of messages to Run.
+	batch chan []message.Message
+
+	// dumpReply is the channel this adapter uses to service replies to dump requests, if it compresses dumps.
+	dumpReply chan Response
+
+	// dumping holds the messages of the dump in progress, if the adapter compresses dumps; see flushDump.
+	dumping []message.Message
+
+	// MarkOwn, if true, makes the adapter tag broadcasts caused by its client's own requests with TagOwnBcast, so
+	// the Bifrost client can tell its own changes from everyone else's.
+	// It must be set before Run.
+	MarkOwn bool
+
+	// CompressDumps, if true, makes the adapter send each dump, including the one in the handshake, as a single
+	// compressed ZDUMP message rather than a message per response; see RsZdump.
+	// This is for clients on slow links, as it trades CPU time for bandwidth.
+	// It must be set before Run.
+	CompressDumps bool
+
+	// SelectionOnly, if true, makes the adapter send, in place of whatever its parser would usually emit for each
+	// response, only the compact selection notifications it emits as a SelectionParser; see SelectionParser.
+	// This covers dumps and replies as well as broadcasts; messages the adapter makes itself, such as ACKs, are
+	// unaffected.
+	// This is for dumb displays that only show what is on air, and saves them receiving and parsing everything else.
+	// It must be set before Run.
+	SelectionOnly bool
+
+	// PlayStateOnly, if true, makes the adapter send, in place of whatever its parser would usually emit for each
+	// response, only the notifications of the selection's play state it emits as a PlayStateParser; see
+	// PlayStateParser.
+	// Like SelectionOnly, which overrides it, this covers dumps and replies as well as broadcasts, so the handshake
+	// still gives the play state as of connection.
+	// This is for on-air lights and the like, which only care whether the selection is playing.
+	// It must be set before Run.
+	PlayStateOnly bool
+
+	// playState, if the adapter is PlayStateOnly, is its parser's play state emitter, made on first use.
+	playState PlayStateEmitter
+
+	// CommandOnly, if true, makes the adapter drop every broadcast, and skip the dump in the handshake, so that its
+	// client only gets replies to its own requests; see commandOnlyDrops.
+	// This is for clients, such as scheduled task runners, that send commands and never show any state.
+	// It must be set before Run.
+	CommandOnly bool
 }
 
 // NewBifrost wraps client inside a Bifrost adapter with parsing and emitting
@@ -51,11 +140,32 @@ func NewBifrost(client *Client, parser comm.Parser) (*Bifrost, *comm.Endpoint) {
 		bifrost: privEnd,
 		reply:   reply,
 		parser:  parser,
+		batch:   make(chan []message.Message),
+		// Dumps have their own reply channel, so their replies can't be mistaken for those of other requests.
+		dumpReply: make(chan Response),
 	}
 
 	return &bif, pubEnd
 }
 
+// RestOfLine tells tokenisers feeding b which requests have a rest-of-line argument; see RestOfLineParser.
+// It always returns ok = false if b's parser isn't a RestOfLineParser.
+func (b *Bifrost) RestOfLine(word string) (fixed int, ok bool) {
+	if rp, isRP := b.parser.(RestOfLineParser); isRP {
+		return rp.RestOfLine(word)
+	}
+	return 0, false
+}
+
+// IsLatestWins tells senders feeding b's client which broadcasts are superseded by later ones; see LatestWinsParser.
+// It always returns false if b's parser isn't a LatestWinsParser.
+func (b *Bifrost) IsLatestWins(word string) bool {
+	if lp, isLP := b.parser.(LatestWinsParser); isLP {
+		return lp.IsLatestWins(word)
+	}
+	return false
+}
+
 func (b *Bifrost) respond(m message.Message) {
 	b.bifrost.Tx <- m
 }
@@ -64,10 +174,41 @@ func (b *Bifrost) close() {
 	close(b.bifrost.Tx)
 }
 
+// detach tells the Controller that b's Bifrost client has disconnected, by closing b's Client.
+// Until the Controller catches up, detach discards anything it sends b, such as the rest of a dump, so that the
+// Controller never blocks on a client with nobody left to listen to it.
+func (b *Bifrost) detach() {
+	close(b.client.Tx)
+	for {
+		select {
+		case _, ok := <-b.client.Rx:
+			if !ok {
+				return
+			}
+		case <-b.reply:
+		case <-b.dumpReply:
+		}
+	}
+}
+
 // Run runs the main body of the Bifrost adapter.
 // It will immediately send the new client responses to the response channel.
+//
+// Run forwards responses to the Bifrost client in the order the Controller sends them; so, on the wire, the
+// broadcasts a request causes come before that request's ACK.
+//
+// If the Bifrost client disconnects, or Run panics, Run detaches b's Client from the Controller before returning, so
+// the Client can't be used afterwards.
 func (b *Bifrost) Run(ctx context.Context) {
 	defer b.close()
+	defer func() {
+		// If b panics (say, in its parser), the Controller would go on talking to b's Client, so detach it on the
+		// way out; the panic itself is for the caller to deal with.
+		if r := recover(); r != nil {
+			b.detach()
+			panic(r)
+		}
+	}()
 
 	if !b.handleNewClientResponses(ctx) {
 		return
@@ -81,11 +222,21 @@ func (b *Bifrost) Run(ctx context.Context) {
 
 		select {
 		case rq, ok := <-b.bifrost.Rx:
-			if !ok || !b.handleRequest(ctx, rq) {
+			if !ok {
+				b.detach()
+				return
+			}
+			if !b.handleRequest(ctx, rq) {
+				return
+			}
+		case msgs := <-b.batch:
+			if !b.handleBatch(ctx, msgs) {
 				return
 			}
 		case rs := <-b.reply:
 			b.handleResponseForwardingError(rs)
+		case rs := <-b.dumpReply:
+			b.handleDumpResponseForwardingError(rs)
 		case rs, ok := <-b.client.Rx:
 			// No need to check b.client.Done:
 			// if the controller shuts down, it pull both this
@@ -105,6 +256,8 @@ func (b *Bifrost) Run(ctx context.Context) {
 // handleRequest handles the request message rq.
 // It returns whether or not the client is still able to handle
 // requests.
+//
+// If b's parser is a Prioritiser that allows the request, it goes to the Controller as a priority request.
 func (b *Bifrost) handleRequest(ctx context.Context, rq message.Message) bool {
 	request, err := b.fromMessage(rq)
 	if err != nil {
@@ -112,7 +265,84 @@ func (b *Bifrost) handleRequest(ctx context.Context, rq message.Message) bool {
 		return true
 	}
 
-	return b.client.Send(ctx, *request)
+	if b.isPriority(request.Body) {
+		return b.send(ctx, b.client.priorityTx, *request)
+	}
+	return b.send(ctx, b.client.Tx, *request)
+}
+
+// isPriority checks whether b's parser is a Prioritiser that allows requests with body rbody.
+func (b *Bifrost) isPriority(rbody interface{}) bool {
+	p, ok := b.parser.(Prioritiser)
+	return ok && p.IsPriority(rbody)
+}
+
+// SendBatch passes msgs, a batch of request messages, to b's Run loop.
+// Where Run would send each message to the Controller separately, it sends the batch in one go; the Controller
+// still treats each message as a separate request, in order, with its own replies.
+// Priority requests can't be batched, so they split the batch in two, going to the Controller on their own.
+// SendBatch returns false if ctx finishes before Run takes the batch.
+func (b *Bifrost) SendBatch(ctx context.Context, msgs []message.Message) bool {
+	select {
+	case b.batch <- msgs:
+		return true
+	case <-ctx.Done():
+		return false
+	}
+}
+
+// handleBatch handles the batch of request messages msgs; see SendBatch.
+// It returns whether or not the client is still able to handle requests.
+func (b *Bifrost) handleBatch(ctx context.Context, msgs []message.Message) bool {
+	batch := make(batchRequest, 0, len(msgs))
+	for _, m := range msgs {
+		request, err := b.fromMessage(m)
+		if err != nil {
+			request = makeRequest(badRequest{err: err}, m.Tag(), b.reply)
+		}
+
+		if b.isPriority(request.Body) {
+			if !b.sendBatch(ctx, batch) || !b.send(ctx, b.client.priorityTx, *request) {
+				return false
+			}
+			batch = make(batchRequest, 0, len(msgs))
+			continue
+		}
+		batch = append(batch, *request)
+	}
+	return b.sendBatch(ctx, batch)
+}
+
+// sendBatch sends batch, if it isn't empty, to the Controller.
+func (b *Bifrost) sendBatch(ctx context.Context, batch batchRequest) bool {
+	if len(batch) == 0 {
+		return true
+	}
+	return b.send(ctx, b.client.Tx, Request{Body: batch})
+}
+
+// send sends rq to the Controller on tx, handling anything the Controller sends b in the meantime.
+// The Controller may well be trying to reply to an earlier request, or broadcast, before it takes rq, so simply
+// blocking on tx could deadlock.
+// It returns false if ctx finishes, or the Controller shuts down, first.
+func (b *Bifrost) send(ctx context.Context, tx chan<- Request, rq Request) bool {
+	for {
+		select {
+		case tx <- rq:
+			return true
+		case rs := <-b.reply:
+			b.handleResponseForwardingError(rs)
+		case rs := <-b.dumpReply:
+			b.handleDumpResponseForwardingError(rs)
+		case rs, ok := <-b.client.Rx:
+			if !ok {
+				return false
+			}
+			b.handleResponseForwardingError(rs)
+		case <-ctx.Done():
+			return false
+		}
+	}
 }
 
 // fromMessage tries to parse a message as a controller request.
@@ -122,6 +352,9 @@ func (b *Bifrost) fromMessage(m message.Message) (*Request, error) {
 		return nil, err
 	}
 
+	if _, isDump := rbody.(DumpRequest); isDump && b.CompressDumps {
+		return makeRequest(rbody, m.Tag(), b.dumpReply), nil
+	}
 	return makeRequest(rbody, m.Tag(), b.reply), nil
 }
 
@@ -131,6 +364,14 @@ func (b *Bifrost) bodyFromMessage(m message.Message) (interface{}, error) {
 	switch m.Word() {
 	case "dump":
 		return parseDumpMessage(m.Args())
+	case "sched":
+		return b.parseSchedMessage(m.Args())
+	case "scheds":
+		return parseSchedsMessage(m.Args())
+	case "unsched":
+		return parseUnschedMessage(m.Args())
+	case "ping":
+		return parsePingMessage(m.Args())
 	default:
 		return b.parser.ParseBifrostRequest(m.Word(), m.Args())
 	}
@@ -163,12 +404,82 @@ func parseDumpMessage(args []string) (interface{}, error) {
 	return DumpRequest{}, nil
 }
 
+// parseSchedMessage tries to parse a 'sched' message.
+// Its arguments are an RFC 3339 time, 'fire' or 'fail' (what to do if the time has passed), and then the word and
+// arguments of the request to schedule, which b's parser parses.
+func (b *Bifrost) parseSchedMessage(args []string) (interface{}, error) {
+	if len(args) < 3 {
+		return nil, fmt.Errorf("bad arity")
+	}
+
+	at, err := ParseTime(args[0])
+	if err != nil {
+		return nil, err
+	}
+
+	var fireIfPast bool
+	switch args[1] {
+	case "fire":
+		fireIfPast = true
+	case "fail":
+		fireIfPast = false
+	default:
+		return nil, fmt.Errorf("unknown past-time policy: %s", args[1])
+	}
+
+	word, rargs := args[2], args[3:]
+	body, err := b.parser.ParseBifrostRequest(word, rargs)
+	if err != nil {
+		return nil, err
+	}
+
+	label := strings.Join(args[2:], " ")
+	return ScheduleRequest{At: at, Body: body, Label: label, FireIfPast: fireIfPast}, nil
+}
+
+// parseSchedsMessage tries to parse a 'scheds' message.
+func parseSchedsMessage(args []string) (interface{}, error) {
+	if len(args) != 0 {
+		return nil, fmt.Errorf("bad arity")
+	}
+
+	return ListSchedulesRequest{}, nil
+}
+
+// parseUnschedMessage tries to parse an 'unsched' message.
+func parseUnschedMessage(args []string) (interface{}, error) {
+	if len(args) != 1 {
+		return nil, fmt.Errorf("bad arity")
+	}
+
+	id, err := strconv.ParseUint(args[0], 10, 64)
+	if err != nil {
+		return nil, err
+	}
+
+	return CancelScheduleRequest{ID: id}, nil
+}
+
+// parsePingMessage tries to parse a 'ping' message, with an optional token.
+func parsePingMessage(args []string) (interface{}, error) {
+	switch len(args) {
+	case 0:
+		return PingRequest{}, nil
+	case 1:
+		return PingRequest{Token: args[0]}, nil
+	default:
+		return nil, fmt.Errorf("bad arity")
+	}
+}
+
 //
 // Response emitting
 //
 
 // handleNewClientResponses handles the new client responses (OHAI, IAMA, etc).
 // It returns true if the client context hasn't hung up midway through.
+//
+// The handshake includes a full dump, which supersedes the client's Greeting, so the adapter doesn't forward it.
 func (b *Bifrost) handleNewClientResponses(ctx context.Context) bool {
 	// SPEC: see http://universityradioyork.github.io/baps3-spec/protocol/core/commands.html
 
@@ -176,23 +487,36 @@ func (b *Bifrost) handleNewClientResponses(ctx context.Context) bool {
 	b.sendOhai()
 
 	// We don't use b.reply here, because we want to suppress ACK.
+	// Other clients' requests may well cause broadcasts in the meantime, which we forward as usual.
 	ncreply := make(chan Response)
-	if !b.client.Send(ctx, *makeRequest(RoleRequest{}, message.TagBcast, ncreply)) {
+	if !b.send(ctx, b.client.Tx, *makeRequest(RoleRequest{}, message.TagBcast, ncreply)) {
 		return false
 	}
-	if ProcessRepliesUntilAck(ncreply, b.handleResponse) != nil {
+	if b.processRepliesUntilAck(ncreply) != nil {
 		return false
 	}
-	if !b.client.Send(ctx, *makeRequest(DumpRequest{}, message.TagBcast, ncreply)) {
+	if b.CommandOnly {
+		return true
+	}
+	if !b.send(ctx, b.client.Tx, *makeRequest(DumpRequest{}, message.TagBcast, ncreply)) {
 		return false
 	}
-	return ProcessRepliesUntilAck(ncreply, b.handleResponse) == nil
+	if !b.CompressDumps {
+		return b.processRepliesUntilAck(ncreply) == nil
+	}
+	err := processRepliesUntilAck(nil, ncreply, b.client.Rx, b.handleResponseForwardingError, b.handleDumpResponse)
+	return err == nil && b.flushDump(message.TagBcast) == nil
+}
+
+// processRepliesUntilAck handles replies on reply until the ack, forwarding any broadcasts that arrive meanwhile.
+func (b *Bifrost) processRepliesUntilAck(reply <-chan Response) error {
+	return processRepliesUntilAck(nil, reply, b.client.Rx, b.handleResponseForwardingError, b.handleResponse)
 }
 
 func (b *Bifrost) sendOhai() {
 	ohai := core.OhaiResponse{
 		ProtocolVer: core.ThisProtocolVer,
-		ServerVer:   sversion,
+		ServerVer:   Version,
 	}
 	b.respond(*ohai.Message(message.TagBcast))
 }
@@ -201,24 +525,61 @@ func (b *Bifrost) sendOhai() {
 // the error as a // message.
 func (b *Bifrost) handleResponseForwardingError(rs Response) {
 	if err := b.handleResponse(rs); err != nil {
-		b.respond(*errorToMessage(bifrostTagOf(rs), err))
+		b.respond(*errorToMessage(b.tagOf(rs), err))
+	}
+}
+
+// handleDumpResponseForwardingError handles a controller response rs to a dump request, forwarding the error as a
+// message; see handleDumpResponse.
+func (b *Bifrost) handleDumpResponseForwardingError(rs Response) {
+	if err := b.handleDumpResponse(rs); err != nil {
+		b.respond(*errorToMessage(b.tagOf(rs), err))
 	}
 }
 
 // handleResponse handles a controller response rs.
 func (b *Bifrost) handleResponse(rs Response) error {
-	tag := bifrostTagOf(rs)
+	if b.commandOnlyDrops(rs) {
+		return nil
+	}
+	tag := b.tagOf(rs)
 
 	switch r := rs.Body.(type) {
 	case DoneResponse:
 		return b.handleAck(tag, r)
 	case core.IamaResponse:
 		return b.handleRole(tag, r)
+	case ScheduleResponse:
+		return b.handleSchedule(tag, r)
+	case ScheduleFiredResponse:
+		return b.handleScheduleFired(tag, r)
+	case ScheduleCancelledResponse:
+		return b.handleScheduleCancelled(tag, r)
+	case PongResponse:
+		return b.handlePong(tag, r)
+	case ReloadedResponse:
+		return b.handleReloaded(tag, r)
+	case PausedResponse:
+		return b.handlePaused(tag, r)
+	case ResumedResponse:
+		return b.handleResumed(tag, r)
+	case DumpQueuedResponse:
+		return b.handleDumpQueued(tag, r)
+	case DeadmanResponse:
+		return b.handleDeadman(tag, r)
 	default:
-		return b.parser.EmitBifrostResponse(tag, r, b.bifrost.Tx)
+		return b.emit(tag, r, b.bifrost.Tx)
 	}
 }
 
+// tagOf works out the Bifrost message tag b gives response rs, taking MarkOwn into account.
+func (b *Bifrost) tagOf(rs Response) string {
+	if b.MarkOwn && rs.Broadcast && rs.Own {
+		return TagOwnBcast
+	}
+	return bifrostTagOf(rs)
+}
+
 // bifrostTagOf works out the Bifrost message tag of response rs.
 // This is either the broadcast tag, if rs is a broadcast, or the given tag.
 func bifrostTagOf(rs Response) string {
@@ -239,7 +600,7 @@ func (b *Bifrost) handleAck(t string, r DoneResponse) error {
 		return r.Err
 	}
 
-	b.respond(*message.New(t, core.RsAck).AddArgs("OK", "success"))
+	b.respond(NewMessage(t, core.RsAck, "OK", "success"))
 	return nil
 }
 
@@ -249,8 +610,91 @@ func (b *Bifrost) handleRole(t string, r core.IamaResponse) error {
 	return nil
 }
 
+// handleSchedule handles converting a ScheduleResponse r into messages for tag t.
+func (b *Bifrost) handleSchedule(t string, r ScheduleResponse) error {
+	b.respond(NewMessage(t, "SCHED", strconv.FormatUint(r.ID, 10), FormatTime(r.At), r.Label))
+	return nil
+}
+
+// handleScheduleFired handles converting a ScheduleFiredResponse r into messages for tag t.
+// The result goes on the end: 'OK', or 'FAIL' and the error.
+func (b *Bifrost) handleScheduleFired(t string, r ScheduleFiredResponse) error {
+	msg := message.New(t, "SCHEDFIRED").AddArgs(strconv.FormatUint(r.ID, 10), FormatTime(r.At), r.Label)
+	if r.Err == nil {
+		msg.AddArgs("OK")
+	} else {
+		msg.AddArgs("FAIL", r.Err.Error())
+	}
+	b.respond(*msg)
+	return nil
+}
+
+// handleScheduleCancelled handles converting a ScheduleCancelledResponse r into messages for tag t.
+func (b *Bifrost) handleScheduleCancelled(t string, r ScheduleCancelledResponse) error {
+	b.respond(NewMessage(t, "UNSCHED", strconv.FormatUint(r.ID, 10)))
+	return nil
+}
+
+// handleReloaded handles converting a ReloadedResponse into messages for tag t.
+func (b *Bifrost) handleReloaded(t string, _ ReloadedResponse) error {
+	b.respond(NewMessage(t, "RELOADED"))
+	return nil
+}
+
+// handlePaused handles converting a PausedResponse into messages for tag t.
+func (b *Bifrost) handlePaused(t string, _ PausedResponse) error {
+	b.respond(NewMessage(t, "PAUSED"))
+	return nil
+}
+
+// handleResumed handles converting a ResumedResponse r into messages for tag t.
+func (b *Bifrost) handleResumed(t string, r ResumedResponse) error {
+	b.respond(NewMessage(t, "RESUMED", strconv.Itoa(r.Held)))
+	return nil
+}
+
+// handleDumpQueued handles converting a DumpQueuedResponse r into messages for tag t.
+func (b *Bifrost) handleDumpQueued(t string, r DumpQueuedResponse) error {
+	b.respond(NewMessage(t, "QUEUED", strconv.Itoa(r.Ahead)))
+	return nil
+}
+
+// handleDeadman handles converting a DeadmanResponse r into messages for tag t.
+// The idle time is in milliseconds; then comes 'ALERT', if there was no fallback action, or its result as in
+// SCHEDFIRED.
+func (b *Bifrost) handleDeadman(t string, r DeadmanResponse) error {
+	msg := message.New(t, "DEADMAN").AddArgs(strconv.FormatInt(r.Idle.Milliseconds(), 10))
+	switch {
+	case !r.Acted:
+		msg.AddArgs("ALERT")
+	case r.Err == nil:
+		msg.AddArgs("OK")
+	default:
+		msg.AddArgs("FAIL", r.Err.Error())
+	}
+	b.respond(*msg)
+	return nil
+}
+
+// handlePong handles converting a PongResponse r into messages for tag t.
+// The token goes last, and only if there is one, so that an empty token doesn't leave an empty argument.
+func (b *Bifrost) handlePong(t string, r PongResponse) error {
+	msg := message.New(t, "PONG").AddArgs(FormatTime(r.Time))
+	if r.Token != "" {
+		msg.AddArgs(r.Token)
+	}
+	b.respond(*msg)
+	return nil
+}
+
 // errorToMessage converts the error e to a Bifrost message sent to tag t.
+// Requests that failed because the state changed under them (see ErrStateChanged) get FAIL, so clients can tell them
+// apart and retry.
 func errorToMessage(t string, e error) *message.Message {
-	// TODO(@MattWindsor91): figure out whether e is a WHAT or a FAIL.
-	return message.New(t, core.RsAck).AddArgs("WHAT", e.Error())
+	// TODO(@MattWindsor91): figure out whether other errors are a WHAT or a FAIL.
+	status := "WHAT"
+	if errors.Is(e, ErrStateChanged) {
+		status = "FAIL"
+	}
+	return message.New(t, core.RsAck).AddArgs(status, e.Error())
 }
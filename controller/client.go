@@ -25,6 +25,14 @@ type Client struct {
 
 	// Rx is the channel on which the Controller sends status update messages.
 	Rx <-chan Response
+
+	// Greeting holds the initial state the Controller gave this Client when it was attached, if the Controller's
+	// state is a Greeter.
+	// It is delivered here, rather than on Rx, so that greeting a Client never changes Rx's backpressure.
+	Greeting []Response
+
+	// priorityTx is the Controller's priority request channel; see SendPriority.
+	priorityTx chan<- Request
 }
 
 // Send tries to send a request on a Client.
@@ -41,6 +49,22 @@ func (c *Client) Send(ctx context.Context, r Request) bool {
 	return true
 }
 
+// SendPriority tries to send a request on a Client's priority channel.
+// It returns false if the given context has shut down.
+//
+// Priority requests jump ahead of any normal requests waiting for the Controller, but the Controller only serves
+// a small allowlist of them: health checks, shutdowns, and anything its state's Prioritiser allows.
+// It replies to any other priority request with ErrNotPriority, without handling it.
+// See Controller.Run for the exact ordering guarantees.
+func (c *Client) SendPriority(ctx context.Context, r Request) bool {
+	select {
+	case c.priorityTx <- r:
+	case <-ctx.Done():
+		return false
+	}
+	return true
+}
+
 // Copy copies a Client, creating a new handle to the Client's Controller.
 // The new Client will be separate from this Client: it is ok to dispose of the
 // original.
@@ -48,6 +72,10 @@ func (c *Client) Send(ctx context.Context, r Request) bool {
 // Under the hood, this causes a request to be sent to the Controller goroutine,
 // so the Copy will only succeed when the Controller is able to process it.
 //
+// If the Controller's state is a Greeter, the new Client's Greeting holds a
+// snapshot of the greeting taken when the Client was attached; any later
+// changes arrive on Rx.
+//
 // If Copy returns an error, then the Controller shut down during the copy.
 func (c *Client) Copy(ctx context.Context) (*Client, error) {
 	var ncli *Client
@@ -95,8 +123,70 @@ func (c *Client) Shutdown(ctx context.Context) error {
 	return err
 }
 
+// HangUp detaches c, usually a Copy, from its Controller, leaving the Controller and its other Clients running.
+// It closes Tx, then discards anything the Controller sends c until the Controller has taken c out of the set of
+// Clients it broadcasts to, so that the Controller never blocks on c.
+// If ctx finishes first, HangUp returns ctx's error, and carries on discarding in the background.
+// c must not be used after HangUp.
+//
+// Hanging up a Controller's last Client stops the Controller, as nothing could send it requests.
+func (c *Client) HangUp(ctx context.Context) error {
+	close(c.Tx)
+	for {
+		select {
+		case _, ok := <-c.Rx:
+			if !ok {
+				return nil
+			}
+		case <-ctx.Done():
+			go func() {
+				for range c.Rx {
+				}
+			}()
+			return ctx.Err()
+		}
+	}
+}
+
+// CheckAlive checks that Client c's Controller is still running and processing requests.
+// It returns ErrControllerShutDown if the Controller didn't pick up the check before ctx finished.
+//
+// A Controller that has shut down never picks up requests, so ctx should have a deadline.
+func (c *Client) CheckAlive(ctx context.Context) error {
+	return c.sendWithoutReplies(ctx, healthRequest{})
+}
+
+// Pause asks Client c's Controller to pause; see PauseRequest.
+// Once Pause returns without error, the caller may change the Controller's state directly, until it calls Resume.
+func (c *Client) Pause(ctx context.Context) error {
+	return c.sendWithoutReplies(ctx, PauseRequest{})
+}
+
+// Resume asks Client c's Controller to resume after a Pause; see ResumeRequest.
+func (c *Client) Resume(ctx context.Context) error {
+	return c.sendWithoutReplies(ctx, ResumeRequest{})
+}
+
+// sendWithoutReplies sends a request with body body, which shouldn't get any replies other than its acknowledgement.
+// It returns ErrControllerShutDown if the Controller didn't pick up the request before ctx finished.
+func (c *Client) sendWithoutReplies(ctx context.Context, body interface{}) error {
+	cb := func(Response) error {
+		return fmt.Errorf("got an unexpected response")
+	}
+
+	alive, err := c.SendAndProcessReplies(ctx, "", body, cb)
+	if !alive {
+		return ErrControllerShutDown
+	}
+	return err
+}
+
 // Bifrost tries to get a Bifrost adapter for Client c's Controller.
 // This fails if the Controller's state can't understand Bifrost messages.
+//
+// Nothing takes c's broadcasts until the adapter runs, so Bifrost discards any that arrive while it waits, rather than
+// block the Controller on c; the adapter dumps the Controller's state when it starts, so the discarded broadcasts
+// are never missed.
 func (c *Client) Bifrost(ctx context.Context) (*Bifrost, *comm.Endpoint, error) {
 	var (
 		bf  *Bifrost
@@ -119,7 +209,7 @@ func (c *Client) Bifrost(ctx context.Context) (*Bifrost, *comm.Endpoint, error)
 		return nil
 	}
 
-	alive, err := c.SendAndProcessReplies(ctx, "", bifrostParserRequest{}, cb)
+	alive, err := c.sendAndProcessRepliesDiscarding(ctx, "", bifrostParserRequest{}, cb)
 	if !alive {
 		return nil, nil, ErrControllerShutDown
 	}
@@ -143,31 +233,67 @@ func (c *Client) Bifrost(ctx context.Context) (*Bifrost, *comm.Endpoint, error)
 // 2) the first error returned by cb;
 // 3) any error coming from the DoneResponse.
 func ProcessRepliesUntilAck(reply <-chan Response, cb func(Response) error) error {
+	return processRepliesUntilAck(nil, reply, nil, nil, cb)
+}
+
+// ProcessRepliesUntilAckOrDone is ProcessRepliesUntilAck, but gives up, returning ctx's error, if ctx finishes first.
+// Requests whose replies it processes should carry ctx.Done() as their origin's Done channel, so that the Controller
+// drops the replies left over once it gives up; see RequestOrigin.
+func ProcessRepliesUntilAckOrDone(ctx context.Context, reply <-chan Response, cb func(Response) error) error {
+	err := processRepliesUntilAck(ctx.Done(), reply, nil, nil, cb)
+	if errors.Is(err, errGaveUp) {
+		return ctx.Err()
+	}
+	return err
+}
+
+// errGaveUp is the error processRepliesUntilAck gives when its done channel closes before the ack.
+var errGaveUp = errors.New("gave up waiting for ack")
+
+// processRepliesUntilAck is ProcessRepliesUntilAck, but also feeds anything that arrives on rx, if non-nil, into onRx
+// in the meantime, so that the Controller doesn't block broadcasting before it gets round to the ack.
+// If rx closes first, the Controller has shut down, and the ack never comes.
+// If done, if non-nil, closes first, it gives up with errGaveUp.
+func processRepliesUntilAck(done <-chan struct{}, reply <-chan Response, rx <-chan Response, onRx func(Response),
+	cb func(Response) error) error {
 	var cberr error
 
-	for r := range reply {
-		if ack, isAck := r.Body.(DoneResponse); isAck {
-			if cberr != nil {
-				return cberr
+	for {
+		select {
+		case <-done:
+			return errGaveUp
+		case r, ok := <-reply:
+			if !ok {
+				return fmt.Errorf("reply channel closed before ack received")
+			}
+			if ack, isAck := r.Body.(DoneResponse); isAck {
+				if cberr != nil {
+					return cberr
+				}
+				return ack.Err
 			}
-			return ack.Err
-		}
 
-		if cberr == nil {
-			cberr = cb(r)
+			if cberr == nil {
+				cberr = cb(r)
+			}
+		case r, ok := <-rx:
+			if !ok {
+				return ErrControllerShutDown
+			}
+			onRx(r)
 		}
 	}
-	return fmt.Errorf("reply channel closed before ack received")
 }
 
 // SendAndProcessReplies sends a request with tag tag and body body.
 // It then uses cb to process any non-Ack replies.
 // It returns whether the Client was able to process the message, and any error.
+// If ctx finishes before the ack, it gives up with ctx's error, and the Controller drops the replies left over.
 func (c *Client) SendAndProcessReplies(ctx context.Context, tag string, body interface{}, cb func(Response) error) (bool, error) {
 	reply := make(chan Response)
 
 	rq := Request{
-		Origin: RequestOrigin{Tag: tag, ReplyTx: reply},
+		Origin: RequestOrigin{Tag: tag, ReplyTx: reply, Done: ctx.Done()},
 		Body:   body,
 	}
 
@@ -175,7 +301,33 @@ func (c *Client) SendAndProcessReplies(ctx context.Context, tag string, body int
 		return false, nil
 	}
 
-	return true, ProcessRepliesUntilAck(reply, cb)
+	return true, ProcessRepliesUntilAckOrDone(ctx, reply, cb)
+}
+
+// sendAndProcessRepliesDiscarding is SendAndProcessReplies, but discards any broadcasts sent to c in the meantime.
+// It is for Clients that nothing else is taking broadcasts from yet.
+func (c *Client) sendAndProcessRepliesDiscarding(ctx context.Context, tag string, body interface{}, cb func(Response) error) (bool, error) {
+	reply := make(chan Response)
+
+	rq := Request{
+		Origin: RequestOrigin{Tag: tag, ReplyTx: reply},
+		Body:   body,
+	}
+
+	for sent := false; !sent; {
+		select {
+		case c.Tx <- rq:
+			sent = true
+		case _, ok := <-c.Rx:
+			if !ok {
+				return false, nil
+			}
+		case <-ctx.Done():
+			return false, nil
+		}
+	}
+
+	return true, processRepliesUntilAck(nil, reply, c.Rx, func(Response) {}, cb)
 }
 
 // coclient is the type of internal client handles.
@@ -192,7 +344,7 @@ func (c *coclient) Close() {
 	close(c.tx)
 }
 
-// makeClient creates a new client and coclient pair, given a parent context.
+// makeClient creates a new client and coclient pair.
 func makeClient() (Client, coclient) {
 	rq := make(chan Request)
 	rs := make(chan Response)
@@ -0,0 +1,78 @@
+package controller
+
+// File clock.go contains Clock, the source of time for the Controller (and for anything else that takes one, such as
+// the net server), so that time-triggered behaviour can be tested without waiting for real time to pass.
+
+import "time"
+
+// Clock is the interface of sources of wall-clock time and timers.
+type Clock interface {
+	// Now gets the current time.
+	Now() time.Time
+	// NewTimer creates a Timer that fires once d has passed.
+	NewTimer(d time.Duration) Timer
+	// NewTicker creates a Ticker that fires every time d passes.
+	// d must be positive.
+	NewTicker(d time.Duration) Ticker
+}
+
+// Timer is the interface of one-shot timers made by a Clock.
+type Timer interface {
+	// C gets the channel on which the Timer sends the time when it fires.
+	C() <-chan time.Time
+	// Stop stops the Timer, returning false if it has already fired or been stopped.
+	Stop() bool
+}
+
+// Ticker is the interface of repeating tickers made by a Clock.
+// As with time.Ticker, a Ticker whose ticks aren't taken in time drops them, rather than letting them pile up.
+type Ticker interface {
+	// C gets the channel on which the Ticker sends the time whenever it fires.
+	C() <-chan time.Time
+	// Stop stops the Ticker; it then fires no more.
+	Stop()
+}
+
+// SystemClock is the Clock that uses the system's own time and timers.
+type SystemClock struct{}
+
+// Now gets the current system time.
+func (SystemClock) Now() time.Time {
+	return time.Now()
+}
+
+// NewTimer creates a Timer backed by a time.Timer.
+func (SystemClock) NewTimer(d time.Duration) Timer {
+	return systemTimer{time.NewTimer(d)}
+}
+
+// NewTicker creates a Ticker backed by a time.Ticker.
+func (SystemClock) NewTicker(d time.Duration) Ticker {
+	return systemTicker{time.NewTicker(d)}
+}
+
+// systemTimer adapts a time.Timer to Timer.
+type systemTimer struct {
+	t *time.Timer
+}
+
+func (t systemTimer) C() <-chan time.Time {
+	return t.t.C
+}
+
+func (t systemTimer) Stop() bool {
+	return t.t.Stop()
+}
+
+// systemTicker adapts a time.Ticker to Ticker.
+type systemTicker struct {
+	t *time.Ticker
+}
+
+func (t systemTicker) C() <-chan time.Time {
+	return t.t.C
+}
+
+func (t systemTicker) Stop() {
+	t.t.Stop()
+}
@@ -0,0 +1,14 @@
+package controller
+
+// File commandonly.go contains command-only mode, in which Bifrost adapters send their clients replies to their own
+// requests and nothing else, for clients that only ever issue commands.
+
+// commandOnlyDrops gets whether b, if CommandOnly, drops rs rather than forwarding it.
+//
+// A command-only adapter drops every broadcast, including those its own client's requests cause (such as the
+// ScheduleResponse for a schedule it asks for); the ACK still says whether each request worked.
+// It still takes each broadcast off its Client's Rx as soon as the Controller sends it, so the Controller never waits
+// on a client that isn't listening, and nothing ever reaches the Bifrost client's queue.
+func (b *Bifrost) commandOnlyDrops(rs Response) bool {
+	return b.CommandOnly && rs.Broadcast
+}
@@ -0,0 +1,67 @@
+package controller_test
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/UniversityRadioYork/bifrost-go/message"
+
+	"github.com/UniversityRadioYork/baps3d/controller"
+	"github.com/UniversityRadioYork/baps3d/list"
+)
+
+// TestBifrost_CommandOnly tests that a command-only adapter skips the handshake dump and drops broadcasts, even those
+// its own requests cause, while still sending replies.
+func TestBifrost_CommandOnly(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	l := list.New()
+	if err := l.Add(list.NewTrack("a", "a.mp3"), 0); err != nil {
+		t.Fatalf("couldn't add: %v", err)
+	}
+	ctl, root := controller.NewController(l)
+	go ctl.Run(ctx)
+	go func() {
+		for range root.Rx {
+		}
+	}()
+
+	c, err := root.Copy(ctx)
+	if err != nil {
+		t.Fatalf("couldn't copy client: %v", err)
+	}
+	bf, sep, err := c.Bifrost(ctx)
+	if err != nil {
+		t.Fatalf("couldn't get Bifrost adapter: %v", err)
+	}
+	bf.CommandOnly = true
+	go bf.Run(ctx)
+	// OHAI and IAMA are replies, so still come.
+	readMessages(t, sep, 2)
+
+	// The adapter sends replies as it goes, so we need to read them while sending.
+	go func() {
+		for _, m := range []*message.Message{
+			message.New("t", "sel").AddArgs("0", "a"),
+			message.New("u", "sel").AddArgs("0", "x"),
+		} {
+			sep.Tx <- *m
+		}
+	}()
+
+	var got []string
+	for _, bs := range readMessages(t, sep, 2) {
+		got = append(got, string(bs))
+	}
+	// Had the selection's broadcast come through, it would be here instead of u's ACK.
+	if len(got) != 2 || got[0] != "t ACK OK success\n" || !strings.HasPrefix(got[1], "u ACK FAIL") {
+		t.Errorf("got %q, want only ACKs", got)
+	}
+
+	if err := root.Shutdown(ctx); err != nil {
+		t.Fatalf("couldn't shut down: %v", err)
+	}
+}
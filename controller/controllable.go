@@ -14,5 +14,34 @@ type Controllable interface {
 	Dump(dumpCb ResponseCb)
 
 	// HandleRequest handles a request with body rbody, reply callback replyCb, and broadcast callback bcastCb.
+	// The request's origin gets the responses in the order HandleRequest makes them, followed by the acknowledgement.
 	HandleRequest(replyCb ResponseCb, bcastCb ResponseCb, rbody interface{}) error
 }
+
+// Greeter is the interface of Controllables that push an initial state to newly attached clients.
+//
+// Controllers with Greeter states give each new Client the greeting, in Client.Greeting, as soon as it is created,
+// without waiting for a DumpRequest.
+type Greeter interface {
+	// Greet calls greetCb for each response that should be sent to a newly attached client.
+	Greet(greetCb ResponseCb)
+}
+
+// Prioritiser is the interface of Controllables that let some of their requests jump the queue.
+//
+// A Controller only serves a request sent with Client.SendPriority if the request is one of the Controller's own
+// priority requests (health checks and shutdowns), or the Controller's state is a Prioritiser whose IsPriority
+// allows it.
+type Prioritiser interface {
+	// IsPriority checks whether a request with body rbody may be sent as a priority request.
+	// It should only allow small, safety-critical requests, as an allowed request can hold up everything else.
+	IsPriority(rbody interface{}) bool
+}
+
+// Reloader is the interface of Controllables that can take new settings in place; see ReloadRequest.
+type Reloader interface {
+	// Reload replaces the Controllable's settings with settings, whose type is up to the Controllable.
+	// It must apply either all of settings or, returning an error, none of them.
+	// Whatever Dump would send, other than what the settings themselves change, must survive the reload.
+	Reload(settings interface{}) error
+}
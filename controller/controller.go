@@ -8,6 +8,7 @@ import (
 	"errors"
 	"fmt"
 	"reflect"
+	"time"
 
 	"github.com/UniversityRadioYork/bifrost-go/core"
 
@@ -19,11 +20,33 @@ var (
 	// a Bifrost adapter for a Controller, but its Controllable state doesn't
 	// implement BifrostParser.
 	ErrControllerCannotSpeakBifrost = errors.New("this controller's state can't parse Bifrost messages")
+
+	// ErrNotPriority is the error sent when a Client sends a priority request
+	// whose body the Controller doesn't allow to jump the queue.
+	ErrNotPriority = errors.New("this request can't be sent as a priority request")
+
+	// ErrCannotReload is the error sent when a Client sends a ReloadRequest, but the Controller's Controllable state
+	// doesn't implement Reloader.
+	ErrCannotReload = errors.New("this controller's state can't reload its settings")
+
+	// ErrStateChanged is the error, wrapped with details, that Controllable states give when a request's guard (such
+	// as the hash of the item it targets) no longer holds by the time the Controller handles it.
+	// Controllers handle one request at a time, so guards are always checked against the state as it then is, and a
+	// request racing another client's change fails with this rather than acting on the wrong thing.
+	// The request itself was fine, so the client can catch up on the change and retry.
+	ErrStateChanged = errors.New("state changed")
 )
 
 // Controller wraps a baps3d service in a channel-based interface.
 // The service must satisfy the 'Controllable' interface.
 type Controller struct {
+	// lastActivity is the Clock time, in Unix nanoseconds, at which the Controller last went round its main loop.
+	// It is read and written atomically, and comes first so that it is 64-bit aligned.
+	lastActivity int64
+
+	// alive is 1 while the Controller's Run loop is running, and 0 otherwise; it is read and written atomically.
+	alive int32
+
 	// state is the internal state managed by the Controller.
 	state Controllable
 
@@ -43,14 +66,71 @@ type Controller struct {
 	// channel.
 	cselects []reflect.SelectCase
 
+	// priority is the channel, shared by all clients, on which the Controller receives priority requests.
+	// It is never closed, as no one client owns it.
+	priority chan Request
+
+	// clock is the Controller's source of time for scheduling.
+	clock Clock
+
+	// schedules holds the pending schedules, earliest first.
+	schedules []schedule
+
+	// nextScheduleID is the ID that will be given to the next schedule.
+	nextScheduleID uint64
+
+	// timer, if non-nil, fires when the earliest pending schedule is due.
+	timer Timer
+
+	// heartbeat, if non-nil, wakes the Controller up every heartbeatInterval so that it marks itself active.
+	heartbeat Ticker
+
+	// heartbeatInterval is how often the heartbeat fires.
+	heartbeatInterval time.Duration
+
+	// origin is the client whose request the Controller is handling, so broadcasts can tell it that they are its own.
+	// It is the zero coclient while handling priority requests and schedules, as these have no one client to credit.
+	origin coclient
+
+	// paused is true if the Controller is paused; see PauseRequest.
+	paused bool
+
+	// held holds the requests that arrived while the Controller was paused, earliest first.
+	held []heldRequest
+
+	// pauseQueueLen is the most requests the Controller holds while paused.
+	pauseQueueLen int
+
+	// dumps holds the dump requests waiting for their turn, earliest first; see SetDumpLimit.
+	dumps []queuedDump
+
+	// dumpLimit is the most queued dumps the Controller handles in a row while other requests wait.
+	dumpLimit int
+
+	// dumpRun is the number of queued dumps the Controller has handled in a row.
+	dumpRun int
+
+	// deadmanWindow, if positive, is how long the Controller can go without a state change before its deadman switch
+	// fires; see SetDeadman.
+	deadmanWindow time.Duration
+
+	// deadmanAction, if non-nil, is the request body the Controller handles when its deadman switch fires.
+	deadmanAction interface{}
+
+	// lastChange is the Clock time at which the Controller last broadcast anything, or started running.
+	lastChange time.Time
+
 	// running is the internal is-running flag.
 	// When this is set to false, the controller loop will exit.
 	running bool
 }
 
 // makeAndAddClient creates a new client and coclient pair, and adds the coclient to c's clients.
+// If c's state is a Greeter, the new client carries its greeting.
 func (c *Controller) makeAndAddClient() *Client {
 	client, co := makeClient()
+	client.Greeting = c.greeting()
+	client.priorityTx = c.priority
 	c.clients[co] = -1
 
 	c.rebuildClientSelects()
@@ -58,47 +138,170 @@ func (c *Controller) makeAndAddClient() *Client {
 	return &client
 }
 
+// greeting gets the responses that c sends to each new client, if any.
+func (c *Controller) greeting() []Response {
+	g, ok := c.state.(Greeter)
+	if !ok {
+		return nil
+	}
+
+	var greeting []Response
+	g.Greet(func(rbody interface{}) {
+		greeting = append(greeting, Response{Broadcast: true, Origin: nil, Body: rbody})
+	})
+	return greeting
+}
+
 // rebuildClientSelects repopulates the list of client select cases.
 // It should be run whenever a client connects or disconnects.
+//
+// After the client cases come the heartbeat, the priority channel, so that priority requests can wake up an idle
+// Controller, then the schedule timer.
 func (c *Controller) rebuildClientSelects() {
-	c.cselects = make([]reflect.SelectCase, len(c.clients))
+	c.cselects = make([]reflect.SelectCase, len(c.clients)+3)
 	i := 0
 	for cl := range c.clients {
-		c.cselects[i] = reflect.SelectCase{Dir: reflect.SelectRecv, Chan: reflect.ValueOf(cl.rx)}
+		c.cselects[i] = c.clientCase(cl)
 		c.clients[cl] = i
 		i++
 	}
+	c.cselects[i] = c.heartbeatCase()
+	c.cselects[i+1] = reflect.SelectCase{Dir: reflect.SelectRecv, Chan: reflect.ValueOf(c.priority)}
+	c.cselects[i+2] = c.timerCase()
+}
+
+// clientCase gets the select case for client cl.
+// While cl has a dump queued, the case never fires, so that cl's later requests wait for the dump.
+func (c *Controller) clientCase(cl coclient) reflect.SelectCase {
+	rx := cl.rx
+	if c.hasQueuedDump(cl) {
+		rx = nil
+	}
+	return reflect.SelectCase{Dir: reflect.SelectRecv, Chan: reflect.ValueOf(rx)}
+}
+
+// refreshClientCase updates the select case for client cl, if it is still connected.
+func (c *Controller) refreshClientCase(cl coclient) {
+	if i, ok := c.clients[cl]; ok {
+		c.cselects[i] = c.clientCase(cl)
+	}
 }
 
 // NewController constructs a new Controller for a given Controllable.
 func NewController(c Controllable) (*Controller, *Client) {
 	controller := &Controller{
-		state:   c,
-		clients: make(map[coclient]int),
+		state:             c,
+		clients:           make(map[coclient]int),
+		priority:          make(chan Request),
+		clock:             SystemClock{},
+		nextScheduleID:    1,
+		pauseQueueLen:     DefaultPauseQueueLen,
+		heartbeatInterval: DefaultHeartbeatInterval,
+		dumpLimit:         DefaultDumpLimit,
 	}
 	client := controller.makeAndAddClient()
 	return controller, client
 }
 
 // Run runs this Controller's event loop.
+//
+// The Controller handles one request at a time, to completion.
+// Before picking up each request, it checks for priority requests (see Client.SendPriority): if any client is
+// already waiting to send one, the Controller serves it before any normal request, even one that was waiting first.
+// Priority requests don't interrupt the request being handled when they arrive, and the Controller makes no
+// promises about the order in which it serves priority requests waiting at the same time, or normal requests
+// waiting at the same time.
+//
+// Scheduled requests (see ScheduleRequest) fire between requests, once the Controller's Clock says they are due.
+//
+// While paused (see PauseRequest), the Controller still takes requests, but holds those that touch its state; once it
+// resumes, it handles them, in the order they arrived, before taking any more normal requests.
+// If it shuts down with requests still held, it fails them with ErrControllerShutDown.
+//
+// Dumps queue, so that a flood of them can't starve other requests; see SetDumpLimit.
+// Like held requests, queued dumps wait out pauses, and fail with ErrControllerShutDown if the Controller shuts down.
+//
+// While handling a request, the Controller sends its responses one at a time, in the order it makes them, and waits
+// for each client to take each response before sending the next.
+// So every client sees broadcasts in the same order, and the client that sent a request sees every broadcast that
+// the request caused before the request's DoneResponse, which always comes last.
+// Clients must take broadcasts while waiting for replies, or the Controller blocks.
+//
+// Each time round its loop, including on heartbeats while idle, the Controller updates its LastActivity, and checks
+// its deadman switch (see SetDeadman).
 func (c *Controller) Run(ctx context.Context) {
+	c.heartbeat = c.clock.NewTicker(c.heartbeatInterval)
+	c.rebuildClientSelects()
+	c.setAlive(true)
+	c.noteChange()
+
 	c.running = true
 	for c.running {
-		i, value, open := reflect.Select(c.cselects)
-		if open {
-			// TODO(@MattWindsor91): properly handle if this isn't a Request
-			rq, ok := value.Interface().(Request)
-			if !ok {
-				panic("FIXME: got bad request")
-			}
-
-			c.handleRequest(ctx, rq)
-		} else {
-			c.hangUpClientWithCase(i)
+		c.markActive()
+		c.checkDeadman()
+
+		if rq, ok := c.pollPriority(); ok {
+			c.handlePriorityRequest(ctx, rq)
+			continue
 		}
+		if !c.paused && 0 < len(c.held) {
+			c.handleHeld(ctx)
+			continue
+		}
+		if !c.paused && 0 < len(c.dumps) {
+			c.serveDumps(ctx)
+			continue
+		}
+
+		i, value, open := reflect.Select(c.cselects)
+		c.handleCase(ctx, i, value, open)
+		c.noteIfNotDump(0)
 	}
 
+	if c.timer != nil {
+		c.timer.Stop()
+	}
+	c.heartbeat.Stop()
+	c.heartbeat = nil
+	c.failHeld()
+	c.failDumps()
 	c.hangUpClients()
+	c.setAlive(false)
+}
+
+// handleCase handles the result of selecting, on c's select cases, the value value from case i.
+// open is false if the case's channel has closed.
+func (c *Controller) handleCase(ctx context.Context, i int, value reflect.Value, open bool) {
+	switch {
+	case i == len(c.cselects)-1:
+		c.fireDue()
+	case i == len(c.cselects)-2:
+		c.handlePriorityRequest(ctx, value.Interface().(Request))
+	case i == len(c.cselects)-3:
+		// Heartbeats only wake the Controller up, so that it marks itself active.
+	case open:
+		// TODO(@MattWindsor91): properly handle if this isn't a Request
+		rq, ok := value.Interface().(Request)
+		if !ok {
+			panic("FIXME: got bad request")
+		}
+
+		c.origin, _ = c.clientWithCase(i)
+		c.handleClientRequest(ctx, rq)
+		c.origin = coclient{}
+	default:
+		c.hangUpClientWithCase(i)
+	}
+}
+
+// pollPriority gets a priority request, if there is one waiting, without blocking.
+func (c *Controller) pollPriority() (Request, bool) {
+	select {
+	case rq := <-c.priority:
+		return rq, true
+	default:
+		return Request{}, false
+	}
 }
 
 // hangUpClients hangs up every connected client.
@@ -110,14 +313,21 @@ func (c *Controller) hangUpClients() {
 	c.rebuildClientSelects()
 }
 
-// hangUpClientWithCase hangs up the client whose select case is at index i.
-func (c *Controller) hangUpClientWithCase(i int) {
+// clientWithCase gets the client whose select case is at index i.
+func (c *Controller) clientWithCase(i int) (coclient, bool) {
 	for cl, j := range c.clients {
 		if i == j {
-			c.hangUpClient(cl)
-			return
+			return cl, true
 		}
 	}
+	return coclient{}, false
+}
+
+// hangUpClientWithCase hangs up the client whose select case is at index i.
+func (c *Controller) hangUpClientWithCase(i int) {
+	if cl, ok := c.clientWithCase(i); ok {
+		c.hangUpClient(cl)
+	}
 }
 
 // hangUpClient closes a client's channels and removes it from the client list.
@@ -136,6 +346,34 @@ func (c *Controller) hangUpClient(cl coclient) {
 // Request handling
 //
 
+// handleClientRequest handles a Request rq from a client's request channel, unpacking it if it is a batch.
+// While c is paused, it holds rq instead, unless rq doesn't touch c's state; it queues dumps (see SetDumpLimit).
+func (c *Controller) handleClientRequest(ctx context.Context, rq Request) {
+	if c.paused && !runsWhilePaused(rq.Body) {
+		c.hold(c.origin, rq)
+		return
+	}
+	if _, ok := rq.Body.(DumpRequest); ok {
+		c.queueDump(c.origin, rq)
+		return
+	}
+
+	batch, ok := rq.Body.(batchRequest)
+	if !ok {
+		c.handleRequest(ctx, rq)
+		return
+	}
+
+	// Batches come from Bifrost adapters, which can't pause the Controller, so a batch never pauses part-way through.
+	for _, brq := range batch {
+		// A shutdown part-way through a batch stops the rest of it, as it would stop later requests.
+		if !c.running {
+			return
+		}
+		c.handleRequest(ctx, brq)
+	}
+}
+
 // handleRequest handles a Request rq.
 // If the request is a standard Request, the Controller will handle it itself.
 // Otherwise, the Controller forwards it to the Controllable.
@@ -156,6 +394,24 @@ func (c *Controller) handleRequest(ctx context.Context, rq Request) {
 		err = c.handleShutdownRequest(o, body)
 	case bifrostParserRequest:
 		err = c.handleBifrostParserRequest(o, body)
+	case ScheduleRequest:
+		err = c.handleScheduleRequest(o, body)
+	case CancelScheduleRequest:
+		err = c.handleCancelScheduleRequest(o, body)
+	case ListSchedulesRequest:
+		err = c.handleListSchedulesRequest(o, body)
+	case ReloadRequest:
+		err = c.handleReloadRequest(body)
+	case PingRequest:
+		c.reply(o, PongResponse{Token: body.Token, Time: c.clock.Now()})
+	case PauseRequest:
+		err = c.handlePauseRequest()
+	case ResumeRequest:
+		err = c.handleResumeRequest()
+	case healthRequest:
+		// Getting this far is the health check, so there's nothing else to do.
+	case badRequest:
+		err = body.err
 	default:
 		err = c.handleStateSpecificRequest(o, body)
 	}
@@ -164,6 +420,32 @@ func (c *Controller) handleRequest(ctx context.Context, rq Request) {
 	c.reply(o, ack)
 }
 
+// handlePriorityRequest handles a Request rq sent on the priority channel.
+// It refuses rq with ErrNotPriority if rq's body isn't allowed to jump the queue.
+// While c is paused, it holds rq alongside normal requests, unless rq doesn't touch c's state.
+func (c *Controller) handlePriorityRequest(ctx context.Context, rq Request) {
+	if !c.isPriority(rq.Body) {
+		c.reply(rq.Origin, DoneResponse{ErrNotPriority})
+		return
+	}
+	if c.paused && !runsWhilePaused(rq.Body) {
+		c.hold(coclient{}, rq)
+		return
+	}
+	c.handleRequest(ctx, rq)
+}
+
+// isPriority checks whether a request with body rbody may be sent as a priority request.
+func (c *Controller) isPriority(rbody interface{}) bool {
+	switch rbody.(type) {
+	case healthRequest, shutdownRequest, PauseRequest, ResumeRequest:
+		return true
+	}
+
+	p, ok := c.state.(Prioritiser)
+	return ok && p.IsPriority(rbody)
+}
+
 func (c *Controller) handleStateSpecificRequest(o RequestOrigin, body interface{}) error {
 	replyCb := func(rbody interface{}) {
 		c.reply(o, rbody)
@@ -173,15 +455,27 @@ func (c *Controller) handleStateSpecificRequest(o RequestOrigin, body interface{
 
 // handleDumpRequest handles a dump with origin o and body b.
 func (c *Controller) handleDumpRequest(o RequestOrigin, b DumpRequest) error {
-	dumpCb := func(rbody interface{}) {
-		c.reply(o, rbody)
-	}
-	c.state.Dump(dumpCb)
+	c.resync(o)
 
 	// Dump requests never fail
 	return nil
 }
 
+// handleReloadRequest handles a ReloadRequest b; see ReloadRequest for what survives the reload.
+func (c *Controller) handleReloadRequest(b ReloadRequest) error {
+	r, ok := c.state.(Reloader)
+	if !ok {
+		return ErrCannotReload
+	}
+	if err := r.Reload(b.Settings); err != nil {
+		return err
+	}
+
+	c.broadcast(ReloadedResponse{})
+	c.state.Dump(c.broadcast)
+	return nil
+}
+
 // handleNewClientRequest handles a new client request with origin o and body b.
 func (c *Controller) handleNewClientRequest(o RequestOrigin, b newClientRequest) error {
 	cl := c.makeAndAddClient()
@@ -234,6 +528,8 @@ func (c *Controller) handleBifrostParserRequest(o RequestOrigin, b bifrostParser
 }
 
 // reply sends a unicast response with body rbody to the request origin to.
+// It blocks until the origin takes the response, or, if the origin has a Done channel, until that closes, in which
+// case the response is dropped.
 func (c *Controller) reply(to RequestOrigin, rbody interface{}) {
 	reply := Response{
 		Broadcast: false,
@@ -241,18 +537,23 @@ func (c *Controller) reply(to RequestOrigin, rbody interface{}) {
 		Body:      rbody,
 	}
 
-	to.ReplyTx <- reply
+	select {
+	case to.ReplyTx <- reply:
+	case <-to.Done:
+	}
 }
 
 // broadcast sends a broadcast response with body rbody to all clients.
+// It blocks until every client has taken the response, so no later reply can overtake it.
+// The client whose request caused the broadcast, if any, gets it with Own set.
 func (c *Controller) broadcast(rbody interface{}) {
-	response := Response{
-		Broadcast: true,
-		Origin:    nil,
-		Body:      rbody,
-	}
-
+	c.noteChange()
 	for cl := range c.clients {
-		cl.tx <- response
+		cl.tx <- Response{
+			Broadcast: true,
+			Own:       cl == c.origin,
+			Origin:    nil,
+			Body:      rbody,
+		}
 	}
 }
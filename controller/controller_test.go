@@ -2,10 +2,12 @@ package controller_test
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"reflect"
 	"sync"
 	"testing"
+	"time"
 
 	"github.com/UniversityRadioYork/bifrost-go/message"
 
@@ -24,6 +26,14 @@ type knownDummyRequest struct {
 }
 type knownDummyResponse struct{}
 
+// taggedBroadcastRequest asks for taggedBroadcastResponse with the same ID to be broadcast.
+type taggedBroadcastRequest struct {
+	ID string
+}
+type taggedBroadcastResponse struct {
+	ID string
+}
+
 /*
 Controllable implementation
 */
@@ -46,6 +56,9 @@ func (*testState) HandleRequest(replyCb, bcastCb controller.ResponseCb, rbody in
 
 		cb(knownDummyResponse{})
 		return nil
+	case taggedBroadcastRequest:
+		bcastCb(taggedBroadcastResponse(b))
+		return nil
 	default:
 		return fmt.Errorf("unknown request")
 	}
@@ -67,6 +80,20 @@ func (*testStateWithParser) EmitBifrostResponse(string, interface{}, chan<- mess
 	return nil
 }
 
+type testStateWithGreeter struct {
+	testState
+}
+
+type greetingDummyResponse struct{}
+
+/*
+Greeter implementation for testStateWithGreeter
+*/
+
+func (*testStateWithGreeter) Greet(greetCb controller.ResponseCb) {
+	greetCb(greetingDummyResponse{})
+}
+
 /*
 Test helpers
 */
@@ -260,3 +287,444 @@ func TestClient_CopyAfterShutdown(t *testing.T) {
 	}
 	testWithController(&testState{}, f, t)
 }
+
+// TestClient_CheckAlive tests Client.CheckAlive's behaviour before and after shutdown.
+func TestClient_CheckAlive(t *testing.T) {
+	f := func(ctx context.Context, c *controller.Client, t *testing.T) {
+		if err := c.CheckAlive(ctx); err != nil {
+			t.Fatalf("unexpected error on running controller: %s", err.Error())
+		}
+
+		if err := c.Shutdown(ctx); err != nil {
+			t.Fatalf("unexpected error on shutdown: %s", err.Error())
+		}
+
+		// Use a fresh context, so we're testing the check and not the cancellation.
+		cctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+		defer cancel()
+		if err := c.CheckAlive(cctx); err != controller.ErrControllerShutDown {
+			t.Fatalf("got %v on shut-down controller, want ErrControllerShutDown", err)
+		}
+	}
+	testWithController(&testState{}, f, t)
+}
+
+// TestClient_HangUp tests that hanging up a copy detaches just that copy, even while the Controller is broadcasting,
+// and leaves the Controller serving everyone else.
+func TestClient_HangUp(t *testing.T) {
+	f := func(ctx context.Context, root *controller.Client, t *testing.T) {
+		gone, err := root.Copy(ctx)
+		if err != nil {
+			t.Fatalf("couldn't copy client: %v", err)
+		}
+		stays, err := root.Copy(ctx)
+		if err != nil {
+			t.Fatalf("couldn't copy client: %v", err)
+		}
+		go func() {
+			for range stays.Rx {
+			}
+		}()
+
+		// Nobody takes gone's broadcasts but HangUp, so, if HangUp didn't, the Controller would block on it.
+		bcasts := make(chan error, 1)
+		go func() {
+			var err error
+			for i := 0; i < 10 && err == nil; i++ {
+				err = sendAndCheckBroadcastFirst(ctx, root, fmt.Sprintf("b%d", i))
+			}
+			bcasts <- err
+		}()
+		if err := gone.HangUp(ctx); err != nil {
+			t.Fatalf("unexpected error hanging up: %v", err)
+		}
+		if _, ok := <-gone.Rx; ok {
+			t.Error("hung-up client still receiving")
+		}
+		if err := <-bcasts; err != nil {
+			t.Fatalf("broadcasting: %v", err)
+		}
+
+		if err := root.CheckAlive(ctx); err != nil {
+			t.Fatalf("controller not alive after hangup: %v", err)
+		}
+		if err := stays.CheckAlive(ctx); err != nil {
+			t.Errorf("other copy can't use controller after hangup: %v", err)
+		}
+	}
+	testWithController(&testState{}, f, t)
+}
+
+// TestClient_Copy_Greeting tests that Client.Copy's new Client receives its
+// Controller's greeting without having to ask for it.
+func TestClient_Copy_Greeting(t *testing.T) {
+	f := func(ctx context.Context, c *controller.Client, t *testing.T) {
+		c2, err := c.Copy(ctx)
+		if err != nil {
+			t.Fatalf("unexpected error on copy: %s", err.Error())
+		}
+
+		if len(c2.Greeting) != 1 {
+			t.Fatalf("expected one greeting response, got %d", len(c2.Greeting))
+		}
+		rs := c2.Greeting[0]
+		if !rs.Broadcast {
+			t.Error("greeting erroneously marked as unicast")
+		}
+		if _, isGreeting := rs.Body.(greetingDummyResponse); !isGreeting {
+			t.Errorf("unexpected greeting response type: %T", rs.Body)
+		}
+	}
+	testWithController(&testStateWithGreeter{}, f, t)
+}
+
+// testStateWithPriority is a testState whose dummy request handling can be held up, and which allows broadcast dummy
+// requests as priority requests.
+type testStateWithPriority struct {
+	testState
+
+	// started receives a value when the state starts handling a held request.
+	started chan struct{}
+	// release lets a held request finish.
+	release chan struct{}
+	// handled records the order in which the state handled requests.
+	handled []knownDummyRequest
+}
+
+// heldDummyRequest is a request that testStateWithPriority holds up until it is released.
+type heldDummyRequest struct{}
+
+func (s *testStateWithPriority) HandleRequest(replyCb, bcastCb controller.ResponseCb, rbody interface{}) error {
+	switch b := rbody.(type) {
+	case heldDummyRequest:
+		s.started <- struct{}{}
+		<-s.release
+		return nil
+	case knownDummyRequest:
+		s.handled = append(s.handled, b)
+		return nil
+	default:
+		return fmt.Errorf("unknown request")
+	}
+}
+
+func (*testStateWithPriority) IsPriority(rbody interface{}) bool {
+	b, ok := rbody.(knownDummyRequest)
+	return ok && b.Broadcast
+}
+
+// sendAndAck sends body with send, then waits for its ack, returning its error.
+func sendAndAck(ctx context.Context, send func(context.Context, controller.Request) bool, body interface{}) error {
+	reply := make(chan controller.Response)
+	rq := controller.Request{Origin: controller.RequestOrigin{ReplyTx: reply}, Body: body}
+	if !send(ctx, rq) {
+		return fmt.Errorf("controller shut down")
+	}
+	return controller.ProcessRepliesUntilAck(reply, func(controller.Response) error { return nil })
+}
+
+// TestClient_SendPriority_Order tests that a priority request waiting for a busy Controller is served before a normal
+// request that started waiting earlier.
+func TestClient_SendPriority_Order(t *testing.T) {
+	s := &testStateWithPriority{started: make(chan struct{}), release: make(chan struct{})}
+	f := func(ctx context.Context, c *controller.Client, t *testing.T) {
+		c2, err := c.Copy(ctx)
+		if err != nil {
+			t.Fatalf("unexpected error on copy: %s", err.Error())
+		}
+
+		heldErr := make(chan error, 1)
+		go func() { heldErr <- sendAndAck(ctx, c.Send, heldDummyRequest{}) }()
+		<-s.started
+
+		normalErr := make(chan error, 1)
+		go func() { normalErr <- sendAndAck(ctx, c2.Send, knownDummyRequest{Broadcast: false}) }()
+		// There's no way to see that the normal request is waiting, so give it a moment to get there.
+		time.Sleep(20 * time.Millisecond)
+
+		priorityErr := make(chan error, 1)
+		go func() { priorityErr <- sendAndAck(ctx, c.SendPriority, knownDummyRequest{Broadcast: true}) }()
+		time.Sleep(20 * time.Millisecond)
+
+		close(s.release)
+		for _, ch := range []chan error{heldErr, normalErr, priorityErr} {
+			if err := <-ch; err != nil {
+				t.Fatalf("unexpected error: %s", err.Error())
+			}
+		}
+
+		want := []knownDummyRequest{{Broadcast: true}, {Broadcast: false}}
+		if !reflect.DeepEqual(s.handled, want) {
+			t.Errorf("got handling order %v, want %v", s.handled, want)
+		}
+	}
+	testWithController(s, f, t)
+}
+
+// TestClient_SendPriority_NotAllowed tests that a Controller refuses priority requests that aren't on its allowlist.
+func TestClient_SendPriority_NotAllowed(t *testing.T) {
+	s := &testStateWithPriority{}
+	f := func(ctx context.Context, c *controller.Client, t *testing.T) {
+		if err := sendAndAck(ctx, c.SendPriority, knownDummyRequest{Broadcast: false}); err != controller.ErrNotPriority {
+			t.Errorf("got %v, want ErrNotPriority", err)
+		}
+		if len(s.handled) != 0 {
+			t.Errorf("refused priority request was handled anyway")
+		}
+
+		// The same request, with broadcast on, is allowed.
+		if err := sendAndAck(ctx, c.SendPriority, knownDummyRequest{Broadcast: true}); err != nil {
+			t.Errorf("unexpected error on allowed request: %s", err.Error())
+		}
+	}
+	testWithController(s, f, t)
+}
+
+// sendAndCheckBroadcastFirst sends a taggedBroadcastRequest with ID id through c, taking broadcasts while it waits.
+// It fails if the request's acknowledgement comes before its broadcast.
+func sendAndCheckBroadcastFirst(ctx context.Context, c *controller.Client, id string) error {
+	reply := make(chan controller.Response)
+	rq := controller.Request{
+		Origin: controller.RequestOrigin{Tag: id, ReplyTx: reply},
+		Body:   taggedBroadcastRequest{ID: id},
+	}
+
+	tx, seen := c.Tx, false
+	for {
+		select {
+		case tx <- rq:
+			tx = nil
+		case rs := <-c.Rx:
+			if b, ok := rs.Body.(taggedBroadcastResponse); ok && b.ID == id {
+				seen = true
+			}
+		case rs := <-reply:
+			ack, ok := rs.Body.(controller.DoneResponse)
+			if !ok {
+				continue
+			}
+			if ack.Err != nil {
+				return fmt.Errorf("%s: unexpected error: %w", id, ack.Err)
+			}
+			if !seen {
+				return fmt.Errorf("%s: got the acknowledgement before the broadcast", id)
+			}
+			return nil
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+}
+
+// TestController_BroadcastBeforeAck tests that, with many clients sending at once, each client sees the broadcast
+// its request caused before the request's acknowledgement.
+func TestController_BroadcastBeforeAck(t *testing.T) {
+	const (
+		nClients  = 8
+		nRequests = 50
+	)
+
+	f := func(ctx context.Context, root *controller.Client, t *testing.T) {
+		clients := make([]*controller.Client, nClients)
+		for i := range clients {
+			var err error
+			if clients[i], err = root.Copy(ctx); err != nil {
+				t.Fatalf("couldn't copy client: %v", err)
+			}
+		}
+		go func() {
+			for range root.Rx {
+			}
+		}()
+
+		errs := make(chan error, nClients)
+		for i, c := range clients {
+			go func(i int, c *controller.Client) {
+				var err error
+				for j := 0; j < nRequests && err == nil; j++ {
+					err = sendAndCheckBroadcastFirst(ctx, c, fmt.Sprintf("c%d-%d", i, j))
+				}
+				// Hang up, taking broadcasts until the Controller notices.
+				close(c.Tx)
+				for range c.Rx {
+				}
+				errs <- err
+			}(i, c)
+		}
+		for range clients {
+			if err := <-errs; err != nil {
+				t.Error(err)
+			}
+		}
+	}
+	testWithController(&testState{}, f, t)
+}
+
+// TestController_BroadcastOwn tests that a broadcast is Own only for the client whose request caused it.
+func TestController_BroadcastOwn(t *testing.T) {
+	f := func(ctx context.Context, root *controller.Client, t *testing.T) {
+		other, err := root.Copy(ctx)
+		if err != nil {
+			t.Fatalf("couldn't copy client: %v", err)
+		}
+		othersOwn := make(chan bool, 1)
+		go func() {
+			for rs := range other.Rx {
+				if _, ok := rs.Body.(taggedBroadcastResponse); ok {
+					othersOwn <- rs.Own
+				}
+			}
+		}()
+
+		reply := make(chan controller.Response)
+		rq := controller.Request{
+			Origin: controller.RequestOrigin{Tag: "own", ReplyTx: reply},
+			Body:   taggedBroadcastRequest{ID: "own"},
+		}
+		tx, own, seen := root.Tx, false, false
+		for acked := false; !acked; {
+			select {
+			case tx <- rq:
+				tx = nil
+			case rs := <-root.Rx:
+				if _, ok := rs.Body.(taggedBroadcastResponse); ok {
+					own, seen = rs.Own, true
+				}
+			case rs := <-reply:
+				_, acked = rs.Body.(controller.DoneResponse)
+			case <-ctx.Done():
+				t.Fatal("timed out waiting for the acknowledgement")
+			}
+		}
+
+		if !seen {
+			t.Fatal("sender didn't get the broadcast")
+		}
+		if !own {
+			t.Error("sender got the broadcast without Own")
+		}
+		// The broadcast blocks until every client has it, so other has it by now.
+		if <-othersOwn {
+			t.Error("other client got the broadcast with Own")
+		}
+	}
+	testWithController(&testState{}, f, t)
+}
+
+// reloadState is a Controllable that reloads a single string setting, and dumps it.
+type reloadState struct {
+	testState
+
+	setting string
+}
+
+// settingResponse is reloadState's dump.
+type settingResponse struct {
+	Setting string
+}
+
+func (s *reloadState) Dump(dumpCb controller.ResponseCb) {
+	dumpCb(settingResponse{Setting: s.setting})
+}
+
+func (s *reloadState) Reload(settings interface{}) error {
+	setting, ok := settings.(string)
+	if !ok {
+		return fmt.Errorf("bad settings: %v", settings)
+	}
+	s.setting = setting
+	return nil
+}
+
+// TestController_Reload tests that a reload keeps clients attached, and tells them to resync with a fresh dump, but
+// that a refused reload broadcasts nothing.
+func TestController_Reload(t *testing.T) {
+	f := func(ctx context.Context, root *controller.Client, t *testing.T) {
+		watcher, err := root.Copy(ctx)
+		if err != nil {
+			t.Fatalf("couldn't copy client: %v", err)
+		}
+		go func() {
+			for range root.Rx {
+			}
+		}()
+
+		send := func(body interface{}) <-chan error {
+			errs := make(chan error, 1)
+			go func() {
+				cb := func(controller.Response) error { return fmt.Errorf("unexpected reply") }
+				_, err := root.SendAndProcessReplies(ctx, "", body, cb)
+				errs <- err
+			}()
+			return errs
+		}
+
+		errs := send(controller.ReloadRequest{Settings: "new"})
+		want := []interface{}{controller.ReloadedResponse{}, settingResponse{Setting: "new"}}
+		for i, w := range want {
+			if rs := <-watcher.Rx; !rs.Broadcast || !reflect.DeepEqual(rs.Body, w) {
+				t.Errorf("broadcast %d: got %+v, want broadcast %+v", i, rs, w)
+			}
+		}
+		if err := <-errs; err != nil {
+			t.Errorf("unexpected reload error: %v", err)
+		}
+
+		// The refused reload mustn't broadcast, so the next broadcast the watcher sees is the dummy's.
+		if err := <-send(controller.ReloadRequest{Settings: 42}); err == nil {
+			t.Error("expected an error reloading bad settings")
+		}
+		errs = send(knownDummyRequest{Broadcast: true})
+		if rs := <-watcher.Rx; !reflect.DeepEqual(rs.Body, knownDummyResponse{}) {
+			t.Errorf("got %+v after a refused reload, want the dummy broadcast", rs)
+		}
+		if err := <-errs; err != nil {
+			t.Errorf("unexpected error: %v", err)
+		}
+
+		close(watcher.Tx)
+		for range watcher.Rx {
+		}
+	}
+	testWithController(&reloadState{setting: "old"}, f, t)
+}
+
+// TestController_Reload_NotReloader tests that a Controller refuses to reload a state that isn't a Reloader.
+func TestController_Reload_NotReloader(t *testing.T) {
+	f := func(ctx context.Context, c *controller.Client, t *testing.T) {
+		cb := func(controller.Response) error { return fmt.Errorf("unexpected reply") }
+		_, err := c.SendAndProcessReplies(ctx, "", controller.ReloadRequest{Settings: "new"}, cb)
+		if !errors.Is(err, controller.ErrCannotReload) {
+			t.Errorf("got %v, want %v", err, controller.ErrCannotReload)
+		}
+	}
+	testWithController(&testState{}, f, t)
+}
+
+// TestController_Reply_Done tests that a Controller drops replies to a requester that has gone away, rather than
+// blocking on it, whether the requester goes before the Controller gets round to replying or while it waits.
+func TestController_Reply_Done(t *testing.T) {
+	f := func(ctx context.Context, c *controller.Client, t *testing.T) {
+		for i := 0; i < 100; i++ {
+			// Nobody ever reads this channel.
+			reply := make(chan controller.Response)
+			done := make(chan struct{})
+			rq := controller.Request{
+				Origin: controller.RequestOrigin{ReplyTx: reply, Done: done},
+				Body:   knownDummyRequest{},
+			}
+			if !c.Send(ctx, rq) {
+				t.Fatal("controller shut down before we could send test request")
+			}
+			if i%2 == 0 {
+				time.Sleep(time.Millisecond)
+			}
+			close(done)
+
+			if err := sendAndAck(ctx, c.Send, knownDummyRequest{}); err != nil {
+				t.Fatalf("request %d after a gone requester: %v", i, err)
+			}
+		}
+	}
+	testWithController(&testState{}, f, t)
+}
@@ -0,0 +1,57 @@
+package controller
+
+// File deadman.go contains the Controller's deadman switch, which takes a fallback action when nothing has changed its
+// state for too long, such as when an unattended show has got stuck; see SetDeadman.
+
+import "time"
+
+// DeadmanResponse announces that a Controller's deadman switch has fired; see Controller.SetDeadman.
+type DeadmanResponse struct {
+	// Idle is how long the Controller had gone without any state change.
+	Idle time.Duration
+	// Acted is true if the switch had a fallback action to take; if false, this is only an alert.
+	Acted bool
+	// Err, if non-nil, is the error that came from taking the fallback action.
+	Err error
+}
+
+// SetDeadman sets up c's deadman switch, which fires if c goes window without any change to its state.
+// When it fires, c handles action once, if non-nil, as if a client had sent it (except that any unicast replies are
+// dropped), then broadcasts a DeadmanResponse with the result; action must be a request c's state understands, such
+// as one selecting a fallback item.
+//
+// Changes to the state are what c broadcasts, so anything that makes c broadcast counts as activity and resets the
+// window, whether a client's request, a schedule firing, or the switch itself firing; requests that change nothing,
+// such as dumps, don't.
+// So, if nothing happens after the switch fires, it fires again one window later.
+// The switch doesn't fire while c is paused, and c checks it each time round its main loop, so it can fire up to a
+// heartbeat interval late (see SetHeartbeatInterval).
+//
+// The switch is off unless set; a non-positive window turns it off.
+// It must be called before Run.
+func (c *Controller) SetDeadman(window time.Duration, action interface{}) {
+	c.deadmanWindow = window
+	c.deadmanAction = action
+}
+
+// noteChange records that c's state changed, for the deadman switch.
+func (c *Controller) noteChange() {
+	c.lastChange = c.clock.Now()
+}
+
+// checkDeadman fires c's deadman switch, if it is on and c has gone its window without a change.
+func (c *Controller) checkDeadman() {
+	if c.deadmanWindow <= 0 || c.paused {
+		return
+	}
+	idle := c.clock.Now().Sub(c.lastChange)
+	if idle < c.deadmanWindow {
+		return
+	}
+
+	r := DeadmanResponse{Idle: idle, Acted: c.deadmanAction != nil}
+	if r.Acted {
+		r.Err = c.state.HandleRequest(func(interface{}) {}, c.broadcast, c.deadmanAction)
+	}
+	c.broadcast(r)
+}
@@ -0,0 +1,72 @@
+package controller_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/UniversityRadioYork/baps3d/controller"
+)
+
+// TestController_Deadman tests that the deadman switch takes its action once its window passes without a change,
+// that broadcasts reset the window, and that requests changing nothing don't.
+func TestController_Deadman(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	clk := &fakeClock{now: time.Date(2020, 1, 1, 12, 0, 0, 0, time.UTC)}
+	ctl, c := controller.NewController(&testState{})
+	ctl.SetClock(clk)
+	ctl.SetDeadman(time.Minute, knownDummyRequest{Broadcast: true})
+
+	done := make(chan struct{})
+	go func() {
+		ctl.Run(ctx)
+		close(done)
+	}()
+	bcasts := make(chan interface{}, 16)
+	go func() {
+		for r := range c.Rx {
+			bcasts <- r.Body
+		}
+	}()
+
+	// quiet checks that the switch hasn't fired, by waiting for a health check to pass through.
+	quiet := func(when string) {
+		t.Helper()
+		if err := c.CheckAlive(ctx); err != nil {
+			t.Fatalf("%s: unexpected error checking controller: %v", when, err)
+		}
+		if len(bcasts) != 0 {
+			t.Fatalf("%s: switch fired early: %+v", when, <-bcasts)
+		}
+	}
+
+	clk.Advance(30 * time.Second)
+	quiet("half way")
+	if err := sendAndAck(ctx, c.Send, taggedBroadcastRequest{ID: "x"}); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	nextBcast(t, bcasts)
+
+	// The broadcast reset the window; a request that changes nothing doesn't.
+	clk.Advance(45 * time.Second)
+	if err := sendAndAck(ctx, c.Send, knownDummyRequest{}); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	quiet("after the reset")
+
+	clk.Advance(15 * time.Second)
+	if _, ok := nextBcast(t, bcasts).(knownDummyResponse); !ok {
+		t.Fatal("fallback action didn't run")
+	}
+	if r, ok := nextBcast(t, bcasts).(controller.DeadmanResponse); !ok || r.Idle != time.Minute || !r.Acted || r.Err != nil {
+		t.Fatalf("got %+v, want a successful DeadmanResponse after a minute", r)
+	}
+	quiet("after firing")
+
+	if err := c.Shutdown(ctx); err != nil {
+		t.Errorf("couldn't shut down: %v", err)
+	}
+	<-done
+}
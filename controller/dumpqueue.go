@@ -0,0 +1,103 @@
+package controller
+
+// File dumpqueue.go contains the Controller's queue of dump requests, which stops a flood of dumps (such as when many
+// clients reconnect at once) from starving other requests; see SetDumpLimit.
+
+import (
+	"context"
+	"reflect"
+)
+
+// DefaultDumpLimit is the most dumps a Controller handles in a row while other requests wait, unless set with
+// SetDumpLimit.
+const DefaultDumpLimit = 4
+
+// queuedDump is a dump request waiting for its turn.
+type queuedDump struct {
+	// from is the client that sent the request.
+	from coclient
+	// rq is the request itself.
+	rq Request
+}
+
+// SetDumpLimit sets the most dumps c handles in a row while other requests are waiting; it defaults to
+// DefaultDumpLimit.
+//
+// c queues the DumpRequests its clients send, and handles them in the order they arrived, telling those that have to
+// wait for others with a DumpQueuedResponse.
+// Once c has handled n queued dumps in a row, it lets one other waiting request in, if there is one, before the next.
+// A client's requests still happen in order: c takes no more requests from a client until its queued dump is done.
+// Dumps inside batches don't queue.
+//
+// Non-positive limits are ignored.
+// It must be called before Run.
+func (c *Controller) SetDumpLimit(n int) {
+	if 0 < n {
+		c.dumpLimit = n
+	}
+}
+
+// queueDump queues the dump request rq, from client from, telling it if it has other dumps to wait for.
+func (c *Controller) queueDump(from coclient, rq Request) {
+	if 0 < len(c.dumps) {
+		c.reply(rq.Origin, DumpQueuedResponse{Ahead: len(c.dumps)})
+	}
+	c.dumps = append(c.dumps, queuedDump{from: from, rq: rq})
+	c.refreshClientCase(from)
+}
+
+// serveDumps handles the next queued dump, unless c has handled its limit of dumps in a row and another request is
+// waiting, in which case it handles that request instead; any dump it finds waiting joins the queue.
+func (c *Controller) serveDumps(ctx context.Context) {
+	if c.dumpLimit <= c.dumpRun {
+		n := len(c.dumps)
+		if c.pollRequest(ctx) {
+			c.noteIfNotDump(n)
+			return
+		}
+	}
+
+	d := c.dumps[0]
+	c.dumps = c.dumps[1:]
+	c.dumpRun++
+
+	c.handleRequest(ctx, d.rq)
+	c.refreshClientCase(d.from)
+}
+
+// noteIfNotDump ends c's run of dumps if the request it just handled wasn't a dump, given it had n dumps queued before.
+func (c *Controller) noteIfNotDump(n int) {
+	if len(c.dumps) == n {
+		c.dumpRun = 0
+	}
+}
+
+// pollRequest handles whatever c would next select, if anything is ready, without blocking.
+// It returns whether there was anything to handle.
+func (c *Controller) pollRequest(ctx context.Context) bool {
+	cases := append(c.cselects[:len(c.cselects):len(c.cselects)], reflect.SelectCase{Dir: reflect.SelectDefault})
+	i, value, open := reflect.Select(cases)
+	if i == len(c.cselects) {
+		return false
+	}
+	c.handleCase(ctx, i, value, open)
+	return true
+}
+
+// hasQueuedDump checks whether client cl has a dump in c's queue.
+func (c *Controller) hasQueuedDump(cl coclient) bool {
+	for _, d := range c.dumps {
+		if d.from == cl {
+			return true
+		}
+	}
+	return false
+}
+
+// failDumps refuses every dump c still has queued with ErrControllerShutDown.
+func (c *Controller) failDumps() {
+	for _, d := range c.dumps {
+		c.reply(d.rq.Origin, DoneResponse{ErrControllerShutDown})
+	}
+	c.dumps = nil
+}
@@ -0,0 +1,124 @@
+package controller_test
+
+import (
+	"context"
+	"reflect"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/UniversityRadioYork/baps3d/controller"
+)
+
+// dumpLoggingState is a testStateWithPriority that logs, in order, the dumps and dummy requests it handles.
+type dumpLoggingState struct {
+	testStateWithPriority
+
+	// log holds "dump" for each dump and "other" for each dummy request, in the order the state handled them.
+	log []string
+}
+
+func (s *dumpLoggingState) Dump(controller.ResponseCb) {
+	s.log = append(s.log, "dump")
+}
+
+func (s *dumpLoggingState) HandleRequest(replyCb, bcastCb controller.ResponseCb, rbody interface{}) error {
+	if _, ok := rbody.(knownDummyRequest); ok {
+		s.log = append(s.log, "other")
+		return nil
+	}
+	return s.testStateWithPriority.HandleRequest(replyCb, bcastCb, rbody)
+}
+
+// runDumpFlood sends each of bodies to a Controller over s, with dump limit 1, from its own client and all at once,
+// while the Controller is stuck on another request.
+// It returns the DumpQueuedResponses the requests got.
+func runDumpFlood(t *testing.T, s *dumpLoggingState, bodies ...interface{}) []controller.DumpQueuedResponse {
+	t.Helper()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	ctl, root := controller.NewController(s)
+	ctl.SetDumpLimit(1)
+	done := make(chan struct{})
+	go func() {
+		ctl.Run(ctx)
+		close(done)
+	}()
+
+	clients := make([]*controller.Client, len(bodies))
+	for i := range bodies {
+		var err error
+		if clients[i], err = root.Copy(ctx); err != nil {
+			t.Fatalf("couldn't copy client: %v", err)
+		}
+	}
+
+	heldErr := make(chan error, 1)
+	go func() { heldErr <- sendAndAck(ctx, root.Send, heldDummyRequest{}) }()
+	<-s.started
+
+	var (
+		mu     sync.Mutex
+		queued []controller.DumpQueuedResponse
+		wg     sync.WaitGroup
+	)
+	for i, body := range bodies {
+		wg.Add(1)
+		go func(c *controller.Client, body interface{}) {
+			defer wg.Done()
+			_, err := c.SendAndProcessReplies(ctx, "", body, func(r controller.Response) error {
+				if q, ok := r.Body.(controller.DumpQueuedResponse); ok {
+					mu.Lock()
+					queued = append(queued, q)
+					mu.Unlock()
+				}
+				return nil
+			})
+			if err != nil {
+				t.Errorf("%v: unexpected error: %v", body, err)
+			}
+		}(clients[i], body)
+	}
+	// There's no way to see that the requests are waiting, so give them a moment to get there.
+	time.Sleep(20 * time.Millisecond)
+
+	close(s.release)
+	if err := <-heldErr; err != nil {
+		t.Fatalf("held request: unexpected error: %v", err)
+	}
+	wg.Wait()
+
+	if err := root.Shutdown(ctx); err != nil {
+		t.Fatalf("couldn't shut down: %v", err)
+	}
+	<-done
+	return queued
+}
+
+// TestController_DumpLimit_Queued tests that, once a Controller reaches its dump limit, later dumps queue, with notice.
+func TestController_DumpLimit_Queued(t *testing.T) {
+	s := &dumpLoggingState{testStateWithPriority: testStateWithPriority{started: make(chan struct{}), release: make(chan struct{})}}
+	queued := runDumpFlood(t, s, controller.DumpRequest{}, controller.DumpRequest{}, controller.DumpRequest{})
+
+	// The first dump goes straight through, and the second starts the queue; only the third waits behind another.
+	if want := []controller.DumpQueuedResponse{{Ahead: 1}}; !reflect.DeepEqual(queued, want) {
+		t.Errorf("got queued notices %v, want %v", queued, want)
+	}
+	if want := []string{"dump", "dump", "dump"}; !reflect.DeepEqual(s.log, want) {
+		t.Errorf("got log %v, want %v", s.log, want)
+	}
+}
+
+// TestController_DumpLimit_LetsOthersIn tests that a Controller at its dump limit lets a waiting non-dump request in
+// before its next dump.
+func TestController_DumpLimit_LetsOthersIn(t *testing.T) {
+	s := &dumpLoggingState{testStateWithPriority: testStateWithPriority{started: make(chan struct{}), release: make(chan struct{})}}
+	runDumpFlood(t, s, controller.DumpRequest{}, controller.DumpRequest{}, knownDummyRequest{})
+
+	// However the Controller picks up the requests, the other request can't wait for both dumps.
+	if len(s.log) != 3 || s.log[2] != "dump" {
+		t.Errorf("got log %v, want the other request before the last dump", s.log)
+	}
+}
@@ -0,0 +1,30 @@
+package controller
+
+// File limits.go contains the limits that Bifrost parsers can report about their states, so that clients can find
+// them out at runtime.
+
+// Limit is one limit on what clients can ask of a server, such as the most items one request can carry.
+type Limit struct {
+	// Name is the name of the limit, as a single Bifrost word.
+	Name string
+	// Value is the effective value of the limit, as a single Bifrost word; 'none' means there is no such limit.
+	Value string
+}
+
+// LimitsParser is the interface of Bifrost parsers whose states have fixed limits on what requests can carry, for
+// clients that ask a server for its limits.
+// Parsers that don't implement it have nothing to report.
+type LimitsParser interface {
+	// Limits returns the parser's limits, in a fixed order.
+	// It may be called from any goroutine, so it mustn't touch the parser's state.
+	Limits() []Limit
+}
+
+// Limits gets the limits of b's parser; see LimitsParser.
+// It returns nil if b's parser isn't a LimitsParser.
+func (b *Bifrost) Limits() []Limit {
+	if lp, ok := b.parser.(LimitsParser); ok {
+		return lp.Limits()
+	}
+	return nil
+}
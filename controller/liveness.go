@@ -0,0 +1,74 @@
+package controller
+
+// File liveness.go contains the Controller's lock-free liveness observation; see LastActivity and Alive.
+// Unlike Client.CheckAlive, these don't send the Controller anything, so they are safe for watchdogs to call as often
+// as they like, and answer even if the Controller is wedged.
+
+import (
+	"reflect"
+	"sync/atomic"
+	"time"
+)
+
+// DefaultHeartbeatInterval is how often an idle Controller wakes up to mark itself active, unless set with
+// SetHeartbeatInterval.
+const DefaultHeartbeatInterval = time.Second
+
+// stallFactor is how many heartbeat intervals a Controller can go without activity before Alive considers it stalled.
+const stallFactor = 3
+
+// SetHeartbeatInterval sets how often c, if idle, wakes up to mark itself active; it defaults to
+// DefaultHeartbeatInterval.
+// Non-positive intervals are ignored.
+// It must be called before Run.
+func (c *Controller) SetHeartbeatInterval(d time.Duration) {
+	if 0 < d {
+		c.heartbeatInterval = d
+	}
+}
+
+// LastActivity gets the time, according to c's Clock, at which c last went round its main loop.
+// This is the zero time if c has never run.
+// An idle Controller still goes round every heartbeat interval (see SetHeartbeatInterval), so this only falls behind
+// if c has stopped, or is stuck handling a request (for example, waiting for a client that won't take a response).
+// It is safe to call from any goroutine, and never blocks.
+func (c *Controller) LastActivity() time.Time {
+	ns := atomic.LoadInt64(&c.lastActivity)
+	if ns == 0 {
+		return time.Time{}
+	}
+	return time.Unix(0, ns)
+}
+
+// Alive checks whether c's Run loop is running and has been active within the last three heartbeat intervals.
+// It is safe to call from any goroutine, and never blocks.
+func (c *Controller) Alive() bool {
+	if atomic.LoadInt32(&c.alive) == 0 {
+		return false
+	}
+	return c.clock.Now().Sub(c.LastActivity()) <= stallFactor*c.heartbeatInterval
+}
+
+// markActive records that c is going round its main loop now.
+func (c *Controller) markActive() {
+	atomic.StoreInt64(&c.lastActivity, c.clock.Now().UnixNano())
+}
+
+// setAlive records whether c's Run loop is running.
+func (c *Controller) setAlive(alive bool) {
+	var v int32
+	if alive {
+		v = 1
+	}
+	atomic.StoreInt32(&c.alive, v)
+}
+
+// heartbeatCase gets the select case for c's heartbeat ticker.
+// If there is no ticker, as before Run, the case never fires.
+func (c *Controller) heartbeatCase() reflect.SelectCase {
+	var ch <-chan time.Time
+	if c.heartbeat != nil {
+		ch = c.heartbeat.C()
+	}
+	return reflect.SelectCase{Dir: reflect.SelectRecv, Chan: reflect.ValueOf(ch)}
+}
@@ -0,0 +1,77 @@
+package controller_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/UniversityRadioYork/baps3d/controller"
+)
+
+// waitForActivity waits until ctl's LastActivity is want, failing if it doesn't get there soon.
+func waitForActivity(t *testing.T, ctl *controller.Controller, want time.Time) {
+	t.Helper()
+
+	deadline := time.Now().Add(time.Second)
+	for !ctl.LastActivity().Equal(want) {
+		if deadline.Before(time.Now()) {
+			t.Fatalf("last activity is %v, want %v", ctl.LastActivity(), want)
+		}
+		time.Sleep(time.Millisecond)
+	}
+}
+
+// TestController_Liveness tests that a Controller's LastActivity keeps up through idle heartbeats, and that Alive
+// notices when the Controller is stuck on a request or has stopped.
+func TestController_Liveness(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	s := &testStateWithPriority{started: make(chan struct{}), release: make(chan struct{})}
+	start := time.Date(2020, 1, 1, 12, 0, 0, 0, time.UTC)
+	clk := &fakeClock{now: start}
+	ctl, c := controller.NewController(s)
+	ctl.SetClock(clk)
+	ctl.SetHeartbeatInterval(time.Second)
+
+	if ctl.Alive() || !ctl.LastActivity().IsZero() {
+		t.Fatalf("before Run: got alive %v at %v, want not alive at zero time", ctl.Alive(), ctl.LastActivity())
+	}
+
+	done := make(chan struct{})
+	go func() {
+		ctl.Run(ctx)
+		close(done)
+	}()
+
+	waitForActivity(t, ctl, start)
+	clk.Advance(2 * time.Second)
+	waitForActivity(t, ctl, start.Add(2*time.Second))
+	if !ctl.Alive() {
+		t.Error("idle controller: not alive, want alive")
+	}
+
+	heldErr := make(chan error, 1)
+	go func() { heldErr <- sendAndAck(ctx, c.Send, heldDummyRequest{}) }()
+	<-s.started
+	clk.Advance(4 * time.Second)
+	if ctl.Alive() {
+		t.Error("stuck controller: alive, want not alive")
+	}
+	close(s.release)
+	if err := <-heldErr; err != nil {
+		t.Fatalf("held request: unexpected error: %v", err)
+	}
+	waitForActivity(t, ctl, start.Add(6*time.Second))
+	if !ctl.Alive() {
+		t.Error("unstuck controller: not alive, want alive")
+	}
+
+	if err := c.Shutdown(ctx); err != nil {
+		t.Fatalf("couldn't shut down: %v", err)
+	}
+	<-done
+	if ctl.Alive() {
+		t.Error("stopped controller: alive, want not alive")
+	}
+}
@@ -0,0 +1,67 @@
+package controller
+
+// File message.go contains helpers for building Bifrost messages in emitters.
+//
+// Message itself lives in bifrost-go, so these are functions over it rather than methods.
+// Message.AddArgs already appends arguments in place (and works on the zero Message), so there is no AddArg here.
+//
+// It also contains the canonical formats of times and durations in message arguments.
+// These never depend on the server's time zone or locale, so clients anywhere can parse them the same way.
+
+import (
+	"fmt"
+	"strconv"
+	"time"
+
+	"github.com/UniversityRadioYork/bifrost-go/message"
+)
+
+// TimeLayout is the layout of times in Bifrost messages: RFC 3339, in UTC, to the millisecond.
+// Every time formatted with it has the same width.
+const TimeLayout = "2006-01-02T15:04:05.000Z07:00"
+
+// NewMessage creates a message with tag tag, word word, and arguments args.
+// It returns the message by value, ready to send down a message channel.
+// The message takes a copy of args, so the caller may reuse the slice.
+func NewMessage(tag, word string, args ...string) message.Message {
+	m := message.New(tag, word)
+	if 0 < len(args) {
+		m.AddArgs(args...)
+	}
+	return *m
+}
+
+// FormatTime formats t as a message argument, in UTC, using TimeLayout.
+func FormatTime(t time.Time) string {
+	return t.UTC().Format(TimeLayout)
+}
+
+// ParseTime tries to parse a time given as a message argument.
+// It accepts any RFC 3339 time, with or without fractional seconds, not just those in TimeLayout.
+func ParseTime(s string) (time.Time, error) {
+	t, err := time.Parse(time.RFC3339, s)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("bad time: %w", err)
+	}
+	return t, nil
+}
+
+// FormatMillis formats d as a message argument: a whole number of milliseconds.
+func FormatMillis(d time.Duration) string {
+	return strconv.FormatInt(int64(d/time.Millisecond), 10)
+}
+
+// ParseMillis tries to parse a duration given as a message argument: a non-negative whole number of milliseconds.
+func ParseMillis(s string) (time.Duration, error) {
+	ms, err := strconv.ParseUint(s, 10, 32)
+	if err != nil {
+		return 0, fmt.Errorf("bad duration: %w", err)
+	}
+	return time.Duration(ms) * time.Millisecond, nil
+}
+
+// ArgCount gets the number of arguments in m.
+// The zero Message has no arguments.
+func ArgCount(m message.Message) int {
+	return len(m.Args())
+}
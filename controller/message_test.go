@@ -0,0 +1,95 @@
+package controller_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/UniversityRadioYork/bifrost-go/message"
+
+	"github.com/UniversityRadioYork/baps3d/controller"
+)
+
+// TestNewMessage tests that NewMessage builds the same message as New followed by AddArgs.
+func TestNewMessage(t *testing.T) {
+	cases := []struct {
+		args []string
+		want string
+	}{
+		{nil, "t1 SEL\n"},
+		{[]string{"0", "a b"}, "t1 SEL 0 'a b'\n"},
+	}
+
+	for _, c := range cases {
+		m := controller.NewMessage("t1", "SEL", c.args...)
+		if got := m.String(); got != c.want {
+			t.Errorf("%v: got %q, want %q", c.args, got, c.want)
+		}
+		if got := controller.ArgCount(m); got != len(c.args) {
+			t.Errorf("%v: got %d arguments, want %d", c.args, got, len(c.args))
+		}
+	}
+}
+
+// TestNewMessage_Copy tests that NewMessage doesn't alias its argument slice.
+func TestNewMessage_Copy(t *testing.T) {
+	args := []string{"0", "a"}
+	m := controller.NewMessage("t1", "SEL", args...)
+	args[1] = "b"
+	if got := m.Args()[1]; got != "a" {
+		t.Errorf("message changed with its argument slice: got %q, want %q", got, "a")
+	}
+}
+
+// TestArgCount_Zero tests that ArgCount, and AddArgs, work on the zero Message.
+func TestArgCount_Zero(t *testing.T) {
+	var m message.Message
+	if got := controller.ArgCount(m); got != 0 {
+		t.Fatalf("got %d arguments on the zero Message, want 0", got)
+	}
+	m.AddArgs("x")
+	if got := controller.ArgCount(m); got != 1 {
+		t.Errorf("got %d arguments after AddArgs, want 1", got)
+	}
+}
+
+// TestFormatTime tests that FormatTime gives the same fixed-width UTC string for an instant, whatever its time zone.
+func TestFormatTime(t *testing.T) {
+	at := time.Date(2020, 2, 3, 4, 5, 6, 7000000, time.UTC)
+	zones := []*time.Location{time.UTC, time.FixedZone("east", 5*60*60+30*60), time.FixedZone("west", -8*60*60)}
+
+	const want = "2020-02-03T04:05:06.007Z"
+	for _, z := range zones {
+		if got := controller.FormatTime(at.In(z)); got != want {
+			t.Errorf("%s: got %q, want %q", z, got, want)
+		}
+	}
+	if got := controller.FormatTime(at.Truncate(time.Second)); got != "2020-02-03T04:05:06.000Z" {
+		t.Errorf("whole second: got %q, want trailing zeroes kept", got)
+	}
+
+	back, err := controller.ParseTime(want)
+	if err != nil {
+		t.Fatalf("unexpected parse error: %v", err)
+	}
+	if !back.Equal(at) {
+		t.Errorf("round trip: got %v, want %v", back, at)
+	}
+}
+
+// TestMillis tests that durations round-trip through FormatMillis and ParseMillis, and that bad ones don't parse.
+func TestMillis(t *testing.T) {
+	d := 90*time.Second + 250*time.Millisecond
+	s := controller.FormatMillis(d)
+	if s != "90250" {
+		t.Errorf("got %q, want %q", s, "90250")
+	}
+	if back, err := controller.ParseMillis(s); err != nil || back != d {
+		t.Errorf("round trip: got %v (%v), want %v", back, err, d)
+	}
+
+	for _, bad := range []string{"", "-5", "1.5", "1,000", "1e3"} {
+		if _, err := controller.ParseMillis(bad); err == nil {
+			t.Errorf("%q: expected an error", bad)
+		}
+	}
+}
@@ -0,0 +1,100 @@
+package controller
+
+// File pause.go contains the Controller's pausing, during which it holds requests rather than handle them; see
+// PauseRequest.
+
+import (
+	"context"
+	"errors"
+)
+
+// DefaultPauseQueueLen is the number of requests a paused Controller holds, unless set with SetPauseQueueLen.
+const DefaultPauseQueueLen = 64
+
+// ErrPauseQueueFull is the error sent when a Controller is paused, and already holding as many requests as it can.
+var ErrPauseQueueFull = errors.New("controller is paused, and can't hold any more requests")
+
+// heldRequest is a request that arrived while the Controller was paused.
+type heldRequest struct {
+	// from is the client that sent the request, or the zero coclient if it came through the priority channel.
+	from coclient
+	// rq is the request itself.
+	rq Request
+}
+
+// SetPauseQueueLen sets the number of requests c holds while paused; it defaults to DefaultPauseQueueLen.
+// It must be called before Run.
+func (c *Controller) SetPauseQueueLen(n int) {
+	c.pauseQueueLen = n
+}
+
+// runsWhilePaused checks whether a request with body rbody is handled as usual while the Controller is paused.
+// These are the requests that don't touch the Controller's state.
+func runsWhilePaused(rbody interface{}) bool {
+	switch rbody.(type) {
+	case PauseRequest, ResumeRequest, PingRequest, healthRequest, shutdownRequest:
+		return true
+	}
+	return false
+}
+
+// hold holds rq, from client from, until c resumes.
+// If c is already holding as many requests as it can, it refuses rq with ErrPauseQueueFull instead.
+func (c *Controller) hold(from coclient, rq Request) {
+	if c.pauseQueueLen <= len(c.held) {
+		c.reply(rq.Origin, DoneResponse{ErrPauseQueueFull})
+		return
+	}
+	c.held = append(c.held, heldRequest{from: from, rq: rq})
+}
+
+// handleHeld handles the earliest request c held while paused, crediting any broadcasts to the client that sent it.
+func (c *Controller) handleHeld(ctx context.Context) {
+	h := c.held[0]
+	c.held = c.held[1:]
+
+	c.origin = h.from
+	c.handleClientRequest(ctx, h.rq)
+	c.origin = coclient{}
+}
+
+// failHeld refuses every request c is still holding with ErrControllerShutDown.
+func (c *Controller) failHeld() {
+	for _, h := range c.held {
+		c.reply(h.rq.Origin, DoneResponse{ErrControllerShutDown})
+	}
+	c.held = nil
+}
+
+// handlePauseRequest pauses c, if it isn't already paused.
+func (c *Controller) handlePauseRequest() error {
+	if c.paused {
+		return nil
+	}
+	c.paused = true
+
+	// Schedules touch the state too, so they wait until the Controller resumes.
+	if c.timer != nil {
+		c.timer.Stop()
+		c.timer = nil
+	}
+	c.cselects[len(c.cselects)-1] = c.timerCase()
+
+	c.broadcast(PausedResponse{})
+	return nil
+}
+
+// handleResumeRequest resumes c, if it is paused.
+// c handles the requests it held on its way back round its main loop.
+func (c *Controller) handleResumeRequest() error {
+	if !c.paused {
+		return nil
+	}
+	c.paused = false
+
+	// Any schedules that fell due during the pause fire as soon as the Controller gets round to its timer.
+	c.rearm()
+
+	c.broadcast(ResumedResponse{Held: len(c.held)})
+	return nil
+}
@@ -0,0 +1,126 @@
+package controller_test
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/UniversityRadioYork/baps3d/controller"
+)
+
+// runPausable starts a Controller over s, holding at most two requests while paused.
+// It returns the Controller's root client, a channel carrying the bodies of the root client's broadcasts, and a channel
+// that closes once the Controller stops.
+func runPausable(ctx context.Context, s controller.Controllable) (*controller.Client, <-chan interface{}, <-chan struct{}) {
+	ctl, root := controller.NewController(s)
+	ctl.SetPauseQueueLen(2)
+
+	done := make(chan struct{})
+	go func() {
+		ctl.Run(ctx)
+		close(done)
+	}()
+
+	bcasts := make(chan interface{}, 16)
+	go func() {
+		for rs := range root.Rx {
+			bcasts <- rs.Body
+		}
+	}()
+	return root, bcasts, done
+}
+
+// sendHeld sends body through c, expecting the Controller to take it, and returns the channel its replies come on.
+func sendHeld(ctx context.Context, t *testing.T, c *controller.Client, body interface{}) <-chan controller.Response {
+	t.Helper()
+
+	reply := make(chan controller.Response)
+	if !c.Send(ctx, controller.Request{Origin: controller.RequestOrigin{ReplyTx: reply}, Body: body}) {
+		t.Fatal("controller shut down")
+	}
+	return reply
+}
+
+// TestController_Pause tests that a paused Controller holds requests up to its bound, refuses any more, still answers
+// pings, and handles the held requests in order once it resumes.
+func TestController_Pause(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	s := &testStateWithPriority{}
+	root, bcasts, done := runPausable(ctx, s)
+
+	if err := root.Pause(ctx); err != nil {
+		t.Fatalf("couldn't pause: %v", err)
+	}
+	if b := <-bcasts; !reflect.DeepEqual(b, controller.PausedResponse{}) {
+		t.Fatalf("got broadcast %+v, want PausedResponse", b)
+	}
+
+	first := sendHeld(ctx, t, root, knownDummyRequest{Broadcast: false})
+	second := sendHeld(ctx, t, root, knownDummyRequest{Broadcast: true})
+	overflow := sendHeld(ctx, t, root, knownDummyRequest{})
+	noop := func(controller.Response) error { return nil }
+	if err := controller.ProcessRepliesUntilAck(overflow, noop); !errors.Is(err, controller.ErrPauseQueueFull) {
+		t.Errorf("request past the bound: got error %v, want ErrPauseQueueFull", err)
+	}
+
+	if err := sendAndAck(ctx, root.Send, controller.PingRequest{}); err != nil {
+		t.Errorf("ping while paused: unexpected error: %v", err)
+	}
+	if len(s.handled) != 0 {
+		t.Fatalf("state handled %v while paused", s.handled)
+	}
+
+	if err := root.Resume(ctx); err != nil {
+		t.Fatalf("couldn't resume: %v", err)
+	}
+	if b := <-bcasts; !reflect.DeepEqual(b, controller.ResumedResponse{Held: 2}) {
+		t.Errorf("got broadcast %+v, want ResumedResponse with 2 held", b)
+	}
+	for i, reply := range []<-chan controller.Response{first, second} {
+		if err := controller.ProcessRepliesUntilAck(reply, noop); err != nil {
+			t.Errorf("held request %d: unexpected error: %v", i, err)
+		}
+	}
+	if want := []knownDummyRequest{{Broadcast: false}, {Broadcast: true}}; !reflect.DeepEqual(s.handled, want) {
+		t.Errorf("handled %v, want %v", s.handled, want)
+	}
+
+	if err := root.Shutdown(ctx); err != nil {
+		t.Fatalf("couldn't shut down: %v", err)
+	}
+	<-done
+}
+
+// TestController_Pause_Shutdown tests that a Controller shutting down while paused fails the requests it held.
+func TestController_Pause_Shutdown(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	s := &testStateWithPriority{}
+	root, _, done := runPausable(ctx, s)
+
+	if err := root.Pause(ctx); err != nil {
+		t.Fatalf("couldn't pause: %v", err)
+	}
+	held := sendHeld(ctx, t, root, knownDummyRequest{})
+
+	shut := make(chan error, 1)
+	go func() {
+		shut <- root.Shutdown(ctx)
+	}()
+	err := controller.ProcessRepliesUntilAck(held, func(controller.Response) error { return nil })
+	if !errors.Is(err, controller.ErrControllerShutDown) {
+		t.Errorf("held request: got error %v, want ErrControllerShutDown", err)
+	}
+	if err := <-shut; err != nil {
+		t.Errorf("couldn't shut down: %v", err)
+	}
+	<-done
+	if len(s.handled) != 0 {
+		t.Errorf("state handled %v, despite the pause", s.handled)
+	}
+}
@@ -0,0 +1,38 @@
+package controller
+
+// File playstate.go contains play-state-only mode, a narrower relative of selection-only mode in which Bifrost
+// adapters send their clients nothing from their parsers but notifications of whether the selection is cued, playing,
+// or finished.
+
+import "github.com/UniversityRadioYork/bifrost-go/message"
+
+// PlayStateEmitter is the interface of things that boil responses down to notifications of the selection's play state.
+// Working out the play state can take remembering earlier responses, so each adapter has its own.
+type PlayStateEmitter interface {
+	// EmitBifrostPlayState emits into msgTx, for tag, the notification of the selection's play state that the
+	// response body rbody, given the responses before it, leads to, if it leads to one.
+	// It emits nothing for responses that don't bear on the play state.
+	EmitBifrostPlayState(tag string, rbody interface{}, msgTx chan<- message.Message) error
+}
+
+// PlayStateParser is the interface of Bifrost parsers that can make PlayStateEmitters, for adapters with
+// PlayStateOnly set.
+// Adapters over parsers that don't implement it send play-state-only clients nothing from the parser at all.
+type PlayStateParser interface {
+	// NewPlayStateEmitter makes a PlayStateEmitter for one adapter, which feeds it every response it sends on.
+	NewPlayStateEmitter() PlayStateEmitter
+}
+
+// emitPlayState converts the response body rbody, for tag t, into a play state notification on msgTx, if it leads to
+// one, using b's play state emitter.
+// b sends responses on one at a time, so the emitter never sees two at once.
+func (b *Bifrost) emitPlayState(t string, rbody interface{}, msgTx chan<- message.Message) error {
+	if b.playState == nil {
+		pp, ok := b.parser.(PlayStateParser)
+		if !ok {
+			return nil
+		}
+		b.playState = pp.NewPlayStateEmitter()
+	}
+	return b.playState.EmitBifrostPlayState(t, rbody, msgTx)
+}
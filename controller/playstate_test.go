@@ -0,0 +1,105 @@
+package controller_test
+
+import (
+	"context"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/UniversityRadioYork/bifrost-go/message"
+
+	"github.com/UniversityRadioYork/baps3d/controller"
+	"github.com/UniversityRadioYork/baps3d/list"
+)
+
+// TestBifrost_PlayStateOnly tests that a play-state-only adapter sends PLAYSTATE notifications in place of its
+// parser's usual messages, only when the play state changes, following the selection across a move while playing,
+// and gives the whole play state in one message in each dump, including the one in the handshake.
+func TestBifrost_PlayStateOnly(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	l := list.New()
+	for i, h := range []string{"a", "b"} {
+		if err := l.Add(list.NewTrack(h, h+".mp3"), i); err != nil {
+			t.Fatalf("couldn't add %q: %v", h, err)
+		}
+	}
+	ctl, root := controller.NewController(l)
+	go ctl.Run(ctx)
+	go func() {
+		for range root.Rx {
+		}
+	}()
+
+	c, err := root.Copy(ctx)
+	if err != nil {
+		t.Fatalf("couldn't copy client: %v", err)
+	}
+	bf, sep, err := c.Bifrost(ctx)
+	if err != nil {
+		t.Fatalf("couldn't get Bifrost adapter: %v", err)
+	}
+	bf.PlayStateOnly = true
+	go bf.Run(ctx)
+	readMessages(t, sep, 2)
+	if got := string(readMessages(t, sep, 1)[0]); got != "! PLAYSTATE none\n" {
+		t.Errorf("handshake dump: got %q, want only the PLAYSTATE", got)
+	}
+
+	go func() {
+		for _, m := range []*message.Message{
+			message.New("t", "sel").AddArgs("0", "a"),
+			message.New("t", "playing").AddArgs("on"),
+			message.New("t", "played").AddArgs("0", "a"),
+			message.New("t", "next"),
+			message.New("t", "dur").AddArgs("1", "b", "1000"),
+			message.New("t", "dump"),
+		} {
+			sep.Tx <- *m
+		}
+	}()
+
+	var got []string
+	for _, bs := range readMessages(t, sep, 11) {
+		got = append(got, string(bs))
+	}
+	ack := "t ACK OK success\n"
+	want := []string{
+		"! PLAYSTATE cued a\n", ack,
+		"! PLAYSTATE playing a\n", ack,
+		"! PLAYSTATE finished a\n", ack,
+		"! PLAYSTATE playing b\n", ack,
+		ack,
+		"t PLAYSTATE playing b\n", ack,
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("got %q, want %q", got, want)
+	}
+
+	// A client connecting now learns the play state from its handshake dump, which gives the selection before
+	// whether it is playing, in one PLAYSTATE; the next thing it hears is the reply to its first request.
+	c2, err := root.Copy(ctx)
+	if err != nil {
+		t.Fatalf("couldn't copy client: %v", err)
+	}
+	bf2, sep2, err := c2.Bifrost(ctx)
+	if err != nil {
+		t.Fatalf("couldn't get Bifrost adapter: %v", err)
+	}
+	bf2.PlayStateOnly = true
+	go bf2.Run(ctx)
+	readMessages(t, sep2, 2)
+	go func() { sep2.Tx <- *message.New("t2", "dur").AddArgs("1", "b", "2000") }()
+	got = nil
+	for _, bs := range readMessages(t, sep2, 2) {
+		got = append(got, string(bs))
+	}
+	if want := []string{"! PLAYSTATE playing b\n", "t2 ACK OK success\n"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("second handshake dump: got %q, want %q", got, want)
+	}
+
+	if err := root.Shutdown(ctx); err != nil {
+		t.Fatalf("couldn't shut down: %v", err)
+	}
+}
@@ -2,13 +2,24 @@ package controller
 
 // File request.go contains the high-level Request type, and request bodies common to all Controllers.
 
+import "time"
+
 // RequestOrigin is the structure identifying where a request originated.
 type RequestOrigin struct {
 	// Tag is a string used to identify this request, if any.
 	Tag string
 
 	// ReplyTx is the channel any unicast responses will be sent down.
+	// Requesters must never close it, even once they stop listening: sending on a closed channel panics, and the
+	// Controller can't tell a closed channel from an open one without sending on it.
 	ReplyTx chan<- Response
+
+	// Done, if non-nil, closes when the requester stops listening for responses on ReplyTx, for instance because it
+	// gave up waiting or disconnected.
+	// The Controller drops any response it would then send, rather than block on a requester that has gone away.
+	// The request itself still goes ahead.
+	// If nil, the Controller assumes the requester listens until the ack.
+	Done <-chan struct{}
 }
 
 // Request is the base structure for requests to a Controller.
@@ -40,6 +51,72 @@ type OnRequest struct {
 // It will result in a RoleResponse reply.
 type RoleRequest struct{}
 
+// ScheduleRequest requests that the Controller handle a request at a given wall-clock time.
+// The Controller broadcasts a ScheduleResponse, carrying the schedule's ID.
+// When the time comes, as measured by the Controller's Clock, the Controller handles Body once, as if a client had
+// sent it (except that any unicast replies are dropped), then broadcasts a ScheduleFiredResponse with the result.
+type ScheduleRequest struct {
+	// At is the time at which to handle Body.
+	At time.Time
+	// Body is the body of the request to handle.
+	// It must be a request the Controller's state understands.
+	Body interface{}
+	// Label describes Body, for reporting.
+	Label string
+	// FireIfPast, if true, makes a schedule whose time has already come fire straight away.
+	// Otherwise, scheduling a time that isn't in the future fails with ErrScheduleInPast.
+	FireIfPast bool
+}
+
+// CancelScheduleRequest requests that the Controller cancel a pending schedule.
+// The Controller broadcasts a ScheduleCancelledResponse if it does.
+type CancelScheduleRequest struct {
+	// ID is the ID of the schedule to cancel.
+	ID uint64
+}
+
+// ListSchedulesRequest requests the Controller's pending schedules.
+// It results in a ScheduleResponse reply for each, earliest first.
+type ListSchedulesRequest struct{}
+
+// PingRequest checks that the Controller is responsive, rather than just its clients' connections.
+// The Controller replies straight away with a PongResponse, without touching its state.
+type PingRequest struct {
+	// Token, which may be empty, is echoed verbatim in the PongResponse.
+	Token string
+}
+
+// ReloadRequest asks the Controller to give its state new settings in place, without restarting or hanging up any
+// clients; the Controller's state must be a Reloader.
+//
+// Only the settings change.
+// The state keeps everything else it would dump, and the Controller keeps its clients and pending schedules.
+// Once the state has taken the settings, the Controller broadcasts a ReloadedResponse, then its state's full dump, so
+// that every client can resync; if the state refuses them, nothing changes, and nothing is broadcast.
+type ReloadRequest struct {
+	// Settings are the new settings, in whatever form the Controller's state takes them.
+	Settings interface{}
+}
+
+// PauseRequest asks the Controller to stop handling requests that touch its state, without hanging up any clients,
+// so that something else can safely change the state directly; ResumeRequest undoes it.
+// It is for Go programs embedding the Controller: Bifrost clients can't send it.
+// See Client.Pause, which is the usual way to send one.
+//
+// Once it acknowledges the request, the Controller doesn't touch its state until it resumes.
+// It broadcasts a PausedResponse, then holds every request other than pauses, resumes, pings, health checks, and
+// shutdowns, whether normal or priority, in the order they arrive.
+// It holds at most a bounded number (see SetPauseQueueLen); once it is holding that many, it refuses any more with
+// ErrPauseQueueFull rather than holding them.
+// Schedules that fall due during the pause fire after it.
+// Pausing an already paused Controller does nothing.
+type PauseRequest struct{}
+
+// ResumeRequest asks a paused Controller to resume; see PauseRequest.
+// The Controller broadcasts a ResumedResponse, then handles the requests it held.
+// Resuming a Controller that isn't paused does nothing.
+type ResumeRequest struct{}
+
 //
 // Internal request bodies
 //
@@ -57,6 +134,26 @@ type newClientRequest struct{}
 // This is kept private because clients should instead call Client.Shutdown.
 type shutdownRequest struct{}
 
+// healthRequest checks that the Controller is processing requests.
+// The Controller does nothing with it other than acknowledge it.
+//
+// This is kept private because clients should instead call Client.CheckAlive.
+type healthRequest struct{}
+
+// batchRequest carries several requests from one client.
+// The Controller handles them in order, as if each had arrived on its own, but without going back round its main
+// loop in between; each still gets its own replies and acknowledgement.
+// The batch itself isn't acknowledged, and may not be sent as a priority request.
+//
+// This is kept private because clients should instead call Bifrost.SendBatch.
+type batchRequest []Request
+
+// badRequest stands in, within a batchRequest, for a message that couldn't be parsed.
+// The Controller acknowledges it with err, so that the error keeps its place among the batch's replies.
+type badRequest struct {
+	err error
+}
+
 // bifrostParserRequest requests a BifrostParser for the Controller.
 // If the Controller's internal state understands Bifrost messages, it will send a bifrostParserResponse.
 //
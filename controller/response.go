@@ -1,6 +1,10 @@
 package controller
 
-import "github.com/UniversityRadioYork/bifrost-go/comm"
+import (
+	"time"
+
+	"github.com/UniversityRadioYork/bifrost-go/comm"
+)
 
 // File response.go contains the high-level Response type, and response bodies common to all Controllers.
 
@@ -9,6 +13,11 @@ type Response struct {
 	// Broadcast gives whether this is a broadcast response.
 	Broadcast bool
 
+	// Own, if 'Broadcast' is true, gives whether the broadcast came from a request sent by the client receiving it.
+	// It says nothing about which other client sent the request, if any.
+	// Broadcasts caused by priority requests and schedules are never Own.
+	Own bool
+
 	// Origin, if 'Broadcast' is false, gives the original request's RequestOrigin.
 	// Else, it is nil.
 	Origin *RequestOrigin
@@ -35,6 +44,63 @@ type OnResponse struct {
 	Request Response
 }
 
+// ScheduleResponse announces a pending schedule; see ScheduleRequest.
+type ScheduleResponse struct {
+	// ID identifies the schedule.
+	ID uint64
+	// At is the time at which the schedule fires.
+	At time.Time
+	// Label describes the schedule's request.
+	Label string
+}
+
+// ScheduleFiredResponse announces that a schedule has fired, and its request has been handled.
+type ScheduleFiredResponse struct {
+	// ID identifies the schedule.
+	ID uint64
+	// At is the time at which the schedule was due to fire.
+	At time.Time
+	// Label describes the schedule's request.
+	Label string
+	// Err, if non-nil, is the error that came from handling the schedule's request.
+	Err error
+}
+
+// ScheduleCancelledResponse announces that a pending schedule has been cancelled.
+type ScheduleCancelledResponse struct {
+	// ID identifies the schedule.
+	ID uint64
+}
+
+// PongResponse answers a PingRequest.
+type PongResponse struct {
+	// Token is the PingRequest's token.
+	Token string
+	// Time is the time at which the Controller handled the PingRequest, as measured by its Clock.
+	Time time.Time
+}
+
+// ReloadedResponse announces that the Controller's state has reloaded its settings; see ReloadRequest.
+// A full dump of the state follows it, as broadcasts, and clients should resync from that.
+type ReloadedResponse struct{}
+
+// PausedResponse announces that the Controller has paused; see PauseRequest.
+// Requests sent from now on don't get their replies until the Controller resumes.
+type PausedResponse struct{}
+
+// ResumedResponse announces that the Controller has resumed after a pause; see ResumeRequest.
+type ResumedResponse struct {
+	// Held is the number of requests the Controller held during the pause, which it is about to handle.
+	Held int
+}
+
+// DumpQueuedResponse tells a client that its DumpRequest is waiting behind others; see Controller.SetDumpLimit.
+// The dump, and its acknowledgement, follow once its turn comes.
+type DumpQueuedResponse struct {
+	// Ahead is the number of dumps queued ahead of this one.
+	Ahead int
+}
+
 //
 // Internal response bodies
 //
@@ -0,0 +1,39 @@
+package controller
+
+// File resync.go contains resyncing, the one place in which a Controller replays its state's full current state to
+// a single client, for clients that have missed broadcasts or have only just started listening.
+
+import "context"
+
+// resync sends the full dump of c's state to the request origin o alone, as unicast replies.
+//
+// A Controller handles one request at a time, and the state dumps itself in one go, so the dump is a consistent
+// snapshot: no other request can change the state part way through, and no broadcast can come in between.
+// Every dump, including the one in each Bifrost handshake, goes through here.
+func (c *Controller) resync(o RequestOrigin) {
+	c.state.Dump(func(rbody interface{}) {
+		c.reply(o, rbody)
+	})
+}
+
+// Resync asks c's Controller to replay its state's full current state to c alone, feeding each part of it, in
+// order, to cb; see DumpRequest.
+// All of the parts come from one consistent snapshot, however many other clients are changing the state meanwhile;
+// any broadcasts about their changes arrive on c's Rx, as usual, either before or after the snapshot.
+// The Controller's broadcasts go on while Resync waits its turn, so something must take them from c's Rx.
+//
+// It returns ErrControllerShutDown if the Controller didn't pick up the request before ctx finished, and the first
+// error cb returns, if any.
+func (c *Client) Resync(ctx context.Context, cb func(Response) error) error {
+	alive, err := c.SendAndProcessReplies(ctx, "", DumpRequest{}, func(r Response) error {
+		// Queueing behind other dumps isn't part of the state.
+		if _, ok := r.Body.(DumpQueuedResponse); ok {
+			return nil
+		}
+		return cb(r)
+	})
+	if !alive {
+		return ErrControllerShutDown
+	}
+	return err
+}
@@ -0,0 +1,85 @@
+package controller_test
+
+import (
+	"context"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/UniversityRadioYork/baps3d/controller"
+	"github.com/UniversityRadioYork/baps3d/list"
+)
+
+// TestClient_Resync tests that a resync gives a consistent snapshot of the state to the one Client, even while
+// another Client keeps changing it.
+func TestClient_Resync(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	ctl, root := controller.NewController(list.New())
+	go ctl.Run(ctx)
+	go func() {
+		for range root.Rx {
+		}
+	}()
+
+	// The mutator keeps replacing the list with one more item than before, selecting the last; any snapshot taken
+	// part way through a replacement would show a selection that doesn't match the items.
+	mut, err := root.Copy(ctx)
+	if err != nil {
+		t.Fatalf("couldn't copy client: %v", err)
+	}
+	lmut := list.NewClient(mut, nil)
+	mctx, mcancel := context.WithCancel(ctx)
+	mdone := make(chan struct{})
+	go func() {
+		defer close(mdone)
+		var items []list.Item
+		for i := 0; mctx.Err() == nil; i++ {
+			items = append(items, *list.NewTrack(strconv.Itoa(i), "x.mp3"))
+			if err := lmut.Replace(mctx, items, len(items)-1); err != nil && mctx.Err() == nil {
+				t.Errorf("couldn't replace: %v", err)
+				return
+			}
+		}
+	}()
+
+	c, err := root.Copy(ctx)
+	if err != nil {
+		t.Fatalf("couldn't copy client: %v", err)
+	}
+	go func() {
+		for range c.Rx {
+		}
+	}()
+	for n := 0; n < 20; n++ {
+		var (
+			items []list.Item
+			sel   list.SelectResponse
+		)
+		err := c.Resync(ctx, func(r controller.Response) error {
+			if r.Broadcast {
+				t.Errorf("resync gave a broadcast: %v", r)
+			}
+			switch b := r.Body.(type) {
+			case list.FreezeResponse:
+				items = b
+			case list.SelectResponse:
+				sel = b
+			}
+			return nil
+		})
+		if err != nil {
+			t.Fatalf("couldn't resync: %v", err)
+		}
+		if 0 < len(items) && (sel.Index != len(items)-1 || sel.Hash != items[len(items)-1].Hash()) {
+			t.Fatalf("resync %d: selection %+v doesn't match %d items", n, sel, len(items))
+		}
+	}
+
+	mcancel()
+	<-mdone
+	if err := root.Shutdown(ctx); err != nil {
+		t.Fatalf("couldn't shut down: %v", err)
+	}
+}
@@ -0,0 +1,123 @@
+package controller
+
+// File schedule.go contains the Controller's one-shot scheduled requests; see ScheduleRequest.
+
+import (
+	"errors"
+	"fmt"
+	"reflect"
+	"sort"
+	"time"
+)
+
+// ErrScheduleInPast is the error sent when a ScheduleRequest without FireIfPast asks for a time that has already come.
+var ErrScheduleInPast = errors.New("scheduled time has already passed")
+
+// schedule is a pending scheduled request.
+type schedule struct {
+	// id identifies the schedule.
+	id uint64
+	// rq is the request that set up the schedule.
+	rq ScheduleRequest
+}
+
+// response gets s as a ScheduleResponse.
+func (s schedule) response() ScheduleResponse {
+	return ScheduleResponse{ID: s.id, At: s.rq.At, Label: s.rq.Label}
+}
+
+// SetClock sets the Clock c uses for scheduling; it defaults to SystemClock.
+// It must be called before Run.
+func (c *Controller) SetClock(clk Clock) {
+	c.clock = clk
+}
+
+// handleScheduleRequest handles a schedule request with origin o and body b.
+func (c *Controller) handleScheduleRequest(o RequestOrigin, b ScheduleRequest) error {
+	due := !b.At.After(c.clock.Now())
+	if due && !b.FireIfPast {
+		return fmt.Errorf("%w: %s", ErrScheduleInPast, FormatTime(b.At))
+	}
+
+	s := schedule{id: c.nextScheduleID, rq: b}
+	c.nextScheduleID++
+	c.broadcast(s.response())
+
+	if due {
+		c.fire(s)
+		return nil
+	}
+
+	c.schedules = append(c.schedules, s)
+	// Ties go to whichever was scheduled first.
+	sort.SliceStable(c.schedules, func(i, j int) bool {
+		return c.schedules[i].rq.At.Before(c.schedules[j].rq.At)
+	})
+	c.rearm()
+	return nil
+}
+
+// handleCancelScheduleRequest handles a schedule cancellation request with origin o and body b.
+func (c *Controller) handleCancelScheduleRequest(o RequestOrigin, b CancelScheduleRequest) error {
+	for i, s := range c.schedules {
+		if s.id == b.ID {
+			c.schedules = append(c.schedules[:i], c.schedules[i+1:]...)
+			c.rearm()
+			c.broadcast(ScheduleCancelledResponse{ID: b.ID})
+			return nil
+		}
+	}
+	return fmt.Errorf("no pending schedule with ID %d", b.ID)
+}
+
+// handleListSchedulesRequest handles a schedule listing request with origin o and body b.
+func (c *Controller) handleListSchedulesRequest(o RequestOrigin, b ListSchedulesRequest) error {
+	for _, s := range c.schedules {
+		c.reply(o, s.response())
+	}
+
+	// Listing schedules never fails
+	return nil
+}
+
+// fireDue fires every pending schedule that is now due, then sets the timer for the next one.
+func (c *Controller) fireDue() {
+	c.timer = nil
+
+	now := c.clock.Now()
+	for 0 < len(c.schedules) && !c.schedules[0].rq.At.After(now) {
+		s := c.schedules[0]
+		c.schedules = c.schedules[1:]
+		c.fire(s)
+	}
+
+	c.rearm()
+}
+
+// fire handles s's request, and broadcasts the result.
+func (c *Controller) fire(s schedule) {
+	err := c.state.HandleRequest(func(interface{}) {}, c.broadcast, s.rq.Body)
+	c.broadcast(ScheduleFiredResponse{ID: s.id, At: s.rq.At, Label: s.rq.Label, Err: err})
+}
+
+// rearm sets c's timer for its earliest pending schedule, if any, replacing any existing timer.
+func (c *Controller) rearm() {
+	if c.timer != nil {
+		c.timer.Stop()
+		c.timer = nil
+	}
+	if 0 < len(c.schedules) {
+		c.timer = c.clock.NewTimer(c.schedules[0].rq.At.Sub(c.clock.Now()))
+	}
+	c.cselects[len(c.cselects)-1] = c.timerCase()
+}
+
+// timerCase gets the select case for c's timer.
+// If there is no timer, the case never fires.
+func (c *Controller) timerCase() reflect.SelectCase {
+	var ch <-chan time.Time
+	if c.timer != nil {
+		ch = c.timer.C()
+	}
+	return reflect.SelectCase{Dir: reflect.SelectRecv, Chan: reflect.ValueOf(ch)}
+}
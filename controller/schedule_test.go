@@ -0,0 +1,303 @@
+package controller_test
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/UniversityRadioYork/baps3d/controller"
+)
+
+// fakeClock is a Clock whose time only moves when told to.
+type fakeClock struct {
+	mu      sync.Mutex
+	now     time.Time
+	timers  []*fakeTimer
+	tickers []*fakeTicker
+}
+
+// fakeTimer is a Timer made by a fakeClock.
+type fakeTimer struct {
+	clk      *fakeClock
+	c        chan time.Time
+	deadline time.Time
+	stopped  bool
+}
+
+func (f *fakeClock) Now() time.Time {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return f.now
+}
+
+func (f *fakeClock) NewTimer(d time.Duration) controller.Timer {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	t := &fakeTimer{clk: f, c: make(chan time.Time, 1), deadline: f.now.Add(d)}
+	f.timers = append(f.timers, t)
+	f.fire()
+	return t
+}
+
+func (f *fakeClock) NewTicker(d time.Duration) controller.Ticker {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	t := &fakeTicker{clk: f, c: make(chan time.Time, 1), period: d, next: f.now.Add(d)}
+	f.tickers = append(f.tickers, t)
+	return t
+}
+
+// Advance moves f's time on by d, firing any timers and tickers that are now due.
+func (f *fakeClock) Advance(d time.Duration) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	f.now = f.now.Add(d)
+	f.fire()
+}
+
+// fire fires each unstopped timer and ticker that is due; f's mutex must be held.
+func (f *fakeClock) fire() {
+	var pending []*fakeTimer
+	for _, t := range f.timers {
+		switch {
+		case t.stopped:
+		case t.deadline.After(f.now):
+			pending = append(pending, t)
+		default:
+			t.stopped = true
+			t.c <- f.now
+		}
+	}
+	f.timers = pending
+
+	for _, t := range f.tickers {
+		for ; !t.stopped && !t.next.After(f.now); t.next = t.next.Add(t.period) {
+			// Like time.Ticker, drop ticks nobody has taken.
+			select {
+			case t.c <- t.next:
+			default:
+			}
+		}
+	}
+}
+
+func (t *fakeTimer) C() <-chan time.Time {
+	return t.c
+}
+
+func (t *fakeTimer) Stop() bool {
+	t.clk.mu.Lock()
+	defer t.clk.mu.Unlock()
+
+	wasStopped := t.stopped
+	t.stopped = true
+	return !wasStopped
+}
+
+// fakeTicker is a Ticker made by a fakeClock.
+type fakeTicker struct {
+	clk     *fakeClock
+	c       chan time.Time
+	period  time.Duration
+	next    time.Time
+	stopped bool
+}
+
+func (t *fakeTicker) C() <-chan time.Time {
+	return t.c
+}
+
+func (t *fakeTicker) Stop() {
+	t.clk.mu.Lock()
+	defer t.clk.mu.Unlock()
+
+	t.stopped = true
+}
+
+// testSchedules runs f against a Controller over a testState using a fakeClock.
+// It gives f a channel carrying every broadcast the Controller sends.
+func testSchedules(t *testing.T, f func(ctx context.Context, c *controller.Client, clk *fakeClock, bcasts <-chan interface{})) {
+	t.Helper()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	clk := &fakeClock{now: time.Date(2020, 1, 1, 12, 0, 0, 0, time.UTC)}
+	ctl, c := controller.NewController(&testState{})
+	ctl.SetClock(clk)
+
+	done := make(chan struct{})
+	go func() {
+		ctl.Run(ctx)
+		close(done)
+	}()
+
+	bcasts := make(chan interface{}, 16)
+	go func() {
+		for r := range c.Rx {
+			bcasts <- r.Body
+		}
+	}()
+
+	f(ctx, c, clk, bcasts)
+
+	if err := c.Shutdown(ctx); err != nil {
+		t.Errorf("error shutting client down after test: %s", err.Error())
+	}
+	<-done
+}
+
+// schedule sends a ScheduleRequest for a broadcast dummy request at, returning its ack's error.
+func schedule(ctx context.Context, c *controller.Client, at time.Time, fireIfPast bool) error {
+	rq := controller.ScheduleRequest{At: at, Body: knownDummyRequest{Broadcast: true}, Label: "dummy", FireIfPast: fireIfPast}
+	_, err := c.SendAndProcessReplies(ctx, "", rq, func(controller.Response) error { return nil })
+	return err
+}
+
+// nextBcast gets the next broadcast from bcasts, failing if there isn't one soon.
+func nextBcast(t *testing.T, bcasts <-chan interface{}) interface{} {
+	t.Helper()
+
+	select {
+	case b := <-bcasts:
+		return b
+	case <-time.After(time.Second):
+		t.Fatal("timed out waiting for broadcast")
+		return nil
+	}
+}
+
+// TestController_Schedule_Fires tests that a schedule fires once, when its time comes, and broadcasts the result.
+func TestController_Schedule_Fires(t *testing.T) {
+	testSchedules(t, func(ctx context.Context, c *controller.Client, clk *fakeClock, bcasts <-chan interface{}) {
+		at := clk.Now().Add(time.Hour)
+		if err := schedule(ctx, c, at, false); err != nil {
+			t.Fatalf("unexpected error scheduling: %s", err.Error())
+		}
+		if sr, ok := nextBcast(t, bcasts).(controller.ScheduleResponse); !ok || sr.ID != 1 || !sr.At.Equal(at) {
+			t.Fatalf("got %+v, want a ScheduleResponse with ID 1", sr)
+		}
+
+		clk.Advance(59 * time.Minute)
+		// Check that nothing has fired by waiting for a health check to pass through.
+		if err := c.CheckAlive(ctx); err != nil {
+			t.Fatalf("unexpected error checking controller: %s", err.Error())
+		}
+		if len(bcasts) != 0 {
+			t.Fatalf("schedule fired early: %+v", <-bcasts)
+		}
+
+		clk.Advance(time.Minute)
+		if _, ok := nextBcast(t, bcasts).(knownDummyResponse); !ok {
+			t.Fatal("scheduled request didn't run")
+		}
+		if fr, ok := nextBcast(t, bcasts).(controller.ScheduleFiredResponse); !ok || fr.ID != 1 || fr.Err != nil {
+			t.Fatalf("got %+v, want a successful ScheduleFiredResponse with ID 1", fr)
+		}
+
+		clk.Advance(time.Hour)
+		if err := c.CheckAlive(ctx); err != nil {
+			t.Fatalf("unexpected error checking controller: %s", err.Error())
+		}
+		if len(bcasts) != 0 {
+			t.Fatalf("schedule fired twice: %+v", <-bcasts)
+		}
+	})
+}
+
+// TestController_Schedule_Past tests that schedules for times that have passed fail by default, and fire straight away
+// if asked to.
+func TestController_Schedule_Past(t *testing.T) {
+	testSchedules(t, func(ctx context.Context, c *controller.Client, clk *fakeClock, bcasts <-chan interface{}) {
+		at := clk.Now().Add(-time.Second)
+		if err := schedule(ctx, c, at, false); !errors.Is(err, controller.ErrScheduleInPast) {
+			t.Fatalf("got error %v, want ErrScheduleInPast", err)
+		}
+
+		if err := schedule(ctx, c, at, true); err != nil {
+			t.Fatalf("unexpected error scheduling: %s", err.Error())
+		}
+		for _, want := range []string{"schedule", "dummy", "fired"} {
+			b := nextBcast(t, bcasts)
+			var ok bool
+			switch want {
+			case "schedule":
+				_, ok = b.(controller.ScheduleResponse)
+			case "dummy":
+				_, ok = b.(knownDummyResponse)
+			case "fired":
+				_, ok = b.(controller.ScheduleFiredResponse)
+			}
+			if !ok {
+				t.Fatalf("got %T, want %s broadcast", b, want)
+			}
+		}
+	})
+}
+
+// TestController_Schedule_Cancel tests that cancelled schedules don't fire, and that listing schedules reports only
+// pending ones.
+func TestController_Schedule_Cancel(t *testing.T) {
+	testSchedules(t, func(ctx context.Context, c *controller.Client, clk *fakeClock, bcasts <-chan interface{}) {
+		for _, d := range []time.Duration{2 * time.Hour, time.Hour} {
+			if err := schedule(ctx, c, clk.Now().Add(d), false); err != nil {
+				t.Fatalf("unexpected error scheduling: %s", err.Error())
+			}
+			nextBcast(t, bcasts)
+		}
+
+		_, err := c.SendAndProcessReplies(ctx, "", controller.CancelScheduleRequest{ID: 2}, func(controller.Response) error { return nil })
+		if err != nil {
+			t.Fatalf("unexpected error cancelling: %s", err.Error())
+		}
+		if cr, ok := nextBcast(t, bcasts).(controller.ScheduleCancelledResponse); !ok || cr.ID != 2 {
+			t.Fatalf("got %+v, want a ScheduleCancelledResponse with ID 2", cr)
+		}
+
+		var listed []uint64
+		_, err = c.SendAndProcessReplies(ctx, "", controller.ListSchedulesRequest{}, func(r controller.Response) error {
+			listed = append(listed, r.Body.(controller.ScheduleResponse).ID)
+			return nil
+		})
+		if err != nil {
+			t.Fatalf("unexpected error listing: %s", err.Error())
+		}
+		if len(listed) != 1 || listed[0] != 1 {
+			t.Errorf("got schedules %v, want [1]", listed)
+		}
+
+		clk.Advance(90 * time.Minute)
+		if err := c.CheckAlive(ctx); err != nil {
+			t.Fatalf("unexpected error checking controller: %s", err.Error())
+		}
+		if len(bcasts) != 0 {
+			t.Fatalf("cancelled schedule fired: %+v", <-bcasts)
+		}
+	})
+}
+
+// TestController_Ping tests that a ping is answered with its token and the Controller's time.
+func TestController_Ping(t *testing.T) {
+	testSchedules(t, func(ctx context.Context, c *controller.Client, clk *fakeClock, _ <-chan interface{}) {
+		for _, token := range []string{"", "abc 123"} {
+			var pongs []controller.PongResponse
+			_, err := c.SendAndProcessReplies(ctx, "", controller.PingRequest{Token: token}, func(rs controller.Response) error {
+				if p, ok := rs.Body.(controller.PongResponse); ok {
+					pongs = append(pongs, p)
+				}
+				return nil
+			})
+			if err != nil {
+				t.Fatalf("%q: unexpected error: %v", token, err)
+			}
+			if want := (controller.PongResponse{Token: token, Time: clk.Now()}); len(pongs) != 1 || pongs[0] != want {
+				t.Errorf("%q: got %+v, want one %+v", token, pongs, want)
+			}
+		}
+	})
+}
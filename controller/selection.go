@@ -0,0 +1,33 @@
+package controller
+
+// File selection.go contains selection-only mode, in which Bifrost adapters send their clients nothing from their
+// parsers but compact notifications of the state's selection, for displays that show what is on air and nothing else.
+
+import "github.com/UniversityRadioYork/bifrost-go/message"
+
+// SelectionParser is the interface of Bifrost parsers that can boil their responses down to compact notifications of
+// the selection, for adapters with SelectionOnly set.
+// Adapters over parsers that don't implement it send selection-only clients nothing from the parser at all.
+type SelectionParser interface {
+	// EmitBifrostSelection emits into msgTx, for tag, the compact notification of the selection that the response
+	// body rbody carries, if it carries one.
+	// It emits nothing for responses that don't bear on the selection.
+	EmitBifrostSelection(tag string, rbody interface{}, msgTx chan<- message.Message) error
+}
+
+// emit converts the response body rbody, for tag t, into messages on msgTx using b's parser.
+// If b is SelectionOnly, this gives the parser's compact selection notification, if any, rather than the usual
+// messages; failing that, if b is PlayStateOnly, it gives the play state notification, if any (see emitPlayState).
+func (b *Bifrost) emit(t string, rbody interface{}, msgTx chan<- message.Message) error {
+	switch {
+	case b.SelectionOnly:
+		if sp, ok := b.parser.(SelectionParser); ok {
+			return sp.EmitBifrostSelection(t, rbody, msgTx)
+		}
+		return nil
+	case b.PlayStateOnly:
+		return b.emitPlayState(t, rbody, msgTx)
+	default:
+		return b.parser.EmitBifrostResponse(t, rbody, msgTx)
+	}
+}
@@ -0,0 +1,75 @@
+package controller_test
+
+import (
+	"context"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/UniversityRadioYork/bifrost-go/message"
+
+	"github.com/UniversityRadioYork/baps3d/controller"
+	"github.com/UniversityRadioYork/baps3d/list"
+)
+
+// TestBifrost_SelectionOnly tests that a selection-only adapter sends NOW notifications in place of its parser's
+// usual messages, in dumps, broadcasts, and replies alike, and nothing for responses that don't bear on the
+// selection.
+func TestBifrost_SelectionOnly(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	l := list.New()
+	for i, h := range []string{"a", "b"} {
+		if err := l.Add(list.NewTrack(h, h+".mp3"), i); err != nil {
+			t.Fatalf("couldn't add %q: %v", h, err)
+		}
+	}
+	ctl, root := controller.NewController(l)
+	go ctl.Run(ctx)
+	go func() {
+		for range root.Rx {
+		}
+	}()
+
+	c, err := root.Copy(ctx)
+	if err != nil {
+		t.Fatalf("couldn't copy client: %v", err)
+	}
+	bf, sep, err := c.Bifrost(ctx)
+	if err != nil {
+		t.Fatalf("couldn't get Bifrost adapter: %v", err)
+	}
+	bf.SelectionOnly = true
+	go bf.Run(ctx)
+	// OHAI and IAMA come from the adapter itself, so are unaffected.
+	readMessages(t, sep, 2)
+	if got := string(readMessages(t, sep, 1)[0]); got != "! NOW none\n" {
+		t.Errorf("handshake dump: got %q, want only the NOW", got)
+	}
+
+	// The adapter sends replies as it goes, so we need to read them while sending.
+	go func() {
+		for _, m := range []*message.Message{
+			message.New("t", "dur").AddArgs("1", "b", "1000"),
+			message.New("t", "sel").AddArgs("1", "b"),
+			message.New("t", "dump"),
+		} {
+			sep.Tx <- *m
+		}
+	}()
+
+	var got []string
+	for _, bs := range readMessages(t, sep, 5) {
+		got = append(got, string(bs))
+	}
+	ack := "t ACK OK success\n"
+	want := []string{ack, "! NOW selected b\n", ack, "t NOW selected b\n", ack}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("got %q, want %q", got, want)
+	}
+
+	if err := root.Shutdown(ctx); err != nil {
+		t.Fatalf("couldn't shut down: %v", err)
+	}
+}
@@ -0,0 +1,112 @@
+package controller
+
+// File zdump.go contains compressed dumps, which Bifrost adapters with CompressDumps set send in place of the usual
+// run of dump messages.
+
+import (
+	"bytes"
+	"compress/gzip"
+	"encoding/base64"
+	"fmt"
+	"io/ioutil"
+	"strconv"
+
+	"github.com/UniversityRadioYork/bifrost-go/message"
+)
+
+// RsZdump is the response word of compressed dumps.
+// A ZDUMP message's arguments are the number of messages in the dump, then the messages themselves, packed as lines
+// as they would have been sent, gzipped, and base64-encoded; see DecompressDump.
+const RsZdump = "ZDUMP"
+
+// DecompressDump decodes the data argument of a ZDUMP message, giving the dump's messages as packed lines.
+func DecompressDump(data string) ([]byte, error) {
+	gz, err := base64.StdEncoding.DecodeString(data)
+	if err != nil {
+		return nil, fmt.Errorf("bad compressed dump: %w", err)
+	}
+	zr, err := gzip.NewReader(bytes.NewReader(gz))
+	if err != nil {
+		return nil, fmt.Errorf("bad compressed dump: %w", err)
+	}
+	defer zr.Close()
+	return ioutil.ReadAll(zr)
+}
+
+// compressDump makes a ZDUMP message, with tag tag, carrying msgs.
+func compressDump(tag string, msgs []message.Message) (*message.Message, error) {
+	var buf bytes.Buffer
+	zw := gzip.NewWriter(&buf)
+	for _, m := range msgs {
+		bs, err := m.Pack()
+		if err != nil {
+			return nil, err
+		}
+		if _, err := zw.Write(bs); err != nil {
+			return nil, err
+		}
+	}
+	if err := zw.Close(); err != nil {
+		return nil, err
+	}
+
+	data := base64.StdEncoding.EncodeToString(buf.Bytes())
+	return message.New(tag, RsZdump).AddArgs(strconv.Itoa(len(msgs)), data), nil
+}
+
+// handleDumpResponse handles a controller response rs to a dump request, when b compresses dumps.
+// It holds back the dump's messages until the acknowledgement, then sends them as one ZDUMP, followed by the ACK.
+// Notices that the dump has queued (see DumpQueuedResponse) aren't part of the dump, so go through straight away.
+func (b *Bifrost) handleDumpResponse(rs Response) error {
+	tag := b.tagOf(rs)
+	switch r := rs.Body.(type) {
+	case DoneResponse:
+		if err := b.flushDump(tag); err != nil {
+			return err
+		}
+		return b.handleAck(tag, r)
+	case DumpQueuedResponse:
+		return b.handleResponse(rs)
+	default:
+		return b.holdDumpResponse(tag, r)
+	}
+}
+
+// holdDumpResponse converts the dump response rbody into messages for tag t, holding them back for flushDump.
+// b's parser emits into a channel, so it runs on its own goroutine; any panic in it carries on in this one.
+func (b *Bifrost) holdDumpResponse(t string, rbody interface{}) error {
+	var (
+		err error
+		p   interface{}
+	)
+	msgs := make(chan message.Message)
+	go func() {
+		defer func() {
+			p = recover()
+			close(msgs)
+		}()
+		err = b.emit(t, rbody, msgs)
+	}()
+
+	for m := range msgs {
+		b.dumping = append(b.dumping, m)
+	}
+	if p != nil {
+		panic(p)
+	}
+	return err
+}
+
+// flushDump sends the dump messages b has held back, if any, as one ZDUMP with tag t.
+func (b *Bifrost) flushDump(t string) error {
+	if len(b.dumping) == 0 {
+		return nil
+	}
+	m, err := compressDump(t, b.dumping)
+	b.dumping = nil
+	if err != nil {
+		return err
+	}
+	b.respond(*m)
+	return nil
+}
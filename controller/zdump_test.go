@@ -0,0 +1,164 @@
+package controller_test
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/UniversityRadioYork/bifrost-go/comm"
+	"github.com/UniversityRadioYork/bifrost-go/message"
+
+	"github.com/UniversityRadioYork/baps3d/controller"
+	"github.com/UniversityRadioYork/baps3d/list"
+)
+
+// realisticListLen is the number of items in the list made by realisticList.
+const realisticListLen = 500
+
+// realisticList makes a list that looks like a real show's: realisticListLen tracks, with durations, and a selection.
+func realisticList(t testing.TB) *list.List {
+	t.Helper()
+
+	l := list.New()
+	for i := 0; i < realisticListLen; i++ {
+		hash := fmt.Sprintf("%08x-%04x-4000-8000-%012x", i*2654435761%(1<<32), i%0xffff, i*40503)
+		path := fmt.Sprintf("/srv/music/Artist %d/Album %d/%02d - Track Title Number %d.mp3", i%37, i%91, i%20+1, i)
+		if err := l.Add(list.NewTrack(hash, path), i); err != nil {
+			t.Fatalf("couldn't add item %d: %v", i, err)
+		}
+		if err := l.SetDuration(i, hash, time.Duration(150+i%120)*time.Second); err != nil {
+			t.Fatalf("couldn't set duration %d: %v", i, err)
+		}
+	}
+	if _, err := l.Select(0, l.ItemWithIndex(0).Hash()); err != nil {
+		t.Fatalf("couldn't select: %v", err)
+	}
+	return l
+}
+
+// startBifrost starts a Bifrost adapter over a copy of root, compressing dumps if compress is true.
+func startBifrost(ctx context.Context, t testing.TB, root *controller.Client, compress bool) *comm.Endpoint {
+	t.Helper()
+
+	c, err := root.Copy(ctx)
+	if err != nil {
+		t.Fatalf("couldn't copy client: %v", err)
+	}
+	bf, ep, err := c.Bifrost(ctx)
+	if err != nil {
+		t.Fatalf("couldn't get Bifrost adapter: %v", err)
+	}
+	bf.CompressDumps = compress
+	go bf.Run(ctx)
+	return ep
+}
+
+// readMessages reads n messages from ep, packing them as lines.
+func readMessages(t testing.TB, ep *comm.Endpoint, n int) [][]byte {
+	t.Helper()
+
+	lines := make([][]byte, n)
+	for i := range lines {
+		m, ok := <-ep.Rx
+		if !ok {
+			t.Fatal("adapter closed early")
+		}
+		bs, err := m.Pack()
+		if err != nil {
+			t.Fatalf("couldn't pack message: %v", err)
+		}
+		lines[i] = bs
+	}
+	return lines
+}
+
+// readZdump reads a message from ep, checking that it is a ZDUMP with tag tag, and returns its message count and
+// decompressed lines.
+func readZdump(t testing.TB, ep *comm.Endpoint, tag string) (int, []byte) {
+	t.Helper()
+
+	m := <-ep.Rx
+	if m.Tag() != tag || m.Word() != controller.RsZdump || len(m.Args()) != 2 {
+		t.Fatalf("got %v, want a ZDUMP tagged %q", m, tag)
+	}
+	var n int
+	if _, err := fmt.Sscan(m.Args()[0], &n); err != nil {
+		t.Fatalf("bad ZDUMP count: %v", err)
+	}
+	lines, err := controller.DecompressDump(m.Args()[1])
+	if err != nil {
+		t.Fatalf("couldn't decompress: %v", err)
+	}
+	return n, lines
+}
+
+// TestBifrost_CompressDumps tests that compressed dumps, both in the handshake and on request, decompress to exactly
+// the lines an uncompressed adapter sends.
+func TestBifrost_CompressDumps(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	ctl, root := controller.NewController(realisticList(t))
+	go ctl.Run(ctx)
+	go func() {
+		for range root.Rx {
+		}
+	}()
+
+	zep := startBifrost(ctx, t, root, true)
+	// OHAI and IAMA go uncompressed.
+	readMessages(t, zep, 2)
+	n, zlines := readZdump(t, zep, message.TagBcast)
+
+	ep := startBifrost(ctx, t, root, false)
+	want := bytes.Join(readMessages(t, ep, n+2)[2:], nil)
+	if !bytes.Equal(zlines, want) {
+		t.Errorf("handshake dump: decompressed lines differ from uncompressed ones\ngot:\n%s\nwant:\n%s", zlines, want)
+	}
+
+	zep.Tx <- *message.New("t", "dump")
+	n, zlines = readZdump(t, zep, "t")
+	if ack := readMessages(t, zep, 1)[0]; string(ack) != "t ACK OK success\n" {
+		t.Errorf("got %q after the ZDUMP, want the ACK", ack)
+	}
+	ep.Tx <- *message.New("t", "dump")
+	if want := bytes.Join(readMessages(t, ep, n), nil); !bytes.Equal(zlines, want) {
+		t.Error("requested dump: decompressed lines differ from uncompressed ones")
+	}
+
+	if err := root.Shutdown(ctx); err != nil {
+		t.Fatalf("couldn't shut down: %v", err)
+	}
+}
+
+// BenchmarkBifrost_CompressDumps benchmarks requesting compressed dumps of a realistic list, reporting the ratio of
+// the uncompressed dump's size to the size of the ZDUMP line.
+func BenchmarkBifrost_CompressDumps(b *testing.B) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	ctl, root := controller.NewController(realisticList(b))
+	go ctl.Run(ctx)
+	go func() {
+		for range root.Rx {
+		}
+	}()
+	ep := startBifrost(ctx, b, root, true)
+	readMessages(b, ep, 3)
+
+	b.ResetTimer()
+	var packed int
+	for i := 0; i < b.N; i++ {
+		ep.Tx <- *message.New("t", "dump")
+		z := readMessages(b, ep, 2)[0]
+		packed = len(z)
+	}
+	b.StopTimer()
+
+	ep.Tx <- *message.New("t", "dump")
+	_, lines := readZdump(b, ep, "t")
+	b.ReportMetric(float64(len(lines))/float64(packed), "ratio")
+	b.ReportMetric(float64(packed), "zbytes")
+}
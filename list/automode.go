@@ -1,7 +1,7 @@
 package list
 
 // This file contains AutoMode, which enumerates over autoselection modes.
-// It also contains functions for converting AutoModes to and from strings.
+// It also contains the registry of supported AutoModes, and functions for converting AutoModes to and from strings.
 // For the actual autoselection logic, see 'list.go'.
 
 import "fmt"
@@ -21,37 +21,42 @@ const (
 	// FirstAuto points to the first AutoMode constant.
 	FirstAuto = AutoOff
 	// LastAuto points to the last AutoMode constant.
-	LastAuto = AutoNext
+	LastAuto = AutoShuffle
 )
 
+// autoModeNames is the registry of supported AutoModes, mapping each to its Bifrost name.
+// The names are part of the protocol: clients store and send them, so once a mode is added, its name must never
+// change.
+var autoModeNames = [...]string{
+	AutoOff:     "off",
+	AutoDrop:    "drop",
+	AutoNext:    "next",
+	AutoShuffle: "shuffle",
+}
+
+// AutoModes gets every supported AutoMode, in order.
+func AutoModes() []AutoMode {
+	modes := make([]AutoMode, len(autoModeNames))
+	for i := range autoModeNames {
+		modes[i] = AutoMode(i)
+	}
+	return modes
+}
+
 // String gets the Bifrost name of an AutoMode as a string.
 func (a AutoMode) String() string {
-	switch a {
-	case AutoOff:
-		return "off"
-	case AutoDrop:
-		return "drop"
-	case AutoNext:
-		return "next"
-	case AutoShuffle:
-		return "shuffle"
-	default:
+	if a < 0 || int(a) >= len(autoModeNames) {
 		return "?unknown?"
 	}
+	return autoModeNames[a]
 }
 
 // ParseAutoMode tries to parse an AutoMode from a string.
 func ParseAutoMode(s string) (AutoMode, error) {
-	switch s {
-	case "off":
-		return AutoOff, nil
-	case "drop":
-		return AutoDrop, nil
-	case "next":
-		return AutoNext, nil
-	case "shuffle":
-		return AutoShuffle, nil
-	default:
-		return AutoOff, fmt.Errorf("invalid automode")
+	for i, name := range autoModeNames {
+		if s == name {
+			return AutoMode(i), nil
+		}
 	}
+	return AutoOff, fmt.Errorf("invalid automode")
 }
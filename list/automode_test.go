@@ -100,3 +100,25 @@ func TestAutoModeParseIdempotence(t *testing.T) {
 		}
 	}
 }
+
+// TestAutoModes checks the registry of supported AutoModes against the tokens clients rely on.
+// The tokens must stay stable across versions, so only ever append to want.
+func TestAutoModes(t *testing.T) {
+	want := []string{"off", "drop", "next", "shuffle"}
+
+	modes := list.AutoModes()
+	if len(modes) != len(want) {
+		t.Fatalf("got %d automodes, want %d", len(modes), len(want))
+	}
+	for i, a := range modes {
+		if a != list.AutoMode(i) {
+			t.Errorf("automode %d is %d, want modes in order", i, a)
+		}
+		if got := a.String(); got != want[i] {
+			t.Errorf("automode %d is %q, want %q", i, got, want[i])
+		}
+	}
+	if modes[0] != list.FirstAuto || modes[len(modes)-1] != list.LastAuto {
+		t.Errorf("automodes run from %v to %v, want %v to %v", modes[0], modes[len(modes)-1], list.FirstAuto, list.LastAuto)
+	}
+}
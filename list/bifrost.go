@@ -2,36 +2,184 @@ package list
 
 // File list/bifrost.go implements BifrostParser for List.
 // - See `comm/bifrost.go` for the common marshalling logic.
+//
+// Binary item payloads travel as binary words: BinaryWordPrefix followed by base64.
+// The line protocol itself (tokenising and packing) lives in bifrost-go, which knows nothing about item payloads, so
+// the convention is applied here, and only in the BLOADL/bloadl messages that declare a binary payload.
 
 import (
+	"encoding/base64"
 	"fmt"
 	"strconv"
+	"strings"
 
 	"github.com/UniversityRadioYork/bifrost-go/message"
 
 	"github.com/UniversityRadioYork/baps3d/controller"
 )
 
+const (
+	// BinaryWordPrefix is the prefix marking a Bifrost word as base64-encoded binary data.
+	BinaryWordPrefix = "base64:"
+
+	// MaxBinaryLen is the maximum decoded size, in bytes, of a binary word.
+	MaxBinaryLen = 64 * 1024
+)
+
 // ParseBifrostRequest handles Bifrost parsing for List controllers.
 func (l *List) ParseBifrostRequest(word string, args []string) (interface{}, error) {
 	switch word {
+	case "advance":
+		return parseAdvanceMessage(args)
+	case "airtime":
+		return parseAirtimeMessage(args)
+	case "airtimes":
+		return parseAirtimesMessage(args)
 	case "auto":
 		return parseAutoMessage(args)
+	case "automodes":
+		return parseAutomodesMessage(args)
+	case "bloadl":
+		return parseBloadlMessage(args)
+	case "bupdate":
+		return parseBupdateMessage(args)
+	case "bytype":
+		return parseBytypeMessage(args)
+	case "context":
+		return parseContextMessage(args)
+	case "dur":
+		return parseDurMessage(args)
+	case "elapsed":
+		return parseElapsedMessage(args)
+	case "export":
+		return parseExportMessage(args)
+	case "fallback":
+		return parseFallbackMessage(args)
 	case "floadl":
 		return parseFloadlMessage(args)
+	case "floadrel":
+		return parseFloadrelMessage(args)
+	case "history":
+		return parseHistoryMessage(args)
+	case "jog":
+		return parseJogMessage(args)
+	case "lock":
+		return parseLockMessage(args)
+	case "next":
+		return parseNextMessage(args)
+	case "played":
+		return parsePlayedMessage(args)
+	case "playing":
+		return parsePlayingMessage(args)
+	case "prev":
+		return parsePrevMessage(args)
+	case "revert":
+		return parseRevertMessage(args)
 	case "sel":
 		return parseSelMessage(args)
+	case "selfallback":
+		return parseSelfallbackMessage(args)
+	case "since":
+		return parseSinceMessage(args)
+	case "status":
+		return parseStatusMessage(args)
+	case "stopafter":
+		return parseStopafterMessage(args)
+	case "swap":
+		return parseSwapMessage(args)
 	case "tloadl":
 		return parseTloadlMessage(args)
+	case "tloadlr":
+		return parseTloadlMessage(args)
+	case "tloadrel":
+		return parseTloadrelMessage(args)
+	case "tx":
+		return l.parseTxMessage(args)
+	case "update":
+		return parseUpdateMessage(args)
 	default:
 		return nil, controller.UnknownWord(word)
 	}
 }
 
+// RestOfLine tells tokenisers which List requests end with a rest-of-line argument.
+// This is opt-in per request: 'tloadlr' is 'tloadl' with the text taking up the rest of the line, so that text
+// items with spaces in them don't need quoting.
+// Every other request is tokenised strictly.
+func (l *List) RestOfLine(word string) (fixed int, ok bool) {
+	switch word {
+	case "tloadlr":
+		// index hash <text...>
+		return 2, true
+	default:
+		return 0, false
+	}
+}
+
+// IsLatestWins tells senders which List broadcasts are superseded by later ones with the same word.
+// These are the announcements of the automode and the selection, which each give the whole current state.
+func (l *List) IsLatestWins(word string) bool {
+	switch word {
+	case "AUTO", "SEL":
+		return true
+	default:
+		return false
+	}
+}
+
+// Limits tells clients asking for a server's limits the fixed limits on List requests.
+// These are 'maxreplace', the most items in a replacement (MaxReplaceLen); 'maxtx', the most operations in a 'tx'
+// (MaxTransactionLen); 'maxhistory', the most changes 'since' can catch up on (MaxHistory); 'maxbytype', the most
+// items 'bytype' lists (MaxTypeMatches); 'maxbinary', the most bytes in a binary word (MaxBinaryLen); and
+// 'maxexport', the most bytes in an export (MaxExportLen).
+func (l *List) Limits() []controller.Limit {
+	return []controller.Limit{
+		{Name: "maxreplace", Value: strconv.Itoa(MaxReplaceLen)},
+		{Name: "maxtx", Value: strconv.Itoa(MaxTransactionLen)},
+		{Name: "maxhistory", Value: strconv.Itoa(MaxHistory)},
+		{Name: "maxbytype", Value: strconv.Itoa(MaxTypeMatches)},
+		{Name: "maxbinary", Value: strconv.Itoa(MaxBinaryLen)},
+		{Name: "maxexport", Value: strconv.Itoa(MaxExportLen)},
+	}
+}
+
 //
 // Request parsers
 //
 
+// parseAdvanceMessage tries to parse an 'advance' message.
+func parseAdvanceMessage(args []string) (interface{}, error) {
+	if len(args) != 0 {
+		return nil, fmt.Errorf("bad arity")
+	}
+
+	return AdvanceRequest{}, nil
+}
+
+// parseAirtimeMessage tries to parse an 'airtime' message.
+// It takes the index and hash of the item.
+func parseAirtimeMessage(args []string) (interface{}, error) {
+	if len(args) != 2 {
+		return nil, fmt.Errorf("bad arity")
+	}
+
+	index, err := strconv.Atoi(args[0])
+	if err != nil {
+		return nil, err
+	}
+
+	return AirTimeRequest{Index: index, Hash: args[1]}, nil
+}
+
+// parseAirtimesMessage tries to parse an 'airtimes' message.
+func parseAirtimesMessage(args []string) (interface{}, error) {
+	if len(args) != 0 {
+		return nil, fmt.Errorf("bad arity")
+	}
+
+	return AirTimesRequest{}, nil
+}
+
 // parseAutoMessage tries to parse an 'auto' message.
 func parseAutoMessage(args []string) (interface{}, error) {
 	if len(args) != 1 {
@@ -46,9 +194,273 @@ func parseAutoMessage(args []string) (interface{}, error) {
 	return SetAutoModeRequest{AutoMode: amode}, nil
 }
 
+// parseAutomodesMessage tries to parse an 'automodes' message.
+func parseAutomodesMessage(args []string) (interface{}, error) {
+	if len(args) != 0 {
+		return nil, fmt.Errorf("bad arity")
+	}
+
+	return AutoModesRequest{}, nil
+}
+
+// parseBytypeMessage tries to parse a 'bytype' message.
+// It takes the name of an item type, such as 'track'.
+func parseBytypeMessage(args []string) (interface{}, error) {
+	if len(args) != 1 {
+		return nil, fmt.Errorf("bad arity")
+	}
+
+	itype, err := ParseItemType(args[0])
+	if err != nil {
+		return nil, err
+	}
+	return ListByTypeRequest{Type: itype}, nil
+}
+
+// parseContextMessage tries to parse a 'context' message.
+// It takes an optional radius, defaulting to DefaultContextRadius.
+func parseContextMessage(args []string) (interface{}, error) {
+	switch len(args) {
+	case 0:
+		return ContextDumpRequest{Radius: DefaultContextRadius}, nil
+	case 1:
+		radius, err := strconv.Atoi(args[0])
+		if err != nil {
+			return nil, err
+		}
+		return ContextDumpRequest{Radius: radius}, nil
+	default:
+		return nil, fmt.Errorf("bad arity")
+	}
+}
+
+// parseDurMessage tries to parse a 'dur' message.
+func parseDurMessage(args []string) (interface{}, error) {
+	if len(args) != 3 {
+		return nil, fmt.Errorf("bad arity")
+	}
+
+	index, err := strconv.Atoi(args[0])
+	if err != nil {
+		return nil, err
+	}
+	hash := args[1]
+	d, err := controller.ParseMillis(args[2])
+	if err != nil {
+		return nil, err
+	}
+
+	return SetDurationRequest{Index: index, Hash: hash, Duration: d}, nil
+}
+
+// parseElapsedMessage tries to parse an 'elapsed' message.
+// With an elapsed time in milliseconds, it reports that time; without, it asks for it.
+func parseElapsedMessage(args []string) (interface{}, error) {
+	switch len(args) {
+	case 0:
+		return ElapsedRequest{}, nil
+	case 1:
+	default:
+		return nil, fmt.Errorf("bad arity")
+	}
+
+	d, err := controller.ParseMillis(args[0])
+	if err != nil {
+		return nil, err
+	}
+
+	return SetElapsedRequest{Elapsed: d}, nil
+}
+
+// parseExportMessage tries to parse an 'export' message.
+func parseExportMessage(args []string) (interface{}, error) {
+	if len(args) != 0 {
+		return nil, fmt.Errorf("bad arity")
+	}
+
+	return ExportRequest{}, nil
+}
+
 // parseFloadlMessage tries to parse a 'floadl' message.
 func parseFloadlMessage(args []string) (interface{}, error) {
-	return parseItemAddMessage(NewTrack, args)
+	return parseItemAddMessage(ItemTrack, args)
+}
+
+// parseFloadrelMessage tries to parse a 'floadrel' message.
+func parseFloadrelMessage(args []string) (interface{}, error) {
+	return parseItemInsertRelativeMessage(ItemTrack, args)
+}
+
+// parseHistoryMessage tries to parse a 'history' message.
+// It takes an optional count, defaulting to every play the List remembers.
+func parseHistoryMessage(args []string) (interface{}, error) {
+	switch len(args) {
+	case 0:
+		return HistoryRequest{}, nil
+	case 1:
+		n, err := strconv.Atoi(args[0])
+		if err != nil {
+			return nil, err
+		}
+		return HistoryRequest{Count: n}, nil
+	default:
+		return nil, fmt.Errorf("bad arity")
+	}
+}
+
+// parseJogMessage tries to parse a 'jog' message.
+// It takes a signed offset, then an optional 'wrap' argument.
+func parseJogMessage(args []string) (interface{}, error) {
+	if len(args) == 0 {
+		return nil, fmt.Errorf("bad arity")
+	}
+
+	offset, err := strconv.Atoi(args[0])
+	if err != nil {
+		return nil, err
+	}
+	wrap, err := parseWrap(args[1:])
+	if err != nil {
+		return nil, err
+	}
+	return SelectRelativeRequest{Offset: offset, Wrap: wrap}, nil
+}
+
+// parseFallbackMessage tries to parse a 'fallback' message.
+// It takes the index and hash of the item, then 'on' to make it the fallback, or 'off' to unmark it.
+func parseFallbackMessage(args []string) (interface{}, error) {
+	if len(args) != 3 {
+		return nil, fmt.Errorf("bad arity")
+	}
+
+	index, err := strconv.Atoi(args[0])
+	if err != nil {
+		return nil, err
+	}
+	on, err := parseOnOff(args[2])
+	if err != nil {
+		return nil, err
+	}
+
+	return SetFallbackRequest{Index: index, Hash: args[1], Fallback: on}, nil
+}
+
+// parseSelfallbackMessage tries to parse a 'selfallback' message.
+func parseSelfallbackMessage(args []string) (interface{}, error) {
+	if len(args) != 0 {
+		return nil, fmt.Errorf("bad arity")
+	}
+
+	return SelectFallbackRequest{}, nil
+}
+
+// parseLockMessage tries to parse a 'lock' message.
+// It takes the index and hash of the item, then 'on' to lock it, or 'off' to unlock it.
+func parseLockMessage(args []string) (interface{}, error) {
+	if len(args) != 3 {
+		return nil, fmt.Errorf("bad arity")
+	}
+
+	index, err := strconv.Atoi(args[0])
+	if err != nil {
+		return nil, err
+	}
+	on, err := parseOnOff(args[2])
+	if err != nil {
+		return nil, err
+	}
+
+	return SetLockedRequest{Index: index, Hash: args[1], Locked: on}, nil
+}
+
+// parseNextMessage tries to parse a 'next' message.
+// It takes an optional count, making it a NextNRequest, then an optional 'wrap' argument.
+func parseNextMessage(args []string) (interface{}, error) {
+	if 0 < len(args) {
+		if n, err := strconv.Atoi(args[0]); err == nil {
+			wrap, err := parseWrap(args[1:])
+			if err != nil {
+				return nil, err
+			}
+			return NextNRequest{Count: n, Wrap: wrap}, nil
+		}
+	}
+
+	wrap, err := parseWrap(args)
+	if err != nil {
+		return nil, err
+	}
+	return NextRequest{Wrap: wrap}, nil
+}
+
+// parsePlayedMessage tries to parse a 'played' message.
+// It takes the index and hash of the item that has finished playing.
+func parsePlayedMessage(args []string) (interface{}, error) {
+	if len(args) != 2 {
+		return nil, fmt.Errorf("bad arity")
+	}
+
+	index, err := strconv.Atoi(args[0])
+	if err != nil {
+		return nil, err
+	}
+	return MarkPlayedRequest{Index: index, Hash: args[1]}, nil
+}
+
+// parsePlayingMessage tries to parse a 'playing' message.
+// It takes 'on' if the selection is playing, and 'off' if it is paused.
+func parsePlayingMessage(args []string) (interface{}, error) {
+	if len(args) != 1 {
+		return nil, fmt.Errorf("bad arity")
+	}
+
+	on, err := parseOnOff(args[0])
+	if err != nil {
+		return nil, err
+	}
+	return SetPlayingRequest{Playing: on}, nil
+}
+
+// parsePrevMessage tries to parse a 'prev' message.
+// It takes an optional 'wrap' argument.
+func parsePrevMessage(args []string) (interface{}, error) {
+	wrap, err := parseWrap(args)
+	if err != nil {
+		return nil, err
+	}
+	return PreviousRequest{Wrap: wrap}, nil
+}
+
+// parseRevertMessage tries to parse a 'revert' message.
+func parseRevertMessage(args []string) (interface{}, error) {
+	if len(args) != 0 {
+		return nil, fmt.Errorf("bad arity")
+	}
+
+	return RevertRequest{}, nil
+}
+
+// parseWrap tries to parse the optional 'wrap' argument at the end of a 'next', 'prev', or 'jog' message.
+func parseWrap(args []string) (bool, error) {
+	switch {
+	case len(args) == 0:
+		return false, nil
+	case len(args) == 1 && args[0] == "wrap":
+		return true, nil
+	case len(args) == 1:
+		return false, fmt.Errorf("unknown option: %s", args[0])
+	default:
+		return false, fmt.Errorf("bad arity")
+	}
+}
+
+// parseStatusMessage tries to parse a 'status' message.
+func parseStatusMessage(args []string) (interface{}, error) {
+	if len(args) != 0 {
+		return nil, fmt.Errorf("bad arity")
+	}
+
+	return StatusRequest{}, nil
 }
 
 // parseSelMessage tries to parse a 'sel' message.
@@ -66,14 +478,149 @@ func parseSelMessage(args []string) (interface{}, error) {
 	return SetSelectRequest{Index: index, Hash: hash}, nil
 }
 
+// parseSinceMessage tries to parse a 'since' message.
+func parseSinceMessage(args []string) (interface{}, error) {
+	if len(args) != 1 {
+		return nil, fmt.Errorf("bad arity")
+	}
+
+	v, err := strconv.ParseUint(args[0], 10, 64)
+	if err != nil {
+		return nil, err
+	}
+
+	return SinceRequest{Version: v}, nil
+}
+
+// parseStopafterMessage tries to parse a 'stopafter' message.
+// It takes the index and hash of the item, then 'on' to mark it as a stop point, or 'off' to unmark it.
+func parseStopafterMessage(args []string) (interface{}, error) {
+	if len(args) != 3 {
+		return nil, fmt.Errorf("bad arity")
+	}
+
+	index, err := strconv.Atoi(args[0])
+	if err != nil {
+		return nil, err
+	}
+	on, err := parseOnOff(args[2])
+	if err != nil {
+		return nil, err
+	}
+
+	return SetStopAfterRequest{Index: index, Hash: args[1], StopAfter: on}, nil
+}
+
+// parseOnOff parses an 'on' or 'off' argument.
+func parseOnOff(arg string) (bool, error) {
+	switch arg {
+	case "on":
+		return true, nil
+	case "off":
+		return false, nil
+	default:
+		return false, fmt.Errorf("expected 'on' or 'off', got %q", arg)
+	}
+}
+
+// formatOnOff formats on as an 'on' or 'off' argument.
+func formatOnOff(on bool) string {
+	if on {
+		return "on"
+	}
+	return "off"
+}
+
+// parseSwapMessage tries to parse a 'swap' message.
+// It takes the index and hash of each of the two items.
+func parseSwapMessage(args []string) (interface{}, error) {
+	if len(args) != 4 {
+		return nil, fmt.Errorf("bad arity")
+	}
+
+	ia, err := strconv.Atoi(args[0])
+	if err != nil {
+		return nil, err
+	}
+	ib, err := strconv.Atoi(args[2])
+	if err != nil {
+		return nil, err
+	}
+
+	return SwapItemsRequest{IndexA: ia, HashA: args[1], IndexB: ib, HashB: args[3]}, nil
+}
+
 // parseTloadlMessage tries to parse a 'tloadl' message.
 func parseTloadlMessage(args []string) (interface{}, error) {
-	return parseItemAddMessage(NewText, args)
+	return parseItemAddMessage(ItemText, args)
+}
+
+// parseTloadrelMessage tries to parse a 'tloadrel' message.
+func parseTloadrelMessage(args []string) (interface{}, error) {
+	return parseItemInsertRelativeMessage(ItemText, args)
+}
+
+// TxSeparator is the word separating the operations in a 'tx' message.
+// As a word of its own, it always separates operations, even if quoted: no argument of an operation can be just it.
+const TxSeparator = ";"
+
+// parseTxMessage tries to parse a 'tx' message.
+// It takes operations separated by TxSeparator, each a word and arguments as in one of the messages 'floadl',
+// 'tloadl', 'bloadl', 'floadrel', 'tloadrel', 'update', 'bupdate', 'swap', or 'sel', or the word 'clear', which empties
+// the list; see List.Transact.
+func (l *List) parseTxMessage(args []string) (interface{}, error) {
+	var ops []interface{}
+	for start := 0; start <= len(args); {
+		end := start
+		for end < len(args) && args[end] != TxSeparator {
+			end++
+		}
+		op, err := l.parseTxOp(args[start:end])
+		if err != nil {
+			return nil, fmt.Errorf("operation %d: %w", len(ops), err)
+		}
+		ops = append(ops, op)
+		if MaxTransactionLen < len(ops) {
+			return nil, fmt.Errorf("more than %d operations", MaxTransactionLen)
+		}
+		start = end + 1
+	}
+	return TransactionRequest{Ops: ops}, nil
+}
+
+// parseTxOp tries to parse the words of one operation in a 'tx' message.
+func (l *List) parseTxOp(words []string) (interface{}, error) {
+	if len(words) == 0 {
+		return nil, fmt.Errorf("empty operation")
+	}
+
+	switch word, args := words[0], words[1:]; word {
+	case "clear":
+		if len(args) != 0 {
+			return nil, fmt.Errorf("bad arity")
+		}
+		return ReplaceListRequest{Selection: -1}, nil
+	case "floadl", "tloadl", "bloadl", "floadrel", "tloadrel", "update", "bupdate", "swap", "sel":
+		return l.ParseBifrostRequest(word, args)
+	default:
+		return nil, fmt.Errorf("%s can't be part of a transaction", word)
+	}
+}
+
+// parseItemInsertRelativeMessage tries to parse a '*loadrel' message with arguments args.
+// These are like '*loadl' messages, but with an offset from the selection in place of the index.
+func parseItemInsertRelativeMessage(itype ItemType, args []string) (interface{}, error) {
+	rq, err := parseItemAddMessage(itype, args)
+	if err != nil {
+		return nil, err
+	}
+	add := rq.(AddItemRequest)
+	return InsertRelativeRequest{Offset: add.Index, Item: add.Item}, nil
 }
 
 // parseItemAddMessage tries to parse a '*loadl' message with arguments args.
-// We have already decided which type of item we're adding and stored its constructor in con.
-func parseItemAddMessage(con func(string, string) *Item, args []string) (interface{}, error) {
+// We have already decided which type of item we're adding and stored it in itype.
+func parseItemAddMessage(itype ItemType, args []string) (interface{}, error) {
 	if len(args) != 3 {
 		return nil, fmt.Errorf("bad arity")
 	}
@@ -85,10 +632,105 @@ func parseItemAddMessage(con func(string, string) *Item, args []string) (interfa
 	hash := args[1]
 	payload := args[2]
 
-	item := con(hash, payload)
+	item := NewItem(itype, hash, payload)
+	return AddItemRequest{Index: index, Item: *item}, nil
+}
+
+// parseBloadlMessage tries to parse a 'bloadl' message.
+// This is the only message whose payload is a binary word: other '*loadl' messages pass payloads through verbatim,
+// even if they happen to start with BinaryWordPrefix.
+func parseBloadlMessage(args []string) (interface{}, error) {
+	if len(args) != 4 {
+		return nil, fmt.Errorf("bad arity")
+	}
+
+	itype, err := ParseItemType(args[0])
+	if err != nil {
+		return nil, err
+	}
+	index, err := strconv.Atoi(args[1])
+	if err != nil {
+		return nil, err
+	}
+	hash := args[2]
+	data, err := decodeBinaryWord(args[3])
+	if err != nil {
+		return nil, err
+	}
+
+	item := NewBinaryItem(itype, hash, data)
 	return AddItemRequest{Index: index, Item: *item}, nil
 }
 
+// parseUpdateMessage tries to parse an 'update' message.
+// Its arguments are the index and current hash of the item, then the item's new type, hash, and payload.
+func parseUpdateMessage(args []string) (interface{}, error) {
+	return parseItemUpdateMessage(args, func(itype ItemType, hash, payload string) (*Item, error) {
+		return NewItem(itype, hash, payload), nil
+	})
+}
+
+// parseBupdateMessage tries to parse a 'bupdate' message.
+// This is 'update' with a binary word for the new payload, as 'bloadl' is to the other '*loadl' messages.
+func parseBupdateMessage(args []string) (interface{}, error) {
+	return parseItemUpdateMessage(args, func(itype ItemType, hash, payload string) (*Item, error) {
+		data, err := decodeBinaryWord(payload)
+		if err != nil {
+			return nil, err
+		}
+		return NewBinaryItem(itype, hash, data), nil
+	})
+}
+
+// parseItemUpdateMessage tries to parse an '*update' message with arguments args, using mk to make the new item.
+func parseItemUpdateMessage(args []string, mk func(itype ItemType, hash, payload string) (*Item, error)) (interface{}, error) {
+	if len(args) != 5 {
+		return nil, fmt.Errorf("bad arity")
+	}
+
+	index, err := strconv.Atoi(args[0])
+	if err != nil {
+		return nil, err
+	}
+	itype, err := ParseItemType(args[2])
+	if err != nil {
+		return nil, err
+	}
+	item, err := mk(itype, args[3], args[4])
+	if err != nil {
+		return nil, err
+	}
+	return UpdateItemRequest{Index: index, Hash: args[1], Item: *item}, nil
+}
+
+// decodeBinaryWord tries to decode w as a binary word.
+// It fails if w doesn't have the binary word prefix, isn't valid base64, or decodes to more than MaxBinaryLen bytes.
+func decodeBinaryWord(w string) ([]byte, error) {
+	if !strings.HasPrefix(w, BinaryWordPrefix) {
+		return nil, fmt.Errorf("binary word must start with %q", BinaryWordPrefix)
+	}
+	enc := w[len(BinaryWordPrefix):]
+
+	// Check the size before decoding, so oversized words don't cost us an allocation.
+	if base64.StdEncoding.EncodedLen(MaxBinaryLen) < len(enc) {
+		return nil, fmt.Errorf("binary word too long: over %d bytes", MaxBinaryLen)
+	}
+
+	data, err := base64.StdEncoding.DecodeString(enc)
+	if err != nil {
+		return nil, fmt.Errorf("bad binary word: %w", err)
+	}
+	if MaxBinaryLen < len(data) {
+		return nil, fmt.Errorf("binary word too long: %d bytes, max %d", len(data), MaxBinaryLen)
+	}
+	return data, nil
+}
+
+// encodeBinaryWord encodes data as a binary word.
+func encodeBinaryWord(data []byte) string {
+	return BinaryWordPrefix + base64.StdEncoding.EncodeToString(data)
+}
+
 //
 // Response emitting
 //
@@ -97,14 +739,66 @@ func parseItemAddMessage(con func(string, string) *Item, args []string) (interfa
 // It sends response messages to msgTx.
 func (l *List) EmitBifrostResponse(tag string, rbody interface{}, msgTx chan<- message.Message) (err error) {
 	switch r := rbody.(type) {
+	case AirTimeResponse:
+		err = handleAirTime(tag, r, msgTx)
 	case AutoModeResponse:
 		err = handleAutoMode(tag, r, msgTx)
+	case AutoModesResponse:
+		err = handleAutoModes(tag, r, msgTx)
+	case ByTypeResponse:
+		err = handleByType(tag, r, msgTx)
+	case ContextResponse:
+		err = handleContext(tag, r, msgTx)
+	case DiffResponse:
+		err = l.handleDiff(tag, r, msgTx)
+	case DurationResponse:
+		err = handleDuration(tag, r, msgTx)
+	case ElapsedResponse:
+		err = handleElapsed(tag, r, msgTx)
+	case ExhaustedResponse:
+		err = handleExhausted(tag, r, msgTx)
+	case ExportResponse:
+		err = handleExport(tag, r, msgTx)
+	case FallbackResponse:
+		err = handleFallback(tag, r, msgTx)
+	case FinishedResponse:
+		err = handleFinished(tag, r, msgTx)
 	case FreezeResponse:
 		err = handleFreeze(tag, r, msgTx)
+	case HistoryResponse:
+		err = handleHistory(tag, r, msgTx)
 	case ItemResponse:
 		err = handleItem(tag, r, msgTx)
+	case ItemUpdatedResponse:
+		err = handleItemUpdated(tag, r, msgTx)
+	case ItemsSwappedResponse:
+		err = handleItemsSwapped(tag, r, msgTx)
+	case ListReplacedResponse:
+		err = handleListReplaced(tag, r, msgTx)
+	case LockedResponse:
+		err = handleLocked(tag, r, msgTx)
+	case PlayResponse:
+		err = handlePlay(tag, r, msgTx)
+	case OpFailedResponse:
+		err = handleOpFailed(tag, r, msgTx)
+	case ProjectionResponse:
+		err = handleProjection(tag, r, msgTx)
+	case RejectionResponse:
+		err = handleRejection(tag, r, msgTx)
 	case SelectResponse:
 		err = handleSelect(tag, r, msgTx)
+	case StaleHashResponse:
+		err = handleStaleHash(tag, r, msgTx)
+	case StatusResponse:
+		err = handleStatus(tag, r, msgTx)
+	case StopAfterResponse:
+		err = handleStopAfter(tag, r, msgTx)
+	case StoppedResponse:
+		err = handleStopped(tag, r, msgTx)
+	case SummaryResponse:
+		err = handleSummary(tag, r, msgTx)
+	case VersionResponse:
+		err = handleVersion(tag, r, msgTx)
 	default:
 		err = fmt.Errorf("response with no message equivalent: %v", r)
 	}
@@ -112,15 +806,177 @@ func (l *List) EmitBifrostResponse(tag string, rbody interface{}, msgTx chan<- m
 	return
 }
 
+// EmitBifrostSelection handles a controller response with tag tag and body rbody for a selection-only client; see
+// controller.SelectionParser.
+// It sends a NOW message to msgTx for each response that bears on the selection, and nothing for the rest.
+// NOW's arguments are the state of the selection ('selected', 'stopped' at a stop point, 'exhausted', or 'none'),
+// then the selected item's hash, if there is one.
+func (l *List) EmitBifrostSelection(tag string, rbody interface{}, msgTx chan<- message.Message) error {
+	switch r := rbody.(type) {
+	case SelectResponse:
+		emitNow(tag, r, msgTx)
+	case ListReplacedResponse:
+		emitNow(tag, r.Selection, msgTx)
+	case StoppedResponse:
+		msgTx <- controller.NewMessage(tag, "NOW", "stopped", r.Hash)
+	case ExhaustedResponse:
+		msgTx <- controller.NewMessage(tag, "NOW", "exhausted")
+	}
+	return nil
+}
+
+// emitNow sends msgTx a NOW message for tag t announcing the selection sel.
+func emitNow(t string, sel SelectResponse, msgTx chan<- message.Message) {
+	if sel.Index < 0 {
+		msgTx <- controller.NewMessage(t, "NOW", "none")
+		return
+	}
+	msgTx <- controller.NewMessage(t, "NOW", "selected", sel.Hash)
+}
+
+// handleAirTime handles converting an AirTimeResponse r into messages for tag t.
+// Unknown air times are sent as 'unknown'.
+func handleAirTime(t string, r AirTimeResponse, msgTx chan<- message.Message) error {
+	startsIn := "unknown"
+	if r.Known {
+		startsIn = controller.FormatMillis(r.StartsIn)
+	}
+	msgTx <- controller.NewMessage(t, "AIRTIME", strconv.Itoa(r.Index), r.Hash, startsIn)
+	return nil
+}
+
+// handleProjection handles converting a ProjectionResponse r into messages for tag t.
+// The arguments are the item's index and hash, its air time and time until it starts, then the assumptions: the
+// index of the selection, and its elapsed time.
+func handleProjection(t string, r ProjectionResponse, msgTx chan<- message.Message) error {
+	msgTx <- controller.NewMessage(t, "AIRAT", strconv.Itoa(r.Index), r.Hash, controller.FormatTime(r.At),
+		controller.FormatMillis(r.StartsIn), strconv.Itoa(r.Selection), controller.FormatMillis(r.Elapsed))
+	return nil
+}
+
 // handleAutoMode handles converting an AutoModeResponse r into messages for tag t.
 func handleAutoMode(t string, r AutoModeResponse, msgTx chan<- message.Message) error {
-	msgTx <- *message.New(t, "AUTO").AddArgs(r.AutoMode.String())
+	msgTx <- controller.NewMessage(t, "AUTO", r.AutoMode.String())
+	return nil
+}
+
+// handleAutoModes handles converting an AutoModesResponse r into messages for tag t.
+// Each supported AutoMode is an argument, by name.
+func handleAutoModes(t string, r AutoModesResponse, msgTx chan<- message.Message) error {
+	names := make([]string, len(r.AutoModes))
+	for i, a := range r.AutoModes {
+		names[i] = a.String()
+	}
+	msgTx <- controller.NewMessage(t, "AUTOMODES", names...)
+	return nil
+}
+
+// handleByType handles converting a ByTypeResponse r into messages for tag t.
+// It sends BYTYPE, with the type, the number of items that follow, and 'truncated' if some were left out, or
+// 'complete' if not; then the items, as in a dump.
+func handleByType(t string, r ByTypeResponse, msgTx chan<- message.Message) error {
+	complete := "complete"
+	if r.Truncated {
+		complete = "truncated"
+	}
+	msgTx <- controller.NewMessage(t, "BYTYPE", r.Type.String(), strconv.Itoa(len(r.Items)), complete)
+	for _, ir := range r.Items {
+		if err := handleItem(t, ir, msgTx); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// handleContext handles converting a ContextResponse r into messages for tag t.
+// It sends CONTEXT, with the selected index and hash and the number of items, then each item as in a dump.
+func handleContext(t string, r ContextResponse, msgTx chan<- message.Message) error {
+	msgTx <- controller.NewMessage(t, "CONTEXT", strconv.Itoa(r.Selection.Index), r.Selection.Hash, strconv.Itoa(len(r.Items)))
+	for _, ir := range r.Items {
+		if err := handleItem(t, ir, msgTx); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// handleDiff handles converting a DiffResponse r into messages for tag t.
+// It sends DIFF, with the version after the change, then the change's messages, exactly as they were broadcast.
+func (l *List) handleDiff(t string, r DiffResponse, msgTx chan<- message.Message) error {
+	msgTx <- controller.NewMessage(t, "DIFF", strconv.FormatUint(r.Version, 10))
+	return l.EmitBifrostResponse(t, r.Change, msgTx)
+}
+
+// handleDuration handles converting a DurationResponse r into messages for tag t.
+func handleDuration(t string, r DurationResponse, msgTx chan<- message.Message) error {
+	msgTx <- controller.NewMessage(t, "DUR", strconv.Itoa(r.Index), r.Hash, controller.FormatMillis(r.Duration))
+	return nil
+}
+
+// handleElapsed handles converting an ElapsedResponse r into messages for tag t.
+// The arguments are the selection's index and hash, its elapsed time, and then either 'playing' and its start time,
+// or 'paused'.
+func handleElapsed(t string, r ElapsedResponse, msgTx chan<- message.Message) error {
+	args := []string{strconv.Itoa(r.Index), r.Hash, controller.FormatMillis(r.Elapsed), "paused"}
+	if r.Playing {
+		args = append(args[:3], "playing", controller.FormatTime(r.Started))
+	}
+	msgTx <- controller.NewMessage(t, "ELAPSED", args...)
+	return nil
+}
+
+// handleExhausted handles converting an ExhaustedResponse r into messages for tag t.
+func handleExhausted(t string, r ExhaustedResponse, msgTx chan<- message.Message) error {
+	msgTx <- controller.NewMessage(t, "EXHAUSTED")
+	return nil
+}
+
+// handleItemsSwapped handles converting an ItemsSwappedResponse r into messages for tag t.
+func handleItemsSwapped(t string, r ItemsSwappedResponse, msgTx chan<- message.Message) error {
+	msgTx <- controller.NewMessage(t, "SWAP", strconv.Itoa(r.IndexA), r.HashA, strconv.Itoa(r.IndexB), r.HashB)
+	return nil
+}
+
+// handleLocked handles converting a LockedResponse r into messages for tag t.
+func handleLocked(t string, r LockedResponse, msgTx chan<- message.Message) error {
+	msgTx <- controller.NewMessage(t, "LOCKED", strconv.Itoa(r.Index), r.Hash, formatOnOff(r.Locked))
+	return nil
+}
+
+// handleStopAfter handles converting a StopAfterResponse r into messages for tag t.
+func handleStopAfter(t string, r StopAfterResponse, msgTx chan<- message.Message) error {
+	msgTx <- controller.NewMessage(t, "STOPAFTER", strconv.Itoa(r.Index), r.Hash, formatOnOff(r.StopAfter))
+	return nil
+}
+
+// handleStopped handles converting a StoppedResponse r into messages for tag t.
+func handleStopped(t string, r StoppedResponse, msgTx chan<- message.Message) error {
+	msgTx <- controller.NewMessage(t, "STOPPED", strconv.Itoa(r.Index), r.Hash)
+	return nil
+}
+
+// handleExport handles converting an ExportResponse r into messages for tag t.
+// The JSON goes in one word, so it arrives in one piece.
+func handleExport(t string, r ExportResponse, msgTx chan<- message.Message) error {
+	msgTx <- controller.NewMessage(t, "EXPORT", string(r.JSON))
+	return nil
+}
+
+// handleFallback handles converting a FallbackResponse r into messages for tag t.
+func handleFallback(t string, r FallbackResponse, msgTx chan<- message.Message) error {
+	msgTx <- controller.NewMessage(t, "FALLBACK", strconv.Itoa(r.Index), r.Hash, formatOnOff(r.Fallback))
+	return nil
+}
+
+// handleFinished handles converting a FinishedResponse r into messages for tag t.
+func handleFinished(t string, r FinishedResponse, msgTx chan<- message.Message) error {
+	msgTx <- controller.NewMessage(t, "FINISHED", strconv.Itoa(r.Index), r.Hash)
 	return nil
 }
 
 // handleFreeze handles converting a FreezeResponse r into messages for tag t.
 func handleFreeze(t string, r FreezeResponse, msgTx chan<- message.Message) error {
-	msgTx <- *message.New(t, "COUNTL").AddArgs(strconv.Itoa(len(r)))
+	msgTx <- controller.NewMessage(t, "COUNTL", strconv.Itoa(len(r)))
 
 	// The next bit is the same as if we were loading the items--
 	// so we reuse the logic.
@@ -138,8 +994,65 @@ func handleFreeze(t string, r FreezeResponse, msgTx chan<- message.Message) erro
 	return nil
 }
 
+// handleHistory handles converting a HistoryResponse r into messages for tag t.
+func handleHistory(t string, r HistoryResponse, msgTx chan<- message.Message) error {
+	msgTx <- controller.NewMessage(t, "HISTORY", strconv.Itoa(r.Count))
+	return nil
+}
+
+// handlePlay handles converting a PlayResponse r into messages for tag t.
+// The arguments are the time of the play, then the item's type, hash, and payload, and its ID; as in updates, binary
+// payloads are sent as binary words.
+func handlePlay(t string, r PlayResponse, msgTx chan<- message.Message) error {
+	itype := r.Item.Type()
+	if itype != ItemTrack && itype != ItemText {
+		return fmt.Errorf("unknown item type %v", itype)
+	}
+
+	payload := r.Item.Payload()
+	if r.Item.IsBinary() {
+		payload = encodeBinaryWord(r.Item.Data())
+	}
+	msgTx <- controller.NewMessage(t, "PLAYED", controller.FormatTime(r.At), itype.String(), r.Item.Hash(), payload, itemID(r.Item))
+	return nil
+}
+
+// handleSummary handles converting a SummaryResponse r into messages for tag t.
+// The arguments are the numbers of items requested, applied, and rejected.
+func handleSummary(t string, r SummaryResponse, msgTx chan<- message.Message) error {
+	msgTx <- controller.NewMessage(t, "SUMMARY", strconv.Itoa(r.Requested), strconv.Itoa(r.Applied), strconv.Itoa(r.Rejected))
+	return nil
+}
+
+// handleRejection handles converting a RejectionResponse r into messages for tag t.
+// The arguments are the item's index in the request, its hash, and the reason it was left out.
+func handleRejection(t string, r RejectionResponse, msgTx chan<- message.Message) error {
+	msgTx <- controller.NewMessage(t, "REJECTED", strconv.Itoa(r.Index), r.Hash, r.Err.Error())
+	return nil
+}
+
+// handleOpFailed handles converting an OpFailedResponse r into messages for tag t.
+func handleOpFailed(t string, r OpFailedResponse, msgTx chan<- message.Message) error {
+	msgTx <- controller.NewMessage(t, "OPFAILED", strconv.Itoa(r.Op), r.Err.Error())
+	return nil
+}
+
+// handleListReplaced handles converting a ListReplacedResponse r into messages for tag t.
+// It sends REPLACEL, to tell clients to forget the old list, then the new list and selection as in a dump.
+func handleListReplaced(t string, r ListReplacedResponse, msgTx chan<- message.Message) error {
+	msgTx <- controller.NewMessage(t, "REPLACEL")
+	if err := handleFreeze(t, r.Items, msgTx); err != nil {
+		return err
+	}
+	return handleSelect(t, r.Selection, msgTx)
+}
+
 // handleItem handles converting an ItemResponse r into messages for tag t.
 func handleItem(t string, r ItemResponse, msgTx chan<- message.Message) error {
+	if r.Item.IsBinary() {
+		return handleBinaryItem(t, r, msgTx)
+	}
+
 	var word string
 	switch r.Item.Type() {
 	case ItemTrack:
@@ -150,13 +1063,65 @@ func handleItem(t string, r ItemResponse, msgTx chan<- message.Message) error {
 		return fmt.Errorf("unknown item type %v", r.Item.Type())
 	}
 
-	msgTx <- *message.New(t, word).AddArgs(strconv.Itoa(r.Index), r.Item.Hash(), r.Item.Payload())
+	msgTx <- controller.NewMessage(t, word, strconv.Itoa(r.Index), r.Item.Hash(), r.Item.Payload(), itemID(r.Item))
 	return nil
 }
 
+// handleBinaryItem handles converting an ItemResponse r for an item with binary data into messages for tag t.
+func handleBinaryItem(t string, r ItemResponse, msgTx chan<- message.Message) error {
+	itype := r.Item.Type()
+	if itype != ItemTrack && itype != ItemText {
+		return fmt.Errorf("unknown item type %v", itype)
+	}
+
+	msgTx <- controller.NewMessage(t, "BLOADL", itype.String(), strconv.Itoa(r.Index), r.Item.Hash(), encodeBinaryWord(r.Item.Data()), itemID(r.Item))
+	return nil
+}
+
+// handleItemUpdated handles converting an ItemUpdatedResponse r into messages for tag t.
+// The arguments are the index and old hash of the item, then its new type, hash, and payload, and its ID; items with
+// binary data are sent with BUPDATEL, and their payload as a binary word.
+func handleItemUpdated(t string, r ItemUpdatedResponse, msgTx chan<- message.Message) error {
+	itype := r.Item.Type()
+	if itype != ItemTrack && itype != ItemText {
+		return fmt.Errorf("unknown item type %v", itype)
+	}
+
+	word, payload := "UPDATEL", r.Item.Payload()
+	if r.Item.IsBinary() {
+		word, payload = "BUPDATEL", encodeBinaryWord(r.Item.Data())
+	}
+	msgTx <- controller.NewMessage(t, word, strconv.Itoa(r.Index), r.OldHash, itype.String(), r.Item.Hash(), payload, itemID(r.Item))
+	return nil
+}
+
+// itemID formats the ID of item for use in a message.
+// Item announcements carry the ID as their last argument, so clients can track items as indices shift.
+func itemID(item Item) string {
+	return strconv.FormatUint(item.ID(), 10)
+}
+
 // handleSelect handles converting a SelectResponse r into messages for tag t.
 func handleSelect(t string, r SelectResponse, msgTx chan<- message.Message) error {
-	msg := *message.New(t, "SEL").AddArgs(strconv.Itoa(r.Index), r.Hash)
-	msgTx <- msg
+	msgTx <- controller.NewMessage(t, "SEL", strconv.Itoa(r.Index), r.Hash)
+	return nil
+}
+
+// handleStaleHash handles converting a StaleHashResponse r into messages for tag t.
+func handleStaleHash(t string, r StaleHashResponse, msgTx chan<- message.Message) error {
+	msgTx <- controller.NewMessage(t, "STALE", strconv.Itoa(r.Index), r.Hash, r.Current)
+	return nil
+}
+
+// handleStatus handles converting a StatusResponse r into messages for tag t.
+// The arguments are the item count, the selected index and hash, and the automode.
+func handleStatus(t string, r StatusResponse, msgTx chan<- message.Message) error {
+	msgTx <- controller.NewMessage(t, controller.RsStatus, strconv.Itoa(r.Count), strconv.Itoa(r.Selection.Index), r.Selection.Hash, r.AutoMode.String())
+	return nil
+}
+
+// handleVersion handles converting a VersionResponse r into messages for tag t.
+func handleVersion(t string, r VersionResponse, msgTx chan<- message.Message) error {
+	msgTx <- controller.NewMessage(t, "VERSION", strconv.FormatUint(r.Version, 10))
 	return nil
 }
@@ -0,0 +1,466 @@
+package list_test
+
+import (
+	"bytes"
+	"encoding/base64"
+	"reflect"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/UniversityRadioYork/bifrost-go/message"
+
+	"github.com/UniversityRadioYork/baps3d/list"
+)
+
+// TestList_ParseBifrostRequest_Binary checks that binary payload words round-trip through parsing and emitting.
+func TestList_ParseBifrostRequest_Binary(t *testing.T) {
+	l := list.New()
+	want := []byte{0x00, 0xff, '\n', ' ', 'x'}
+	word := list.BinaryWordPrefix + base64.StdEncoding.EncodeToString(want)
+
+	rq, err := l.ParseBifrostRequest("bloadl", []string{"track", "0", "abc", word})
+	if err != nil {
+		t.Fatalf("unexpected parse error: %v", err)
+	}
+	add, ok := rq.(list.AddItemRequest)
+	if !ok {
+		t.Fatalf("unexpected request type: %T", rq)
+	}
+	if !add.Item.IsBinary() {
+		t.Fatal("binary word didn't make a binary item")
+	}
+	if add.Item.Type() != list.ItemTrack {
+		t.Errorf("item type: got %v, want track", add.Item.Type())
+	}
+	if !bytes.Equal(add.Item.Data(), want) {
+		t.Errorf("decoded data: got %v, want %v", add.Item.Data(), want)
+	}
+
+	msgs := make(chan message.Message, 1)
+	if err := l.EmitBifrostResponse("!", list.ItemResponse(add), msgs); err != nil {
+		t.Fatalf("unexpected emit error: %v", err)
+	}
+	m := <-msgs
+	if m.Word() != "BLOADL" {
+		t.Errorf("emitted word: got %s, want BLOADL", m.Word())
+	}
+	if got := m.Args()[3]; got != word {
+		t.Errorf("re-encoded word: got %q, want %q", got, word)
+	}
+}
+
+// TestList_ParseBifrostRequest_BinaryInvalid checks that malformed and oversized binary words are rejected.
+func TestList_ParseBifrostRequest_BinaryInvalid(t *testing.T) {
+	l := list.New()
+	cases := map[string]string{
+		"no prefix":  base64.StdEncoding.EncodeToString([]byte("foo")),
+		"not base64": list.BinaryWordPrefix + "!!!",
+		"oversized":  list.BinaryWordPrefix + base64.StdEncoding.EncodeToString(make([]byte, list.MaxBinaryLen+1)),
+		"overlong":   list.BinaryWordPrefix + strings.Repeat("A", base64.StdEncoding.EncodedLen(list.MaxBinaryLen)+4),
+	}
+
+	for name, word := range cases {
+		if _, err := l.ParseBifrostRequest("bloadl", []string{"track", "0", "abc", word}); err == nil {
+			t.Errorf("%s: expected an error", name)
+		}
+	}
+}
+
+// TestList_ParseBifrostRequest_TextualPrefix checks that textual payloads that look like binary words pass through.
+func TestList_ParseBifrostRequest_TextualPrefix(t *testing.T) {
+	l := list.New()
+	path := list.BinaryWordPrefix + "foo.mp3"
+
+	rq, err := l.ParseBifrostRequest("floadl", []string{"0", "abc", path})
+	if err != nil {
+		t.Fatalf("unexpected parse error: %v", err)
+	}
+	add := rq.(list.AddItemRequest)
+	if add.Item.IsBinary() {
+		t.Error("textual payload parsed as binary")
+	}
+	if add.Item.Payload() != path {
+		t.Errorf("payload: got %q, want %q", add.Item.Payload(), path)
+	}
+}
+
+// TestList_EmitBifrostResponse_ItemID checks that item announcements carry the item's ID as their last argument.
+func TestList_EmitBifrostResponse_ItemID(t *testing.T) {
+	l := list.New()
+
+	var announced []list.ItemResponse
+	bcastCb := func(r interface{}) {
+		if ir, ok := r.(list.ItemResponse); ok {
+			announced = append(announced, ir)
+		}
+	}
+	for _, h := range []string{"abc", "def"} {
+		rq := list.AddItemRequest{Index: 0, Item: *list.NewTrack(h, h+".mp3")}
+		if err := l.HandleRequest(func(interface{}) {}, bcastCb, rq); err != nil {
+			t.Fatalf("unexpected error adding %s: %v", h, err)
+		}
+	}
+
+	msgs := make(chan message.Message, len(announced))
+	for i, ir := range announced {
+		if err := l.EmitBifrostResponse("!", ir, msgs); err != nil {
+			t.Fatalf("unexpected emit error: %v", err)
+		}
+		m := <-msgs
+		args := m.Args()
+		want := strconv.FormatUint(uint64(i+1), 10)
+		if got := args[len(args)-1]; got != want {
+			t.Errorf("%s: ID argument: got %s, want %s", m.Word(), got, want)
+		}
+	}
+}
+
+// TestList_RestOfLine checks that only 'tloadlr' opts into a rest-of-line argument, and that it parses like 'tloadl'.
+func TestList_RestOfLine(t *testing.T) {
+	l := list.New()
+	if fixed, ok := l.RestOfLine("tloadlr"); !ok || fixed != 2 {
+		t.Errorf("tloadlr: got (%d, %v), want (2, true)", fixed, ok)
+	}
+	for _, w := range []string{"tloadl", "floadl", "sel"} {
+		if _, ok := l.RestOfLine(w); ok {
+			t.Errorf("%s: unexpectedly opted into rest-of-line", w)
+		}
+	}
+
+	body := "Say hello to 'everyone'"
+	rq, err := l.ParseBifrostRequest("tloadlr", []string{"0", "abc", body})
+	if err != nil {
+		t.Fatalf("unexpected parse error: %v", err)
+	}
+	add := rq.(list.AddItemRequest)
+	if add.Item.Type() != list.ItemText || add.Item.Payload() != body {
+		t.Errorf("got %v item with payload %q, want text item with payload %q", add.Item.Type(), add.Item.Payload(), body)
+	}
+}
+
+// TestList_Bifrost_Timing checks that timing requests parse from milliseconds, and that air times emit as milliseconds
+// or 'unknown'.
+func TestList_Bifrost_Timing(t *testing.T) {
+	l := list.New()
+
+	rq, err := l.ParseBifrostRequest("dur", []string{"1", "abc", "1500"})
+	if err != nil {
+		t.Fatalf("unexpected dur parse error: %v", err)
+	}
+	if want := (list.SetDurationRequest{Index: 1, Hash: "abc", Duration: 1500 * time.Millisecond}); rq != want {
+		t.Errorf("dur: got %+v, want %+v", rq, want)
+	}
+	if _, err := l.ParseBifrostRequest("elapsed", []string{"-5"}); err == nil {
+		t.Error("elapsed: expected an error on a negative duration")
+	}
+
+	msgs := make(chan message.Message, 2)
+	ats := []list.AirTimeResponse{
+		{Index: 1, Hash: "abc", StartsIn: 90 * time.Second, Known: true},
+		{Index: 2, Hash: "def", Known: false},
+	}
+	for _, at := range ats {
+		if err := l.EmitBifrostResponse("!", at, msgs); err != nil {
+			t.Fatalf("unexpected emit error: %v", err)
+		}
+	}
+	for _, want := range []string{"90000", "unknown"} {
+		m := <-msgs
+		if m.Word() != "AIRTIME" || m.Args()[2] != want {
+			t.Errorf("got %s %v, want AIRTIME ending in %s", m.Word(), m.Args(), want)
+		}
+	}
+}
+
+// TestList_ParseBifrostRequest_Next checks that 'next' parses as a NextNRequest when it has a count, and that 'prev'
+// takes the same wrap option.
+func TestList_ParseBifrostRequest_Next(t *testing.T) {
+	l := list.New()
+	cases := []struct {
+		word string
+		args []string
+		want interface{}
+	}{
+		{"next", nil, list.NextRequest{}},
+		{"next", []string{"wrap"}, list.NextRequest{Wrap: true}},
+		{"next", []string{"3"}, list.NextNRequest{Count: 3}},
+		{"next", []string{"3", "wrap"}, list.NextNRequest{Count: 3, Wrap: true}},
+		{"next", []string{"3", "4"}, nil},
+		{"next", []string{"wrap", "3"}, nil},
+		{"prev", nil, list.PreviousRequest{}},
+		{"prev", []string{"wrap"}, list.PreviousRequest{Wrap: true}},
+		{"prev", []string{"3"}, nil},
+	}
+
+	for _, c := range cases {
+		got, err := l.ParseBifrostRequest(c.word, c.args)
+		if c.want == nil {
+			if err == nil {
+				t.Errorf("%s %v: expected an error", c.word, c.args)
+			}
+			continue
+		}
+		if err != nil || got != c.want {
+			t.Errorf("%s %v: got (%v, %v), want %v", c.word, c.args, got, err, c.want)
+		}
+	}
+}
+
+// TestList_Bifrost_Update tests parsing 'update' and 'bupdate', and emitting item updates.
+func TestList_Bifrost_Update(t *testing.T) {
+	l := list.New()
+	data := []byte{0x00, 'x'}
+	bword := list.BinaryWordPrefix + base64.StdEncoding.EncodeToString(data)
+
+	cases := []struct {
+		word string
+		args []string
+		emit string
+	}{
+		{"update", []string{"1", "old", "track", "new", "new.mp3"}, "! UPDATEL 1 old track new new.mp3 0\n"},
+		{"update", []string{"1", "old", "text", "new", bword}, "! UPDATEL 1 old text new " + bword + " 0\n"},
+		{"bupdate", []string{"1", "old", "track", "new", bword}, "! BUPDATEL 1 old track new " + bword + " 0\n"},
+	}
+	for _, c := range cases {
+		rq, err := l.ParseBifrostRequest(c.word, c.args)
+		if err != nil {
+			t.Fatalf("%s %q: unexpected parse error: %v", c.word, c.args, err)
+		}
+		u, ok := rq.(list.UpdateItemRequest)
+		if !ok || u.Index != 1 || u.Hash != "old" || u.Item.Hash() != "new" {
+			t.Fatalf("%s %q: got %#v", c.word, c.args, rq)
+		}
+
+		msgs := make(chan message.Message, 1)
+		rs := list.ItemUpdatedResponse{Index: u.Index, OldHash: u.Hash, Item: u.Item}
+		if err := l.EmitBifrostResponse("!", rs, msgs); err != nil {
+			t.Fatalf("%s %q: unexpected emit error: %v", c.word, c.args, err)
+		}
+		m := <-msgs
+		if got := m.String(); got != c.emit {
+			t.Errorf("%s %q: emitted %q, want %q", c.word, c.args, got, c.emit)
+		}
+	}
+
+	for _, args := range [][]string{
+		{"1", "old", "track", "new"},
+		{"x", "old", "track", "new", "new.mp3"},
+		{"1", "old", "video", "new", "new.mp3"},
+	} {
+		if _, err := l.ParseBifrostRequest("update", args); err == nil {
+			t.Errorf("update %q: expected an error", args)
+		}
+	}
+	if _, err := l.ParseBifrostRequest("bupdate", []string{"1", "old", "track", "new", "new.mp3"}); err == nil {
+		t.Error("bupdate with a textual payload: expected an error")
+	}
+}
+
+// TestList_Bifrost_Swap checks that 'swap' parses, and that a swap is broadcast as a single SWAP.
+func TestList_Bifrost_Swap(t *testing.T) {
+	l := list.New()
+	for i, h := range []string{"a", "b"} {
+		if err := l.Add(list.NewTrack(h, h+".mp3"), i); err != nil {
+			t.Fatalf("couldn't add item: %v", err)
+		}
+	}
+
+	rq, err := l.ParseBifrostRequest("swap", []string{"0", "a", "1", "b"})
+	if err != nil {
+		t.Fatalf("unexpected parse error: %v", err)
+	}
+	if want := (list.SwapItemsRequest{IndexA: 0, HashA: "a", IndexB: 1, HashB: "b"}); rq != want {
+		t.Fatalf("got request %+v, want %+v", rq, want)
+	}
+	for _, args := range [][]string{{"0", "a", "1"}, {"0", "a", "x", "b"}} {
+		if _, err := l.ParseBifrostRequest("swap", args); err == nil {
+			t.Errorf("swap %q: expected an error", args)
+		}
+	}
+
+	msgs := make(chan message.Message, 2)
+	bcastCb := func(rbody interface{}) {
+		if err := l.EmitBifrostResponse("!", rbody, msgs); err != nil {
+			t.Fatalf("unexpected emit error: %v", err)
+		}
+	}
+	if err := l.HandleRequest(func(interface{}) {}, bcastCb, rq); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	close(msgs)
+	var got []string
+	for m := range msgs {
+		got = append(got, m.String())
+	}
+	if want := []string{"! SWAP 0 a 1 b\n"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("got messages %q, want %q", got, want)
+	}
+}
+
+// TestList_Bifrost_AutoModes checks that 'automodes' gets every supported automode, by name, in one AUTOMODES reply.
+func TestList_Bifrost_AutoModes(t *testing.T) {
+	l := list.New()
+
+	rq, err := l.ParseBifrostRequest("automodes", nil)
+	if err != nil {
+		t.Fatalf("unexpected parse error: %v", err)
+	}
+	if _, err := l.ParseBifrostRequest("automodes", []string{"x"}); err == nil {
+		t.Error("automodes with an argument: expected an error")
+	}
+
+	var replies []interface{}
+	replyCb := func(r interface{}) { replies = append(replies, r) }
+	if err := l.HandleRequest(replyCb, func(interface{}) {}, rq); err != nil {
+		t.Fatalf("unexpected error handling %T: %v", rq, err)
+	}
+	if len(replies) != 1 {
+		t.Fatalf("got %d replies, want 1", len(replies))
+	}
+
+	msgs := make(chan message.Message, 1)
+	if err := l.EmitBifrostResponse("t", replies[0], msgs); err != nil {
+		t.Fatalf("unexpected emit error: %v", err)
+	}
+	m := <-msgs
+	if got, want := m.String(), "t AUTOMODES off drop next shuffle\n"; got != want {
+		t.Errorf("emitted %q, want %q", got, want)
+	}
+}
+
+// TestList_Bifrost_Context checks that 'context' defaults its radius, and gets a CONTEXT line then the items around
+// the selection, with their indices in the whole list.
+func TestList_Bifrost_Context(t *testing.T) {
+	l := list.New()
+	for i, h := range []string{"a", "b", "c", "d", "e"} {
+		if err := l.Add(list.NewTrack(h, h+".mp3"), i); err != nil {
+			t.Fatalf("couldn't add item: %v", err)
+		}
+	}
+	if _, err := l.Select(3, "d"); err != nil {
+		t.Fatalf("couldn't select item: %v", err)
+	}
+
+	rq, err := l.ParseBifrostRequest("context", nil)
+	if err != nil {
+		t.Fatalf("unexpected parse error: %v", err)
+	}
+	if want := (list.ContextDumpRequest{Radius: list.DefaultContextRadius}); rq != want {
+		t.Errorf("parsed %#v, want %#v", rq, want)
+	}
+	if rq, err = l.ParseBifrostRequest("context", []string{"1"}); err != nil {
+		t.Fatalf("unexpected parse error: %v", err)
+	}
+
+	var replies []interface{}
+	replyCb := func(r interface{}) { replies = append(replies, r) }
+	if err := l.HandleRequest(replyCb, func(interface{}) {}, rq); err != nil {
+		t.Fatalf("unexpected error handling %T: %v", rq, err)
+	}
+	if len(replies) != 1 {
+		t.Fatalf("got %d replies, want 1", len(replies))
+	}
+
+	msgs := make(chan message.Message, 4)
+	if err := l.EmitBifrostResponse("t", replies[0], msgs); err != nil {
+		t.Fatalf("unexpected emit error: %v", err)
+	}
+	close(msgs)
+	var got []string
+	for m := range msgs {
+		got = append(got, m.String())
+	}
+	want := []string{"t CONTEXT 3 d 3\n", "t FLOADL 2 c c.mp3 3\n", "t FLOADL 3 d d.mp3 4\n", "t FLOADL 4 e e.mp3 5\n"}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("emitted %q, want %q", got, want)
+	}
+}
+
+// TestList_EmitBifrostSelection tests the NOW notifications selection-only clients get for each response that bears
+// on the selection, and that they get nothing for others.
+func TestList_EmitBifrostSelection(t *testing.T) {
+	l := list.New()
+	cases := []struct {
+		rbody interface{}
+		want  string
+	}{
+		{list.SelectResponse{Index: -1, Hash: "(undefined)"}, "! NOW none\n"},
+		{list.SelectResponse{Index: 2, Hash: "abc"}, "! NOW selected abc\n"},
+		{list.ListReplacedResponse{Selection: list.SelectResponse{Index: 0, Hash: "def"}}, "! NOW selected def\n"},
+		{list.StoppedResponse{Index: 1, Hash: "ghi"}, "! NOW stopped ghi\n"},
+		{list.ExhaustedResponse{}, "! NOW exhausted\n"},
+		{list.AutoModeResponse{AutoMode: list.AutoNext}, ""},
+		{list.DurationResponse{Index: 2, Hash: "abc", Duration: time.Second}, ""},
+	}
+	for _, c := range cases {
+		msgs := make(chan message.Message, 1)
+		if err := l.EmitBifrostSelection("!", c.rbody, msgs); err != nil {
+			t.Fatalf("%+v: unexpected error: %v", c.rbody, err)
+		}
+		close(msgs)
+		var got string
+		for m := range msgs {
+			got += m.String()
+		}
+		if got != c.want {
+			t.Errorf("%+v: got %q, want %q", c.rbody, got, c.want)
+		}
+	}
+}
+
+// TestList_Bifrost_ByType tests the 'bytype' request, and that its reply gives only items of the type, with their
+// indices, marking whether any were left out.
+func TestList_Bifrost_ByType(t *testing.T) {
+	l := list.New()
+	// There are MaxTypeMatches tracks, and one text item more than that.
+	for i := 0; i <= 2*list.MaxTypeMatches; i++ {
+		h := strconv.Itoa(i)
+		item := list.NewText(h, h)
+		if i%2 == 1 {
+			item = list.NewTrack(h, h+".mp3")
+		}
+		if err := l.Add(item, i); err != nil {
+			t.Fatalf("couldn't add %s: %v", h, err)
+		}
+	}
+	for _, bad := range []string{"none", "jingle"} {
+		if _, err := l.ParseBifrostRequest("bytype", []string{bad}); err == nil {
+			t.Errorf("expected an error for item type %q", bad)
+		}
+	}
+
+	for _, c := range []struct {
+		itype string
+		want  string
+		first string
+	}{
+		{"track", "! BYTYPE track 1000 complete\n", "! FLOADL 1 1 1.mp3 2\n"},
+		{"text", "! BYTYPE text 1000 truncated\n", "! TLOADL 0 0 0 1\n"},
+	} {
+		rq, err := l.ParseBifrostRequest("bytype", []string{c.itype})
+		if err != nil {
+			t.Fatalf("unexpected parse error: %v", err)
+		}
+		msgs := make(chan message.Message, list.MaxTypeMatches+1)
+		replyCb := func(rbody interface{}) {
+			if err := l.EmitBifrostResponse("!", rbody, msgs); err != nil {
+				t.Fatalf("unexpected emit error: %v", err)
+			}
+		}
+		if err := l.HandleRequest(replyCb, func(interface{}) {}, rq); err != nil {
+			t.Fatalf("unexpected error handling %+v: %v", rq, err)
+		}
+		close(msgs)
+
+		var got []string
+		for m := range msgs {
+			got = append(got, m.String())
+		}
+		if got[0] != c.want || got[1] != c.first || len(got) != list.MaxTypeMatches+1 {
+			t.Errorf("%s: got %d messages starting %q, want %d starting %q, %q", c.itype, len(got), got[:2],
+				list.MaxTypeMatches+1, c.want, c.first)
+		}
+	}
+}
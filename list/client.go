@@ -0,0 +1,323 @@
+package list
+
+// File client.go contains Client, a typed wrapper over a controller.Client connected to a List.
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/UniversityRadioYork/baps3d/controller"
+)
+
+// Client is a handle on a Controller whose state is a List, for Go programs embedding baps3d.
+// Each of its methods sends one of the requests in 'request.go', waits for the Controller to acknowledge it, and
+// returns the replies as their response types, so callers needn't manage reply channels.
+//
+// Methods fail with any error the List gave the request (such as controller.ErrStateChanged for a stale hash), with
+// ctx's error if ctx finishes before the Controller picks the request up, or with controller.ErrControllerShutDown if
+// the Controller stops.
+// ctx only bounds sending: once the Controller has the request, it always acknowledges it.
+//
+// A Client takes every broadcast sent to its underlying controller.Client, so nothing else may read that Client's
+// Rx.
+// It is safe to use from several goroutines at once.
+type Client struct {
+	c *controller.Client
+	// done closes once c's Rx has closed.
+	done chan struct{}
+}
+
+// NewClient wraps c, which must be connected to a List's Controller, as a Client.
+// It starts taking broadcasts from c straight away, passing each to onBroadcast (if non-nil) from a goroutine of its
+// own, so onBroadcast must not block for long: the Controller waits on it.
+func NewClient(c *controller.Client, onBroadcast func(controller.Response)) *Client {
+	lc := Client{c: c, done: make(chan struct{})}
+	go func() {
+		for r := range c.Rx {
+			if onBroadcast != nil {
+				onBroadcast(r)
+			}
+		}
+		close(lc.done)
+	}()
+	return &lc
+}
+
+// HangUp detaches c from its Controller, leaving the Controller and its other clients running; see
+// controller.Client.HangUp.
+// Broadcasts the Controller sends while c hangs up may be discarded rather than passed to onBroadcast; once HangUp
+// returns without error, onBroadcast won't be called again.
+// c must not be used after HangUp.
+func (c *Client) HangUp(ctx context.Context) error {
+	if err := c.c.HangUp(ctx); err != nil {
+		return err
+	}
+	select {
+	case <-c.done:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// Shutdown asks c's Controller to shut down; see controller.Client.Shutdown.
+func (c *Client) Shutdown(ctx context.Context) error {
+	return c.c.Shutdown(ctx)
+}
+
+// Dump gets a snapshot of every item in the List.
+func (c *Client) Dump(ctx context.Context) ([]Item, error) {
+	var items []Item
+	// The dump holds the rest of the List's state too, but Status is a cheaper way to get that.
+	err := c.request(ctx, controller.DumpRequest{}, func(r controller.Response) error {
+		if f, ok := r.Body.(FreezeResponse); ok {
+			items = f
+		}
+		return nil
+	})
+	return items, err
+}
+
+// Status gets a summary of the List's state; see StatusRequest.
+func (c *Client) Status(ctx context.Context) (StatusResponse, error) {
+	var st StatusResponse
+	err := c.request(ctx, StatusRequest{}, func(r controller.Response) error {
+		var ok bool
+		if st, ok = r.Body.(StatusResponse); !ok {
+			return unexpectedResponse(r)
+		}
+		return nil
+	})
+	return st, err
+}
+
+// Context gets the selection, and the items within radius places either side of it; see ContextDumpRequest.
+func (c *Client) Context(ctx context.Context, radius int) (ContextResponse, error) {
+	var cr ContextResponse
+	err := c.request(ctx, ContextDumpRequest{Radius: radius}, func(r controller.Response) error {
+		var ok bool
+		if cr, ok = r.Body.(ContextResponse); !ok {
+			return unexpectedResponse(r)
+		}
+		return nil
+	})
+	return cr, err
+}
+
+// ByType gets the items of type itype, with their indices; see ListByTypeRequest.
+func (c *Client) ByType(ctx context.Context, itype ItemType) (ByTypeResponse, error) {
+	var br ByTypeResponse
+	err := c.request(ctx, ListByTypeRequest{Type: itype}, func(r controller.Response) error {
+		var ok bool
+		if br, ok = r.Body.(ByTypeResponse); !ok {
+			return unexpectedResponse(r)
+		}
+		return nil
+	})
+	return br, err
+}
+
+// Export gets the List's state as JSON; see ExportRequest.
+func (c *Client) Export(ctx context.Context) ([]byte, error) {
+	var bs []byte
+	err := c.request(ctx, ExportRequest{}, func(r controller.Response) error {
+		er, ok := r.Body.(ExportResponse)
+		if !ok {
+			return unexpectedResponse(r)
+		}
+		bs = er.JSON
+		return nil
+	})
+	return bs, err
+}
+
+// History gets the n most recent plays the List remembers, oldest first, or all of them if n is 0; see
+// HistoryRequest.
+func (c *Client) History(ctx context.Context, n int) ([]Play, error) {
+	var plays []Play
+	err := c.request(ctx, HistoryRequest{Count: n}, func(r controller.Response) error {
+		switch b := r.Body.(type) {
+		case HistoryResponse:
+			plays = make([]Play, 0, b.Count)
+		case PlayResponse:
+			plays = append(plays, Play(b))
+		default:
+			return unexpectedResponse(r)
+		}
+		return nil
+	})
+	return plays, err
+}
+
+// AirTime projects when the item at index, which must have hash hash, goes to air; see AirTimeRequest.
+func (c *Client) AirTime(ctx context.Context, index int, hash string) (Projection, error) {
+	var p Projection
+	err := c.request(ctx, AirTimeRequest{Index: index, Hash: hash}, func(r controller.Response) error {
+		switch b := r.Body.(type) {
+		case ProjectionResponse:
+			p = Projection(b)
+		case StaleHashResponse:
+			// The List has corrected a stale hash; the projection follows.
+		default:
+			return unexpectedResponse(r)
+		}
+		return nil
+	})
+	return p, err
+}
+
+// Elapsed gets how much of the selected item has played, and whether it is playing; see ElapsedRequest.
+func (c *Client) Elapsed(ctx context.Context) (ElapsedResponse, error) {
+	var er ElapsedResponse
+	err := c.request(ctx, ElapsedRequest{}, func(r controller.Response) error {
+		var ok bool
+		if er, ok = r.Body.(ElapsedResponse); !ok {
+			return unexpectedResponse(r)
+		}
+		return nil
+	})
+	return er, err
+}
+
+// SetPlaying reports whether the selected item is playing or paused; see SetPlayingRequest.
+func (c *Client) SetPlaying(ctx context.Context, playing bool) error {
+	return c.request(ctx, SetPlayingRequest{Playing: playing}, nil)
+}
+
+// AutoModes gets the AutoModes the List supports, in order.
+func (c *Client) AutoModes(ctx context.Context) ([]AutoMode, error) {
+	var ms []AutoMode
+	err := c.request(ctx, AutoModesRequest{}, func(r controller.Response) error {
+		am, ok := r.Body.(AutoModesResponse)
+		if !ok {
+			return unexpectedResponse(r)
+		}
+		ms = am.AutoModes
+		return nil
+	})
+	return ms, err
+}
+
+// SetAutoMode changes the List's AutoMode to mode.
+func (c *Client) SetAutoMode(ctx context.Context, mode AutoMode) error {
+	return c.request(ctx, SetAutoModeRequest{AutoMode: mode}, nil)
+}
+
+// Select selects the item at index, which must have hash hash.
+func (c *Client) Select(ctx context.Context, index int, hash string) error {
+	return c.request(ctx, SetSelectRequest{Index: index, Hash: hash}, nil)
+}
+
+// Next moves the selection to the next selectable item; see NextRequest.
+func (c *Client) Next(ctx context.Context, wrap bool) error {
+	return c.request(ctx, NextRequest{Wrap: wrap}, nil)
+}
+
+// Previous moves the selection back to the previous selectable item; see PreviousRequest.
+func (c *Client) Previous(ctx context.Context, wrap bool) error {
+	return c.request(ctx, PreviousRequest{Wrap: wrap}, nil)
+}
+
+// SelectRelative moves the selection by offset selectable items; see SelectRelativeRequest.
+func (c *Client) SelectRelative(ctx context.Context, offset int, wrap bool) error {
+	return c.request(ctx, SelectRelativeRequest{Offset: offset, Wrap: wrap}, nil)
+}
+
+// Add enqueues item in front of index.
+func (c *Client) Add(ctx context.Context, index int, item Item) error {
+	return c.request(ctx, AddItemRequest{Index: index, Item: item}, nil)
+}
+
+// InsertRelative enqueues item at offset from the selection; see InsertRelativeRequest.
+func (c *Client) InsertRelative(ctx context.Context, offset int, item Item) error {
+	return c.request(ctx, InsertRelativeRequest{Offset: offset, Item: item}, nil)
+}
+
+// MarkPlayed marks the item at index, which must have hash hash, as having finished playing; see MarkPlayedRequest.
+func (c *Client) MarkPlayed(ctx context.Context, index int, hash string) error {
+	return c.request(ctx, MarkPlayedRequest{Index: index, Hash: hash}, nil)
+}
+
+// Update replaces the content of the item at index, which must have hash hash, with item; see UpdateItemRequest.
+func (c *Client) Update(ctx context.Context, index int, hash string, item Item) error {
+	return c.request(ctx, UpdateItemRequest{Index: index, Hash: hash, Item: item}, nil)
+}
+
+// Replace replaces the whole List with items, then selects selection (or nothing, if -1); see ReplaceListRequest.
+func (c *Client) Replace(ctx context.Context, items []Item, selection int) error {
+	_, err := c.replace(ctx, ReplaceListRequest{Items: items, Selection: selection})
+	return err
+}
+
+// ReplaceSkipping is like Replace, but leaves out items the List would otherwise fail on, returning a Summary of them;
+// see List.ReplaceSkipping.
+// The Summary lists at most MaxListedRejections of the rejected items, though its Requested and Applied counts still
+// account for all of them.
+func (c *Client) ReplaceSkipping(ctx context.Context, items []Item, selection int) (Summary, error) {
+	return c.replace(ctx, ReplaceListRequest{Items: items, Selection: selection, SkipRejected: true})
+}
+
+// Transact applies ops to the List all at once, or not at all; see TransactionRequest.
+// If the List rejects any of them, Transact returns each that failed, along with an error.
+func (c *Client) Transact(ctx context.Context, ops ...interface{}) ([]OpFailure, error) {
+	var failures []OpFailure
+	err := c.request(ctx, TransactionRequest{Ops: ops}, func(r controller.Response) error {
+		of, ok := r.Body.(OpFailedResponse)
+		if !ok {
+			return unexpectedResponse(r)
+		}
+		failures = append(failures, OpFailure(of))
+		return nil
+	})
+	return failures, err
+}
+
+// Revert replaces the whole List with its saved list, returning a Summary of the items it applied; see RevertRequest.
+func (c *Client) Revert(ctx context.Context) (Summary, error) {
+	return c.replace(ctx, RevertRequest{})
+}
+
+// replace sends the replacement request rq, such as a ReplaceListRequest, gathering its Summary.
+func (c *Client) replace(ctx context.Context, rq interface{}) (Summary, error) {
+	var sum Summary
+	err := c.request(ctx, rq, func(r controller.Response) error {
+		switch b := r.Body.(type) {
+		case SummaryResponse:
+			sum.Requested, sum.Applied = b.Requested, b.Applied
+		case RejectionResponse:
+			sum.Rejected = append(sum.Rejected, Rejection(b))
+		default:
+			return unexpectedResponse(r)
+		}
+		return nil
+	})
+	return sum, err
+}
+
+// request sends a request with body body, feeding its replies into cb.
+// If cb is nil, the request shouldn't have any replies.
+// If ctx finishes before the ack, it gives up with ctx's error, and the Controller drops the replies left over.
+func (c *Client) request(ctx context.Context, body interface{}, cb func(controller.Response) error) error {
+	if cb == nil {
+		cb = unexpectedResponse
+	}
+
+	reply := make(chan controller.Response)
+	rq := controller.Request{
+		Origin: controller.RequestOrigin{ReplyTx: reply, Done: ctx.Done()},
+		Body:   body,
+	}
+	select {
+	case c.c.Tx <- rq:
+	case <-c.done:
+		return controller.ErrControllerShutDown
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+	return controller.ProcessRepliesUntilAckOrDone(ctx, reply, cb)
+}
+
+// unexpectedResponse is the error for a reply that the request shouldn't have got.
+func unexpectedResponse(r controller.Response) error {
+	return fmt.Errorf("got an unexpected response: %T", r.Body)
+}
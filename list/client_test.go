@@ -0,0 +1,84 @@
+package list_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/UniversityRadioYork/baps3d/controller"
+	"github.com/UniversityRadioYork/baps3d/list"
+)
+
+// TestClient tests a list Client against a running List Controller, including its broadcasts and error mapping.
+func TestClient(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	ctl, root := controller.NewController(list.New())
+	done := make(chan struct{})
+	go func() {
+		ctl.Run(ctx)
+		close(done)
+	}()
+
+	selects := make(chan list.SelectResponse, 16)
+	c := list.NewClient(root, func(r controller.Response) {
+		if s, ok := r.Body.(list.SelectResponse); ok && r.Own {
+			selects <- s
+		}
+	})
+
+	for i, h := range []string{"a", "b", "c"} {
+		if err := c.Add(ctx, i, *list.NewTrack(h, h+".mp3")); err != nil {
+			t.Fatalf("couldn't add %q: %v", h, err)
+		}
+	}
+	if err := c.Select(ctx, 0, "a"); err != nil {
+		t.Fatalf("couldn't select: %v", err)
+	}
+	if err := c.Next(ctx, false); err != nil {
+		t.Fatalf("couldn't move to next: %v", err)
+	}
+	for _, want := range []list.SelectResponse{{Index: 0, Hash: "a"}, {Index: 1, Hash: "b"}} {
+		if got := <-selects; got != want {
+			t.Errorf("got select broadcast %v, want %v", got, want)
+		}
+	}
+
+	st, err := c.Status(ctx)
+	if err != nil {
+		t.Fatalf("couldn't get status: %v", err)
+	}
+	if want := (list.SelectResponse{Index: 1, Hash: "b"}); st.Count != 3 || st.Selection != want {
+		t.Errorf("got status %+v, want 3 items and selection %v", st, want)
+	}
+
+	items, err := c.Dump(ctx)
+	if err != nil {
+		t.Fatalf("couldn't dump: %v", err)
+	}
+	if len(items) != 3 || items[2].Hash() != "c" {
+		t.Errorf("got dump %v, want items a, b, c", items)
+	}
+
+	cr, err := c.Context(ctx, 1)
+	if err != nil {
+		t.Fatalf("couldn't get context: %v", err)
+	}
+	if len(cr.Items) != 3 || cr.Items[0].Index != 0 {
+		t.Errorf("got context %+v, want items 0 to 2", cr)
+	}
+
+	if err := c.Select(ctx, 2, "a"); !errors.Is(err, controller.ErrStateChanged) {
+		t.Errorf("select with stale hash: got error %v, want ErrStateChanged", err)
+	}
+
+	if err := c.Shutdown(ctx); err != nil {
+		t.Fatalf("couldn't shut down: %v", err)
+	}
+	<-done
+	if err := c.Next(ctx, false); !errors.Is(err, controller.ErrControllerShutDown) {
+		t.Errorf("after shutdown: got error %v, want ErrControllerShutDown", err)
+	}
+}
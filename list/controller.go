@@ -54,16 +54,57 @@ func (l *List) Dump(dumpCb controller.ResponseCb) {
 	dumpCb(l.autoModeResponse())
 	dumpCb(l.freezeResponse())
 	dumpCb(l.selectResponse())
+	if l.Exhausted() {
+		dumpCb(ExhaustedResponse{})
+	}
+	l.dumpDurations(dumpCb)
+	l.dumpStops(dumpCb)
+	l.dumpLocks(dumpCb)
+	l.dumpFallback(dumpCb)
+	l.dumpPlayed(dumpCb)
+	l.dumpElapsed(dumpCb)
+	if l.dumpAirTimes {
+		l.sendAirTimes(dumpCb)
+	}
+	dumpCb(VersionResponse{Version: l.version})
 	// TODO(@MattWindsor91): other items in dump
 }
 
+// dumpDurations sends a DurationResponse to dumpCb for each item in l with a known duration.
+func (l *List) dumpDurations(dumpCb controller.ResponseCb) {
+	for i, item := range l.Freeze() {
+		if d, ok := item.Duration(); ok {
+			dumpCb(DurationResponse{Index: i, Hash: item.Hash(), Duration: d})
+		}
+	}
+}
+
+// sendAirTimes sends an AirTimeResponse to cb for each of l's air times.
+func (l *List) sendAirTimes(cb controller.ResponseCb) {
+	for _, at := range l.AirTimes() {
+		cb(AirTimeResponse(at))
+	}
+}
+
+// Greet handles greeting a new client.
+// It sends just enough state to render transport controls: the automode, selection, and whether the list is exhausted.
+func (l *List) Greet(greetCb controller.ResponseCb) {
+	greetCb(l.autoModeResponse())
+	greetCb(l.selectResponse())
+	if l.Exhausted() {
+		greetCb(ExhaustedResponse{})
+	}
+}
+
 //
 // Request handling
 //
 
 // HandleRequest handles a request for List l.
+// Every broadcast it makes is a change, recorded in l's history; see Since.
 func (l *List) HandleRequest(replyCb controller.ResponseCb, bcastCb controller.ResponseCb, rbody interface{}) error {
 	var err error
+	bcastCb = l.recording(bcastCb)
 
 	switch b := rbody.(type) {
 	case SetAutoModeRequest:
@@ -72,6 +113,64 @@ func (l *List) HandleRequest(replyCb controller.ResponseCb, bcastCb controller.R
 		err = l.handleSelectRequest(replyCb, bcastCb, b)
 	case AddItemRequest:
 		err = l.handleAddItemRequest(replyCb, bcastCb, b)
+	case InsertRelativeRequest:
+		err = l.handleAddItemRequest(replyCb, bcastCb, AddItemRequest{Index: l.RelativeIndex(b.Offset), Item: b.Item})
+	case AdvanceRequest:
+		err = l.handleAdvanceRequest(replyCb, bcastCb, b)
+	case NextRequest:
+		err = l.handleNextRequest(replyCb, bcastCb, b)
+	case NextNRequest:
+		err = l.handleNextNRequest(replyCb, bcastCb, b)
+	case PreviousRequest:
+		err = l.handlePreviousRequest(replyCb, bcastCb, b)
+	case SelectRelativeRequest:
+		err = l.handleSelectRelativeRequest(replyCb, bcastCb, b)
+	case ReplaceListRequest:
+		err = l.handleReplaceListRequest(replyCb, bcastCb, b)
+	case TransactionRequest:
+		err = l.handleTransactionRequest(replyCb, bcastCb, b)
+	case RevertRequest:
+		err = l.handleRevertRequest(replyCb, bcastCb, b)
+	case UpdateItemRequest:
+		err = l.handleUpdateItemRequest(replyCb, bcastCb, b)
+	case SwapItemsRequest:
+		err = l.handleSwapItemsRequest(replyCb, bcastCb, b)
+	case SetDurationRequest:
+		err = l.handleDurationRequest(replyCb, bcastCb, b)
+	case SetStopAfterRequest:
+		err = l.handleStopAfterRequest(replyCb, bcastCb, b)
+	case SetLockedRequest:
+		err = l.handleLockedRequest(replyCb, bcastCb, b)
+	case SetFallbackRequest:
+		err = l.handleFallbackRequest(replyCb, bcastCb, b)
+	case SelectFallbackRequest:
+		err = l.handleSelectFallbackRequest(replyCb, bcastCb)
+	case MarkPlayedRequest:
+		err = l.handleMarkPlayedRequest(replyCb, bcastCb, b)
+	case SetElapsedRequest:
+		err = l.SetElapsed(b.Elapsed)
+	case SetPlayingRequest:
+		err = l.handlePlayingRequest(bcastCb, b)
+	case ElapsedRequest:
+		err = l.handleElapsedRequest(replyCb, b)
+	case AirTimesRequest:
+		l.sendAirTimes(replyCb)
+	case AirTimeRequest:
+		err = l.handleAirTimeRequest(replyCb, b)
+	case AutoModesRequest:
+		replyCb(AutoModesResponse{AutoModes: AutoModes()})
+	case ContextDumpRequest:
+		err = l.handleContextDumpRequest(replyCb, b)
+	case ListByTypeRequest:
+		err = l.handleListByTypeRequest(replyCb, b)
+	case SinceRequest:
+		err = l.handleSinceRequest(replyCb, b)
+	case HistoryRequest:
+		err = l.handleHistoryRequest(replyCb, b)
+	case ExportRequest:
+		err = l.handleExportRequest(replyCb, b)
+	case StatusRequest:
+		replyCb(StatusResponse{Count: l.Count(), Selection: l.selectResponse(), AutoMode: l.AutoMode()})
 	default:
 		err = fmt.Errorf("list can't handle this request")
 	}
@@ -79,6 +178,22 @@ func (l *List) HandleRequest(replyCb controller.ResponseCb, bcastCb controller.R
 	return err
 }
 
+// IsPriority checks whether rbody is a request that may jump the List's request queue.
+// The only such request is turning automode off, which stops the List advancing on its own.
+func (l *List) IsPriority(rbody interface{}) bool {
+	b, ok := rbody.(SetAutoModeRequest)
+	return ok && b.AutoMode == AutoOff
+}
+
+// handleAirTimeRequest handles a single-item air time request for List l.
+func (l *List) handleAirTimeRequest(replyCb controller.ResponseCb, b AirTimeRequest) error {
+	p, err := l.AirTimeOf(b.Index, l.lenientHash(replyCb, b.Index, b.Hash))
+	if err == nil {
+		replyCb(ProjectionResponse(p))
+	}
+	return err
+}
+
 // handleAutoModeRequest handles an automode change request for List l.
 func (l *List) handleAutoModeRequest(replyCb controller.ResponseCb, bcastCb controller.ResponseCb, b SetAutoModeRequest) error {
 	if l.SetAutoMode(b.AutoMode) {
@@ -91,9 +206,10 @@ func (l *List) handleAutoModeRequest(replyCb controller.ResponseCb, bcastCb cont
 
 // handleSelectRequest handles a selection change request for List l.
 func (l *List) handleSelectRequest(replyCb controller.ResponseCb, bcastCb controller.ResponseCb, b SetSelectRequest) error {
+	b.Hash = l.lenientHash(replyCb, b.Index, b.Hash)
 	changed, err := l.Select(b.Index, b.Hash)
-	if err != nil && changed {
-		bcastCb(l.selectResponse())
+	if err == nil && changed {
+		l.broadcastNewSelection(bcastCb)
 	}
 
 	return err
@@ -108,3 +224,254 @@ func (l *List) handleAddItemRequest(replyCb controller.ResponseCb, bcastCb contr
 
 	return err
 }
+
+// handleAdvanceRequest handles an automode advance request for List l.
+func (l *List) handleAdvanceRequest(replyCb controller.ResponseCb, bcastCb controller.ResponseCb, b AdvanceRequest) error {
+	wasExhausted := l.Exhausted()
+	stopped := l.AtStop()
+
+	if _, changed := l.Next(); changed {
+		l.broadcastNewSelection(bcastCb)
+	}
+	if stopped {
+		sel := l.selectResponse()
+		bcastCb(StoppedResponse{Index: sel.Index, Hash: sel.Hash})
+	}
+	// Only announce the transition into exhaustion, so clients hear about it once.
+	if l.Exhausted() && !wasExhausted {
+		bcastCb(ExhaustedResponse{})
+	}
+
+	return nil
+}
+
+// handleNextRequest handles a manual next request for List l.
+func (l *List) handleNextRequest(replyCb controller.ResponseCb, bcastCb controller.ResponseCb, b NextRequest) error {
+	_, changed, err := l.SelectNext(b.Wrap)
+	if err == nil && changed {
+		l.broadcastNewSelection(bcastCb)
+	}
+
+	return err
+}
+
+// handleNextNRequest handles a manual multiple-item next request for List l.
+func (l *List) handleNextNRequest(replyCb controller.ResponseCb, bcastCb controller.ResponseCb, b NextNRequest) error {
+	_, changed, err := l.SelectNextN(b.Count, b.Wrap)
+	if err == nil && changed {
+		l.broadcastNewSelection(bcastCb)
+	}
+
+	return err
+}
+
+// handlePreviousRequest handles a manual previous request for List l.
+func (l *List) handlePreviousRequest(replyCb controller.ResponseCb, bcastCb controller.ResponseCb, b PreviousRequest) error {
+	_, changed, err := l.SelectPrevious(b.Wrap)
+	if err == nil && changed {
+		l.broadcastNewSelection(bcastCb)
+	}
+
+	return err
+}
+
+// handleContextDumpRequest handles a context dump request for List l.
+func (l *List) handleContextDumpRequest(replyCb controller.ResponseCb, b ContextDumpRequest) error {
+	start, items, err := l.Context(b.Radius)
+	if err != nil {
+		return err
+	}
+
+	r := ContextResponse{Selection: l.selectResponse(), Items: make([]ItemResponse, len(items))}
+	for i, item := range items {
+		r.Items[i] = ItemResponse{Index: start + i, Item: item}
+	}
+	replyCb(r)
+	return nil
+}
+
+// handleListByTypeRequest handles a request for the items of one type in List l.
+func (l *List) handleListByTypeRequest(replyCb controller.ResponseCb, b ListByTypeRequest) error {
+	indices, items, truncated, err := l.OfType(b.Type)
+	if err != nil {
+		return err
+	}
+
+	r := ByTypeResponse{Type: b.Type, Items: make([]ItemResponse, len(items)), Truncated: truncated}
+	for i, item := range items {
+		r.Items[i] = ItemResponse{Index: indices[i], Item: item}
+	}
+	replyCb(r)
+	return nil
+}
+
+// handleExportRequest handles a request for an export of List l.
+func (l *List) handleExportRequest(replyCb controller.ResponseCb, _ ExportRequest) error {
+	bs, err := l.Export()
+	if err != nil {
+		return err
+	}
+	replyCb(ExportResponse{JSON: bs})
+	return nil
+}
+
+// handleSinceRequest handles a request for the changes since a version of List l.
+func (l *List) handleSinceRequest(replyCb controller.ResponseCb, b SinceRequest) error {
+	diffs, err := l.Since(b.Version)
+	if err != nil {
+		return err
+	}
+
+	for _, d := range diffs {
+		replyCb(d)
+	}
+	return nil
+}
+
+// handleHistoryRequest handles a request for the recent plays of List l.
+func (l *List) handleHistoryRequest(replyCb controller.ResponseCb, b HistoryRequest) error {
+	plays, err := l.Plays(b.Count)
+	if err != nil {
+		return err
+	}
+
+	replyCb(HistoryResponse{Count: len(plays)})
+	for _, p := range plays {
+		replyCb(PlayResponse(p))
+	}
+	return nil
+}
+
+// handleSelectRelativeRequest handles a relative selection request for List l.
+func (l *List) handleSelectRelativeRequest(replyCb controller.ResponseCb, bcastCb controller.ResponseCb, b SelectRelativeRequest) error {
+	_, changed, err := l.SelectRelative(b.Offset, b.Wrap)
+	if err == nil && changed {
+		l.broadcastNewSelection(bcastCb)
+	}
+
+	return err
+}
+
+// handleDurationRequest handles an item duration change request for List l.
+func (l *List) handleDurationRequest(replyCb controller.ResponseCb, bcastCb controller.ResponseCb, b SetDurationRequest) error {
+	b.Hash = l.lenientHash(replyCb, b.Index, b.Hash)
+	err := l.SetDuration(b.Index, b.Hash, b.Duration)
+	if err == nil {
+		bcastCb(DurationResponse(b))
+	}
+
+	return err
+}
+
+// handleSwapItemsRequest handles an item swap request for List l.
+func (l *List) handleSwapItemsRequest(replyCb controller.ResponseCb, bcastCb controller.ResponseCb, b SwapItemsRequest) error {
+	b.HashA = l.lenientHash(replyCb, b.IndexA, b.HashA)
+	b.HashB = l.lenientHash(replyCb, b.IndexB, b.HashB)
+	err := l.Swap(b.IndexA, b.HashA, b.IndexB, b.HashB)
+	if err == nil {
+		bcastCb(ItemsSwappedResponse(b))
+	}
+
+	return err
+}
+
+// handleStopAfterRequest handles a stop point change request for List l.
+func (l *List) handleStopAfterRequest(replyCb controller.ResponseCb, bcastCb controller.ResponseCb, b SetStopAfterRequest) error {
+	b.Hash = l.lenientHash(replyCb, b.Index, b.Hash)
+	err := l.SetStopAfter(b.Index, b.Hash, b.StopAfter)
+	if err == nil {
+		bcastCb(StopAfterResponse(b))
+	}
+
+	return err
+}
+
+// handleLockedRequest handles an item lock change request for List l.
+func (l *List) handleLockedRequest(replyCb controller.ResponseCb, bcastCb controller.ResponseCb, b SetLockedRequest) error {
+	b.Hash = l.lenientHash(replyCb, b.Index, b.Hash)
+	err := l.SetLocked(b.Index, b.Hash, b.Locked)
+	if err == nil {
+		bcastCb(LockedResponse(b))
+	}
+
+	return err
+}
+
+// handleMarkPlayedRequest handles a request for List l to mark an item played.
+func (l *List) handleMarkPlayedRequest(replyCb controller.ResponseCb, bcastCb controller.ResponseCb, b MarkPlayedRequest) error {
+	b.Hash = l.lenientHash(replyCb, b.Index, b.Hash)
+	marked, err := l.MarkPlayed(b.Index, b.Hash)
+	if err != nil || !marked {
+		return err
+	}
+
+	bcastCb(FinishedResponse(b))
+	if l.advanceOnPlayed && b.Index == l.selection {
+		return l.handleAdvanceRequest(replyCb, bcastCb, AdvanceRequest{})
+	}
+	return nil
+}
+
+// handlePlayingRequest handles a play state report for List l.
+func (l *List) handlePlayingRequest(bcastCb controller.ResponseCb, b SetPlayingRequest) error {
+	changed, err := l.SetPlaying(b.Playing)
+	if err != nil {
+		return err
+	}
+
+	if changed {
+		bcastCb(l.elapsedResponse())
+	}
+	return nil
+}
+
+// handleElapsedRequest handles a request for the elapsed time of List l's selection.
+func (l *List) handleElapsedRequest(replyCb controller.ResponseCb, _ ElapsedRequest) error {
+	if l.selection == -1 {
+		return fmt.Errorf("Elapsed: nothing selected")
+	}
+
+	replyCb(l.elapsedResponse())
+	return nil
+}
+
+// handleUpdateItemRequest handles an item update request for List l.
+func (l *List) handleUpdateItemRequest(replyCb controller.ResponseCb, bcastCb controller.ResponseCb, b UpdateItemRequest) error {
+	b.Hash = l.lenientHash(replyCb, b.Index, b.Hash)
+	if err := l.Update(b.Index, b.Hash, &b.Item); err != nil {
+		return err
+	}
+
+	bcastCb(ItemUpdatedResponse{Index: b.Index, OldHash: b.Hash, Item: *l.ItemWithIndex(b.Index)})
+	// Clients know the selection by its hash, so tell them the new one.
+	if b.Index == l.selection {
+		bcastCb(l.selectResponse())
+	}
+	return nil
+}
+
+// handleReplaceListRequest handles a list replacement request for List l.
+func (l *List) handleReplaceListRequest(replyCb controller.ResponseCb, bcastCb controller.ResponseCb, b ReplaceListRequest) error {
+	sum, err := l.replace(b.Items, b.Selection, b.SkipRejected)
+	if err != nil {
+		return err
+	}
+
+	bcastCb(ListReplacedResponse{Items: l.freezeResponse(), Selection: l.selectResponse()})
+	l.broadcastRestartedElapsed(bcastCb)
+	replySummary(replyCb, sum)
+	return nil
+}
+
+// handleRevertRequest handles a request to revert List l to its saved list.
+func (l *List) handleRevertRequest(replyCb controller.ResponseCb, bcastCb controller.ResponseCb, _ RevertRequest) error {
+	sum, err := l.Revert()
+	if err != nil {
+		return err
+	}
+
+	bcastCb(ListReplacedResponse{Items: l.freezeResponse(), Selection: l.selectResponse()})
+	l.broadcastRestartedElapsed(bcastCb)
+	replySummary(replyCb, sum)
+	return nil
+}
@@ -0,0 +1,429 @@
+package list_test
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/UniversityRadioYork/baps3d/controller"
+	"github.com/UniversityRadioYork/baps3d/list"
+)
+
+// exhaustionCounter handles requests on a List, counting the ExhaustedResponse broadcasts that result.
+type exhaustionCounter struct {
+	l     *list.List
+	count int
+}
+
+// handle sends rbody to c's List, failing the test on error.
+func (c *exhaustionCounter) handle(t *testing.T, rbody interface{}) {
+	t.Helper()
+
+	replyCb := func(interface{}) {}
+	bcastCb := func(r interface{}) {
+		if _, ok := r.(list.ExhaustedResponse); ok {
+			c.count++
+		}
+	}
+	if err := c.l.HandleRequest(replyCb, bcastCb, rbody); err != nil {
+		t.Fatalf("unexpected error handling %T: %v", rbody, err)
+	}
+}
+
+// expect checks that c has counted want exhaustion broadcasts.
+func (c *exhaustionCounter) expect(t *testing.T, when string, want int) {
+	t.Helper()
+
+	if c.count != want {
+		t.Fatalf("%s: got %d exhaustion broadcasts, want %d", when, c.count, want)
+	}
+}
+
+// newExhaustionCounter makes an exhaustionCounter over a list with two tracks, the first selected, in mode mode.
+func newExhaustionCounter(t *testing.T, mode list.AutoMode) *exhaustionCounter {
+	t.Helper()
+
+	c := exhaustionCounter{l: list.New()}
+	c.handle(t, list.AddItemRequest{Index: 0, Item: *list.NewTrack("abc", "foo.mp3")})
+	c.handle(t, list.AddItemRequest{Index: 1, Item: *list.NewTrack("xyz", "bar.mp3")})
+	c.handle(t, list.SetSelectRequest{Index: 0, Hash: "abc"})
+	c.handle(t, list.SetAutoModeRequest{AutoMode: mode})
+	return &c
+}
+
+// TestList_HandleRequest_ExhaustedOnce tests that repeated advances broadcast exhaustion once, and that adding an item
+// or selecting one lets the list exhaust again.
+func TestList_HandleRequest_ExhaustedOnce(t *testing.T) {
+	c := newExhaustionCounter(t, list.AutoNext)
+
+	c.handle(t, list.AdvanceRequest{})
+	c.expect(t, "before the end", 0)
+	for i := 0; i < 3; i++ {
+		c.handle(t, list.AdvanceRequest{})
+	}
+	c.expect(t, "after repeatedly advancing off the end", 1)
+
+	// Selecting clears exhaustion; advancing off the end again re-fires it.
+	c.handle(t, list.SetSelectRequest{Index: 1, Hash: "xyz"})
+	if c.l.Exhausted() {
+		t.Fatal("still exhausted after select")
+	}
+	c.handle(t, list.AdvanceRequest{})
+	c.handle(t, list.AdvanceRequest{})
+	c.expect(t, "after exhausting again post-select", 2)
+
+	// Adding clears exhaustion too, but without a selection there's nothing to advance from.
+	c.handle(t, list.AddItemRequest{Index: 2, Item: *list.NewTrack("ghi", "baz.mp3")})
+	if c.l.Exhausted() {
+		t.Fatal("still exhausted after add")
+	}
+	c.handle(t, list.AdvanceRequest{})
+	c.expect(t, "after advancing with no selection", 2)
+}
+
+// TestList_HandleRequest_ExhaustedShuffle tests that exhaustion is broadcast once in AutoShuffle mode.
+func TestList_HandleRequest_ExhaustedShuffle(t *testing.T) {
+	c := newExhaustionCounter(t, list.AutoShuffle)
+
+	// Each advance picks an unpicked item, so two advances use up both; the third runs out.
+	for i := 0; i < 6; i++ {
+		c.handle(t, list.AdvanceRequest{})
+	}
+	c.expect(t, "after shuffling through everything", 1)
+	if !c.l.Exhausted() {
+		t.Fatal("not exhausted after shuffling through everything")
+	}
+}
+
+// TestList_Dump_Exhausted tests that dumps and greetings report exhaustion only while the list is exhausted.
+func TestList_Dump_Exhausted(t *testing.T) {
+	c := newExhaustionCounter(t, list.AutoNext)
+
+	reported := func(f func(func(interface{}))) bool {
+		found := false
+		f(func(r interface{}) {
+			if _, ok := r.(list.ExhaustedResponse); ok {
+				found = true
+			}
+		})
+		return found
+	}
+	dump := func(cb func(interface{})) { c.l.Dump(cb) }
+	greet := func(cb func(interface{})) { c.l.Greet(cb) }
+
+	if reported(dump) || reported(greet) {
+		t.Fatal("exhaustion reported before exhausting")
+	}
+
+	c.handle(t, list.AdvanceRequest{})
+	c.handle(t, list.AdvanceRequest{})
+	if !reported(dump) {
+		t.Error("dump doesn't report exhaustion")
+	}
+	if !reported(greet) {
+		t.Error("greeting doesn't report exhaustion")
+	}
+
+	c.handle(t, list.SetAutoModeRequest{AutoMode: list.AutoOff})
+	if reported(dump) {
+		t.Error("dump still reports exhaustion after automode change")
+	}
+}
+
+// TestList_IsPriority tests that only turning automode off may jump a List's request queue.
+func TestList_IsPriority(t *testing.T) {
+	cases := []struct {
+		rbody interface{}
+		want  bool
+	}{
+		{list.SetAutoModeRequest{AutoMode: list.AutoOff}, true},
+		{list.SetAutoModeRequest{AutoMode: list.AutoShuffle}, false},
+		{list.AdvanceRequest{}, false},
+		{list.NextRequest{}, false},
+	}
+
+	l := list.New()
+	for _, c := range cases {
+		if got := l.IsPriority(c.rbody); got != c.want {
+			t.Errorf("IsPriority(%#v): got %v, want %v", c.rbody, got, c.want)
+		}
+	}
+}
+
+// TestList_HandleRequest_ReplaceList tests that replacing a list broadcasts once, keeps the automode, and leaves the
+// list untouched on failure.
+func TestList_HandleRequest_ReplaceList(t *testing.T) {
+	l := list.New()
+	l.SetAutoMode(list.AutoNext)
+	if err := l.Add(list.NewTrack("old", "old.mp3"), 0); err != nil {
+		t.Fatalf("couldn't add item: %v", err)
+	}
+
+	var bcasts []interface{}
+	bcastCb := func(r interface{}) { bcasts = append(bcasts, r) }
+	replace := func(sel int, hashes ...string) error {
+		items := make([]list.Item, len(hashes))
+		for i, h := range hashes {
+			items[i] = *list.NewTrack(h, h+".mp3")
+		}
+		return l.HandleRequest(func(interface{}) {}, bcastCb, list.ReplaceListRequest{Items: items, Selection: sel})
+	}
+
+	for _, c := range []struct {
+		name   string
+		sel    int
+		hashes []string
+	}{
+		{"duplicate hash", 0, []string{"a", "a"}},
+		{"selection out of bounds", 2, []string{"a", "b"}},
+	} {
+		if err := replace(c.sel, c.hashes...); err == nil {
+			t.Errorf("%s: expected an error", c.name)
+		}
+	}
+	if len(bcasts) != 0 || l.Count() != 1 {
+		t.Fatalf("failed replacements changed the list: %d broadcasts, %d items", len(bcasts), l.Count())
+	}
+
+	if err := replace(1, "a", "b", "c"); err != nil {
+		t.Fatalf("unexpected error replacing: %v", err)
+	}
+	if len(bcasts) != 1 {
+		t.Fatalf("got %d broadcasts, want 1", len(bcasts))
+	}
+	lr, ok := bcasts[0].(list.ListReplacedResponse)
+	if !ok {
+		t.Fatalf("got %T, want ListReplacedResponse", bcasts[0])
+	}
+	if len(lr.Items) != 3 || lr.Selection.Index != 1 || lr.Selection.Hash != "b" {
+		t.Errorf("got %d items and selection %+v, want 3 items and b selected", len(lr.Items), lr.Selection)
+	}
+	if _, item := l.ItemWithHash("old"); item != nil {
+		t.Error("old item survived replacement")
+	}
+	if l.AutoMode() != list.AutoNext {
+		t.Errorf("automode changed to %v", l.AutoMode())
+	}
+}
+
+// TestList_Replace_TooLong tests that Replace refuses more than MaxReplaceLen items.
+func TestList_Replace_TooLong(t *testing.T) {
+	items := make([]list.Item, list.MaxReplaceLen+1)
+	for i := range items {
+		items[i] = *list.NewTrack(strconv.Itoa(i), "x.mp3")
+	}
+	if err := list.New().Replace(items, -1); err == nil {
+		t.Error("expected an error")
+	}
+}
+
+// TestList_HandleRequest_NextN tests that skipping several items broadcasts only the final selection.
+func TestList_HandleRequest_NextN(t *testing.T) {
+	l := list.New()
+	for i, h := range []string{"a", "b", "c"} {
+		if err := l.Add(list.NewTrack(h, h+".mp3"), i); err != nil {
+			t.Fatalf("couldn't add item: %v", err)
+		}
+	}
+
+	var bcasts []interface{}
+	bcastCb := func(r interface{}) { bcasts = append(bcasts, r) }
+	if err := l.HandleRequest(func(interface{}) {}, bcastCb, list.NextNRequest{Count: 3}); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if len(bcasts) != 1 {
+		t.Fatalf("got %d broadcasts, want 1", len(bcasts))
+	}
+	if sel, ok := bcasts[0].(list.SelectResponse); !ok || sel.Index != 2 || sel.Hash != "c" {
+		t.Errorf("got %#v, want c selected", bcasts[0])
+	}
+}
+
+// TestList_HandleRequest_Status tests that a status request replies with one summary, and broadcasts nothing.
+func TestList_HandleRequest_Status(t *testing.T) {
+	l := list.New()
+	l.SetAutoMode(list.AutoNext)
+	for i, h := range []string{"a", "b"} {
+		if err := l.Add(list.NewTrack(h, h+".mp3"), i); err != nil {
+			t.Fatalf("couldn't add item: %v", err)
+		}
+	}
+	if _, err := l.Select(1, "b"); err != nil {
+		t.Fatalf("couldn't select item: %v", err)
+	}
+
+	var replies []interface{}
+	replyCb := func(r interface{}) { replies = append(replies, r) }
+	bcastCb := func(r interface{}) { t.Errorf("unexpected broadcast: %#v", r) }
+	if err := l.HandleRequest(replyCb, bcastCb, list.StatusRequest{}); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	want := list.StatusResponse{Count: 2, Selection: list.SelectResponse{Index: 1, Hash: "b"}, AutoMode: list.AutoNext}
+	if len(replies) != 1 || replies[0] != want {
+		t.Errorf("got %#v, want one %#v", replies, want)
+	}
+}
+
+// TestList_HandleRequest_UpdateItem tests that updating the selected item broadcasts the update, then the selection
+// with its new hash.
+func TestList_HandleRequest_UpdateItem(t *testing.T) {
+	l := list.New()
+	for i, h := range []string{"a", "b"} {
+		if err := l.Add(list.NewTrack(h, h+".mp3"), i); err != nil {
+			t.Fatalf("couldn't add item: %v", err)
+		}
+	}
+	if _, err := l.Select(0, "a"); err != nil {
+		t.Fatalf("couldn't select item: %v", err)
+	}
+
+	var bcasts []interface{}
+	bcastCb := func(r interface{}) { bcasts = append(bcasts, r) }
+	update := func(index int, hash, newHash string) error {
+		rq := list.UpdateItemRequest{Index: index, Hash: hash, Item: *list.NewTrack(newHash, newHash+".mp3")}
+		return l.HandleRequest(func(interface{}) {}, bcastCb, rq)
+	}
+
+	if err := update(0, "a", "a2"); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(bcasts) != 2 {
+		t.Fatalf("got %d broadcasts, want 2", len(bcasts))
+	}
+	if u, ok := bcasts[0].(list.ItemUpdatedResponse); !ok || u.Index != 0 || u.OldHash != "a" || u.Item.Hash() != "a2" || u.Item.ID() == 0 {
+		t.Errorf("got %#v, want a2 updated from a", bcasts[0])
+	}
+	if sel, ok := bcasts[1].(list.SelectResponse); !ok || sel.Index != 0 || sel.Hash != "a2" {
+		t.Errorf("got %#v, want a2 selected", bcasts[1])
+	}
+
+	// Updating an unselected item doesn't re-announce the selection; failing to update announces nothing.
+	bcasts = nil
+	if err := update(1, "b", "b2"); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if err := update(1, "b", "b3"); err == nil {
+		t.Error("stale hash: expected an error")
+	}
+	if len(bcasts) != 1 {
+		t.Errorf("got %d broadcasts, want 1", len(bcasts))
+	}
+}
+
+// TestList_HandleRequest_Select tests that a selection change is broadcast, and a failed or no-op selection isn't.
+func TestList_HandleRequest_Select(t *testing.T) {
+	l := list.New()
+	for i, h := range []string{"a", "b"} {
+		if err := l.Add(list.NewTrack(h, h+".mp3"), i); err != nil {
+			t.Fatalf("couldn't add item: %v", err)
+		}
+	}
+
+	var bcasts []interface{}
+	bcastCb := func(r interface{}) { bcasts = append(bcasts, r) }
+	sel := func(index int, hash string) error {
+		bcasts = nil
+		return l.HandleRequest(func(interface{}) {}, bcastCb, list.SetSelectRequest{Index: index, Hash: hash})
+	}
+
+	if err := sel(1, "b"); err != nil {
+		t.Fatalf("unexpected error selecting: %v", err)
+	}
+	want := []interface{}{list.SelectResponse{Index: 1, Hash: "b"}}
+	if !reflect.DeepEqual(bcasts, want) {
+		t.Errorf("got broadcasts %v, want %v", bcasts, want)
+	}
+
+	if err := sel(1, "b"); err != nil {
+		t.Fatalf("unexpected error reselecting: %v", err)
+	}
+	if len(bcasts) != 0 {
+		t.Errorf("got broadcasts %v reselecting, want none", bcasts)
+	}
+
+	if err := sel(0, "b"); err == nil {
+		t.Error("expected an error selecting with the wrong hash")
+	}
+	if len(bcasts) != 0 {
+		t.Errorf("got broadcasts %v on a failed select, want none", bcasts)
+	}
+}
+
+// TestList_Controller_ConflictingUpdates tests that, when two clients race to update the same item by its old hash,
+// exactly one update wins, and the other fails with controller.ErrStateChanged, after which it can catch up and retry.
+func TestList_Controller_ConflictingUpdates(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	l := list.New()
+	if err := l.Add(list.NewTrack("a", "a.mp3"), 0); err != nil {
+		t.Fatalf("couldn't add item: %v", err)
+	}
+	ctl, root := controller.NewController(l)
+	done := make(chan struct{})
+	go func() {
+		ctl.Run(ctx)
+		close(done)
+	}()
+
+	clients := make([]*controller.Client, 2)
+	for i := range clients {
+		var err error
+		if clients[i], err = root.Copy(ctx); err != nil {
+			t.Fatalf("couldn't copy client: %v", err)
+		}
+	}
+	for _, c := range append(clients, root) {
+		go func(c *controller.Client) {
+			for range c.Rx {
+			}
+		}(c)
+	}
+
+	update := func(c *controller.Client, hash, newHash string) error {
+		rq := list.UpdateItemRequest{Index: 0, Hash: hash, Item: *list.NewTrack(newHash, newHash+".mp3")}
+		alive, err := c.SendAndProcessReplies(ctx, "", rq, func(controller.Response) error { return nil })
+		if !alive {
+			return errors.New("controller not responding")
+		}
+		return err
+	}
+
+	// Both clients last saw the item as 'a', and try to rename it at once.
+	errs := make([]chan error, len(clients))
+	for i, c := range clients {
+		errs[i] = make(chan error, 1)
+		go func(i int, c *controller.Client) {
+			errs[i] <- update(c, "a", "a"+strconv.Itoa(i))
+		}(i, c)
+	}
+	winner, loser := -1, -1
+	for i := range clients {
+		switch err := <-errs[i]; {
+		case err == nil:
+			winner = i
+		case errors.Is(err, controller.ErrStateChanged):
+			loser = i
+		default:
+			t.Fatalf("client %d: unexpected error: %v", i, err)
+		}
+	}
+	if winner == -1 || loser == -1 {
+		t.Fatalf("got winner %d and loser %d, want one of each", winner, loser)
+	}
+
+	// The loser catches up with the winner's hash, and retries.
+	if err := update(clients[loser], "a"+strconv.Itoa(winner), "b"); err != nil {
+		t.Errorf("retry: unexpected error: %v", err)
+	}
+
+	if err := root.Shutdown(ctx); err != nil {
+		t.Fatalf("couldn't shut down: %v", err)
+	}
+	<-done
+}
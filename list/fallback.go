@@ -0,0 +1,91 @@
+package list
+
+// File fallback.go contains the fallback item: the item, such as a long ambient bed, that the List falls back on
+// rather than go silent when it runs out of items or nobody has touched it for too long.
+
+import (
+	"errors"
+
+	"github.com/UniversityRadioYork/baps3d/controller"
+)
+
+// ErrNoFallback is the error given when selecting the fallback of a List that has none.
+var ErrNoFallback = errors.New("no fallback item")
+
+// SetFallback tries to mark (if on) or unmark the item with the given index and hash as l's fallback.
+// A List has at most one fallback, so marking an item unmarks any other.
+// When Next, in AutoNext or AutoShuffle, has nothing left to select, it selects the fallback instead of leaving the
+// List exhausted; SelectFallback selects it outright, for instance as the action of a deadman switch.
+// The mark belongs to the item, not its place in the list, so it moves with the item and stays over updates; as
+// nothing but a replacement (or a revert) takes items out of a List, and those drop every mark, a List that loses its
+// fallback item simply has no fallback, and exhausts as usual.
+// It fails if the item doesn't exist or has a different hash (see controller.ErrStateChanged), or if the item is
+// being marked and can't be selected.
+func (l *List) SetFallback(index int, hash string, on bool) error {
+	item, err := l.guardedItem("SetFallback", index, hash)
+	if err != nil {
+		return err
+	}
+	if on && !item.IsSelectable() {
+		return errors.New("SetFallback: item can't be selected")
+	}
+
+	if on {
+		for e := l.list.Front(); e != nil; e = e.Next() {
+			e.Value.(*Item).fallback = false
+		}
+	}
+	item.fallback = on
+	return nil
+}
+
+// Fallback gets the index and hash of l's fallback item, or -1 and the empty string if it has none.
+func (l *List) Fallback() (int, string) {
+	i := 0
+	for e := l.list.Front(); e != nil; e = e.Next() {
+		if item := e.Value.(*Item); item.fallback {
+			return i, item.Hash()
+		}
+		i++
+	}
+	return -1, ""
+}
+
+// SelectFallback tries to select l's fallback item.
+// It returns a Boolean stating whether the selection changed, and fails with ErrNoFallback if l has no fallback.
+func (l *List) SelectFallback() (bool, error) {
+	i, h := l.Fallback()
+	if i == -1 {
+		return false, ErrNoFallback
+	}
+	return l.Select(i, h)
+}
+
+// dumpFallback sends a FallbackResponse to dumpCb for l's fallback item, if it has one.
+func (l *List) dumpFallback(dumpCb controller.ResponseCb) {
+	if i, h := l.Fallback(); i != -1 {
+		dumpCb(FallbackResponse{Index: i, Hash: h, Fallback: true})
+	}
+}
+
+// handleFallbackRequest handles a fallback change request for List l.
+// Marking a fallback unmarks the old one without a broadcast of its own; clients know there is only ever one.
+func (l *List) handleFallbackRequest(replyCb controller.ResponseCb, bcastCb controller.ResponseCb, b SetFallbackRequest) error {
+	b.Hash = l.lenientHash(replyCb, b.Index, b.Hash)
+	err := l.SetFallback(b.Index, b.Hash, b.Fallback)
+	if err == nil {
+		bcastCb(FallbackResponse(b))
+	}
+
+	return err
+}
+
+// handleSelectFallbackRequest handles a request for List l to select its fallback.
+func (l *List) handleSelectFallbackRequest(replyCb controller.ResponseCb, bcastCb controller.ResponseCb) error {
+	changed, err := l.SelectFallback()
+	if err == nil && changed {
+		l.broadcastNewSelection(bcastCb)
+	}
+
+	return err
+}
@@ -0,0 +1,145 @@
+package list_test
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+
+	"github.com/UniversityRadioYork/bifrost-go/message"
+
+	"github.com/UniversityRadioYork/baps3d/list"
+)
+
+// fallbackList makes a List with tracks a to c, with c marked as the fallback, a selected, and AutoNext on.
+func fallbackList(t *testing.T) *list.List {
+	t.Helper()
+
+	l := list.New()
+	for i, h := range []string{"a", "b", "c"} {
+		if err := l.Add(list.NewTrack(h, h+".mp3"), i); err != nil {
+			t.Fatalf("couldn't add %q: %v", h, err)
+		}
+	}
+	if err := l.SetFallback(2, "c", true); err != nil {
+		t.Fatalf("couldn't mark c: %v", err)
+	}
+	if _, err := l.Select(0, "a"); err != nil {
+		t.Fatalf("couldn't select: %v", err)
+	}
+	l.SetAutoMode(list.AutoNext)
+	return l
+}
+
+// TestList_SetFallback tests that there is only ever one fallback, and that it follows its item round the List.
+func TestList_SetFallback(t *testing.T) {
+	l := fallbackList(t)
+
+	if err := l.SetFallback(1, "b", true); err != nil {
+		t.Fatalf("couldn't mark b: %v", err)
+	}
+	if i, h := l.Fallback(); i != 1 || h != "b" {
+		t.Errorf("got fallback %d, %q; want 1, b", i, h)
+	}
+	if err := l.Swap(0, "a", 1, "b"); err != nil {
+		t.Fatalf("couldn't swap: %v", err)
+	}
+	if i, h := l.Fallback(); i != 0 || h != "b" {
+		t.Errorf("after swapping: got fallback %d, %q; want 0, b", i, h)
+	}
+	if err := l.Update(0, "b", list.NewText("b", "hello")); err == nil {
+		t.Error("expected an error making the fallback unselectable")
+	}
+
+	if err := l.Add(list.NewText("t", "hello"), 3); err != nil {
+		t.Fatalf("couldn't add text: %v", err)
+	}
+	if err := l.SetFallback(3, "t", true); err == nil {
+		t.Error("expected an error marking a text item")
+	}
+	if err := l.SetFallback(0, "x", true); err == nil {
+		t.Error("expected an error marking with the wrong hash")
+	}
+
+	if err := l.Replace([]list.Item{*list.NewTrack("b", "b.mp3")}, -1); err != nil {
+		t.Fatalf("couldn't replace: %v", err)
+	}
+	if i, _ := l.Fallback(); i != -1 {
+		t.Errorf("after replacing: got fallback %d, want none", i)
+	}
+	if _, err := l.SelectFallback(); !errors.Is(err, list.ErrNoFallback) {
+		t.Errorf("got error %v, want ErrNoFallback", err)
+	}
+}
+
+// TestList_Next_Fallback tests that running off the end of the List selects the fallback instead of exhausting it.
+func TestList_Next_Fallback(t *testing.T) {
+	l := fallbackList(t)
+	if err := l.SetFallback(1, "b", true); err != nil {
+		t.Fatalf("couldn't mark b: %v", err)
+	}
+
+	for _, want := range []int{1, 2, 1} {
+		if i, _ := l.Next(); i != want {
+			t.Errorf("got selection %d, want %d", i, want)
+		}
+	}
+	if l.Exhausted() {
+		t.Error("the fallback shouldn't let the list exhaust")
+	}
+
+	if err := l.SetFallback(1, "b", false); err != nil {
+		t.Fatalf("couldn't unmark b: %v", err)
+	}
+	l.Next()
+	l.Next()
+	if !l.Exhausted() {
+		t.Error("without a fallback, the list should exhaust")
+	}
+}
+
+// TestList_Bifrost_Fallback tests the 'fallback' and 'selfallback' requests, and the FALLBACK messages in dumps.
+func TestList_Bifrost_Fallback(t *testing.T) {
+	l := fallbackList(t)
+
+	rq, err := l.ParseBifrostRequest("fallback", []string{"1", "b", "on"})
+	if err != nil {
+		t.Fatalf("unexpected parse error: %v", err)
+	}
+	if want := (list.SetFallbackRequest{Index: 1, Hash: "b", Fallback: true}); rq != want {
+		t.Fatalf("got request %+v, want %+v", rq, want)
+	}
+	sel, err := l.ParseBifrostRequest("selfallback", nil)
+	if err != nil {
+		t.Fatalf("unexpected parse error: %v", err)
+	}
+	if _, err := l.ParseBifrostRequest("selfallback", []string{"now"}); err == nil {
+		t.Error("expected an error for an argument to 'selfallback'")
+	}
+
+	msgs := make(chan message.Message, 16)
+	emit := func(rbody interface{}) {
+		if err := l.EmitBifrostResponse("!", rbody, msgs); err != nil {
+			t.Fatalf("unexpected emit error: %v", err)
+		}
+	}
+	for _, rq := range []interface{}{rq, sel} {
+		if err := l.HandleRequest(func(interface{}) {}, emit, rq); err != nil {
+			t.Fatalf("unexpected error handling %+v: %v", rq, err)
+		}
+	}
+	l.Dump(func(rbody interface{}) {
+		if _, ok := rbody.(list.FallbackResponse); ok {
+			emit(rbody)
+		}
+	})
+	close(msgs)
+
+	var got []string
+	for m := range msgs {
+		got = append(got, m.String())
+	}
+	want := []string{"! FALLBACK 1 b on\n", "! SEL 1 b\n", "! FALLBACK 1 b on\n"}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("got messages %q, want %q", got, want)
+	}
+}
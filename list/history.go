@@ -0,0 +1,70 @@
+package list
+
+// File history.go contains the List's change history, from which clients that have fallen behind can catch up with
+// the changes they missed, rather than asking for a whole dump.
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/UniversityRadioYork/baps3d/controller"
+)
+
+// MaxHistory is the number of changes a List keeps for Since.
+const MaxHistory = 256
+
+// ErrHistoryGone is the error Since gives when some of the changes asked for are too old to have been kept.
+// Clients getting it should ask for a dump instead.
+var ErrHistoryGone = errors.New("changes since that version are no longer kept; dump instead")
+
+// change is one change in a List's history.
+type change struct {
+	// version is the version of the List after the change.
+	version uint64
+	// rbody is the broadcast that announced the change.
+	rbody interface{}
+}
+
+// Version gets the version of l: the number of changes l has broadcast through its Controller.
+// Dumps carry the version they reflect, as a VersionResponse.
+func (l *List) Version() uint64 {
+	return l.version
+}
+
+// Since gets a DiffResponse for each change l has made since it was at version v, oldest first.
+// Applying them in order to a copy of l at version v brings it up to date.
+// It fails with ErrHistoryGone if l no longer keeps every one of those changes, or if v is later than l's version.
+func (l *List) Since(v uint64) ([]DiffResponse, error) {
+	if l.version < v {
+		return nil, fmt.Errorf("Since: version %d is later than the current version %d", v, l.version)
+	}
+	n := l.version - v
+	if uint64(len(l.history)) < n {
+		return nil, fmt.Errorf("Since: %w: version %d, oldest kept %d", ErrHistoryGone, v, l.version-uint64(len(l.history)))
+	}
+
+	diffs := make([]DiffResponse, n)
+	for i, c := range l.history[len(l.history)-int(n):] {
+		diffs[i] = DiffResponse{Version: c.version, Change: c.rbody}
+	}
+	return diffs, nil
+}
+
+// recording wraps bcastCb so that l records every change it broadcasts in its history.
+func (l *List) recording(bcastCb controller.ResponseCb) controller.ResponseCb {
+	return func(rbody interface{}) {
+		l.record(rbody)
+		bcastCb(rbody)
+	}
+}
+
+// record adds the change announced by rbody to l's history, bumping l's version, and forgetting the oldest change if
+// the history is full.
+func (l *List) record(rbody interface{}) {
+	l.version++
+	if len(l.history) == MaxHistory {
+		copy(l.history, l.history[1:])
+		l.history = l.history[:MaxHistory-1]
+	}
+	l.history = append(l.history, change{version: l.version, rbody: rbody})
+}
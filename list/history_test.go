@@ -0,0 +1,159 @@
+package list_test
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+
+	"github.com/UniversityRadioYork/bifrost-go/message"
+
+	"github.com/UniversityRadioYork/baps3d/list"
+)
+
+// replica is a client's copy of a List's items and selection, kept up to date by applying diffs.
+type replica struct {
+	hashes  []string
+	sel     int
+	version uint64
+}
+
+// apply applies d to r.
+func (r *replica) apply(t *testing.T, d list.DiffResponse) {
+	t.Helper()
+
+	if d.Version != r.version+1 {
+		t.Fatalf("diff for version %d applied to version %d", d.Version, r.version)
+	}
+	r.version = d.Version
+
+	switch c := d.Change.(type) {
+	case list.ItemResponse:
+		r.hashes = append(r.hashes[:c.Index], append([]string{c.Item.Hash()}, r.hashes[c.Index:]...)...)
+	case list.ItemUpdatedResponse:
+		r.hashes[c.Index] = c.Item.Hash()
+	case list.SelectResponse:
+		r.sel = c.Index
+	default:
+		t.Fatalf("unexpected change %#v", c)
+	}
+}
+
+// TestList_Since tests that diffs from any version bring a replica at that version up to date, each carrying the
+// version it results in.
+func TestList_Since(t *testing.T) {
+	l := list.New()
+	handle := func(rbody interface{}) {
+		t.Helper()
+		if err := l.HandleRequest(func(interface{}) {}, func(interface{}) {}, rbody); err != nil {
+			t.Fatalf("unexpected error handling %T: %v", rbody, err)
+		}
+	}
+
+	handle(list.AddItemRequest{Index: 0, Item: *list.NewTrack("b", "b.mp3")})
+	handle(list.AddItemRequest{Index: 0, Item: *list.NewTrack("a", "a.mp3")})
+	handle(list.AddItemRequest{Index: 2, Item: *list.NewTrack("c", "c.mp3")})
+	handle(list.SetSelectRequest{Index: 1, Hash: "b"})
+	// Updating the selection also broadcasts its new hash, so that's two changes.
+	handle(list.UpdateItemRequest{Index: 1, Hash: "b", Item: *list.NewTrack("b2", "b2.mp3")})
+	handle(list.SetSelectRequest{Index: 0, Hash: "a"})
+
+	if got := l.Version(); got != 7 {
+		t.Fatalf("got version %d, want 7", got)
+	}
+
+	full, err := l.Since(0)
+	if err != nil {
+		t.Fatalf("since 0: unexpected error: %v", err)
+	}
+	want := []string{"a", "b2", "c"}
+	// Bring a replica up to each version with the first diffs from version 0, then the rest with the diffs since then.
+	for v := uint64(0); v <= l.Version(); v++ {
+		r := replica{sel: -1}
+		for _, d := range full[:v] {
+			r.apply(t, d)
+		}
+
+		diffs, err := l.Since(v)
+		if err != nil {
+			t.Fatalf("since %d: unexpected error: %v", v, err)
+		}
+		for _, d := range diffs {
+			r.apply(t, d)
+		}
+		if sel, _ := l.Selection(); r.version != l.Version() || r.sel != sel || !reflect.DeepEqual(r.hashes, want) {
+			t.Errorf("since %d: replica at version %d has %v selecting %d, want %v selecting %d", v, r.version, r.hashes, r.sel, want, sel)
+		}
+	}
+
+	if _, err := l.Since(l.Version() + 1); err == nil {
+		t.Error("since a future version: expected an error")
+	}
+}
+
+// TestList_Since_HistoryGone tests that asking for changes older than the List keeps fails with ErrHistoryGone.
+func TestList_Since_HistoryGone(t *testing.T) {
+	l := list.New()
+	for _, h := range []string{"a", "b"} {
+		if err := l.Add(list.NewTrack(h, h+".mp3"), 0); err != nil {
+			t.Fatalf("couldn't add item: %v", err)
+		}
+	}
+	for i := 0; i < list.MaxHistory+1; i++ {
+		rq := list.SetSelectRequest{Index: i % 2, Hash: []string{"b", "a"}[i%2]}
+		if err := l.HandleRequest(func(interface{}) {}, func(interface{}) {}, rq); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+	}
+
+	if _, err := l.Since(0); !errors.Is(err, list.ErrHistoryGone) {
+		t.Errorf("since 0: got %v, want ErrHistoryGone", err)
+	}
+	if diffs, err := l.Since(1); err != nil || len(diffs) != list.MaxHistory {
+		t.Errorf("since 1: got %d diffs and %v, want %d and no error", len(diffs), err, list.MaxHistory)
+	}
+}
+
+// TestList_Bifrost_Since checks that 'since' gets each change as DIFF then the change as broadcast, and that dumps end
+// with the version.
+func TestList_Bifrost_Since(t *testing.T) {
+	l := list.New()
+	if err := l.Add(list.NewTrack("a", "a.mp3"), 0); err != nil {
+		t.Fatalf("couldn't add item: %v", err)
+	}
+	if err := l.HandleRequest(func(interface{}) {}, func(interface{}) {}, list.SetSelectRequest{Index: 0, Hash: "a"}); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	rq, err := l.ParseBifrostRequest("since", []string{"0"})
+	if err != nil {
+		t.Fatalf("unexpected parse error: %v", err)
+	}
+	if _, err := l.ParseBifrostRequest("since", nil); err == nil {
+		t.Error("since with no version: expected an error")
+	}
+
+	msgs := make(chan message.Message, 10)
+	replyCb := func(r interface{}) {
+		if err := l.EmitBifrostResponse("t", r, msgs); err != nil {
+			t.Fatalf("unexpected emit error: %v", err)
+		}
+	}
+	if err := l.HandleRequest(replyCb, func(interface{}) {}, rq); err != nil {
+		t.Fatalf("unexpected error handling %T: %v", rq, err)
+	}
+	l.Dump(func(r interface{}) {
+		if v, ok := r.(list.VersionResponse); ok {
+			replyCb(v)
+		}
+	})
+	close(msgs)
+
+	var got []string
+	for m := range msgs {
+		got = append(got, m.String())
+	}
+	want := []string{"t DIFF 1\n", "t SEL 0 a\n", "t VERSION 1\n"}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("emitted %q, want %q", got, want)
+	}
+}
@@ -1,5 +1,10 @@
 package list
 
+import (
+	"fmt"
+	"time"
+)
+
 // ItemType is the type of types of item.
 type ItemType int
 
@@ -28,6 +33,19 @@ func (i ItemType) String() string {
 	}
 }
 
+// ParseItemType tries to parse an ItemType from its descriptive name.
+// Only the types of real items can be parsed.
+func ParseItemType(s string) (ItemType, error) {
+	switch s {
+	case "track":
+		return ItemTrack, nil
+	case "text":
+		return ItemText, nil
+	default:
+		return ItemNone, fmt.Errorf("invalid item type")
+	}
+}
+
 // Item is the internal representation of a baps3d list item.
 type Item struct {
 	// hash is the inserter-supplied unique hash of the item.
@@ -36,11 +54,31 @@ type Item struct {
 	payload string
 	// itype is the type of the item.
 	itype ItemType
+	// data, if non-nil, is the binary data component of the item.
+	// Items with binary data have an empty string payload.
+	data []byte
+	// id is the List-assigned stable identifier of the item, or 0 if it isn't in a List.
+	id uint64
+	// duration is the running time of the item, or 0 if it isn't known.
+	duration time.Duration
+	// stopAfter is true if the item is a stop point; see List.SetStopAfter.
+	stopAfter bool
+	// locked is true if the item is locked; see List.SetLocked.
+	locked bool
+	// fallback is true if the item is its List's fallback; see List.SetFallback.
+	fallback bool
+	// played is true if the item has finished playing since it was last selected; see List.MarkPlayed.
+	played bool
 }
 
 // NewItem creates a new item with the given hash, payload, and item type.
 func NewItem(itype ItemType, hash, payload string) *Item {
-	return &Item{hash, payload, itype}
+	return &Item{hash: hash, payload: payload, itype: itype}
+}
+
+// NewBinaryItem creates a new item with the given hash, binary data, and item type.
+func NewBinaryItem(itype ItemType, hash string, data []byte) *Item {
+	return &Item{hash: hash, itype: itype, data: data}
 }
 
 // NewTrack creates a new track-type item.
@@ -63,6 +101,52 @@ func (i *Item) Payload() string {
 	return i.payload
 }
 
+// Data returns the binary data of the Item, or nil if the Item has a textual payload.
+func (i *Item) Data() []byte {
+	return i.data
+}
+
+// IsBinary returns whether the Item carries binary data rather than a textual payload.
+func (i *Item) IsBinary() bool {
+	return i.data != nil
+}
+
+// ID returns the stable identifier the Item was given when it was added to a List, or 0 if it hasn't been.
+// Unlike the index, the ID doesn't change as other items come and go; unlike the hash, it is never reused.
+func (i *Item) ID() uint64 {
+	return i.id
+}
+
+// Duration returns the running time of the Item, and whether it is known.
+// Items start with unknown durations; see List.SetDuration.
+func (i *Item) Duration() (time.Duration, bool) {
+	return i.duration, 0 < i.duration
+}
+
+// StopAfter returns whether the Item is a stop point, after which the automode doesn't advance on its own.
+// Items start unmarked; see List.SetStopAfter.
+func (i *Item) StopAfter() bool {
+	return i.stopAfter
+}
+
+// Locked returns whether the Item is locked against being moved or removed.
+// Items start unlocked; see List.SetLocked.
+func (i *Item) Locked() bool {
+	return i.locked
+}
+
+// Fallback returns whether the Item is its List's fallback, selected when the List would otherwise go silent.
+// Items start unmarked; see List.SetFallback.
+func (i *Item) Fallback() bool {
+	return i.fallback
+}
+
+// Played returns whether the Item has been marked as having finished playing since it was last selected; see
+// List.MarkPlayed.
+func (i *Item) Played() bool {
+	return i.played
+}
+
 // Hash returns the hash of the Item.
 func (i *Item) Hash() string {
 	return i.hash
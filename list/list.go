@@ -5,11 +5,13 @@ import (
 	"fmt"
 	"math/rand"
 	"time"
+
+	"github.com/UniversityRadioYork/baps3d/controller"
 )
 
 // List is the internal representation of a baps3d list.
 // It only maintains the playlist itself: it does not talk to the environment,
-// nor does it know anything about what is actually playing.
+// nor does it know anything about what is actually playing, other than what it's told (see SetElapsed and SetPlaying).
 type List struct {
 	// list is the internal linked list representing the playlist.
 	// Element type is *Item.
@@ -25,6 +27,54 @@ type List struct {
 	// usedHashes is the set of currently spent hashes since the last select.
 	// It is used for calculating the next track in AutoShuffle mode.
 	usedHashes map[string]struct{}
+
+	// exhausted is true if an automode advance has run out of items to select.
+	exhausted bool
+
+	// nextID is the ID that will be given to the next item added to the list.
+	nextID uint64
+
+	// elapsed is how much of the selected item has played, as last reported with SetElapsed, or as of pausing.
+	elapsed time.Duration
+	// playingSince, if non-zero, is when the selection started playing, or was last reported with SetElapsed since;
+	// it has played elapsed plus however long it has been since then.
+	playingSince time.Time
+
+	// dumpAirTimes is true if dumps include air times.
+	dumpAirTimes bool
+
+	// advanceOnPlayed is true if marking the selection played advances the List; see SetAdvanceOnPlayed.
+	advanceOnPlayed bool
+
+	// saveFile is the path of the List's saved list, or empty if it has none; see SetSaveFile.
+	saveFile string
+
+	// version counts the changes the List has broadcast; see Version.
+	version uint64
+
+	// history holds the latest changes the List has broadcast, oldest first, for Since.
+	// It holds at most MaxHistory changes.
+	history []change
+
+	// clock is the List's source of time, for timestamping plays and timing the selection.
+	clock controller.Clock
+	// playHistory is the most plays the List remembers; see SetPlayHistory.
+	playHistory int
+	// plays holds the plays the List remembers, oldest first.
+	plays []Play
+
+	// staleHashes is the most superseded hashes the List remembers; see SetStaleHashes.
+	staleHashes int
+	// superseded holds the hashes that updates have recently replaced, oldest first.
+	superseded []supersession
+
+	// transforms are the Transforms run on items going into the List, before validators; see SetTransforms.
+	transforms []Transform
+	// validators are the Validators run on items going into the List; see SetValidators.
+	validators []Validator
+
+	// emptyCb, if non-nil, is called whenever the List goes from empty to non-empty, or back; see SetEmptyCallback.
+	emptyCb func(empty bool)
 }
 
 // New creates a new baps3d list.
@@ -35,20 +85,55 @@ func New() *List {
 	src := rand.NewSource(time.Now().Unix())
 
 	return &List{
-		list:       list.New(),
-		selection:  -1,
-		autoselect: AutoOff,
-		rng:        rand.New(src),
-		usedHashes: make(map[string]struct{}),
+		list:        list.New(),
+		selection:   -1,
+		autoselect:  AutoOff,
+		rng:         rand.New(src),
+		usedHashes:  make(map[string]struct{}),
+		nextID:      1,
+		clock:       controller.SystemClock{},
+		playHistory: DefaultPlayHistory,
+	}
+}
+
+// RelativeIndex resolves offset, relative to the selection, to the index in front of which Add would insert an
+// item: so 1 is immediately after the selection, and 0 immediately before it.
+// Offsets past either end of the List are clamped to it; if there is no selection, the index is always the end of
+// the List, as if the item were queued.
+func (l *List) RelativeIndex(offset int) int {
+	n := l.Count()
+	if l.selection == -1 {
+		return n
+	}
+	// The comparisons are written this way round so that huge offsets don't overflow.
+	switch {
+	case offset < -l.selection:
+		return 0
+	case n-l.selection < offset:
+		return n
+	default:
+		return l.selection + offset
 	}
 }
 
 // Add adds an Item to a list.
-// It will fail if there is already an Item with the same hash enqueued.
+// It will fail if there is already an Item with the same hash enqueued, or if a Validator rejects it (see
+// SetValidators).
+// Add first runs l's Transforms on item (see SetTransforms), even if it then fails.
+// On success, Add gives item a new ID, which is unique for the lifetime of the List (see Item.ID).
 func (l *List) Add(item *Item, i int) error {
+	defer l.notifyEmpty(l.Count() == 0)
+	l.transform(item)
+
 	if j, _ := l.ItemWithHash(item.Hash()); j > -1 {
 		return fmt.Errorf("List.Add(): duplicate hash %s at index %d", item.Hash(), j)
 	}
+	if i < 0 || l.Count() < i {
+		return fmt.Errorf("Tried to insert element at index %d when there are only %d item(s)", i, l.Count())
+	}
+	if err := l.validate("List.Add()", func() []Item { return l.withInserted(item, i) }, i); err != nil {
+		return err
+	}
 
 	// Adding an item on or before the current selection moves it down one.
 	if i <= l.selection {
@@ -58,17 +143,215 @@ func (l *List) Add(item *Item, i int) error {
 	// We have to handle the 'front of list' situation specially:
 	// all the other ones expect a predecessor element.
 	if i == 0 {
+		l.assignID(item)
 		l.list.PushFront(item)
+		l.exhausted = false
 		return nil
 	}
 
-	if e := l.elementWithIndex(i - 1); e != nil {
-		l.list.InsertAfter(item, e)
+	// The bounds check above means there is always a predecessor.
+	l.assignID(item)
+	l.list.InsertAfter(item, l.elementWithIndex(i-1))
+	l.exhausted = false
+	return nil
+}
+
+// Update replaces the content of the item with the given index and hash with that of item, in place.
+// The item's new hash is item's hash, which mustn't belong to any other item; its type, payload, and any binary data
+// come from item too.
+// It keeps its place in the List, its ID, and its running time.
+//
+// Anything that refers to the item by its old hash follows it to the new one, all at once: in particular, if the
+// item is selected (and so, as far as the List knows, playing), it stays selected, and keeps its elapsed time.
+// As the selection must be selectable, Update fails if it would turn the selected item into, say, a text item, and it
+// fails if a Validator rejects the new content (see SetValidators).
+// Update also fails if the item doesn't exist, or has a different hash (see controller.ErrStateChanged), or if it is
+// locked (see ErrItemLocked); on failure, the List is untouched.
+// Like Add, Update first runs l's Transforms on item.
+func (l *List) Update(index int, hash string, item *Item) error {
+	l.transform(item)
+	old, err := l.guardedItem("Update", index, hash)
+	if err != nil {
+		return err
+	}
+	if err := l.checkUnlocked("Update", index); err != nil {
+		return err
+	}
+	if j, _ := l.ItemWithHash(item.Hash()); j != -1 && j != index {
+		return fmt.Errorf("Update: duplicate hash %s at index %d", item.Hash(), j)
+	}
+	if index == l.selection && !item.IsSelectable() {
+		return fmt.Errorf("Update: selected item would become unselectable")
+	}
+	if old.fallback && !item.IsSelectable() {
+		return fmt.Errorf("Update: fallback item would become unselectable")
+	}
+	if err := l.validate("Update", func() []Item { return l.withReplaced(item, index) }, index); err != nil {
+		return err
+	}
+
+	if _, used := l.usedHashes[hash]; used {
+		delete(l.usedHashes, hash)
+		l.usedHashes[item.Hash()] = struct{}{}
+	}
+	if item.hash != hash {
+		l.supersede(hash, old.ID())
+	}
+	old.hash = item.hash
+	old.payload = item.payload
+	old.itype = item.itype
+	old.data = item.data
+	return nil
+}
+
+// Swap swaps the item at index i, which must have hash hi, with the item at index j, which must have hash hj.
+// The selection, if on either item, follows it, keeping its elapsed time; nothing else about the items changes.
+// Swapping an item with itself does nothing.
+// It fails, leaving l untouched, if either item doesn't exist or has a different hash (see controller.ErrStateChanged),
+// if either is locked (see ErrItemLocked), or if a Validator rejects either item in its new place.
+func (l *List) Swap(i int, hi string, j int, hj string) error {
+	if _, err := l.guardedItem("Swap", i, hi); err != nil {
+		return err
+	}
+	if _, err := l.guardedItem("Swap", j, hj); err != nil {
+		return err
+	}
+	if i == j {
 		return nil
 	}
+	for _, k := range []int{i, j} {
+		if err := l.checkUnlocked("Swap", k); err != nil {
+			return err
+		}
+	}
 
-	// There was no predecessor, and index is not 0, so we've overshot
-	return fmt.Errorf("Tried to insert element at index %d when there are only %d item(s)", i, l.Count())
+	after := func() []Item { return l.withSwapped(i, j) }
+	for _, k := range []int{i, j} {
+		if err := l.validate("Swap", after, k); err != nil {
+			return err
+		}
+	}
+
+	ei, ej := l.elementWithIndex(i), l.elementWithIndex(j)
+	ei.Value, ej.Value = ej.Value, ei.Value
+	switch l.selection {
+	case i:
+		l.selection = j
+	case j:
+		l.selection = i
+	}
+	return nil
+}
+
+// MaxReplaceLen is the largest number of items Replace accepts.
+const MaxReplaceLen = 10000
+
+// Replace replaces every item in the List with items, in order, and then selects the item at index sel, or nothing
+// if sel is -1.
+// It either replaces the whole list or, on error, leaves the List untouched: it fails if there are more than
+// MaxReplaceLen items, if two items share a hash, if sel isn't -1 or the index of a selectable item, if a
+// Validator rejects any of the items (see SetValidators), or, with ErrItemLocked, if any of the old items is locked.
+// The List stores the items as l's Transforms leave them (see SetTransforms), but doesn't change items itself.
+//
+// The new items get new IDs, even if they were in the List before.
+// The automode is preserved, but exhaustion, the shuffle history, and the elapsed time of the selection are not,
+// as they belonged to the old items.
+func (l *List) Replace(items []Item, sel int) error {
+	_, err := l.replace(items, sel, false)
+	return err
+}
+
+// ReplaceSkipping is like Replace, but leaves out, rather than failing on, items that share a hash with an earlier
+// item or that a Validator rejects.
+// It returns a Summary of which items it left out, and why; if it leaves out the item at sel, nothing is selected.
+// It still fails, leaving the List untouched, for every other reason Replace does.
+func (l *List) ReplaceSkipping(items []Item, sel int) (Summary, error) {
+	return l.replace(items, sel, true)
+}
+
+// replace does the work of Replace, or, if skip is true, ReplaceSkipping.
+func (l *List) replace(items []Item, sel int, skip bool) (Summary, error) {
+	defer l.notifyEmpty(l.Count() == 0)
+
+	sum := Summary{Requested: len(items)}
+	if MaxReplaceLen < len(items) {
+		return sum, fmt.Errorf("List.Replace(): %d items, max %d", len(items), MaxReplaceLen)
+	}
+	if err := l.checkNoneLocked("List.Replace()"); err != nil {
+		return sum, err
+	}
+	if sel < -1 || len(items) <= sel {
+		return sum, fmt.Errorf("List.Replace(): selection %d out of bounds", sel)
+	}
+	if sel != -1 && !items[sel].IsSelectable() {
+		return sum, fmt.Errorf("List.Replace(): item %d not selectable", sel)
+	}
+
+	var err error
+	items = l.transformAll(items)
+	if skip {
+		items, sel, sum.Rejected = l.skipRejected(items, sel)
+	} else if err = checkDistinctHashes(items); err == nil {
+		err = l.validateAll("List.Replace()", items)
+	}
+	if err != nil {
+		return sum, err
+	}
+	sum.Applied = len(items)
+
+	l.list.Init()
+	for i := range items {
+		item := items[i]
+		l.assignID(&item)
+		l.list.PushBack(&item)
+	}
+
+	l.selection = sel
+	l.restartElapsed()
+	l.exhausted = false
+	l.clearUsedHashes()
+	// The old items' hashes belong to items that have gone, so they can't stand for the new ones.
+	l.superseded = l.superseded[:0]
+	return sum, nil
+}
+
+// checkDistinctHashes fails if two of items share a hash.
+func checkDistinctHashes(items []Item) error {
+	hashes := make(map[string]int, len(items))
+	for i, item := range items {
+		if j, ok := hashes[item.Hash()]; ok {
+			return fmt.Errorf("List.Replace(): duplicate hash %s at indices %d and %d", item.Hash(), j, i)
+		}
+		hashes[item.Hash()] = i
+	}
+	return nil
+}
+
+// SetEmptyCallback sets f to be called whenever the List goes from empty to non-empty, or back, with whether it is
+// now empty.
+// Only two mutations can do that: Add, taking the List from empty to non-empty, and Replace, taking it either way.
+// Nothing else changes the number of items; failed mutations, and a Replace that leaves an empty List empty (or a
+// non-empty List non-empty), never call f.
+// A nil f stops the calls.
+//
+// f runs synchronously, inside the mutation: under a Controller, that's on the Controller's loop, so calls are in
+// step with the List's broadcasts, but f mustn't block, nor touch the List.
+// Callers that need to do anything slow should hand the value f gets to another goroutine.
+func (l *List) SetEmptyCallback(f func(empty bool)) {
+	l.emptyCb = f
+}
+
+// notifyEmpty calls l's empty callback, if it has one, if l's emptiness has changed since it was wasEmpty.
+func (l *List) notifyEmpty(wasEmpty bool) {
+	if empty := l.Count() == 0; l.emptyCb != nil && empty != wasEmpty {
+		l.emptyCb(empty)
+	}
+}
+
+// assignID gives item the next available ID.
+func (l *List) assignID(item *Item) {
+	item.id = l.nextID
+	l.nextID++
 }
 
 // Count gets the number of items in the list.
@@ -83,6 +366,7 @@ func (l *List) AutoMode() AutoMode {
 
 // SetAutoMode changes the current autoselect mode for the given List.
 // It returns whether the automode has changed.
+// Changing the automode clears any exhaustion (see Exhausted).
 func (l *List) SetAutoMode(mode AutoMode) bool {
 	if mode == l.autoselect {
 		return false
@@ -94,6 +378,8 @@ func (l *List) SetAutoMode(mode AutoMode) bool {
 	}
 
 	l.autoselect = mode
+	// Exhaustion is a property of the old mode's advance, so it no longer holds.
+	l.exhausted = false
 	return true
 }
 
@@ -136,6 +422,21 @@ func (l *List) elementWithHash(hash string) (int, *list.Element) {
 	return -1, nil
 }
 
+// guardedItem finds the item with the given index, checking that it has the given hash.
+// Requests carry both so that they can't act on the wrong item if the List changes in the meantime, so, if the item
+// doesn't exist or has a different hash, guardedItem fails with an error wrapping controller.ErrStateChanged, saying
+// that it happened in op.
+func (l *List) guardedItem(op string, index int, hash string) (*Item, error) {
+	item := l.ItemWithIndex(index)
+	if item == nil {
+		return nil, fmt.Errorf("%s: %w: index %d out of bounds", op, controller.ErrStateChanged, index)
+	}
+	if ihash := item.Hash(); hash != ihash {
+		return nil, fmt.Errorf("%s: %w: requested hash '%s', actual '%s'", op, controller.ErrStateChanged, hash, ihash)
+	}
+	return item, nil
+}
+
 // ItemWithHash tries to find the item with the given hash.
 // The result is returned as a pair of index and possible item.
 // If the index is -1, there is no item with that hash, and the item is nil.
@@ -146,6 +447,20 @@ func (l *List) ItemWithHash(hash string) (int, *Item) {
 	return -1, nil
 }
 
+// ItemWithID tries to find the item with the given ID.
+// The result is returned as a pair of index and possible item.
+// If the index is -1, there is no item with that ID, and the item is nil.
+func (l *List) ItemWithID(id uint64) (int, *Item) {
+	i := 0
+	for e := l.list.Front(); e != nil; e = e.Next() {
+		if item := e.Value.(*Item); item.ID() == id {
+			return i, item
+		}
+		i++
+	}
+	return -1, nil
+}
+
 // Selection gets the current selection for the given List.
 // The selection is returned as a pair of index and possible item.
 // If the index is -1, there is no selection, and the item is nil.
@@ -165,18 +480,11 @@ func (l *List) Selection() (int, *Item) {
 
 // Select tries to select the item with the given index and hash.
 // It returns a Boolean stating whether the selection changed.
-// It fails if the item doesn't exist, or has a different hash.
+// It fails if the item doesn't exist, or has a different hash (see controller.ErrStateChanged).
 func (l *List) Select(index int, hash string) (changed bool, err error) {
 	// We always validate the hash, even if the index hasn't changed.
-	i := l.ItemWithIndex(index)
-	if i == nil {
-		err = fmt.Errorf("Select: index %d out of bounds", index)
-		return
-	}
-
-	ihash := i.Hash()
-	if hash != ihash {
-		err = fmt.Errorf("Select: hash mismatch: requested '%s', actual '%s'", hash, ihash)
+	i, err := l.guardedItem("Select", index, hash)
+	if err != nil {
 		return
 	}
 
@@ -186,10 +494,211 @@ func (l *List) Select(index int, hash string) (changed bool, err error) {
 	}
 
 	changed = index != l.selection
-	l.selection = index
+	l.setSelection(index)
+	l.exhausted = false
 	return
 }
 
+// SelectNext manually moves the selection to the next selectable item after it, regardless of the automode.
+// If nothing is selected, it selects the first selectable item.
+// If no selectable item follows the selection, SelectNext fails, unless wrap is true, in which case it wraps round to
+// the first selectable item.
+// It always fails if the List has no selectable items, including if it is empty.
+// It returns the new selection index, and whether the selection changed.
+func (l *List) SelectNext(wrap bool) (index int, changed bool, err error) {
+	index = l.firstSelectableFrom(l.selection + 1)
+	if index == -1 && wrap {
+		index = l.firstSelectableFrom(0)
+	}
+	if index == -1 {
+		if wrap || l.selection == -1 {
+			return l.selection, false, fmt.Errorf("SelectNext: no selectable items")
+		}
+		return l.selection, false, fmt.Errorf("SelectNext: no selectable items after the selection")
+	}
+
+	changed = index != l.selection
+	l.setSelection(index)
+	l.exhausted = false
+	return index, changed, nil
+}
+
+// SelectNextN manually moves the selection forwards n selectable items, as SelectNext would if called n times, but
+// only changing the selection once.
+// If fewer than n selectable items follow the selection, SelectNextN stops at the last of them, unless wrap is true,
+// in which case it carries on round from the first selectable item.
+// It fails as SelectNext does if it can't move at all, and if n is negative; moving backwards is PreviousRequest's
+// job.
+// If n is zero, nothing happens.
+// It returns the new selection index, and whether the selection changed.
+func (l *List) SelectNextN(n int, wrap bool) (index int, changed bool, err error) {
+	if n < 0 {
+		return l.selection, false, fmt.Errorf("SelectNextN: negative count %d", n)
+	}
+	if n == 0 {
+		return l.selection, false, nil
+	}
+	if s := l.selectableCount(); wrap && 0 < s && s < n {
+		// Each trip round the whole list ends up back where it started.
+		n = (n-1)%s + 1
+	}
+
+	index = l.selection
+	for moved := 0; moved < n; moved++ {
+		next := l.firstSelectableFrom(index + 1)
+		if next == -1 && wrap {
+			next = l.firstSelectableFrom(0)
+		}
+		if next == -1 {
+			if moved == 0 {
+				return l.SelectNext(wrap)
+			}
+			break
+		}
+		index = next
+	}
+
+	changed = index != l.selection
+	l.setSelection(index)
+	l.exhausted = false
+	return index, changed, nil
+}
+
+// SelectPrevious manually moves the selection to the last selectable item before it, regardless of the automode.
+// It is the reverse of SelectNext: if nothing is selected, it selects the last selectable item, and if no selectable
+// item comes before the selection, SelectPrevious fails, unless wrap is true, in which case it wraps round to the
+// last selectable item.
+// It always fails if the List has no selectable items, including if it is empty.
+// As with any selection change, the new selection starts with nothing elapsed.
+// It returns the new selection index, and whether the selection changed.
+func (l *List) SelectPrevious(wrap bool) (index int, changed bool, err error) {
+	from := l.selection
+	if from == -1 {
+		from = l.list.Len()
+	}
+
+	index = l.lastSelectableBefore(from)
+	if index == -1 && wrap {
+		index = l.lastSelectableBefore(l.list.Len())
+	}
+	if index == -1 {
+		if wrap || l.selection == -1 {
+			return l.selection, false, fmt.Errorf("SelectPrevious: no selectable items")
+		}
+		return l.selection, false, fmt.Errorf("SelectPrevious: no selectable items before the selection")
+	}
+
+	changed = index != l.selection
+	l.setSelection(index)
+	l.exhausted = false
+	return index, changed, nil
+}
+
+// SelectRelative manually moves the selection offset selectable items from where it is, forwards if offset is
+// positive and backwards if it is negative, only changing the selection once.
+// This is for jog controls, which know which way and how far to move, but not where the selection is when the move
+// arrives.
+// If wrap is true, moving past either end of the List carries on round from the other.
+// Otherwise, the move clamps: it stops at the first or last selectable item, and does nothing, without failing, if the
+// selection is already there.
+// It fails if nothing is selected, as there is then nothing to move relative to; SelectNext and SelectPrevious pick
+// a starting point instead.
+// It returns the new selection index, and whether the selection changed.
+func (l *List) SelectRelative(offset int, wrap bool) (index int, changed bool, err error) {
+	if l.selection == -1 {
+		return -1, false, fmt.Errorf("SelectRelative: nothing selected")
+	}
+
+	// The selection is selectable, so s is at least 1.
+	// Moving s items either way, wrapping, ends up back where it started; without wrapping, it reaches the end.
+	s := l.selectableCount()
+	switch {
+	case wrap:
+		offset %= s
+	case s < offset:
+		offset = s
+	case offset < -s:
+		offset = -s
+	}
+
+	index = l.selection
+	for ; 0 < offset; offset-- {
+		next := l.firstSelectableFrom(index + 1)
+		if next == -1 && wrap {
+			next = l.firstSelectableFrom(0)
+		}
+		if next == -1 {
+			break
+		}
+		index = next
+	}
+	for ; offset < 0; offset++ {
+		prev := l.lastSelectableBefore(index)
+		if prev == -1 && wrap {
+			prev = l.lastSelectableBefore(l.list.Len())
+		}
+		if prev == -1 {
+			break
+		}
+		index = prev
+	}
+
+	changed = index != l.selection
+	l.setSelection(index)
+	l.exhausted = false
+	return index, changed, nil
+}
+
+// lastSelectableBefore finds the index of the last selectable item before index i, or -1 if there isn't one.
+// i may be the length of the List, to search the whole List.
+func (l *List) lastSelectableBefore(i int) int {
+	e := l.list.Back()
+	if i < l.list.Len() {
+		e = l.elementWithIndex(i).Prev()
+	}
+	for i--; e != nil; e = e.Prev() {
+		if e.Value.(*Item).IsSelectable() {
+			return i
+		}
+		i--
+	}
+	return -1
+}
+
+// selectableCount counts the selectable items in l.
+func (l *List) selectableCount() int {
+	n := 0
+	for e := l.list.Front(); e != nil; e = e.Next() {
+		if e.Value.(*Item).IsSelectable() {
+			n++
+		}
+	}
+	return n
+}
+
+// firstSelectableFrom finds the index of the first selectable item at or after index i, or -1 if there isn't one.
+func (l *List) firstSelectableFrom(i int) int {
+	for e := l.elementWithIndex(i); e != nil; e = e.Next() {
+		if e.Value.(*Item).IsSelectable() {
+			return i
+		}
+		i++
+	}
+	return -1
+}
+
+// Exhausted gets whether the given List has been exhausted.
+//
+// A List becomes exhausted when Next is called on a selected item in AutoNext or AutoShuffle mode, and there is
+// no further item to select: in AutoNext mode, no selectable item follows the selection; in AutoShuffle mode, every
+// selectable item has been picked since the shuffle started.
+// AutoOff and AutoDrop never exhaust a List, as they don't try to advance.
+//
+// A List stops being exhausted when an item is added to it, an item is selected, or its automode changes.
+func (l *List) Exhausted() bool {
+	return l.exhausted
+}
+
 // Freeze copies the current list to a slice.
 func (l *List) Freeze() []Item {
 	// TODO(@MattWindsor91): inefficient
@@ -205,8 +714,70 @@ func (l *List) Freeze() []Item {
 	return frozen
 }
 
+// Context copies the items within radius places either side of the selection to a slice.
+// It returns the index of the first copied item; near either end of the list, fewer than radius items are copied on
+// that side.
+// If there is no selection, it copies nothing, and returns -1.
+// It fails if radius is negative.
+func (l *List) Context(radius int) (start int, items []Item, err error) {
+	if radius < 0 {
+		return -1, nil, fmt.Errorf("Context: negative radius %d", radius)
+	}
+	if l.selection == -1 {
+		return -1, nil, nil
+	}
+
+	// The comparisons are written this way round so that huge radii don't overflow.
+	start, end := 0, l.list.Len()-1
+	if radius < l.selection {
+		start = l.selection - radius
+	}
+	if radius < end-l.selection {
+		end = l.selection + radius
+	}
+
+	items = make([]Item, 0, end-start+1)
+	e := l.elementWithIndex(start)
+	for i := start; i <= end; i++ {
+		items = append(items, *(e.Value.(*Item)))
+		e = e.Next()
+	}
+	return start, items, nil
+}
+
+// MaxTypeMatches is the most items OfType copies.
+const MaxTypeMatches = 1000
+
+// OfType copies, in order, up to MaxTypeMatches of the items with type itype to a slice, along with their indices.
+// It returns truncated = true if there were more.
+// It fails if itype isn't the type of a real item, such as ItemTrack or ItemText.
+func (l *List) OfType(itype ItemType) (indices []int, items []Item, truncated bool, err error) {
+	if itype != ItemTrack && itype != ItemText {
+		return nil, nil, false, fmt.Errorf("OfType: unknown item type %v", itype)
+	}
+
+	i := 0
+	for e := l.list.Front(); e != nil; e = e.Next() {
+		if item := e.Value.(*Item); item.itype == itype {
+			if len(items) == MaxTypeMatches {
+				return indices, items, true, nil
+			}
+			indices = append(indices, i)
+			items = append(items, *item)
+		}
+		i++
+	}
+	return indices, items, false, nil
+}
+
 // Next advances the selection according to the automode.
+// Items that can't be selected, such as text items, are never chosen.
 // It returns the new selection and a Boolean stating whether the selection changed.
+// If the automode had nothing left to advance to, it selects the fallback item (see SetFallback) if there is one;
+// otherwise, the List becomes exhausted (see Exhausted).
+// If the selection is a stop point (see AtStop), the selection stays where it is.
+// The item that was selected goes into the play history (see Plays), whatever the automode, unless MarkPlayed has
+// already put it there.
 func (l *List) Next() (int, bool) {
 	e := l.elementWithIndex(l.selection)
 	// We can't get the next selection if nothing is selected.
@@ -214,9 +785,20 @@ func (l *List) Next() (int, bool) {
 	if e == nil {
 		return -1, false
 	}
+	if item := e.Value.(*Item); !item.played {
+		l.recordPlay(item)
+	}
+	if l.AtStop() {
+		return l.selection, false
+	}
 
 	ni, nh := l.chooseNext(l.selection, e)
-	l.selection = ni
+	if ni == -1 && (l.autoselect == AutoNext || l.autoselect == AutoShuffle) {
+		if ni, nh = l.Fallback(); ni == -1 {
+			l.exhausted = true
+		}
+	}
+	l.setSelection(ni)
 	return ni, nh != e.Value.(*Item).Hash()
 }
 
@@ -228,8 +810,12 @@ func (l *List) chooseNext(i int, prev *list.Element) (int, string) {
 	case AutoDrop:
 		return -1, ""
 	case AutoNext:
-		if e := prev.Next(); e != nil {
-			return i + 1, e.Value.(*Item).Hash()
+		// Skip over anything we can't select, such as text items.
+		for e := prev.Next(); e != nil; e = e.Next() {
+			i++
+			if item := e.Value.(*Item); item.IsSelectable() {
+				return i, item.Hash()
+			}
 		}
 		return -1, ""
 	case AutoShuffle:
@@ -260,7 +846,7 @@ func (l *List) shuffleChoose() (int, string) {
 	for e := l.list.Front(); e != nil; e = e.Next() {
 		le := e.Value.(*Item)
 		lh := le.Hash()
-		if _, in := l.usedHashes[lh]; !in {
+		if _, in := l.usedHashes[lh]; !in && le.IsSelectable() {
 			unpickedH[count] = lh
 			unpickedI[count] = i
 			count++
@@ -2,7 +2,10 @@ package list_test
 
 import (
 	"fmt"
+	"math"
+	"reflect"
 	"testing"
+	"time"
 
 	"github.com/UniversityRadioYork/baps3d/list"
 )
@@ -136,3 +139,577 @@ func Test_CannotSelectTextItem(t *testing.T) {
 
 	// TODO(@MattWindsor91): make sure we get the right error
 }
+
+// TestList_Next_Exhausted checks that advancing off the end of the list in AutoNext mode exhausts it,
+// and that adding an item clears the exhaustion.
+func TestList_Next_Exhausted(t *testing.T) {
+	l := list.New()
+	l.SetAutoMode(list.AutoNext)
+
+	if err := l.Add(list.NewTrack("abc", "foo.mp3"), 0); err != nil {
+		panic(err)
+	}
+	if err := l.Add(list.NewTrack("xyz", "bar.mp3"), 1); err != nil {
+		panic(err)
+	}
+	if _, err := l.Select(0, "abc"); err != nil {
+		panic(err)
+	}
+
+	if i, _ := l.Next(); i != 1 {
+		t.Fatalf("next selection: got %d, want 1", i)
+	}
+	if l.Exhausted() {
+		t.Fatal("list exhausted too early")
+	}
+
+	if i, changed := l.Next(); i != -1 || !changed {
+		t.Fatalf("next selection at end: got %d (changed: %v), want -1 (changed: true)", i, changed)
+	}
+	if !l.Exhausted() {
+		t.Fatal("list not exhausted after advancing off the end")
+	}
+
+	if err := l.Add(list.NewTrack("ghi", "baz.mp3"), 2); err != nil {
+		panic(err)
+	}
+	if l.Exhausted() {
+		t.Error("list still exhausted after adding an item")
+	}
+}
+
+// TestList_Next_SkipsText checks that automode advances never select text items.
+func TestList_Next_SkipsText(t *testing.T) {
+	cases := []list.AutoMode{list.AutoNext, list.AutoShuffle}
+
+	for _, mode := range cases {
+		l := list.New()
+		if err := l.Add(list.NewTrack("abc", "foo.mp3"), 0); err != nil {
+			panic(err)
+		}
+		if err := l.Add(list.NewText("def", "test"), 1); err != nil {
+			panic(err)
+		}
+		if err := l.Add(list.NewTrack("xyz", "bar.mp3"), 2); err != nil {
+			panic(err)
+		}
+		if _, err := l.Select(0, "abc"); err != nil {
+			panic(err)
+		}
+		l.SetAutoMode(mode)
+
+		// Shuffle may pick the tracks in any order, but must run out without ever landing on the text item.
+		for i := 0; i < 3; i++ {
+			sel, _ := l.Next()
+			if sel == -1 {
+				break
+			}
+			if sel == 1 {
+				t.Fatalf("%v: advanced onto text item", mode)
+			}
+		}
+	}
+}
+
+// TestList_Add_IDs tests that items get unique IDs on adding, which follow them as indices shift.
+func TestList_Add_IDs(t *testing.T) {
+	l := list.New()
+	items := []*list.Item{list.NewTrack("a", "a.mp3"), list.NewTrack("b", "b.mp3"), list.NewText("c", "c")}
+	// Adding each at the front shifts every other item down.
+	for _, item := range items {
+		if err := l.Add(item, 0); err != nil {
+			t.Fatalf("unexpected error adding %s: %v", item.Hash(), err)
+		}
+	}
+
+	seen := make(map[uint64]string)
+	for _, item := range items {
+		id := item.ID()
+		if id == 0 {
+			t.Errorf("%s: no ID assigned", item.Hash())
+		}
+		if other, ok := seen[id]; ok {
+			t.Errorf("%s: ID %d already given to %s", item.Hash(), id, other)
+		}
+		seen[id] = item.Hash()
+
+		i, got := l.ItemWithID(id)
+		if got == nil || got.Hash() != item.Hash() {
+			t.Errorf("ItemWithID(%d): got %v, want %s", id, got, item.Hash())
+		}
+		if j, _ := l.ItemWithHash(item.Hash()); i != j {
+			t.Errorf("ItemWithID(%d): index %d, but item is at %d", id, i, j)
+		}
+	}
+
+	// A failed add doesn't use up an ID, or touch the item.
+	dup := list.NewTrack("a", "again.mp3")
+	if err := l.Add(dup, 0); err == nil {
+		t.Fatal("duplicate hash added without error")
+	}
+	if dup.ID() != 0 {
+		t.Errorf("failed add assigned ID %d", dup.ID())
+	}
+
+	if i, item := l.ItemWithID(0); i != -1 || item != nil {
+		t.Errorf("ItemWithID(0): got (%d, %v), want (-1, nil)", i, item)
+	}
+}
+
+// TestList_SelectNext tests manual advancing, with and without wrapping.
+func TestList_SelectNext(t *testing.T) {
+	l := list.New()
+	items := []*list.Item{list.NewTrack("a", "a.mp3"), list.NewText("b", "b"), list.NewTrack("c", "c.mp3")}
+	for i, item := range items {
+		if err := l.Add(item, i); err != nil {
+			t.Fatalf("unexpected error adding %s: %v", item.Hash(), err)
+		}
+	}
+
+	steps := []struct {
+		wrap    bool
+		index   int
+		changed bool
+		ok      bool
+	}{
+		// From no selection, we start at the top.
+		{false, 0, true, true},
+		// Text items get skipped.
+		{false, 2, true, true},
+		// At the end, we fail without wrapping...
+		{false, 2, false, false},
+		// ...and go back to the top with it.
+		{true, 0, true, true},
+	}
+
+	for i, s := range steps {
+		index, changed, err := l.SelectNext(s.wrap)
+		if s.ok != (err == nil) {
+			t.Fatalf("step %d: got error %v, want ok=%v", i, err, s.ok)
+		}
+		if index != s.index || changed != s.changed {
+			t.Errorf("step %d: got (%d, %v), want (%d, %v)", i, index, changed, s.index, s.changed)
+		}
+	}
+}
+
+// TestList_SelectNext_Empty tests that manual advancing on a list with nothing selectable fails, even with wrapping.
+func TestList_SelectNext_Empty(t *testing.T) {
+	l := list.New()
+	for _, wrap := range []bool{false, true} {
+		if _, _, err := l.SelectNext(wrap); err == nil {
+			t.Errorf("wrap=%v: expected an error on an empty list", wrap)
+		}
+	}
+
+	if err := l.Add(list.NewText("a", "a"), 0); err != nil {
+		t.Fatalf("unexpected error adding text: %v", err)
+	}
+	if _, _, err := l.SelectNext(true); err == nil {
+		t.Error("expected an error on a list with only text")
+	}
+}
+
+// TestList_SelectNextN tests manual advancing by several items at once, with and without wrapping.
+func TestList_SelectNextN(t *testing.T) {
+	l := list.New()
+	items := []*list.Item{
+		list.NewTrack("a", "a.mp3"), list.NewText("b", "b"), list.NewTrack("c", "c.mp3"), list.NewTrack("d", "d.mp3"),
+	}
+	for i, item := range items {
+		if err := l.Add(item, i); err != nil {
+			t.Fatalf("unexpected error adding %s: %v", item.Hash(), err)
+		}
+	}
+
+	steps := []struct {
+		n       int
+		wrap    bool
+		index   int
+		changed bool
+		ok      bool
+	}{
+		// From no selection, the first step lands on the top; text items don't count.
+		{2, false, 2, true, true},
+		{0, false, 2, false, true},
+		{-1, false, 2, false, false},
+		// Without wrapping, we stop at the end...
+		{5, false, 3, true, true},
+		// ...and fail if we're already there.
+		{1, false, 3, false, false},
+		// With wrapping, we go round as many times as it takes: 3 selectable items, so 7 steps is 1 step.
+		{7, true, 0, true, true},
+		{3, true, 0, false, true},
+	}
+
+	for i, s := range steps {
+		index, changed, err := l.SelectNextN(s.n, s.wrap)
+		if s.ok != (err == nil) {
+			t.Fatalf("step %d: got error %v, want ok=%v", i, err, s.ok)
+		}
+		if index != s.index || changed != s.changed {
+			t.Errorf("step %d: got (%d, %v), want (%d, %v)", i, index, changed, s.index, s.changed)
+		}
+	}
+}
+
+// TestList_Context tests copying the items around the selection, including near the ends of the list.
+func TestList_Context(t *testing.T) {
+	l := list.New()
+	hashes := []string{"a", "b", "c", "d", "e"}
+	for i, h := range hashes {
+		if err := l.Add(list.NewTrack(h, h+".mp3"), i); err != nil {
+			t.Fatalf("unexpected error adding %s: %v", h, err)
+		}
+	}
+
+	if start, items, err := l.Context(1); err != nil || start != -1 || len(items) != 0 {
+		t.Errorf("no selection: got (%d, %d items, %v), want (-1, 0 items, nil)", start, len(items), err)
+	}
+
+	for _, c := range []struct {
+		sel    int
+		radius int
+		start  int
+		hashes string
+	}{
+		{2, 1, 1, "bcd"},
+		{2, 0, 2, "c"},
+		{0, 2, 0, "abc"},
+		{4, 2, 2, "cde"},
+		{1, 1 << 62, 0, "abcde"},
+	} {
+		if _, err := l.Select(c.sel, hashes[c.sel]); err != nil {
+			t.Fatalf("couldn't select %d: %v", c.sel, err)
+		}
+		start, items, err := l.Context(c.radius)
+		if err != nil {
+			t.Fatalf("sel %d, radius %d: unexpected error: %v", c.sel, c.radius, err)
+		}
+		got := ""
+		for _, item := range items {
+			got += item.Hash()
+		}
+		if start != c.start || got != c.hashes {
+			t.Errorf("sel %d, radius %d: got (%d, %q), want (%d, %q)", c.sel, c.radius, start, got, c.start, c.hashes)
+		}
+	}
+
+	if _, _, err := l.Context(-1); err == nil {
+		t.Error("negative radius: expected an error")
+	}
+}
+
+// TestList_SelectPrevious tests manual moving backwards, with and without wrapping.
+func TestList_SelectPrevious(t *testing.T) {
+	l := list.New()
+	items := []*list.Item{list.NewTrack("a", "a.mp3"), list.NewText("b", "b"), list.NewTrack("c", "c.mp3")}
+	for i, item := range items {
+		if err := l.Add(item, i); err != nil {
+			t.Fatalf("unexpected error adding %s: %v", item.Hash(), err)
+		}
+	}
+
+	steps := []struct {
+		wrap    bool
+		index   int
+		changed bool
+		ok      bool
+	}{
+		// From no selection, we start at the bottom.
+		{false, 2, true, true},
+		// Text items get skipped.
+		{false, 0, true, true},
+		// At the start, we fail without wrapping...
+		{false, 0, false, false},
+		// ...and go round to the bottom with it.
+		{true, 2, true, true},
+	}
+
+	for i, s := range steps {
+		index, changed, err := l.SelectPrevious(s.wrap)
+		if s.ok != (err == nil) {
+			t.Fatalf("step %d: got error %v, want ok=%v", i, err, s.ok)
+		}
+		if index != s.index || changed != s.changed {
+			t.Errorf("step %d: got (%d, %v), want (%d, %v)", i, index, changed, s.index, s.changed)
+		}
+	}
+}
+
+// TestList_SelectPrevious_Empty tests that moving backwards on a list with nothing selectable fails, even with
+// wrapping.
+func TestList_SelectPrevious_Empty(t *testing.T) {
+	l := list.New()
+	if err := l.Add(list.NewText("a", "a"), 0); err != nil {
+		t.Fatalf("unexpected error adding text: %v", err)
+	}
+	for _, wrap := range []bool{false, true} {
+		if _, _, err := l.SelectPrevious(wrap); err == nil {
+			t.Errorf("wrap=%v: expected an error on a list with only text", wrap)
+		}
+	}
+}
+
+// TestList_SelectRelative tests moving by signed offsets, clamping and wrapping, and from no selection.
+func TestList_SelectRelative(t *testing.T) {
+	l := list.New()
+	items := []*list.Item{
+		list.NewTrack("a", "a.mp3"), list.NewText("b", "b"), list.NewTrack("c", "c.mp3"), list.NewTrack("d", "d.mp3"),
+	}
+	for i, item := range items {
+		if err := l.Add(item, i); err != nil {
+			t.Fatalf("unexpected error adding %s: %v", item.Hash(), err)
+		}
+	}
+
+	if _, _, err := l.SelectRelative(1, false); err == nil {
+		t.Fatal("expected an error with nothing selected")
+	}
+	if _, err := l.Select(0, "a"); err != nil {
+		t.Fatalf("couldn't select: %v", err)
+	}
+
+	steps := []struct {
+		offset  int
+		wrap    bool
+		index   int
+		changed bool
+	}{
+		// Text items don't count.
+		{1, false, 2, true},
+		{0, false, 2, false},
+		// Without wrapping, we clamp at either end, even if already there.
+		{5, false, 3, true},
+		{1, false, 3, false},
+		{-3, false, 0, true},
+		{-1, false, 0, false},
+		// With wrapping, we go round as many times as it takes: 3 selectable items, so -4 steps is -1 step.
+		{-4, true, 3, true},
+		{2, true, 2, true},
+		// Huge offsets don't overflow.
+		{math.MinInt32, false, 0, true},
+		{math.MaxInt32, true, 2, true},
+	}
+
+	for i, s := range steps {
+		index, changed, err := l.SelectRelative(s.offset, s.wrap)
+		if err != nil {
+			t.Fatalf("step %d: unexpected error: %v", i, err)
+		}
+		if index != s.index || changed != s.changed {
+			t.Errorf("step %d: got (%d, %v), want (%d, %v)", i, index, changed, s.index, s.changed)
+		}
+	}
+}
+
+// TestList_Update tests that updating an item keeps its place, ID, running time, and selection, and that failed
+// updates leave the list untouched.
+func TestList_Update(t *testing.T) {
+	l := list.New()
+	for i, h := range []string{"a", "b", "c"} {
+		if err := l.Add(list.NewTrack(h, h+".mp3"), i); err != nil {
+			t.Fatalf("couldn't add item: %v", err)
+		}
+	}
+	if _, err := l.Select(1, "b"); err != nil {
+		t.Fatalf("couldn't select item: %v", err)
+	}
+	if err := l.SetDuration(1, "b", time.Minute); err != nil {
+		t.Fatalf("couldn't set duration: %v", err)
+	}
+	if err := l.SetElapsed(time.Second); err != nil {
+		t.Fatalf("couldn't set elapsed time: %v", err)
+	}
+	id := l.ItemWithIndex(1).ID()
+
+	for _, c := range []struct {
+		name  string
+		index int
+		hash  string
+		item  *list.Item
+	}{
+		{"out of bounds", 3, "b", list.NewTrack("d", "d.mp3")},
+		{"hash mismatch", 1, "a", list.NewTrack("d", "d.mp3")},
+		{"duplicate hash", 1, "b", list.NewTrack("c", "d.mp3")},
+		{"selection unselectable", 1, "b", list.NewText("d", "d")},
+	} {
+		if err := l.Update(c.index, c.hash, c.item); err == nil {
+			t.Errorf("%s: expected an error", c.name)
+		}
+	}
+	if _, item := l.ItemWithHash("b"); item == nil || item.Payload() != "b.mp3" {
+		t.Fatalf("failed updates changed the item: %v", item)
+	}
+
+	if err := l.Update(1, "b", list.NewTrack("b2", "b2.mp3")); err != nil {
+		t.Fatalf("unexpected error updating: %v", err)
+	}
+	i, item := l.Selection()
+	if i != 1 || item == nil || item.Hash() != "b2" || item.Payload() != "b2.mp3" {
+		t.Fatalf("got selection %d (%v), want updated item at 1", i, item)
+	}
+	if item.ID() != id {
+		t.Errorf("ID changed from %d to %d", id, item.ID())
+	}
+	if d, _ := item.Duration(); d != time.Minute {
+		t.Errorf("duration changed to %v", d)
+	}
+	if e := l.Elapsed(); e != time.Second {
+		t.Errorf("elapsed time changed to %v", e)
+	}
+
+	// Updating to the same hash is fine, as is turning an unselected item into text.
+	if err := l.Update(1, "b2", list.NewTrack("b2", "b3.mp3")); err != nil {
+		t.Errorf("unexpected error keeping hash: %v", err)
+	}
+	if err := l.Update(2, "c", list.NewText("c", "now text")); err != nil {
+		t.Errorf("unexpected error updating unselected item: %v", err)
+	}
+}
+
+// hashes gets the hashes of l's items, in order.
+func hashes(l *list.List) []string {
+	var hs []string
+	for _, item := range l.Freeze() {
+		hs = append(hs, item.Hash())
+	}
+	return hs
+}
+
+// TestList_Swap tests that swapping items checks both hashes, changes nothing on failure, and keeps the selection on
+// its item.
+func TestList_Swap(t *testing.T) {
+	l := list.New()
+	for i, h := range []string{"a", "b", "c", "d"} {
+		if err := l.Add(list.NewTrack(h, h+".mp3"), i); err != nil {
+			t.Fatalf("couldn't add item: %v", err)
+		}
+	}
+	if _, err := l.Select(1, "b"); err != nil {
+		t.Fatalf("couldn't select item: %v", err)
+	}
+	if err := l.SetElapsed(time.Second); err != nil {
+		t.Fatalf("couldn't set elapsed time: %v", err)
+	}
+
+	for _, c := range []struct {
+		name string
+		i    int
+		hi   string
+		j    int
+		hj   string
+	}{
+		{"out of bounds", 1, "b", 4, "e"},
+		{"first hash mismatch", 1, "a", 3, "d"},
+		{"second hash mismatch", 1, "b", 3, "c"},
+	} {
+		if err := l.Swap(c.i, c.hi, c.j, c.hj); err == nil {
+			t.Errorf("%s: expected an error", c.name)
+		}
+	}
+	if got, want := hashes(l), []string{"a", "b", "c", "d"}; !reflect.DeepEqual(got, want) {
+		t.Fatalf("failed swaps changed the list to %v", got)
+	}
+
+	if err := l.Swap(3, "d", 1, "b"); err != nil {
+		t.Fatalf("unexpected error swapping: %v", err)
+	}
+	if got, want := hashes(l), []string{"a", "d", "c", "b"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("got %v after swapping, want %v", got, want)
+	}
+	if i, item := l.Selection(); i != 3 || item == nil || item.Hash() != "b" {
+		t.Errorf("got selection %d (%v), want b at 3", i, item)
+	}
+	if e := l.Elapsed(); e != time.Second {
+		t.Errorf("elapsed time changed to %v", e)
+	}
+
+	// Swapping unselected items leaves the selection alone.
+	if err := l.Swap(0, "a", 2, "c"); err != nil {
+		t.Fatalf("unexpected error swapping: %v", err)
+	}
+	if i, _ := l.Selection(); i != 3 {
+		t.Errorf("got selection %d, want 3", i)
+	}
+}
+
+// TestList_SetEmptyCallback tests that the empty callback fires on, and only on, empty/non-empty transitions.
+func TestList_SetEmptyCallback(t *testing.T) {
+	l := list.New()
+	var got []bool
+	l.SetEmptyCallback(func(empty bool) { got = append(got, empty) })
+
+	steps := []struct {
+		name string
+		f    func() error
+	}{
+		{"add to empty", func() error { return l.Add(list.NewTrack("a", "a.mp3"), 0) }},
+		{"add to non-empty", func() error { return l.Add(list.NewTrack("b", "b.mp3"), 1) }},
+		{"failed add", func() error { _ = l.Add(list.NewTrack("a", "a.mp3"), 0); return nil }},
+		{"replace with items", func() error { return l.Replace([]list.Item{*list.NewTrack("c", "c.mp3")}, -1) }},
+		{"replace with nothing", func() error { return l.Replace(nil, -1) }},
+		{"replace empty with nothing", func() error { return l.Replace(nil, -1) }},
+		{"failed replace", func() error { _ = l.Replace([]list.Item{*list.NewTrack("d", "d.mp3")}, 5); return nil }},
+		{"replace empty with items", func() error { return l.Replace([]list.Item{*list.NewTrack("e", "e.mp3")}, 0) }},
+	}
+	for _, s := range steps {
+		if err := s.f(); err != nil {
+			t.Fatalf("%s: unexpected error: %v", s.name, err)
+		}
+	}
+
+	if want := []bool{false, true, false}; !reflect.DeepEqual(got, want) {
+		t.Errorf("got callbacks %v, want %v", got, want)
+	}
+}
+
+// TestList_RelativeIndex tests resolving offsets from the selection, including clamping and having no selection.
+func TestList_RelativeIndex(t *testing.T) {
+	l := list.New()
+	for i, h := range []string{"a", "b", "c"} {
+		if err := l.Add(list.NewTrack(h, h+".mp3"), i); err != nil {
+			t.Fatalf("couldn't add item: %v", err)
+		}
+	}
+	if got := l.RelativeIndex(1); got != 3 {
+		t.Errorf("with no selection: got %d, want the end, 3", got)
+	}
+
+	if _, err := l.Select(1, "b"); err != nil {
+		t.Fatalf("couldn't select item: %v", err)
+	}
+	for _, c := range []struct{ offset, want int }{{1, 2}, {0, 1}, {-1, 0}, {2, 3}, {-5, 0}, {5, 3}, {math.MaxInt32, 3}} {
+		if got := l.RelativeIndex(c.offset); got != c.want {
+			t.Errorf("offset %d: got %d, want %d", c.offset, got, c.want)
+		}
+	}
+}
+
+// TestList_InsertRelative tests that 'floadrel' lands after the live selection, and is broadcast with its index.
+func TestList_InsertRelative(t *testing.T) {
+	l := list.New()
+	for i, h := range []string{"a", "b", "c"} {
+		if err := l.Add(list.NewTrack(h, h+".mp3"), i); err != nil {
+			t.Fatalf("couldn't add item: %v", err)
+		}
+	}
+	if _, err := l.Select(0, "a"); err != nil {
+		t.Fatalf("couldn't select item: %v", err)
+	}
+
+	rq, err := l.ParseBifrostRequest("floadrel", []string{"1", "x", "x.mp3"})
+	if err != nil {
+		t.Fatalf("unexpected parse error: %v", err)
+	}
+	var bcasts []interface{}
+	if err := l.HandleRequest(func(interface{}) {}, func(r interface{}) { bcasts = append(bcasts, r) }, rq); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if got, want := hashes(l), []string{"a", "x", "b", "c"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("got items %q, want %q", got, want)
+	}
+	if len(bcasts) != 1 || bcasts[0].(list.ItemResponse).Index != 1 {
+		t.Errorf("got broadcasts %+v, want one ItemResponse at index 1", bcasts)
+	}
+}
@@ -0,0 +1,59 @@
+package list
+
+// File locks.go contains item locks, which stop must-air items, such as station IDs and sponsor reads, being moved
+// or removed by accident during live editing.
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/UniversityRadioYork/baps3d/controller"
+)
+
+// ErrItemLocked is the error a List gives when a change would move, remove, or rewrite a locked item; see SetLocked.
+var ErrItemLocked = errors.New("item is locked")
+
+// SetLocked tries to lock (if on) or unlock the item with the given index and hash.
+// While an item is locked, Swap refuses to move it, Update refuses to change its hash or content, and Replace, which
+// removes every item, refuses to run at all, so locked items must be unlocked before the list can be replaced.
+// Locks don't stop anything that leaves the item itself alone: it can still be selected, marked played, and have items
+// added around it.
+// It fails if the item doesn't exist or has a different hash (see controller.ErrStateChanged).
+func (l *List) SetLocked(index int, hash string, on bool) error {
+	item, err := l.guardedItem("SetLocked", index, hash)
+	if err != nil {
+		return err
+	}
+
+	item.locked = on
+	return nil
+}
+
+// checkUnlocked fails, for the operation op, with ErrItemLocked if the item at index is locked.
+func (l *List) checkUnlocked(op string, index int) error {
+	if item := l.ItemWithIndex(index); item != nil && item.locked {
+		return fmt.Errorf("%s: %w: index %d", op, ErrItemLocked, index)
+	}
+	return nil
+}
+
+// checkNoneLocked fails, for the operation op, with ErrItemLocked if any item in l is locked.
+func (l *List) checkNoneLocked(op string) error {
+	i := 0
+	for e := l.list.Front(); e != nil; e = e.Next() {
+		if e.Value.(*Item).locked {
+			return fmt.Errorf("%s: %w: index %d", op, ErrItemLocked, i)
+		}
+		i++
+	}
+	return nil
+}
+
+// dumpLocks sends a LockedResponse to dumpCb for each locked item in l.
+func (l *List) dumpLocks(dumpCb controller.ResponseCb) {
+	for i, item := range l.Freeze() {
+		if item.Locked() {
+			dumpCb(LockedResponse{Index: i, Hash: item.Hash(), Locked: true})
+		}
+	}
+}
@@ -0,0 +1,88 @@
+package list_test
+
+import (
+	"errors"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/UniversityRadioYork/bifrost-go/message"
+
+	"github.com/UniversityRadioYork/baps3d/list"
+)
+
+// TestList_SetLocked tests that locking an item stops it being swapped, updated or replaced, but not selected.
+func TestList_SetLocked(t *testing.T) {
+	l := stopList(t, list.AutoOff)
+	if err := l.SetLocked(1, "b", true); err != nil {
+		t.Fatalf("couldn't lock b: %v", err)
+	}
+	if !l.ItemWithIndex(1).Locked() {
+		t.Error("b should be locked")
+	}
+
+	if err := l.Swap(0, "a", 1, "b"); !errors.Is(err, list.ErrItemLocked) {
+		t.Errorf("swapping a locked item: got %v, want ErrItemLocked", err)
+	}
+	if err := l.Swap(0, "a", 2, "c"); err != nil {
+		t.Errorf("swapping around a locked item: unexpected error %v", err)
+	}
+	if err := l.Update(1, "b", list.NewTrack("e", "e.mp3")); !errors.Is(err, list.ErrItemLocked) {
+		t.Errorf("updating a locked item: got %v, want ErrItemLocked", err)
+	}
+	if err := l.Replace([]list.Item{*list.NewTrack("d", "d.mp3")}, -1); !errors.Is(err, list.ErrItemLocked) {
+		t.Errorf("replacing with a locked item: got %v, want ErrItemLocked", err)
+	}
+	if _, err := l.Select(1, "b"); err != nil {
+		t.Errorf("selecting a locked item: unexpected error %v", err)
+	}
+	if got, want := hashes(l), []string{"c", "b", "a"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("got items %q, want %q", got, want)
+	}
+
+	if err := l.SetLocked(1, "x", false); err == nil {
+		t.Error("expected an error unlocking with the wrong hash")
+	}
+	if err := l.SetLocked(1, "b", false); err != nil {
+		t.Fatalf("couldn't unlock b: %v", err)
+	}
+	if err := l.Replace([]list.Item{*list.NewTrack("d", "d.mp3")}, -1); err != nil {
+		t.Errorf("replacing after unlocking: unexpected error %v", err)
+	}
+}
+
+// TestList_Bifrost_Lock tests the 'lock' request, and the LOCKED messages it and dumps lead to.
+func TestList_Bifrost_Lock(t *testing.T) {
+	l := stopList(t, list.AutoOff)
+
+	rq, err := l.ParseBifrostRequest("lock", []string{"2", "c", "on"})
+	if err != nil {
+		t.Fatalf("unexpected parse error: %v", err)
+	}
+	if want := (list.SetLockedRequest{Index: 2, Hash: "c", Locked: true}); rq != want {
+		t.Fatalf("got request %+v, want %+v", rq, want)
+	}
+
+	msgs := make(chan message.Message, 16)
+	emit := func(rbody interface{}) {
+		if err := l.EmitBifrostResponse("!", rbody, msgs); err != nil {
+			t.Fatalf("unexpected emit error: %v", err)
+		}
+	}
+	if err := l.HandleRequest(func(interface{}) {}, emit, rq); err != nil {
+		t.Fatalf("unexpected error handling %+v: %v", rq, err)
+	}
+	l.Dump(emit)
+	close(msgs)
+
+	var got []string
+	for m := range msgs {
+		if s := m.String(); strings.HasPrefix(s, "! LOCKED ") {
+			got = append(got, s)
+		}
+	}
+	want := []string{"! LOCKED 2 c on\n", "! LOCKED 2 c on\n"}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("got messages %q, want %q", got, want)
+	}
+}
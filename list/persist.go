@@ -0,0 +1,173 @@
+package list
+
+// File persist.go contains the on-disk format for saved lists.
+//
+// A saved list is a JSON object holding the items in order, plus a checksum over them.
+// The checksum lets Load tell a complete file from one that was truncated or corrupted, for example by a crash
+// half-way through saving.
+//
+// An exported list (see List.Export) is a saved list with the rest of the List's state alongside the items, so Load
+// can read one as a saved list, ignoring that state.
+
+import (
+	"crypto/sha256"
+	"encoding/binary"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"hash"
+	"io"
+	"time"
+)
+
+// saveVersion is the version of the saved list format written by Save.
+const saveVersion = 1
+
+// ErrChecksumMismatch is the error returned when a saved list's checksum doesn't match its items.
+var ErrChecksumMismatch = errors.New("saved list checksum mismatch")
+
+// MaxExportLen is the largest export, in bytes of JSON, that List.Export produces.
+const MaxExportLen = 1024 * 1024
+
+// ErrExportTooLarge is the error returned when a List is too large to export within MaxExportLen.
+var ErrExportTooLarge = errors.New("list too large to export")
+
+// savedList is the JSON representation of a saved list.
+type savedList struct {
+	Version  int         `json:"version"`
+	Checksum string      `json:"checksum"`
+	Items    []savedItem `json:"items"`
+}
+
+// savedItem is the JSON representation of an item in a saved list.
+type savedItem struct {
+	Type    string `json:"type"`
+	Hash    string `json:"hash"`
+	Payload string `json:"payload,omitempty"`
+	Data    []byte `json:"data,omitempty"`
+	// DurationMillis is the item's running time, in milliseconds, if known.
+	// Only exports give it; the checksum doesn't cover it, and Load ignores it.
+	DurationMillis int64 `json:"duration_ms,omitempty"`
+	// StopAfter is true if the item is a stop point.
+	// Like DurationMillis, only exports give it.
+	StopAfter bool `json:"stop_after,omitempty"`
+	// Locked is true if the item is locked.
+	// Like DurationMillis, only exports give it.
+	Locked bool `json:"locked,omitempty"`
+	// Fallback is true if the item is the List's fallback.
+	// Like DurationMillis, only exports give it.
+	Fallback bool `json:"fallback,omitempty"`
+}
+
+// exportedList is the JSON representation of an exported list.
+type exportedList struct {
+	savedList
+	// Selection is the index of the selected item, or -1 if there is none.
+	Selection int `json:"selection"`
+	// AutoMode is the name of the List's AutoMode.
+	AutoMode string `json:"automode"`
+}
+
+// Save writes items to w in the saved list format.
+func Save(w io.Writer, items []Item) error {
+	return json.NewEncoder(w).Encode(makeSavedList(items))
+}
+
+// Export gets the whole of l's state (its items, their running times, stop points, locks and fallback, its selection,
+// and its AutoMode) as JSON.
+// It fails with ErrExportTooLarge, rather than produce an export over MaxExportLen bytes; clients with lists that
+// large must piece them together from a dump instead.
+func (l *List) Export() ([]byte, error) {
+	items := l.Freeze()
+	el := exportedList{savedList: makeSavedList(items), AutoMode: l.AutoMode().String()}
+	el.Selection, _ = l.Selection()
+	for i, item := range items {
+		if d, ok := item.Duration(); ok {
+			el.Items[i].DurationMillis = int64(d / time.Millisecond)
+		}
+		el.Items[i].StopAfter = item.StopAfter()
+		el.Items[i].Locked = item.Locked()
+		el.Items[i].Fallback = item.Fallback()
+	}
+
+	bs, err := json.Marshal(el)
+	if err != nil {
+		return nil, err
+	}
+	if MaxExportLen < len(bs) {
+		return nil, fmt.Errorf("%w: %d bytes, over %d", ErrExportTooLarge, len(bs), MaxExportLen)
+	}
+	return bs, nil
+}
+
+// makeSavedList makes the saved list representation of items, including its checksum.
+func makeSavedList(items []Item) savedList {
+	sl := savedList{Version: saveVersion, Items: make([]savedItem, len(items))}
+	for i, item := range items {
+		sl.Items[i] = savedItem{Type: item.Type().String(), Hash: item.Hash(), Payload: item.Payload(), Data: item.Data()}
+	}
+	sl.Checksum = checksumItems(sl.Items)
+	return sl
+}
+
+// Load reads a saved list from r, as written by Save.
+// It fails with ErrChecksumMismatch if the checksum doesn't match the items read, including if they're out of order.
+func Load(r io.Reader) ([]Item, error) {
+	var sl savedList
+	if err := json.NewDecoder(r).Decode(&sl); err != nil {
+		return nil, fmt.Errorf("couldn't read saved list: %w", err)
+	}
+	if sl.Version != saveVersion {
+		return nil, fmt.Errorf("unsupported saved list version %d", sl.Version)
+	}
+	if sum := checksumItems(sl.Items); sum != sl.Checksum {
+		return nil, fmt.Errorf("%w: file says %s, items give %s", ErrChecksumMismatch, sl.Checksum, sum)
+	}
+
+	items := make([]Item, len(sl.Items))
+	for i, si := range sl.Items {
+		itype, err := ParseItemType(si.Type)
+		if err != nil {
+			return nil, fmt.Errorf("saved item %d: %w", i, err)
+		}
+
+		if si.Data != nil {
+			items[i] = *NewBinaryItem(itype, si.Hash, si.Data)
+		} else {
+			items[i] = *NewItem(itype, si.Hash, si.Payload)
+		}
+	}
+	return items, nil
+}
+
+// checksumItems computes the checksum of items, as a hex string.
+// The checksum covers the number of items, and the position and every field of each item.
+func checksumItems(items []savedItem) string {
+	h := sha256.New()
+
+	writeLen(h, len(items))
+	for i, item := range items {
+		writeLen(h, i)
+		writeField(h, []byte(item.Type))
+		writeField(h, []byte(item.Hash))
+		writeField(h, []byte(item.Payload))
+		writeField(h, item.Data)
+	}
+
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+// writeField writes f to h, prefixed with its length so that adjacent fields can't run into each other.
+func writeField(h hash.Hash, f []byte) {
+	writeLen(h, len(f))
+	// Hashes never return errors on write.
+	_, _ = h.Write(f)
+}
+
+// writeLen writes n to h as a fixed-width integer.
+func writeLen(h hash.Hash, n int) {
+	var buf [8]byte
+	binary.BigEndian.PutUint64(buf[:], uint64(n))
+	_, _ = h.Write(buf[:])
+}
@@ -0,0 +1,168 @@
+package list_test
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"reflect"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/UniversityRadioYork/baps3d/list"
+)
+
+// testSaveItems is a set of items covering each kind of payload.
+var testSaveItems = []list.Item{
+	*list.NewTrack("abc", "foo.mp3"),
+	*list.NewText("def", "say something nice"),
+	*list.NewBinaryItem(list.ItemTrack, "ghi", []byte{0, 1, 2, 3}),
+}
+
+// saveTestItems saves testSaveItems, returning the JSON decoded generically so tests can tamper with it.
+func saveTestItems(t *testing.T) map[string]interface{} {
+	t.Helper()
+
+	var buf bytes.Buffer
+	if err := list.Save(&buf, testSaveItems); err != nil {
+		t.Fatalf("couldn't save: %v", err)
+	}
+
+	var raw map[string]interface{}
+	if err := json.Unmarshal(buf.Bytes(), &raw); err != nil {
+		t.Fatalf("couldn't decode saved list: %v", err)
+	}
+	return raw
+}
+
+// loadRaw encodes raw and tries to load it as a saved list.
+func loadRaw(t *testing.T, raw map[string]interface{}) ([]list.Item, error) {
+	t.Helper()
+
+	bs, err := json.Marshal(raw)
+	if err != nil {
+		t.Fatalf("couldn't encode saved list: %v", err)
+	}
+	return list.Load(bytes.NewReader(bs))
+}
+
+// TestLoad_RoundTrip tests that loading a saved list gets back the items saved.
+func TestLoad_RoundTrip(t *testing.T) {
+	items, err := loadRaw(t, saveTestItems(t))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if !reflect.DeepEqual(items, testSaveItems) {
+		t.Errorf("got %v, want %v", items, testSaveItems)
+	}
+}
+
+// TestLoad_Corrupt tests that loading a saved list whose items don't match its checksum fails.
+func TestLoad_Corrupt(t *testing.T) {
+	cases := []struct {
+		name   string
+		tamper func([]interface{}) []interface{}
+	}{
+		{"truncated", func(is []interface{}) []interface{} { return is[:2] }},
+		{"reordered", func(is []interface{}) []interface{} { return []interface{}{is[1], is[0], is[2]} }},
+		{"payload changed", func(is []interface{}) []interface{} {
+			is[0].(map[string]interface{})["payload"] = "bar.mp3"
+			return is
+		}},
+		{"payload moved between fields", func(is []interface{}) []interface{} {
+			item := is[0].(map[string]interface{})
+			item["hash"] = "abcfoo.mp3"
+			item["payload"] = ""
+			return is
+		}},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			raw := saveTestItems(t)
+			raw["items"] = c.tamper(raw["items"].([]interface{}))
+
+			if _, err := loadRaw(t, raw); !errors.Is(err, list.ErrChecksumMismatch) {
+				t.Errorf("got error %v, want ErrChecksumMismatch", err)
+			}
+		})
+	}
+}
+
+// TestLoad_Truncated tests that loading a saved list cut off mid-file fails.
+func TestLoad_Truncated(t *testing.T) {
+	var buf bytes.Buffer
+	if err := list.Save(&buf, testSaveItems); err != nil {
+		t.Fatalf("couldn't save: %v", err)
+	}
+	half := buf.Bytes()[:buf.Len()/2]
+
+	if _, err := list.Load(bytes.NewReader(half)); err == nil {
+		t.Error("loaded a half-written file without error")
+	}
+}
+
+// TestList_Export tests that an export carries the List's state as well as its items, and still loads as a saved list.
+func TestList_Export(t *testing.T) {
+	l := list.New()
+	for i, item := range testSaveItems {
+		// The List keeps the pointer, and gives the item an ID, so each item needs its own copy.
+		item := item
+		if err := l.Add(&item, i); err != nil {
+			t.Fatalf("couldn't add item %d: %v", i, err)
+		}
+	}
+	if _, err := l.Select(2, "ghi"); err != nil {
+		t.Fatalf("couldn't select: %v", err)
+	}
+	l.SetAutoMode(list.AutoNext)
+	if err := l.SetDuration(0, "abc", 1500*time.Millisecond); err != nil {
+		t.Fatalf("couldn't set duration: %v", err)
+	}
+
+	bs, err := l.Export()
+	if err != nil {
+		t.Fatalf("couldn't export: %v", err)
+	}
+
+	var got struct {
+		Selection int
+		AutoMode  string
+		Items     []struct {
+			DurationMillis int64 `json:"duration_ms"`
+		}
+	}
+	if err := json.Unmarshal(bs, &got); err != nil {
+		t.Fatalf("couldn't decode export: %v", err)
+	}
+	if got.Selection != 2 || got.AutoMode != list.AutoNext.String() {
+		t.Errorf("got selection %d and automode %q, want 2 and %q", got.Selection, got.AutoMode, list.AutoNext)
+	}
+	if len(got.Items) != 3 || got.Items[0].DurationMillis != 1500 || got.Items[1].DurationMillis != 0 {
+		t.Errorf("got items %+v, want 3 with only the first lasting 1500ms", got.Items)
+	}
+
+	items, err := list.Load(bytes.NewReader(bs))
+	if err != nil {
+		t.Fatalf("couldn't load export: %v", err)
+	}
+	if len(items) != len(testSaveItems) || items[2].Hash() != "ghi" {
+		t.Errorf("loaded %v from export, want %v", items, testSaveItems)
+	}
+}
+
+// TestList_Export_TooLarge tests that a List refuses to export more than MaxExportLen bytes.
+func TestList_Export_TooLarge(t *testing.T) {
+	l := list.New()
+	text := strings.Repeat("x", 64*1024)
+	for i := 0; i*len(text) <= list.MaxExportLen; i++ {
+		if err := l.Add(list.NewText(strconv.Itoa(i), text), i); err != nil {
+			t.Fatalf("couldn't add item %d: %v", i, err)
+		}
+	}
+
+	if _, err := l.Export(); !errors.Is(err, list.ErrExportTooLarge) {
+		t.Errorf("got error %v, want ErrExportTooLarge", err)
+	}
+}
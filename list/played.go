@@ -0,0 +1,38 @@
+package list
+
+// File played.go contains explicit play marks, with which a playout engine tells the List when an item has actually
+// finished, rather than leaving the List to assume that whatever it advances past has played.
+
+import "github.com/UniversityRadioYork/baps3d/controller"
+
+// SetAdvanceOnPlayed sets whether marking the selected item played (see MarkPlayed) also makes l advance, as an
+// AdvanceRequest would; it defaults to false, leaving the engine to send its own AdvanceRequests.
+func (l *List) SetAdvanceOnPlayed(on bool) {
+	l.advanceOnPlayed = on
+}
+
+// MarkPlayed tries to mark the item with the given index and hash as having finished playing, and records the play
+// (see Plays).
+// The mark lasts until the item is next selected; while it lasts, Next doesn't record the item's play again, so
+// engines that mark items played and then advance don't get each play twice.
+// It returns false, doing nothing, if the item is already marked.
+// It fails if the item doesn't exist or has a different hash (see controller.ErrStateChanged).
+func (l *List) MarkPlayed(index int, hash string) (bool, error) {
+	item, err := l.guardedItem("MarkPlayed", index, hash)
+	if err != nil || item.played {
+		return false, err
+	}
+
+	item.played = true
+	l.recordPlay(item)
+	return true, nil
+}
+
+// dumpPlayed sends a FinishedResponse to dumpCb for each item in l marked as played.
+func (l *List) dumpPlayed(dumpCb controller.ResponseCb) {
+	for i, item := range l.Freeze() {
+		if item.Played() {
+			dumpCb(FinishedResponse{Index: i, Hash: item.Hash()})
+		}
+	}
+}
@@ -0,0 +1,84 @@
+package list_test
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/UniversityRadioYork/bifrost-go/message"
+
+	"github.com/UniversityRadioYork/baps3d/list"
+)
+
+// TestList_MarkPlayed tests that marking an item played records one play, however the List then advances, and that
+// the mark clears when the item is selected again.
+func TestList_MarkPlayed(t *testing.T) {
+	l := stopList(t, list.AutoOff)
+
+	for n, want := range []bool{true, false} {
+		if marked, err := l.MarkPlayed(0, "a"); err != nil || marked != want {
+			t.Errorf("mark %d: got %v, %v; want %v, nil", n, marked, err, want)
+		}
+	}
+	l.Next()
+	if plays, _ := l.Plays(0); len(plays) != 1 {
+		t.Errorf("got %d plays, want 1", len(plays))
+	}
+	if !l.ItemWithIndex(0).Played() {
+		t.Error("a should still be marked played")
+	}
+
+	for _, sel := range []struct {
+		index int
+		hash  string
+	}{{1, "b"}, {0, "a"}} {
+		if _, err := l.Select(sel.index, sel.hash); err != nil {
+			t.Fatalf("couldn't select %s: %v", sel.hash, err)
+		}
+	}
+	if l.ItemWithIndex(0).Played() {
+		t.Error("selecting a again should clear its mark")
+	}
+	if _, err := l.MarkPlayed(0, "x"); err == nil {
+		t.Error("expected an error marking with the wrong hash")
+	}
+}
+
+// TestList_Bifrost_Played tests the 'played' request, and that it advances the List only if set to.
+func TestList_Bifrost_Played(t *testing.T) {
+	for _, c := range []struct {
+		advance bool
+		want    []string
+	}{
+		{false, []string{"! FINISHED 0 a\n"}},
+		{true, []string{"! FINISHED 0 a\n", "! SEL 1 b\n"}},
+	} {
+		l := stopList(t, list.AutoNext)
+		l.SetAdvanceOnPlayed(c.advance)
+
+		rq, err := l.ParseBifrostRequest("played", []string{"0", "a"})
+		if err != nil {
+			t.Fatalf("unexpected parse error: %v", err)
+		}
+		msgs := make(chan message.Message, 8)
+		emit := func(rbody interface{}) {
+			if err := l.EmitBifrostResponse("!", rbody, msgs); err != nil {
+				t.Fatalf("unexpected emit error: %v", err)
+			}
+		}
+		// The second mark is a repeat, so does nothing.
+		for i := 0; i < 2; i++ {
+			if err := l.HandleRequest(func(interface{}) {}, emit, rq); err != nil {
+				t.Fatalf("unexpected error handling %+v: %v", rq, err)
+			}
+		}
+		close(msgs)
+
+		var got []string
+		for m := range msgs {
+			got = append(got, m.String())
+		}
+		if !reflect.DeepEqual(got, c.want) {
+			t.Errorf("advance %v: got messages %q, want %q", c.advance, got, c.want)
+		}
+	}
+}
@@ -0,0 +1,80 @@
+package list
+
+// File plays.go contains the List's play history: the items it has most recently advanced past, for "recently
+// played" views and logging.
+// This is separate from the change history in history.go, which is about catching clients up.
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/UniversityRadioYork/baps3d/controller"
+)
+
+const (
+	// DefaultPlayHistory is the number of plays a List remembers, unless set with SetPlayHistory.
+	DefaultPlayHistory = 50
+
+	// MaxPlayHistory is the most plays a List remembers.
+	MaxPlayHistory = 1000
+)
+
+// Play records that an item played.
+type Play struct {
+	// Item is a copy of the item as it was when it played.
+	Item Item
+	// At is when the List advanced past the item, according to its Clock.
+	At time.Time
+}
+
+// SetPlayHistory sets how many plays l remembers, up to MaxPlayHistory; it defaults to DefaultPlayHistory.
+// If n is less than the number l already remembers, it forgets the oldest; if n is 0, l remembers no plays.
+// The play history lives as long as l does: it survives Replace, but isn't saved.
+func (l *List) SetPlayHistory(n int) {
+	if MaxPlayHistory < n {
+		n = MaxPlayHistory
+	}
+	if n < 0 {
+		n = 0
+	}
+	l.playHistory = n
+	l.trimPlays()
+}
+
+// SetClock sets the Clock l uses to timestamp plays and count up the elapsed time of a playing selection; it defaults
+// to controller.SystemClock.
+func (l *List) SetClock(clk controller.Clock) {
+	l.clock = clk
+}
+
+// Plays gets the n most recent plays l remembers, oldest first, or every play it remembers if n is 0.
+// An item plays when Next advances past it, as whatever is playing the List calls Next when each item ends.
+// It fails if n is negative.
+func (l *List) Plays(n int) ([]Play, error) {
+	if n < 0 {
+		return nil, fmt.Errorf("Plays: negative count %d", n)
+	}
+	if n == 0 || len(l.plays) < n {
+		n = len(l.plays)
+	}
+
+	plays := make([]Play, n)
+	copy(plays, l.plays[len(l.plays)-n:])
+	return plays, nil
+}
+
+// recordPlay records that item has just played, if l remembers plays.
+func (l *List) recordPlay(item *Item) {
+	if l.playHistory == 0 {
+		return
+	}
+	l.plays = append(l.plays, Play{Item: *item, At: l.clock.Now()})
+	l.trimPlays()
+}
+
+// trimPlays forgets the oldest plays past l's limit.
+func (l *List) trimPlays() {
+	if over := len(l.plays) - l.playHistory; 0 < over {
+		l.plays = append(l.plays[:0], l.plays[over:]...)
+	}
+}
@@ -0,0 +1,118 @@
+package list_test
+
+import (
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/UniversityRadioYork/bifrost-go/message"
+
+	"github.com/UniversityRadioYork/baps3d/controller"
+	"github.com/UniversityRadioYork/baps3d/list"
+)
+
+// stepClock is a controller.Clock whose time moves on by a minute each time it is read.
+// Only its Now is used, so it borrows the rest from controller.SystemClock.
+type stepClock struct {
+	controller.SystemClock
+	now time.Time
+}
+
+func (c *stepClock) Now() time.Time {
+	c.now = c.now.Add(time.Minute)
+	return c.now
+}
+
+// playedList makes a List with three tracks, a to c, that has advanced through all of them in AutoNext mode,
+// remembering at most two plays.
+// It returns the List and the time of its first play.
+func playedList(t *testing.T) (*list.List, time.Time) {
+	t.Helper()
+
+	start := time.Date(2020, 1, 1, 12, 0, 0, 0, time.UTC)
+	l := list.New()
+	l.SetClock(&stepClock{now: start})
+	l.SetPlayHistory(2)
+	for i, h := range []string{"a", "b", "c"} {
+		if err := l.Add(list.NewTrack(h, h+".mp3"), i); err != nil {
+			t.Fatalf("couldn't add %q: %v", h, err)
+		}
+	}
+	if _, err := l.Select(0, "a"); err != nil {
+		t.Fatalf("couldn't select: %v", err)
+	}
+	l.SetAutoMode(list.AutoNext)
+	for i := 0; i < 3; i++ {
+		l.Next()
+	}
+	return l, start.Add(time.Minute)
+}
+
+// TestList_Plays tests that a List remembers the items it advances past, up to its limit, newest last.
+func TestList_Plays(t *testing.T) {
+	l, first := playedList(t)
+
+	plays, err := l.Plays(0)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(plays) != 2 {
+		t.Fatalf("got %d plays, want 2", len(plays))
+	}
+	for i, want := range []struct {
+		hash string
+		at   time.Time
+	}{{"b", first.Add(time.Minute)}, {"c", first.Add(2 * time.Minute)}} {
+		if got := plays[i]; got.Item.Hash() != want.hash || !got.At.Equal(want.at) {
+			t.Errorf("play %d: got %s at %v, want %s at %v", i, got.Item.Hash(), got.At, want.hash, want.at)
+		}
+	}
+
+	if plays, err := l.Plays(1); err != nil || len(plays) != 1 || plays[0].Item.Hash() != "c" {
+		t.Errorf("latest play: got %v, %v, want just c", plays, err)
+	}
+	if _, err := l.Plays(-1); err == nil {
+		t.Error("expected an error for a negative count")
+	}
+
+	l.SetPlayHistory(0)
+	if plays, _ := l.Plays(0); len(plays) != 0 {
+		t.Errorf("with no play history, got plays %v", plays)
+	}
+}
+
+// TestList_Bifrost_History tests the 'history' request and its HISTORY and PLAYED messages.
+func TestList_Bifrost_History(t *testing.T) {
+	l, first := playedList(t)
+
+	rq, err := l.ParseBifrostRequest("history", []string{"1"})
+	if err != nil {
+		t.Fatalf("unexpected parse error: %v", err)
+	}
+	if want := (list.HistoryRequest{Count: 1}); rq != want {
+		t.Fatalf("got request %+v, want %+v", rq, want)
+	}
+
+	msgs := make(chan message.Message, 4)
+	replyCb := func(rbody interface{}) {
+		if err := l.EmitBifrostResponse("t", rbody, msgs); err != nil {
+			t.Fatalf("unexpected emit error: %v", err)
+		}
+	}
+	if err := l.HandleRequest(replyCb, func(interface{}) {}, rq); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	close(msgs)
+
+	var got []string
+	for m := range msgs {
+		got = append(got, m.String())
+	}
+	want := []string{
+		"t HISTORY 1\n",
+		"t PLAYED " + controller.FormatTime(first.Add(2*time.Minute)) + " track c c.mp3 3\n",
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("got messages %q, want %q", got, want)
+	}
+}
@@ -0,0 +1,117 @@
+package list
+
+// File playstate.go contains the List's side of play-state-only mode, for clients such as on-air lights that only
+// care whether the selection is playing; see controller.PlayStateParser.
+
+import (
+	"github.com/UniversityRadioYork/bifrost-go/message"
+
+	"github.com/UniversityRadioYork/baps3d/controller"
+)
+
+// RsPlayState is the response word of play state notifications.
+// The arguments are the selection's play state ('cued' if it isn't playing, 'playing', 'finished' once marked played
+// (see MarkPlayed), or 'none' if nothing is selected), then the selected item's hash, if there is one.
+const RsPlayState = "PLAYSTATE"
+
+// NewPlayStateEmitter makes a play state emitter for one play-state-only client; see controller.PlayStateParser.
+func (l *List) NewPlayStateEmitter() controller.PlayStateEmitter {
+	return &playStateEmitter{}
+}
+
+// playStateEmitter works out the selection's play state from the responses a play-state-only client would hear.
+// A new selection keeps playing if the old one was (see SetPlaying), and the List only says so in the ELAPSED after
+// the new SEL, so the emitter remembers whether the selection was playing, to get the SEL's state right.
+// Until it first hears an ELAPSED (or a deselection), it doesn't know that, so it holds back; every dump with a
+// selection ends with the selection's ELAPSED.
+type playStateEmitter struct {
+	// known is true once the emitter knows whether the selection is playing.
+	known bool
+	// selected is true if something is selected.
+	selected bool
+	// hash is the hash of the selected item, if selected.
+	hash string
+	// playing is true if the selection is playing.
+	playing bool
+	// finished is true if the selected item has been marked played since it was selected.
+	finished bool
+
+	// sentState and sentHash are the state and hash of the last PLAYSTATE sent, if any.
+	sentState, sentHash string
+}
+
+// EmitBifrostPlayState handles a controller response with tag tag and body rbody for a play-state-only client; see
+// controller.PlayStateEmitter.
+// It sends a PLAYSTATE message (see RsPlayState) to msgTx when a broadcast changes the selection or its play state,
+// and nothing for the rest.
+// Dumps give exactly one, whatever the client already knows: in replies, the emitter waits for the selection's
+// ELAPSED, which ends the play state's part of a dump.
+func (p *playStateEmitter) EmitBifrostPlayState(tag string, rbody interface{}, msgTx chan<- message.Message) error {
+	switch r := rbody.(type) {
+	case SelectResponse:
+		p.noteSelection(r)
+	case ListReplacedResponse:
+		p.noteSelection(r.Selection)
+	case ElapsedResponse:
+		p.noteSelection(SelectResponse{Index: r.Index, Hash: r.Hash})
+		p.playing, p.known = r.Playing, true
+	case FinishedResponse:
+		// Dumps include a FinishedResponse for every played item, not just the selection.
+		if !p.selected || r.Hash != p.hash {
+			return nil
+		}
+		p.finished = true
+	default:
+		return nil
+	}
+
+	if !p.known {
+		return nil
+	}
+	state := "none"
+	if p.selected {
+		state = p.state()
+	}
+
+	if controller.IsBroadcastTag(tag) {
+		if state == p.sentState && p.hash == p.sentHash {
+			return nil
+		}
+	} else if _, isElapsed := rbody.(ElapsedResponse); p.selected && !isElapsed {
+		return nil
+	}
+	p.sentState, p.sentHash = state, p.hash
+
+	if !p.selected {
+		msgTx <- controller.NewMessage(tag, RsPlayState, state)
+		return nil
+	}
+	msgTx <- controller.NewMessage(tag, RsPlayState, state, p.hash)
+	return nil
+}
+
+// noteSelection records that sel is now the selection.
+// Moving to another item clears its finished mark, and deselecting stops it playing, as in the List itself.
+func (p *playStateEmitter) noteSelection(sel SelectResponse) {
+	if sel.Index < 0 {
+		// Deselecting stops the selection playing, so the emitter knows where it stands again.
+		*p = playStateEmitter{known: true, sentState: p.sentState, sentHash: p.sentHash}
+		return
+	}
+	if !p.selected || sel.Hash != p.hash {
+		p.finished = false
+	}
+	p.selected, p.hash = true, sel.Hash
+}
+
+// state gets the name of the play state of the selection, which must exist.
+func (p *playStateEmitter) state() string {
+	switch {
+	case p.finished:
+		return "finished"
+	case p.playing:
+		return "playing"
+	default:
+		return "cued"
+	}
+}
@@ -11,6 +11,8 @@ package list
 // - a parser from messages in 'bifrost.go';
 // - an emitter to messages in 'bifrost.go'.
 
+import "time"
+
 // SetAutoModeRequest requests an automode change.
 type SetAutoModeRequest struct {
 	// AutoMode represents the new AutoMode to use.
@@ -33,3 +35,251 @@ type AddItemRequest struct {
 	// Item is the item itself, including its required hash.
 	Item Item
 }
+
+// InsertRelativeRequest requests that the given item be enqueued at an offset from the selection; see
+// List.RelativeIndex.
+// The List resolves the offset against the selection as it stands when it handles the request, so clients needn't
+// read the selection first; if it succeeds, it broadcasts an ItemResponse with the resolved index, as for an
+// AddItemRequest.
+type InsertRelativeRequest struct {
+	// Offset is the offset from the selection: 1 enqueues the item immediately after it.
+	Offset int
+	// Item is the item itself, including its required hash.
+	Item Item
+}
+
+// UpdateItemRequest requests that an item's content be replaced in place; see List.Update.
+// If it succeeds, the List broadcasts an ItemUpdatedResponse, and then, if the item is selected, a SelectResponse with
+// its new hash.
+type UpdateItemRequest struct {
+	// Index is the index of the item.
+	Index int
+	// Hash is the item's current hash.
+	// It exists to prevent races with other changes to the list.
+	Hash string
+	// Item holds the item's new content, including its new hash.
+	Item Item
+}
+
+// SwapItemsRequest requests that two items swap places; see List.Swap.
+type SwapItemsRequest struct {
+	// IndexA is the index of the first item.
+	IndexA int
+	// HashA is the hash of the first item.
+	// Like HashB, it exists to prevent races with other changes to the list.
+	HashA string
+	// IndexB is the index of the second item.
+	IndexB int
+	// HashB is the hash of the second item.
+	HashB string
+}
+
+// ReplaceListRequest requests that the entire list be replaced in one go; see List.Replace.
+// If it succeeds, the List broadcasts one ListReplacedResponse, rather than anything per item, and replies with a
+// SummaryResponse.
+type ReplaceListRequest struct {
+	// Items holds the new items, in order.
+	// There may be at most MaxReplaceLen of them.
+	Items []Item
+	// Selection is the index of the item to select afterwards, or -1 to select nothing.
+	Selection int
+	// SkipRejected, if true, makes the List leave out items it would otherwise fail on, rather than failing; see
+	// List.ReplaceSkipping.
+	SkipRejected bool
+}
+
+// TransactionRequest requests that several changes apply to the List at once, or not at all; see List.Transact.
+// If it succeeds, the List broadcasts one ListReplacedResponse with the outcome, rather than anything per operation.
+// If it fails because of its operations, it first replies with an OpFailedResponse for each one that failed.
+type TransactionRequest struct {
+	// Ops holds the operations, in order; there may be at most MaxTransactionLen of them.
+	Ops []interface{}
+}
+
+// RevertRequest requests that the entire list go back to the List's saved list; see List.Revert.
+// Like a ReplaceListRequest, it results in one ListReplacedResponse broadcast and a SummaryResponse reply.
+type RevertRequest struct{}
+
+// NextRequest requests that the selection move to the next selectable item, regardless of the automode.
+// It is sent when an operator manually skips forwards; see List.SelectNext.
+type NextRequest struct {
+	// Wrap, if true, makes moving past the last selectable item wrap round to the first.
+	Wrap bool
+}
+
+// PreviousRequest requests that the selection move back to the last selectable item before it, regardless of the
+// automode.
+// It is sent when an operator manually skips backwards; see List.SelectPrevious.
+type PreviousRequest struct {
+	// Wrap, if true, makes moving back past the first selectable item wrap round to the last.
+	Wrap bool
+}
+
+// NextNRequest requests that the selection move forwards several selectable items at once; see List.SelectNextN.
+// The List broadcasts only the final selection.
+// A Count of zero does nothing, and a negative Count is an error.
+type NextNRequest struct {
+	// Count is the number of selectable items to move forwards.
+	Count int
+	// Wrap, if true, makes moving past the last selectable item wrap round to the first.
+	Wrap bool
+}
+
+// SelectRelativeRequest requests that the selection move by a signed number of selectable items from wherever it is
+// when the List handles the request; see List.SelectRelative.
+// The List broadcasts only the final selection.
+// It fails if nothing is selected.
+type SelectRelativeRequest struct {
+	// Offset is the number of selectable items to move: forwards if positive, backwards if negative.
+	Offset int
+	// Wrap, if true, makes moving past either end of the list wrap round to the other, rather than stopping there.
+	Wrap bool
+}
+
+// AdvanceRequest requests that the selection advance according to the automode.
+// It is sent when the selected item has finished playing.
+type AdvanceRequest struct{}
+
+// SetDurationRequest requests that an item's running time be set.
+type SetDurationRequest struct {
+	// Index is the index of the item.
+	Index int
+	// Hash is the hash of the item.
+	// It exists to prevent races with other changes to the list.
+	Hash string
+	// Duration is the item's running time, or 0 to mark it unknown.
+	Duration time.Duration
+}
+
+// SetStopAfterRequest requests that an item be marked or unmarked as a stop point; see List.SetStopAfter.
+type SetStopAfterRequest struct {
+	// Index is the index of the item.
+	Index int
+	// Hash is the hash of the item.
+	// It exists to prevent races with other changes to the list.
+	Hash string
+	// StopAfter is true to mark the item, and false to unmark it.
+	StopAfter bool
+}
+
+// SetLockedRequest requests that an item be locked or unlocked; see List.SetLocked.
+type SetLockedRequest struct {
+	// Index is the index of the item.
+	Index int
+	// Hash is the hash of the item.
+	// It exists to prevent races with other changes to the list.
+	Hash string
+	// Locked is true to lock the item, and false to unlock it.
+	Locked bool
+}
+
+// SetFallbackRequest requests that an item be marked or unmarked as the List's fallback; see List.SetFallback.
+type SetFallbackRequest struct {
+	// Index is the index of the item.
+	Index int
+	// Hash is the hash of the item.
+	// It exists to prevent races with other changes to the list.
+	Hash string
+	// Fallback is true to mark the item, and false to unmark it.
+	Fallback bool
+}
+
+// SelectFallbackRequest requests that the List select its fallback item; see List.SelectFallback.
+// It suits the action of a deadman switch (see controller.Controller.SetDeadman).
+type SelectFallbackRequest struct{}
+
+// MarkPlayedRequest reports that an item has finished playing; see List.MarkPlayed.
+// It is sent by playout engines, which know when audio actually ends, rather than when the selection moves.
+// If the item is selected, and the List advances on played items (see List.SetAdvanceOnPlayed), it then advances as
+// for an AdvanceRequest.
+type MarkPlayedRequest struct {
+	// Index is the index of the item.
+	Index int
+	// Hash is the hash of the item.
+	// It exists to prevent races with other changes to the list.
+	Hash string
+}
+
+// SetElapsedRequest reports how much of the selected item has played.
+// It is sent by whatever is playing the selection; see List.SetElapsed.
+// Unlike a SetPlayingRequest, it isn't broadcast, as engines may send one every few moments.
+type SetElapsedRequest struct {
+	// Elapsed is how much of the selected item has played.
+	Elapsed time.Duration
+}
+
+// SetPlayingRequest reports whether the selected item is playing or paused; see List.SetPlaying.
+// It is sent by whatever is playing the selection; if it changes anything, the List broadcasts an ElapsedResponse.
+type SetPlayingRequest struct {
+	// Playing is true if the selected item is now playing, and false if it is paused.
+	Playing bool
+}
+
+// ElapsedRequest requests how much of the selected item has played, and whether it is playing; see List.Elapsed.
+// It results in a single ElapsedResponse reply.
+type ElapsedRequest struct{}
+
+// StatusRequest requests a compact summary of the List's state, for frequent polling.
+// It results in a single StatusResponse reply, where a DumpRequest would send the whole List.
+type StatusRequest struct{}
+
+// AutoModesRequest requests the AutoModes the List supports.
+// It results in a single AutoModesResponse reply.
+type AutoModesRequest struct{}
+
+// AirTimesRequest requests the projected time-to-air of each item after the selection; see List.AirTimes.
+// It results in an AirTimeResponse reply for each item.
+type AirTimesRequest struct{}
+
+// AirTimeRequest requests the projected wall-clock air time of a single item; see List.AirTimeOf.
+// It results in a single ProjectionResponse reply.
+type AirTimeRequest struct {
+	// Index is the index of the item.
+	Index int
+	// Hash is the hash of the item.
+	Hash string
+}
+
+// DefaultContextRadius is the radius Bifrost 'context' requests use if they don't give one.
+const DefaultContextRadius = 2
+
+// ContextDumpRequest requests the selected item, and the items within Radius places either side of it; see
+// List.Context.
+// It is a cheaper alternative to a DumpRequest for clients, such as on-air displays, that only show the items around
+// the selection.
+// It results in a single ContextResponse reply.
+type ContextDumpRequest struct {
+	// Radius is the number of items wanted either side of the selection.
+	// It must not be negative.
+	Radius int
+}
+
+// ListByTypeRequest requests the items of one type, such as only the tracks, with their indices; see List.OfType.
+// It is a cheaper alternative to a DumpRequest for clients that show one type of item at a time.
+// It results in a single ByTypeResponse reply.
+type ListByTypeRequest struct {
+	// Type is the type of item wanted.
+	// It must be the type of a real item, such as ItemTrack or ItemText.
+	Type ItemType
+}
+
+// ExportRequest requests the whole List's state as JSON, for clients to save; see List.Export.
+// It results in a single ExportResponse reply.
+// Unlike a save, it is read-only, and has nothing to do with the List's saved file.
+type ExportRequest struct{}
+
+// HistoryRequest requests the most recent plays the List remembers; see List.Plays.
+// It results in a HistoryResponse reply, then a PlayResponse reply for each play, oldest first.
+// It is read-only.
+type HistoryRequest struct {
+	// Count is the number of plays wanted, or 0 for every play the List remembers.
+	// It must not be negative.
+	Count int
+}
+
+// SinceRequest requests the changes the List has made since it was at a given version; see List.Since.
+// It results in a DiffResponse reply for each change, oldest first.
+type SinceRequest struct {
+	// Version is the version of the List the client last knew about.
+	Version uint64
+}
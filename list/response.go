@@ -11,6 +11,8 @@ package list
 // - a parser from messages in 'bifrost.go';
 // - an emitter to messages in 'bifrost.go'.
 
+import "time"
+
 // AutoModeResponse announces a change in AutoMode.
 type AutoModeResponse struct {
 	// AutoMode represents the new AutoMode.
@@ -28,6 +30,15 @@ type SelectResponse struct {
 // FreezeResponse announces a snapshot of the entire list.
 type FreezeResponse []Item
 
+// ListReplacedResponse announces that the entire list has been replaced.
+// It carries the new state of the list, so clients needn't ask for a dump.
+type ListReplacedResponse struct {
+	// Items holds a snapshot of the new list, including the IDs the items were given.
+	Items FreezeResponse
+	// Selection is the new selection.
+	Selection SelectResponse
+}
+
 // ItemResponse announces the presence of a single list item.
 type ItemResponse struct {
 	// Index is the index of the item in the list.
@@ -35,3 +46,203 @@ type ItemResponse struct {
 	// Item is the item itself.
 	Item Item
 }
+
+// ItemUpdatedResponse announces that an item's content has been replaced in place.
+type ItemUpdatedResponse struct {
+	// Index is the index of the item in the list.
+	Index int
+	// OldHash is the hash the item had before the update.
+	OldHash string
+	// Item is the updated item, including its new hash and its unchanged ID.
+	Item Item
+}
+
+// ItemsSwappedResponse announces that two items have swapped places: the item with HashA, which was at IndexA, is
+// now at IndexB, and vice versa.
+// If either item was selected, it still is, at its new index; no separate selection broadcast follows.
+type ItemsSwappedResponse SwapItemsRequest
+
+// ExhaustedResponse announces that an automode advance ran out of items to select.
+// It is broadcast once each time the list becomes exhausted; see List.Exhausted for the exact conditions.
+// Dumps, and greetings to new clients, also include it while the list remains exhausted.
+type ExhaustedResponse struct{}
+
+// DurationResponse announces a change in an item's running time.
+// Dumps also include one for each item whose running time is known.
+type DurationResponse struct {
+	// Index is the index of the item in the list.
+	Index int
+	// Hash is the item's hash.
+	Hash string
+	// Duration is the item's running time, or 0 if it is now unknown.
+	Duration time.Duration
+}
+
+// StopAfterResponse announces that an item has been marked or unmarked as a stop point.
+// Dumps also include one for each stop point.
+type StopAfterResponse struct {
+	// Index is the index of the item in the list.
+	Index int
+	// Hash is the item's hash.
+	Hash string
+	// StopAfter is true if the item is now a stop point.
+	StopAfter bool
+}
+
+// LockedResponse announces that an item has been locked or unlocked.
+// Dumps also include one for each locked item.
+type LockedResponse struct {
+	// Index is the index of the item in the list.
+	Index int
+	// Hash is the item's hash.
+	Hash string
+	// Locked is true if the item is now locked.
+	Locked bool
+}
+
+// FallbackResponse announces that an item has been marked or unmarked as the List's fallback.
+// Marking an item implicitly unmarks the old fallback, if any.
+// Dumps also include one for the fallback, if there is one.
+type FallbackResponse struct {
+	// Index is the index of the item in the list.
+	Index int
+	// Hash is the item's hash.
+	Hash string
+	// Fallback is true if the item is now the fallback.
+	Fallback bool
+}
+
+// ElapsedResponse gives how much of the selected item has played, and whether it is playing; see List.SetPlaying.
+// It is broadcast whenever the selection starts or stops playing, and after any new selection that carries on playing
+// (so restarts from 0); dumps include one if anything is selected.
+// Rather than hear every change in the elapsed time, clients showing a countdown work it out from Started while
+// Playing is true; they can query the List again to catch up with seeks.
+type ElapsedResponse struct {
+	// Index is the index of the selected item.
+	Index int
+	// Hash is the selected item's hash.
+	Hash string
+	// Elapsed is how much of the item had played when the response was made.
+	Elapsed time.Duration
+	// Playing is true if the item is playing, in which case its elapsed time counts up from Started.
+	Playing bool
+	// Started, if Playing, is when the item would have started, had it played uninterrupted up to Elapsed, by the
+	// List's Clock; otherwise, it is the zero time.
+	Started time.Time
+}
+
+// FinishedResponse announces that an item has been marked as having finished playing.
+// Dumps also include one for each item so marked.
+type FinishedResponse MarkPlayedRequest
+
+// StoppedResponse announces that an automode advance halted at a stop point, leaving it selected.
+// It is broadcast each time this happens; a manual next moves the selection on.
+type StoppedResponse struct {
+	// Index is the index of the stop point.
+	Index int
+	// Hash is the stop point's hash.
+	Hash string
+}
+
+// HistoryResponse announces the number of plays in the reply to a HistoryRequest, which follow as PlayResponses.
+type HistoryResponse struct {
+	// Count is the number of plays that follow.
+	Count int
+}
+
+// PlayResponse gives one play in the reply to a HistoryRequest.
+type PlayResponse Play
+
+// OpFailedResponse gives one operation that failed in a TransactionRequest.
+type OpFailedResponse OpFailure
+
+// SummaryResponse summarises the outcome of a bulk mutation, such as a ReplaceListRequest, in its reply.
+// Up to MaxListedRejections RejectionResponses follow it, one per item left out.
+type SummaryResponse struct {
+	// Requested is the number of items in the request.
+	Requested int
+	// Applied is the number of items that made it into the List.
+	Applied int
+	// Rejected is the number of items left out, which may be more than the RejectionResponses that follow.
+	Rejected int
+}
+
+// RejectionResponse gives one item left out of a bulk mutation, following a SummaryResponse.
+type RejectionResponse Rejection
+
+// StaleHashResponse warns a client that its request gave a hash that the item it targeted had recently, but no
+// longer has, and that the List went ahead anyway; see List.SetStaleHashes.
+// It comes before the request's acknowledgement, and any broadcasts the request caused give the current hash.
+type StaleHashResponse struct {
+	// Index is the index of the item.
+	Index int
+	// Hash is the stale hash the request gave.
+	Hash string
+	// Current is the item's current hash, which the request went ahead with.
+	Current string
+}
+
+// StatusResponse summarises the state of a List; see StatusRequest.
+type StatusResponse struct {
+	// Count is the number of items in the List.
+	Count int
+	// Selection is the current selection.
+	Selection SelectResponse
+	// AutoMode is the current AutoMode.
+	AutoMode AutoMode
+}
+
+// AutoModesResponse lists the AutoModes a List supports; see AutoModesRequest.
+type AutoModesResponse struct {
+	// AutoModes holds every supported AutoMode, in order.
+	AutoModes []AutoMode
+}
+
+// AirTimeResponse announces the projected time until an item goes to air.
+// It is sent in reply to an AirTimesRequest, and in dumps if the List dumps air times (see List.SetDumpAirTimes).
+type AirTimeResponse AirTime
+
+// ProjectionResponse holds the projected air time of one item, and its assumptions; see AirTimeRequest.
+type ProjectionResponse Projection
+
+// ContextResponse holds the items around the selection; see ContextDumpRequest.
+type ContextResponse struct {
+	// Selection is the current selection.
+	Selection SelectResponse
+	// Items holds the selected item and those around it, in order, with their indices in the whole list.
+	// It is empty if there is no selection.
+	Items []ItemResponse
+}
+
+// ByTypeResponse holds the items of one type; see ListByTypeRequest.
+type ByTypeResponse struct {
+	// Type is the type of the items.
+	Type ItemType
+	// Items holds the items, in order, with their indices in the whole list.
+	// There are at most MaxTypeMatches of them.
+	Items []ItemResponse
+	// Truncated is true if there were more than MaxTypeMatches items of the type, so some were left out.
+	Truncated bool
+}
+
+// DiffResponse holds one change the List made after a version a client asked about; see SinceRequest.
+type DiffResponse struct {
+	// Version is the version of the List after the change.
+	Version uint64
+	// Change is the broadcast that announced the change.
+	Change interface{}
+}
+
+// ExportResponse holds the List's state as JSON; see ExportRequest.
+type ExportResponse struct {
+	// JSON is the exported List, in the saved list format with the List's selection, AutoMode, and running times
+	// alongside; it is at most MaxExportLen bytes.
+	JSON []byte
+}
+
+// VersionResponse announces the List's version; see List.Version.
+// Dumps end with one, giving the version they reflect.
+type VersionResponse struct {
+	// Version is the List's version.
+	Version uint64
+}
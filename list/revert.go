@@ -0,0 +1,58 @@
+package list
+
+// File revert.go contains reverting, with which an operator throws away the List's live edits and goes back to the
+// list it was last saved as.
+
+import (
+	"errors"
+	"fmt"
+	"os"
+)
+
+// ErrNoSaveFile is the error given when reverting a List that has no saved file.
+var ErrNoSaveFile = errors.New("list has no saved file")
+
+// SetSaveFile sets the path of l's saved list, for Revert; the empty path, the default, means it has none.
+func (l *List) SetSaveFile(path string) {
+	l.saveFile = path
+}
+
+// Revert replaces every item in l with those in its saved list file (see SetSaveFile and Load), as in Replace.
+// If the selected item's hash is in the file, Revert selects its copy, which keeps the elapsed time and play state;
+// otherwise, nothing is selected.
+//
+// It reads and checks the whole file before touching l: a missing or corrupt file, or items Replace would fail on,
+// leave l as it was.
+func (l *List) Revert() (Summary, error) {
+	if l.saveFile == "" {
+		return Summary{}, ErrNoSaveFile
+	}
+
+	f, err := os.Open(l.saveFile)
+	if err != nil {
+		return Summary{}, fmt.Errorf("couldn't open saved list: %w", err)
+	}
+	defer f.Close()
+
+	items, err := Load(f)
+	if err != nil {
+		return Summary{}, err
+	}
+
+	sel := -1
+	if _, item := l.Selection(); item != nil {
+		for i := range items {
+			if items[i].Hash() == item.Hash() && items[i].IsSelectable() {
+				sel = i
+				break
+			}
+		}
+	}
+	// The selected item, if it survives, is still the one on air, so it carries on from where it was.
+	elapsed, since := l.elapsed, l.playingSince
+	sum, err := l.replace(items, sel, false)
+	if err == nil && sel != -1 {
+		l.elapsed, l.playingSince = elapsed, since
+	}
+	return sum, err
+}
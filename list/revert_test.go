@@ -0,0 +1,104 @@
+package list_test
+
+import (
+	"errors"
+	"io/ioutil"
+	"os"
+	"reflect"
+	"testing"
+
+	"github.com/UniversityRadioYork/baps3d/list"
+)
+
+// writeSaveFile saves items to a new temporary file, returning its path.
+// The caller must remove the file.
+func writeSaveFile(t *testing.T, items []list.Item) string {
+	t.Helper()
+
+	f, err := ioutil.TempFile("", "baps3d-revert")
+	if err != nil {
+		t.Fatalf("couldn't create save file: %v", err)
+	}
+	defer f.Close()
+	if err := list.Save(f, items); err != nil {
+		os.Remove(f.Name())
+		t.Fatalf("couldn't save: %v", err)
+	}
+	return f.Name()
+}
+
+// TestList_Revert tests that reverting replaces the items with the saved ones, keeping the selection if it was saved.
+func TestList_Revert(t *testing.T) {
+	path := writeSaveFile(t, testSaveItems)
+	defer os.Remove(path)
+
+	l := list.New()
+	l.SetSaveFile(path)
+	for i, hash := range []string{"xyz", "ghi"} {
+		if err := l.Add(list.NewTrack(hash, hash+".mp3"), i); err != nil {
+			t.Fatalf("couldn't add %s: %v", hash, err)
+		}
+	}
+	if _, err := l.Select(1, "ghi"); err != nil {
+		t.Fatalf("couldn't select: %v", err)
+	}
+
+	sum, err := l.Revert()
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if sum.Applied != len(testSaveItems) {
+		t.Errorf("got %d items applied, want %d", sum.Applied, len(testSaveItems))
+	}
+
+	var hashes []string
+	for _, item := range l.Freeze() {
+		hashes = append(hashes, item.Hash())
+	}
+	if want := []string{"abc", "def", "ghi"}; !reflect.DeepEqual(hashes, want) {
+		t.Errorf("got items %v, want %v", hashes, want)
+	}
+	if sel, _ := l.Selection(); sel != 2 {
+		t.Errorf("got selection %d, want 2", sel)
+	}
+}
+
+// TestList_Revert_Fail tests that reverting without a usable saved file fails, leaving the List as it was.
+func TestList_Revert_Fail(t *testing.T) {
+	corrupt, err := ioutil.TempFile("", "baps3d-revert")
+	if err != nil {
+		t.Fatalf("couldn't create save file: %v", err)
+	}
+	defer os.Remove(corrupt.Name())
+	if _, err := corrupt.WriteString(`{"version": 1, "checksum": "nope", "items": []}`); err != nil {
+		t.Fatalf("couldn't write save file: %v", err)
+	}
+	corrupt.Close()
+
+	cases := []struct {
+		name string
+		path string
+		want error
+	}{
+		{"no file", "", list.ErrNoSaveFile},
+		{"missing", corrupt.Name() + "-missing", os.ErrNotExist},
+		{"corrupt", corrupt.Name(), list.ErrChecksumMismatch},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			l := list.New()
+			l.SetSaveFile(c.path)
+			if err := l.Add(list.NewTrack("xyz", "xyz.mp3"), 0); err != nil {
+				t.Fatalf("couldn't add: %v", err)
+			}
+
+			if _, err := l.Revert(); !errors.Is(err, c.want) {
+				t.Errorf("got error %v, want %v", err, c.want)
+			}
+			if items := l.Freeze(); len(items) != 1 || items[0].Hash() != "xyz" {
+				t.Errorf("list changed: got %v", items)
+			}
+		})
+	}
+}
@@ -0,0 +1,53 @@
+package list
+
+// File settings.go contains Settings, the parts of a List's configuration that can change while it runs.
+
+import "fmt"
+
+// Settings holds the settings of a List that can change while it runs; see Reload.
+//
+// Some things that look like settings are deliberately left out.
+// A List has no hash function to reload, as clients give every item's hash.
+// Its request limits (see Limits) are constants, and the dump limit and deadman belong to its Controller, which takes
+// them before it runs.
+// Its Transforms (see SetTransforms) stay too: they only apply to items as they arrive, so changing them would leave
+// the List holding a mix of old and new canonical forms.
+type Settings struct {
+	// DumpAirTimes is whether dumps of the List include its air times; see SetDumpAirTimes.
+	DumpAirTimes bool
+	// StaleHashes is how many superseded hashes the List remembers, or 0 to make it strict; see SetStaleHashes.
+	StaleHashes int
+	// PlayHistory is how many plays the List remembers; see SetPlayHistory.
+	PlayHistory int
+	// AdvanceOnPlayed is whether marking the selection played advances the List; see SetAdvanceOnPlayed.
+	AdvanceOnPlayed bool
+	// SaveFile is the path of the List's saved list, if it has one; see SetSaveFile.
+	SaveFile string
+}
+
+// Settings gets l's current settings.
+func (l *List) Settings() Settings {
+	return Settings{
+		DumpAirTimes:    l.dumpAirTimes,
+		StaleHashes:     l.staleHashes,
+		PlayHistory:     l.playHistory,
+		AdvanceOnPlayed: l.advanceOnPlayed,
+		SaveFile:        l.saveFile,
+	}
+}
+
+// Reload replaces l's settings with settings, which must be a Settings.
+// Everything else, such as the items, their IDs and durations, the selection and the automode, survives.
+func (l *List) Reload(settings interface{}) error {
+	s, ok := settings.(Settings)
+	if !ok {
+		return fmt.Errorf("list can't take settings of type %T", settings)
+	}
+
+	l.SetDumpAirTimes(s.DumpAirTimes)
+	l.SetStaleHashes(s.StaleHashes)
+	l.SetPlayHistory(s.PlayHistory)
+	l.SetAdvanceOnPlayed(s.AdvanceOnPlayed)
+	l.SetSaveFile(s.SaveFile)
+	return nil
+}
@@ -0,0 +1,37 @@
+package list_test
+
+import (
+	"testing"
+
+	"github.com/UniversityRadioYork/baps3d/list"
+)
+
+// TestList_Reload tests that reloading changes a List's settings, but keeps its items and selection.
+func TestList_Reload(t *testing.T) {
+	l := list.New()
+	if err := l.Add(list.NewTrack("a", "a.mp3"), 0); err != nil {
+		t.Fatalf("couldn't add item: %v", err)
+	}
+	if _, err := l.Select(0, "a"); err != nil {
+		t.Fatalf("couldn't select item: %v", err)
+	}
+	l.SetAutoMode(list.AutoNext)
+
+	want := list.Settings{DumpAirTimes: true}
+	if err := l.Reload(want); err != nil {
+		t.Fatalf("unexpected reload error: %v", err)
+	}
+	if got := l.Settings(); got != want {
+		t.Errorf("got settings %+v, want %+v", got, want)
+	}
+	if i, item := l.Selection(); l.Count() != 1 || i != 0 || item.Hash() != "a" || l.AutoMode() != list.AutoNext {
+		t.Errorf("reload lost state: %d items, selection %d, automode %v", l.Count(), i, l.AutoMode())
+	}
+
+	if err := l.Reload("bogus"); err == nil {
+		t.Error("expected an error reloading with the wrong settings type")
+	}
+	if got := l.Settings(); got != want {
+		t.Errorf("failed reload changed settings to %+v", got)
+	}
+}
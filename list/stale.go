@@ -0,0 +1,71 @@
+package list
+
+// File stale.go contains the List's optional leniency towards recently superseded hashes; see SetStaleHashes.
+
+import "github.com/UniversityRadioYork/baps3d/controller"
+
+// MaxStaleHashes is the most superseded hashes a List remembers.
+const MaxStaleHashes = 256
+
+// supersession records that an update gave an item a new hash.
+type supersession struct {
+	// hash is the hash the item had before the update.
+	hash string
+	// id is the item's ID, which updates keep.
+	id uint64
+}
+
+// SetStaleHashes sets how many superseded hashes l remembers, and so whether it is lenient towards them.
+// If n is positive, then each time Update gives an item a new hash, l remembers the old one, up to the n most recent
+// (or MaxStaleHashes, if n is larger).
+// Requests handled through HandleRequest that guard on the hash of an item (selecting it, updating it, or setting its
+// duration) then go ahead if they give a hash the same item had until recently, rather than failing with
+// controller.ErrStateChanged; they get a StaleHashResponse, warning the client, before their acknowledgement.
+// Lenient requests still fail if the item at their index isn't the one that had their hash.
+//
+// If n is 0 (the default), l is strict: it forgets any superseded hashes, and requests must give current ones.
+// Calling the List's own methods, such as Select, directly is always strict.
+func (l *List) SetStaleHashes(n int) {
+	if MaxStaleHashes < n {
+		n = MaxStaleHashes
+	}
+	if n < 0 {
+		n = 0
+	}
+	l.staleHashes = n
+	l.trimSuperseded()
+}
+
+// supersede records that the item with ID id has just had its hash changed from hash, if l remembers stale hashes.
+func (l *List) supersede(hash string, id uint64) {
+	if l.staleHashes == 0 {
+		return
+	}
+	l.superseded = append(l.superseded, supersession{hash: hash, id: id})
+	l.trimSuperseded()
+}
+
+// trimSuperseded forgets the oldest superseded hashes past l's limit.
+func (l *List) trimSuperseded() {
+	if over := len(l.superseded) - l.staleHashes; 0 < over {
+		l.superseded = append(l.superseded[:0], l.superseded[over:]...)
+	}
+}
+
+// lenientHash gets the hash a hash-guarded request for the item at index should check, given the hash it came with.
+// If hash is one that item had until recently, this is the item's current hash, and lenientHash warns the client
+// through replyCb; otherwise, it is hash itself, and the guard applies as usual.
+func (l *List) lenientHash(replyCb controller.ResponseCb, index int, hash string) string {
+	item := l.ItemWithIndex(index)
+	if item == nil || item.Hash() == hash {
+		return hash
+	}
+
+	for i := len(l.superseded) - 1; 0 <= i; i-- {
+		if s := l.superseded[i]; s.hash == hash && s.id == item.ID() {
+			replyCb(StaleHashResponse{Index: index, Hash: hash, Current: item.Hash()})
+			return item.Hash()
+		}
+	}
+	return hash
+}
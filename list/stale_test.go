@@ -0,0 +1,67 @@
+package list_test
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/UniversityRadioYork/baps3d/controller"
+	"github.com/UniversityRadioYork/baps3d/list"
+)
+
+// selectWithReplies sends a select request for index 0 and hash to l, returning its replies and error.
+func selectWithReplies(l *list.List, hash string) ([]interface{}, error) {
+	var replies []interface{}
+	replyCb := func(r interface{}) { replies = append(replies, r) }
+	err := l.HandleRequest(replyCb, func(interface{}) {}, list.SetSelectRequest{Index: 0, Hash: hash})
+	return replies, err
+}
+
+// TestList_SetStaleHashes tests that a lenient List lets requests through on the most recent superseded hashes, with
+// a warning, but that strict Lists, older hashes, and hashes from before a replace still fail.
+func TestList_SetStaleHashes(t *testing.T) {
+	l := list.New()
+	if err := l.Add(list.NewTrack("a", "a.mp3"), 0); err != nil {
+		t.Fatalf("couldn't add item: %v", err)
+	}
+	update := func(hash, newHash string) {
+		t.Helper()
+		if err := l.Update(0, hash, list.NewTrack(newHash, newHash+".mp3")); err != nil {
+			t.Fatalf("couldn't update %q to %q: %v", hash, newHash, err)
+		}
+	}
+
+	// Strict by default.
+	update("a", "b")
+	if _, err := selectWithReplies(l, "a"); !errors.Is(err, controller.ErrStateChanged) {
+		t.Errorf("strict: got error %v, want ErrStateChanged", err)
+	}
+
+	l.SetStaleHashes(2)
+	update("b", "c")
+	update("c", "d")
+	update("d", "e")
+
+	replies, err := selectWithReplies(l, "c")
+	if err != nil {
+		t.Fatalf("lenient: unexpected error: %v", err)
+	}
+	want := list.StaleHashResponse{Index: 0, Hash: "c", Current: "e"}
+	if len(replies) != 1 || replies[0] != want {
+		t.Errorf("lenient: got replies %v, want %v", replies, want)
+	}
+	if _, item := l.Selection(); item == nil || item.Hash() != "e" {
+		t.Errorf("lenient: selected %v, want the item now hashed 'e'", item)
+	}
+
+	// Only the two latest superseded hashes, 'c' and 'd', are remembered.
+	if _, err := selectWithReplies(l, "b"); !errors.Is(err, controller.ErrStateChanged) {
+		t.Errorf("forgotten hash: got error %v, want ErrStateChanged", err)
+	}
+
+	if err := l.Replace([]list.Item{*list.NewTrack("e", "e.mp3")}, -1); err != nil {
+		t.Fatalf("couldn't replace: %v", err)
+	}
+	if _, err := selectWithReplies(l, "d"); !errors.Is(err, controller.ErrStateChanged) {
+		t.Errorf("after replace: got error %v, want ErrStateChanged", err)
+	}
+}
@@ -0,0 +1,38 @@
+package list
+
+// File stops.go contains stop points: items after which the automode doesn't advance on its own, for instance where
+// the presenter wants to talk before the next item.
+
+import "github.com/UniversityRadioYork/baps3d/controller"
+
+// SetStopAfter tries to mark (if on) or unmark the item with the given index and hash as a stop point.
+// When a stop point finishes, Next leaves the selection on it in the AutoModes that would otherwise move on (AutoNext
+// and AutoShuffle), until something else, such as a manual next, moves the selection past it; see AtStop.
+// AutoOff already stays on the selection, and AutoDrop already drops it, so stop points change nothing in them.
+// The mark belongs to the item, not its place in the list, so it moves with the item, stays over updates, and, in
+// AutoShuffle, stops the List wherever in the shuffle the item comes up.
+// It fails if the item doesn't exist or has a different hash (see controller.ErrStateChanged).
+func (l *List) SetStopAfter(index int, hash string, on bool) error {
+	item, err := l.guardedItem("SetStopAfter", index, hash)
+	if err != nil {
+		return err
+	}
+
+	item.stopAfter = on
+	return nil
+}
+
+// AtStop gets whether the selected item is a stop point that Next, in the current AutoMode, won't advance past.
+func (l *List) AtStop() bool {
+	_, item := l.Selection()
+	return item != nil && item.stopAfter && (l.autoselect == AutoNext || l.autoselect == AutoShuffle)
+}
+
+// dumpStops sends a StopAfterResponse to dumpCb for each stop point in l.
+func (l *List) dumpStops(dumpCb controller.ResponseCb) {
+	for i, item := range l.Freeze() {
+		if item.StopAfter() {
+			dumpCb(StopAfterResponse{Index: i, Hash: item.Hash(), StopAfter: true})
+		}
+	}
+}
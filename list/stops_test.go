@@ -0,0 +1,115 @@
+package list_test
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/UniversityRadioYork/bifrost-go/message"
+
+	"github.com/UniversityRadioYork/baps3d/list"
+)
+
+// stopList makes a List with tracks a to c, with b marked as a stop point, a selected, and the given AutoMode.
+func stopList(t *testing.T, mode list.AutoMode) *list.List {
+	t.Helper()
+
+	l := list.New()
+	for i, h := range []string{"a", "b", "c"} {
+		if err := l.Add(list.NewTrack(h, h+".mp3"), i); err != nil {
+			t.Fatalf("couldn't add %q: %v", h, err)
+		}
+	}
+	if err := l.SetStopAfter(1, "b", true); err != nil {
+		t.Fatalf("couldn't mark b: %v", err)
+	}
+	if _, err := l.Select(0, "a"); err != nil {
+		t.Fatalf("couldn't select: %v", err)
+	}
+	l.SetAutoMode(mode)
+	return l
+}
+
+// TestList_StopAfter tests that AutoNext stops at a stop point until a manual next moves past it.
+func TestList_StopAfter(t *testing.T) {
+	l := stopList(t, list.AutoNext)
+
+	if i, changed := l.Next(); i != 1 || !changed {
+		t.Fatalf("advancing onto the stop point: got %d, %v; want 1, true", i, changed)
+	}
+	if !l.AtStop() {
+		t.Error("expected to be at a stop point")
+	}
+	for n := 0; n < 2; n++ {
+		if i, changed := l.Next(); i != 1 || changed {
+			t.Errorf("advance %d past the stop point: got %d, %v; want 1, false", n, i, changed)
+		}
+	}
+	if l.Exhausted() {
+		t.Error("stopping shouldn't exhaust the list")
+	}
+	if plays, _ := l.Plays(0); len(plays) != 3 {
+		t.Errorf("got %d plays, want 3: stopping still plays the stop point", len(plays))
+	}
+
+	if i, changed, err := l.SelectNext(false); err != nil || i != 2 || !changed {
+		t.Errorf("manual next: got %d, %v, %v; want 2, true, nil", i, changed, err)
+	}
+
+	if err := l.SetStopAfter(1, "x", true); err == nil {
+		t.Error("expected an error marking with the wrong hash")
+	}
+}
+
+// TestList_StopAfter_OtherModes tests that only AutoNext and AutoShuffle honour stop points.
+func TestList_StopAfter_OtherModes(t *testing.T) {
+	for _, c := range []struct {
+		mode list.AutoMode
+		stop bool
+	}{{list.AutoOff, false}, {list.AutoDrop, false}, {list.AutoNext, true}, {list.AutoShuffle, true}} {
+		l := stopList(t, c.mode)
+		if _, err := l.Select(1, "b"); err != nil {
+			t.Fatalf("couldn't select b: %v", err)
+		}
+		if got := l.AtStop(); got != c.stop {
+			t.Errorf("%v: got AtStop %v, want %v", c.mode, got, c.stop)
+		}
+	}
+}
+
+// TestList_Bifrost_StopAfter tests the 'stopafter' request, and the STOPAFTER and STOPPED messages it leads to.
+func TestList_Bifrost_StopAfter(t *testing.T) {
+	l := stopList(t, list.AutoNext)
+
+	rq, err := l.ParseBifrostRequest("stopafter", []string{"2", "c", "on"})
+	if err != nil {
+		t.Fatalf("unexpected parse error: %v", err)
+	}
+	if want := (list.SetStopAfterRequest{Index: 2, Hash: "c", StopAfter: true}); rq != want {
+		t.Fatalf("got request %+v, want %+v", rq, want)
+	}
+	if _, err := l.ParseBifrostRequest("stopafter", []string{"2", "c", "yes"}); err == nil {
+		t.Error("expected an error for a flag other than on or off")
+	}
+
+	msgs := make(chan message.Message, 8)
+	emit := func(rbody interface{}) {
+		if err := l.EmitBifrostResponse("!", rbody, msgs); err != nil {
+			t.Fatalf("unexpected emit error: %v", err)
+		}
+	}
+	for _, rq := range []interface{}{rq, list.AdvanceRequest{}, list.AdvanceRequest{}} {
+		if err := l.HandleRequest(func(interface{}) {}, emit, rq); err != nil {
+			t.Fatalf("unexpected error handling %+v: %v", rq, err)
+		}
+	}
+	close(msgs)
+
+	var got []string
+	for m := range msgs {
+		got = append(got, m.String())
+	}
+	want := []string{"! STOPAFTER 2 c on\n", "! SEL 1 b\n", "! STOPPED 1 b\n"}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("got messages %q, want %q", got, want)
+	}
+}
@@ -0,0 +1,79 @@
+package list
+
+// File summary.go contains Summary, which bulk mutations give to say what they did with each item they were asked to
+// apply.
+
+import (
+	"fmt"
+
+	"github.com/UniversityRadioYork/baps3d/controller"
+)
+
+// MaxListedRejections is the most RejectionResponses that follow a SummaryResponse.
+// Any more rejections are only counted, so that a bulk load of bad items still gets a short reply.
+const MaxListedRejections = 64
+
+// Rejection records an item that a bulk mutation left out, and why.
+type Rejection struct {
+	// Index is the index of the item in the request.
+	Index int
+	// Hash is the item's hash.
+	Hash string
+	// Err is the reason the item was left out, such as the error of the Validator that rejected it.
+	Err error
+}
+
+// Summary describes what a bulk mutation did with the items it was asked to apply.
+type Summary struct {
+	// Requested is the number of items in the request.
+	Requested int
+	// Applied is the number of items that made it into the List.
+	Applied int
+	// Rejected holds the items that were left out, in request order.
+	Rejected []Rejection
+}
+
+// skipRejected gets the items, out of items, that ReplaceSkipping keeps, the index among those of the item at sel
+// (or -1 if it isn't kept, or sel is -1), and the items it leaves out.
+// Each item goes before l's Validators with the items kept so far before it, and the items not yet judged after it,
+// so an item judged by its neighbours is judged by the ones that may end up next to it.
+func (l *List) skipRejected(items []Item, sel int) ([]Item, int, []Rejection) {
+	var rejected []Rejection
+	// kept holds the items kept so far, then the items from i on.
+	kept := append([]Item(nil), items...)
+	hashes := make(map[string]struct{}, len(items))
+	newSel, n := -1, 0
+	for i := range items {
+		hash := items[i].Hash()
+		var reason error
+		if _, ok := hashes[hash]; ok {
+			reason = fmt.Errorf("duplicate hash %s", hash)
+		} else {
+			reason = l.rejection(kept, n)
+		}
+		if reason != nil {
+			rejected = append(rejected, Rejection{Index: i, Hash: hash, Err: reason})
+			kept = append(kept[:n], kept[n+1:]...)
+			continue
+		}
+
+		hashes[hash] = struct{}{}
+		if i == sel {
+			newSel = n
+		}
+		n++
+	}
+	return kept, newSel, rejected
+}
+
+// replySummary sends replyCb a SummaryResponse for sum, followed by a RejectionResponse for each of the first
+// MaxListedRejections items it rejected.
+func replySummary(replyCb controller.ResponseCb, sum Summary) {
+	replyCb(SummaryResponse{Requested: sum.Requested, Applied: sum.Applied, Rejected: len(sum.Rejected)})
+	for i, r := range sum.Rejected {
+		if i == MaxListedRejections {
+			break
+		}
+		replyCb(RejectionResponse(r))
+	}
+}
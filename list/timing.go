@@ -0,0 +1,274 @@
+package list
+
+// This file contains List timing: item durations, the elapsed time and play state of the selected item, and the
+// time-to-air projections computed from them.
+
+import (
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/UniversityRadioYork/baps3d/controller"
+)
+
+// ErrNoAirTime is the error AirTimeOf gives when an item between the selection and the requested one has no
+// duration, so its air time can't be projected.
+var ErrNoAirTime = errors.New("air time can't be projected")
+
+// AirTime is the projected time until an item goes to air.
+type AirTime struct {
+	// Index is the index of the item in the list.
+	Index int
+	// Hash is the item's hash.
+	Hash string
+	// StartsIn is how long it is until the item starts, if Known.
+	StartsIn time.Duration
+	// Known is false if an item before this one (or the selection itself) has an unknown duration.
+	// Once one item's air time is unknown, so are those of all items after it.
+	Known bool
+}
+
+// Projection is the projected wall-clock air time of a single item; see AirTimeOf.
+// It holds the assumptions it rests on, as well as the time itself, as the projection is only as good as they are.
+type Projection struct {
+	// Index is the index of the item in the list.
+	Index int
+	// Hash is the item's hash.
+	Hash string
+	// At is when the item is due to start, according to the List's Clock.
+	At time.Time
+	// StartsIn is how long it is until the item starts.
+	StartsIn time.Duration
+
+	// Selection is the index of the selection the projection runs from.
+	// The projection assumes that the selection, and every item between it and this one, play in order, in full.
+	Selection int
+	// Elapsed is how much of the selection the projection assumes has played (see List.Elapsed).
+	Elapsed time.Duration
+}
+
+// SetDuration tries to set the running time of the item with the given index and hash.
+// A zero duration marks the duration as unknown.
+// It fails if the item doesn't exist or has a different hash (see controller.ErrStateChanged), or if d is negative.
+func (l *List) SetDuration(index int, hash string, d time.Duration) error {
+	if d < 0 {
+		return fmt.Errorf("SetDuration: negative duration %s", d)
+	}
+
+	item, err := l.guardedItem("SetDuration", index, hash)
+	if err != nil {
+		return err
+	}
+
+	item.duration = d
+	return nil
+}
+
+// Elapsed gets how much of the selected item has played.
+// While the selection is playing (see SetPlaying), this counts up by the List's Clock.
+// It is 0 if nothing is selected.
+func (l *List) Elapsed() time.Duration {
+	if l.playingSince.IsZero() {
+		return l.elapsed
+	}
+	return l.elapsed + l.clock.Now().Sub(l.playingSince)
+}
+
+// SetElapsed records that d of the selected item has played.
+// The List doesn't watch playback itself, so whatever is playing the selection should call this as it goes, or
+// at least whenever it seeks; while the selection is playing, the List counts up from d by itself in between.
+// The elapsed time goes back to 0 whenever the selection moves to a different item.
+// It fails if nothing is selected, or d is negative.
+func (l *List) SetElapsed(d time.Duration) error {
+	if d < 0 {
+		return fmt.Errorf("SetElapsed: negative elapsed time %s", d)
+	}
+	if l.selection == -1 {
+		return fmt.Errorf("SetElapsed: nothing selected")
+	}
+
+	l.elapsed = d
+	if l.Playing() {
+		l.playingSince = l.clock.Now()
+	}
+	return nil
+}
+
+// Playing gets whether the selection is playing; see SetPlaying.
+func (l *List) Playing() bool {
+	return !l.playingSince.IsZero()
+}
+
+// SetPlaying records whether the selection is playing, as reported by whatever is playing it.
+// While it plays, its elapsed time (see Elapsed) counts up by the List's Clock; pausing freezes it, and playing again
+// carries on from there.
+// The List stays playing when the selection moves to a different item, counting that item's elapsed time up from 0,
+// as engines go straight on to the next item; it stops playing when nothing is selected.
+// It returns false, doing nothing, if the selection is already in that state, and fails if nothing is selected.
+func (l *List) SetPlaying(on bool) (bool, error) {
+	if l.selection == -1 {
+		return false, fmt.Errorf("SetPlaying: nothing selected")
+	}
+	if on == l.Playing() {
+		return false, nil
+	}
+
+	if on {
+		l.playingSince = l.clock.Now()
+	} else {
+		l.elapsed = l.Elapsed()
+		l.playingSince = time.Time{}
+	}
+	return true, nil
+}
+
+// elapsedResponse gets an ElapsedResponse for the selection, which must exist, as of now.
+func (l *List) elapsedResponse() ElapsedResponse {
+	_, item := l.Selection()
+	r := ElapsedResponse{Index: l.selection, Hash: item.Hash(), Elapsed: l.Elapsed(), Playing: l.Playing()}
+	if r.Playing {
+		r.Started = l.clock.Now().Add(-r.Elapsed)
+	}
+	return r
+}
+
+// dumpElapsed sends an ElapsedResponse to dumpCb for the selection, if there is one.
+func (l *List) dumpElapsed(dumpCb controller.ResponseCb) {
+	if l.selection != -1 {
+		dumpCb(l.elapsedResponse())
+	}
+}
+
+// AirTimes projects, for each selectable item after the selection, how long it is until that item goes to air.
+// It assumes the selection is playing from its elapsed time, and that it and every item after it play in order,
+// in full.
+// Unselectable items, such as text items, take no time and get no air time.
+// If nothing is selected, nothing is due to air, so AirTimes returns no air times.
+func (l *List) AirTimes() []AirTime {
+	e := l.elementWithIndex(l.selection)
+	if e == nil {
+		return nil
+	}
+
+	d, known := e.Value.(*Item).Duration()
+	startsIn := d - l.Elapsed()
+	// If the selection has overrun, the next item is due now.
+	if startsIn < 0 {
+		startsIn = 0
+	}
+
+	var ats []AirTime
+	i := l.selection
+	for e = e.Next(); e != nil; e = e.Next() {
+		i++
+		item := e.Value.(*Item)
+		if !item.IsSelectable() {
+			continue
+		}
+
+		at := AirTime{Index: i, Hash: item.Hash(), Known: known}
+		if known {
+			at.StartsIn = startsIn
+			d, known = item.Duration()
+			startsIn += d
+		}
+		ats = append(ats, at)
+	}
+	return ats
+}
+
+// AirTimeOf projects when the item with the given index and hash goes to air, making the same assumptions as
+// AirTimes.
+// It fails if the item doesn't exist or has a different hash (see controller.ErrStateChanged); if it isn't a
+// selectable item after the selection; or, with ErrNoAirTime, if the selection or any selectable item between it and
+// the requested item has no duration.
+func (l *List) AirTimeOf(index int, hash string) (Projection, error) {
+	item, err := l.guardedItem("AirTimeOf", index, hash)
+	if err != nil {
+		return Projection{}, err
+	}
+	if l.selection == -1 {
+		return Projection{}, fmt.Errorf("AirTimeOf: nothing selected")
+	}
+	if index <= l.selection {
+		return Projection{}, fmt.Errorf("AirTimeOf: item %d isn't after the selection", index)
+	}
+	if !item.IsSelectable() {
+		return Projection{}, fmt.Errorf("AirTimeOf: item %d never goes to air", index)
+	}
+
+	var startsIn time.Duration
+	e := l.elementWithIndex(l.selection)
+	for i := l.selection; i < index; i, e = i+1, e.Next() {
+		it := e.Value.(*Item)
+		if i != l.selection && !it.IsSelectable() {
+			continue
+		}
+		d, known := it.Duration()
+		if !known {
+			return Projection{}, fmt.Errorf("AirTimeOf: %w: item %d has no duration", ErrNoAirTime, i)
+		}
+		if i == l.selection {
+			// As in AirTimes, an overrunning selection is about to end.
+			if d -= l.Elapsed(); d < 0 {
+				d = 0
+			}
+		}
+		startsIn += d
+	}
+
+	return Projection{
+		Index:     index,
+		Hash:      hash,
+		At:        l.clock.Now().Add(startsIn),
+		StartsIn:  startsIn,
+		Selection: l.selection,
+		Elapsed:   l.Elapsed(),
+	}, nil
+}
+
+// SetDumpAirTimes sets whether dumps of the List include its air times (see AirTimes).
+// They are computed from the List's state at the time of each dump.
+func (l *List) SetDumpAirTimes(on bool) {
+	l.dumpAirTimes = on
+}
+
+// setSelection moves the selection to index i, resetting the elapsed time, and the new item's play mark (see
+// MarkPlayed), if that moves it to a different item.
+func (l *List) setSelection(i int) {
+	if i == l.selection {
+		return
+	}
+	l.selection = i
+	l.restartElapsed()
+	if item := l.ItemWithIndex(i); item != nil {
+		item.played = false
+	}
+}
+
+// broadcastNewSelection sends bcastCb the announcements of a selection change: the new selection, then, if it is
+// playing, its restarted elapsed time.
+func (l *List) broadcastNewSelection(bcastCb controller.ResponseCb) {
+	bcastCb(l.selectResponse())
+	l.broadcastRestartedElapsed(bcastCb)
+}
+
+// broadcastRestartedElapsed sends bcastCb the elapsed time of a new selection, if it kept playing (see
+// restartElapsed), so that clients counting up from the old selection's Started don't go stale.
+func (l *List) broadcastRestartedElapsed(bcastCb controller.ResponseCb) {
+	if l.Playing() {
+		bcastCb(l.elapsedResponse())
+	}
+}
+
+// restartElapsed resets the elapsed time for a new selection, which keeps playing if the old one was, unless there
+// is no new selection.
+func (l *List) restartElapsed() {
+	l.elapsed = 0
+	switch {
+	case l.selection == -1:
+		l.playingSince = time.Time{}
+	case l.Playing():
+		l.playingSince = l.clock.Now()
+	}
+}
@@ -0,0 +1,356 @@
+package list_test
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/UniversityRadioYork/bifrost-go/message"
+
+	"github.com/UniversityRadioYork/baps3d/controller"
+	"github.com/UniversityRadioYork/baps3d/list"
+)
+
+// manualClock is a controller.Clock whose time only moves when the test moves it.
+type manualClock struct {
+	controller.SystemClock
+	now time.Time
+}
+
+func (c *manualClock) Now() time.Time {
+	return c.now
+}
+
+// newTimedList makes a list with tracks a to d, a text item between b and c, and durations on a, b, and d.
+func newTimedList(t *testing.T) *list.List {
+	t.Helper()
+
+	l := list.New()
+	items := []*list.Item{
+		list.NewTrack("a", "a.mp3"),
+		list.NewTrack("b", "b.mp3"),
+		list.NewText("note", "Read the weather"),
+		list.NewTrack("c", "c.mp3"),
+		list.NewTrack("d", "d.mp3"),
+	}
+	for i, item := range items {
+		if err := l.Add(item, i); err != nil {
+			t.Fatalf("couldn't add item %d: %v", i, err)
+		}
+	}
+
+	for i, d := range map[int]time.Duration{0: time.Minute, 1: 2 * time.Minute, 4: 3 * time.Minute} {
+		if err := l.SetDuration(i, l.ItemWithIndex(i).Hash(), d); err != nil {
+			t.Fatalf("couldn't set duration of item %d: %v", i, err)
+		}
+	}
+	return l
+}
+
+// TestList_AirTimes tests that air times accumulate durations from the selection's remaining time, skip text items,
+// and break at the first unknown duration.
+func TestList_AirTimes(t *testing.T) {
+	l := newTimedList(t)
+	if ats := l.AirTimes(); len(ats) != 0 {
+		t.Errorf("got %d air times with no selection, want none", len(ats))
+	}
+
+	if _, err := l.Select(0, "a"); err != nil {
+		t.Fatalf("couldn't select: %v", err)
+	}
+	if err := l.SetElapsed(15 * time.Second); err != nil {
+		t.Fatalf("couldn't set elapsed time: %v", err)
+	}
+
+	want := []list.AirTime{
+		{Index: 1, Hash: "b", StartsIn: 45 * time.Second, Known: true},
+		{Index: 3, Hash: "c", StartsIn: 165 * time.Second, Known: true},
+		// c has no duration, so d's air time can't be known.
+		{Index: 4, Hash: "d", Known: false},
+	}
+	if got := l.AirTimes(); !reflect.DeepEqual(got, want) {
+		t.Errorf("got air times %+v, want %+v", got, want)
+	}
+}
+
+// TestList_AirTimeOf tests projecting a single item's air time, and that it fails where AirTimes would say unknown.
+func TestList_AirTimeOf(t *testing.T) {
+	start := time.Date(2020, 1, 1, 12, 0, 0, 0, time.UTC)
+	l := newTimedList(t)
+	l.SetClock(&stepClock{now: start})
+	if _, err := l.AirTimeOf(1, "b"); err == nil {
+		t.Error("expected an error with no selection")
+	}
+
+	if _, err := l.Select(0, "a"); err != nil {
+		t.Fatalf("couldn't select: %v", err)
+	}
+	if err := l.SetElapsed(15 * time.Second); err != nil {
+		t.Fatalf("couldn't set elapsed time: %v", err)
+	}
+
+	got, err := l.AirTimeOf(3, "c")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	want := list.Projection{
+		Index:     3,
+		Hash:      "c",
+		At:        start.Add(time.Minute + 165*time.Second),
+		StartsIn:  165 * time.Second,
+		Selection: 0,
+		Elapsed:   15 * time.Second,
+	}
+	if got != want {
+		t.Errorf("got projection %+v, want %+v", got, want)
+	}
+
+	if _, err := l.AirTimeOf(4, "d"); !errors.Is(err, list.ErrNoAirTime) {
+		t.Errorf("got error %v past an item with no duration, want ErrNoAirTime", err)
+	}
+	for _, c := range []struct {
+		index int
+		hash  string
+	}{{0, "a"}, {2, "note"}, {3, "d"}} {
+		if _, err := l.AirTimeOf(c.index, c.hash); err == nil {
+			t.Errorf("expected an error projecting item %d (%s)", c.index, c.hash)
+		}
+	}
+}
+
+// TestList_AirTimes_Overrun tests that the item after an overrunning selection is due straight away.
+func TestList_AirTimes_Overrun(t *testing.T) {
+	l := newTimedList(t)
+	if _, err := l.Select(0, "a"); err != nil {
+		t.Fatalf("couldn't select: %v", err)
+	}
+	if err := l.SetElapsed(2 * time.Minute); err != nil {
+		t.Fatalf("couldn't set elapsed time: %v", err)
+	}
+
+	if ats := l.AirTimes(); len(ats) == 0 || !ats[0].Known || ats[0].StartsIn != 0 {
+		t.Errorf("got air times %+v, want the first due now", ats)
+	}
+}
+
+// TestList_SetElapsed_Reset tests that the elapsed time resets when the selection moves, but not when it is
+// re-selected.
+func TestList_SetElapsed_Reset(t *testing.T) {
+	l := newTimedList(t)
+	if err := l.SetElapsed(time.Second); err == nil {
+		t.Error("expected an error setting elapsed time with no selection")
+	}
+
+	if _, err := l.Select(0, "a"); err != nil {
+		t.Fatalf("couldn't select: %v", err)
+	}
+	if err := l.SetElapsed(time.Second); err != nil {
+		t.Fatalf("couldn't set elapsed time: %v", err)
+	}
+
+	if _, err := l.Select(0, "a"); err != nil {
+		t.Fatalf("couldn't re-select: %v", err)
+	}
+	if got := l.Elapsed(); got != time.Second {
+		t.Errorf("after re-selecting: got elapsed time %s, want 1s", got)
+	}
+
+	if _, _, err := l.SelectNext(false); err != nil {
+		t.Fatalf("couldn't select next: %v", err)
+	}
+	if got := l.Elapsed(); got != 0 {
+		t.Errorf("after selecting next: got elapsed time %s, want 0", got)
+	}
+}
+
+// TestList_SetPlaying tests that the elapsed time counts up by the List's clock while playing, freezes while paused,
+// and restarts from 0, still playing, when the selection moves on.
+func TestList_SetPlaying(t *testing.T) {
+	l := newTimedList(t)
+	clk := &manualClock{now: time.Date(2020, 1, 1, 12, 0, 0, 0, time.UTC)}
+	l.SetClock(clk)
+	if _, err := l.SetPlaying(true); err == nil {
+		t.Error("expected an error playing with no selection")
+	}
+
+	if _, err := l.Select(0, "a"); err != nil {
+		t.Fatalf("couldn't select: %v", err)
+	}
+	if changed, err := l.SetPlaying(true); err != nil || !changed {
+		t.Fatalf("couldn't play: got %v, %v", changed, err)
+	}
+	clk.now = clk.now.Add(1500 * time.Millisecond)
+	if got := l.Elapsed(); got != 1500*time.Millisecond {
+		t.Errorf("while playing: got elapsed time %s, want 1.5s", got)
+	}
+
+	if changed, err := l.SetPlaying(false); err != nil || !changed {
+		t.Fatalf("couldn't pause: got %v, %v", changed, err)
+	}
+	clk.now = clk.now.Add(time.Minute)
+	if got := l.Elapsed(); got != 1500*time.Millisecond {
+		t.Errorf("while paused: got elapsed time %s, want 1.5s", got)
+	}
+	if changed, _ := l.SetPlaying(false); changed {
+		t.Error("pausing twice changed something")
+	}
+
+	if _, err := l.SetPlaying(true); err != nil {
+		t.Fatalf("couldn't resume: %v", err)
+	}
+	clk.now = clk.now.Add(time.Second)
+	if got := l.Elapsed(); got != 2500*time.Millisecond {
+		t.Errorf("after resuming: got elapsed time %s, want 2.5s", got)
+	}
+	// Reports from the engine win over the List's own count.
+	if err := l.SetElapsed(10 * time.Second); err != nil {
+		t.Fatalf("couldn't set elapsed time: %v", err)
+	}
+	clk.now = clk.now.Add(time.Second)
+	if got := l.Elapsed(); got != 11*time.Second {
+		t.Errorf("after a report: got elapsed time %s, want 11s", got)
+	}
+
+	if _, _, err := l.SelectNext(false); err != nil {
+		t.Fatalf("couldn't select next: %v", err)
+	}
+	clk.now = clk.now.Add(time.Second)
+	if got := l.Elapsed(); !l.Playing() || got != time.Second {
+		t.Errorf("after selecting next: got elapsed time %s, playing %v, want 1s, playing", got, l.Playing())
+	}
+}
+
+// TestList_Bifrost_Elapsed tests that play state changes broadcast the selection's start time, and that pausing
+// leaves it out.
+func TestList_Bifrost_Elapsed(t *testing.T) {
+	l := newTimedList(t)
+	clk := &manualClock{now: time.Date(2020, 1, 1, 12, 0, 0, 0, time.UTC)}
+	l.SetClock(clk)
+	if _, err := l.Select(0, "a"); err != nil {
+		t.Fatalf("couldn't select: %v", err)
+	}
+	if err := l.SetElapsed(2 * time.Second); err != nil {
+		t.Fatalf("couldn't set elapsed time: %v", err)
+	}
+
+	cases := []struct {
+		word string
+		args []string
+		want []string
+	}{
+		{"playing", []string{"on"}, []string{"0", "a", "2000", "playing", "2020-01-01T11:59:58.000Z"}},
+		{"playing", []string{"off"}, []string{"0", "a", "2000", "paused"}},
+		{"elapsed", nil, []string{"0", "a", "2000", "paused"}},
+	}
+	for _, c := range cases {
+		rq, err := l.ParseBifrostRequest(c.word, c.args)
+		if err != nil {
+			t.Fatalf("%s %v: couldn't parse: %v", c.word, c.args, err)
+		}
+
+		var got []string
+		cb := func(rbody interface{}) {
+			msgs := make(chan message.Message, 1)
+			if err := l.EmitBifrostResponse("!", rbody, msgs); err != nil {
+				t.Fatalf("%s %v: couldn't emit: %v", c.word, c.args, err)
+			}
+			m := <-msgs
+			got = m.Args()
+		}
+		if err := l.HandleRequest(cb, cb, rq); err != nil {
+			t.Fatalf("%s %v: unexpected error: %v", c.word, c.args, err)
+		}
+		if !reflect.DeepEqual(got, c.want) {
+			t.Errorf("%s %v: got %v, want %v", c.word, c.args, got, c.want)
+		}
+	}
+}
+
+// TestList_Bifrost_Elapsed_NewSelection tests that moving a playing selection broadcasts its restarted elapsed time
+// after the new selection, but that moving a paused one doesn't.
+func TestList_Bifrost_Elapsed_NewSelection(t *testing.T) {
+	l := newTimedList(t)
+	clk := &manualClock{now: time.Date(2020, 1, 1, 12, 0, 0, 0, time.UTC)}
+	l.SetClock(clk)
+	if _, err := l.Select(0, "a"); err != nil {
+		t.Fatalf("couldn't select: %v", err)
+	}
+	if _, err := l.SetPlaying(true); err != nil {
+		t.Fatalf("couldn't play: %v", err)
+	}
+	clk.now = clk.now.Add(5 * time.Second)
+
+	var got []interface{}
+	bcastCb := func(rbody interface{}) { got = append(got, rbody) }
+	if err := l.HandleRequest(func(interface{}) {}, bcastCb, list.NextRequest{}); err != nil {
+		t.Fatalf("couldn't select next: %v", err)
+	}
+	want := []interface{}{
+		list.SelectResponse{Index: 1, Hash: "b"},
+		list.ElapsedResponse{Index: 1, Hash: "b", Playing: true, Started: clk.now},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("while playing: got broadcasts %+v, want %+v", got, want)
+	}
+
+	if _, err := l.SetPlaying(false); err != nil {
+		t.Fatalf("couldn't pause: %v", err)
+	}
+	got = nil
+	if err := l.HandleRequest(func(interface{}) {}, bcastCb, list.PreviousRequest{}); err != nil {
+		t.Fatalf("couldn't select previous: %v", err)
+	}
+	if want := []interface{}{list.SelectResponse{Index: 0, Hash: "a"}}; !reflect.DeepEqual(got, want) {
+		t.Errorf("while paused: got broadcasts %+v, want %+v", got, want)
+	}
+}
+
+// TestList_SetDuration_Errors tests that SetDuration checks its index, hash, and duration.
+func TestList_SetDuration_Errors(t *testing.T) {
+	l := newTimedList(t)
+	cases := []struct {
+		name  string
+		index int
+		hash  string
+		d     time.Duration
+	}{
+		{"out of bounds", 5, "x", time.Second},
+		{"hash mismatch", 0, "b", time.Second},
+		{"negative", 0, "a", -time.Second},
+	}
+
+	for _, c := range cases {
+		if err := l.SetDuration(c.index, c.hash, c.d); err == nil {
+			t.Errorf("%s: expected an error", c.name)
+		}
+	}
+}
+
+// TestList_Dump_AirTimes tests that dumps carry known durations, and air times only if asked to.
+func TestList_Dump_AirTimes(t *testing.T) {
+	l := newTimedList(t)
+	if _, err := l.Select(0, "a"); err != nil {
+		t.Fatalf("couldn't select: %v", err)
+	}
+
+	count := func() (durs, ats int) {
+		l.Dump(func(r interface{}) {
+			switch r.(type) {
+			case list.DurationResponse:
+				durs++
+			case list.AirTimeResponse:
+				ats++
+			}
+		})
+		return
+	}
+
+	if durs, ats := count(); durs != 3 || ats != 0 {
+		t.Errorf("default dump: got %d durations and %d air times, want 3 and 0", durs, ats)
+	}
+	l.SetDumpAirTimes(true)
+	if durs, ats := count(); durs != 3 || ats != 3 {
+		t.Errorf("air time dump: got %d durations and %d air times, want 3 and 3", durs, ats)
+	}
+}
@@ -0,0 +1,148 @@
+package list
+
+// File transaction.go contains transactions, with which automation applies several changes to the List as one: either
+// all of them apply, or none do, so that clients never see the List half-way through.
+
+import (
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/UniversityRadioYork/baps3d/controller"
+)
+
+// MaxTransactionLen is the most operations a transaction can hold.
+const MaxTransactionLen = 64
+
+// ErrTransactionFailed is the error given when an operation in a transaction fails, so that none of them apply.
+var ErrTransactionFailed = errors.New("transaction failed")
+
+// OpFailure records an operation that failed in a transaction, and why.
+type OpFailure struct {
+	// Op is the index of the operation in the transaction.
+	Op int
+	// Err is the reason the operation failed.
+	Err error
+}
+
+// Transact applies ops, in order, to l, all at once.
+// Each operation is the body of one of the requests AddItemRequest, InsertRelativeRequest, UpdateItemRequest,
+// SwapItemsRequest, SetSelectRequest, or ReplaceListRequest (without SkipRejected), and sees l as the operations before
+// it left it; so, for example, a ReplaceListRequest with no items clears l for the operations after it to fill.
+// Hash guards check against that state too, and are never lenient (see SetStaleHashes).
+//
+// If any operation fails, Transact puts l back as it was, and fails with ErrTransactionFailed, returning every
+// operation that failed, checked against the state the operations before it (bar failed ones) would have left.
+// It also fails, without trying anything, if there are more than MaxTransactionLen operations.
+func (l *List) Transact(ops []interface{}) ([]OpFailure, error) {
+	if MaxTransactionLen < len(ops) {
+		return nil, fmt.Errorf("Transact: %d operations, max %d", len(ops), MaxTransactionLen)
+	}
+
+	// No change is final until every operation succeeds, so the empty callback only hears about the outcome.
+	emptyCb, wasEmpty := l.emptyCb, l.Count() == 0
+	l.emptyCb = nil
+	defer func() {
+		l.emptyCb = emptyCb
+		l.notifyEmpty(wasEmpty)
+	}()
+
+	before := l.snapshot()
+	var failures []OpFailure
+	for i, op := range ops {
+		if err := l.applyOp(op); err != nil {
+			failures = append(failures, OpFailure{Op: i, Err: err})
+		}
+	}
+
+	if failures != nil {
+		l.restore(before)
+		return failures, fmt.Errorf("%w: %d of %d operations failed", ErrTransactionFailed, len(failures), len(ops))
+	}
+	return nil, nil
+}
+
+// applyOp applies the single transaction operation op to l.
+func (l *List) applyOp(op interface{}) error {
+	switch b := op.(type) {
+	case AddItemRequest:
+		return l.Add(&b.Item, b.Index)
+	case InsertRelativeRequest:
+		return l.Add(&b.Item, l.RelativeIndex(b.Offset))
+	case UpdateItemRequest:
+		return l.Update(b.Index, b.Hash, &b.Item)
+	case SwapItemsRequest:
+		return l.Swap(b.IndexA, b.HashA, b.IndexB, b.HashB)
+	case SetSelectRequest:
+		_, err := l.Select(b.Index, b.Hash)
+		return err
+	case ReplaceListRequest:
+		if b.SkipRejected {
+			return fmt.Errorf("transactions can't skip rejected items")
+		}
+		_, err := l.replace(b.Items, b.Selection, false)
+		return err
+	default:
+		return fmt.Errorf("%T can't be part of a transaction", op)
+	}
+}
+
+// snapshot is a copy of the parts of a List that transaction operations can change.
+type snapshot struct {
+	items        []Item
+	selection    int
+	usedHashes   map[string]struct{}
+	exhausted    bool
+	nextID       uint64
+	elapsed      time.Duration
+	playingSince time.Time
+	superseded   []supersession
+}
+
+// snapshot takes a snapshot of l.
+func (l *List) snapshot() snapshot {
+	used := make(map[string]struct{}, len(l.usedHashes))
+	for h := range l.usedHashes {
+		used[h] = struct{}{}
+	}
+	return snapshot{
+		items:        l.Freeze(),
+		selection:    l.selection,
+		usedHashes:   used,
+		exhausted:    l.exhausted,
+		nextID:       l.nextID,
+		elapsed:      l.elapsed,
+		playingSince: l.playingSince,
+		superseded:   append([]supersession(nil), l.superseded...),
+	}
+}
+
+// restore puts l back as it was when it took s.
+func (l *List) restore(s snapshot) {
+	l.list.Init()
+	for i := range s.items {
+		l.list.PushBack(&s.items[i])
+	}
+	l.selection = s.selection
+	l.usedHashes = s.usedHashes
+	l.exhausted = s.exhausted
+	l.nextID = s.nextID
+	l.elapsed = s.elapsed
+	l.playingSince = s.playingSince
+	l.superseded = s.superseded
+}
+
+// handleTransactionRequest handles a transaction request for List l.
+func (l *List) handleTransactionRequest(replyCb controller.ResponseCb, bcastCb controller.ResponseCb, b TransactionRequest) error {
+	failures, err := l.Transact(b.Ops)
+	for _, f := range failures {
+		replyCb(OpFailedResponse(f))
+	}
+	if err != nil {
+		return err
+	}
+
+	bcastCb(ListReplacedResponse{Items: l.freezeResponse(), Selection: l.selectResponse()})
+	l.broadcastRestartedElapsed(bcastCb)
+	return nil
+}
@@ -0,0 +1,136 @@
+package list_test
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/UniversityRadioYork/baps3d/controller"
+	"github.com/UniversityRadioYork/baps3d/list"
+)
+
+// hashesOf gets the hashes of l's items, in order.
+func hashesOf(l *list.List) []string {
+	var hashes []string
+	for _, item := range l.Freeze() {
+		hashes = append(hashes, item.Hash())
+	}
+	return hashes
+}
+
+// TestList_Transact tests that a transaction's operations each see the ones before them, and that a failure in any
+// of them leaves the List as it was, reporting every failed operation.
+func TestList_Transact(t *testing.T) {
+	l := list.New()
+	for i, h := range []string{"x", "y"} {
+		if err := l.Add(list.NewTrack(h, h+".mp3"), i); err != nil {
+			t.Fatalf("couldn't add %s: %v", h, err)
+		}
+	}
+	if _, err := l.Select(0, "x"); err != nil {
+		t.Fatalf("couldn't select: %v", err)
+	}
+
+	bad := []interface{}{
+		list.ReplaceListRequest{Selection: -1},
+		list.AddItemRequest{Index: 0, Item: *list.NewTrack("a", "a.mp3")},
+		// There is nothing at index 1 yet.
+		list.SetSelectRequest{Index: 1, Hash: "a"},
+		list.AddItemRequest{Index: 1, Item: *list.NewTrack("a", "a.mp3")},
+	}
+	failures, err := l.Transact(bad)
+	if !errors.Is(err, list.ErrTransactionFailed) {
+		t.Fatalf("got error %v, want ErrTransactionFailed", err)
+	}
+	var ops []int
+	for _, f := range failures {
+		ops = append(ops, f.Op)
+	}
+	if want := []int{2, 3}; !reflect.DeepEqual(ops, want) {
+		t.Errorf("got failed operations %v, want %v", ops, want)
+	}
+	if got, want := hashesOf(l), []string{"x", "y"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("after failing: got items %v, want %v", got, want)
+	}
+	if sel, _ := l.Selection(); sel != 0 {
+		t.Errorf("after failing: got selection %d, want 0", sel)
+	}
+
+	good := []interface{}{
+		list.ReplaceListRequest{Selection: -1},
+		list.AddItemRequest{Index: 0, Item: *list.NewTrack("a", "a.mp3")},
+		list.AddItemRequest{Index: 1, Item: *list.NewTrack("b", "b.mp3")},
+		list.SetSelectRequest{Index: 1, Hash: "b"},
+	}
+	if _, err := l.Transact(good); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if got, want := hashesOf(l), []string{"a", "b"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("got items %v, want %v", got, want)
+	}
+	if sel, _ := l.Selection(); sel != 1 {
+		t.Errorf("got selection %d, want 1", sel)
+	}
+
+	if _, err := l.Transact(make([]interface{}, list.MaxTransactionLen+1)); err == nil {
+		t.Error("expected an error for an oversized transaction")
+	}
+}
+
+// TestList_Bifrost_Tx tests that a 'tx' message splits into its operations, with 'clear' emptying the list.
+func TestList_Bifrost_Tx(t *testing.T) {
+	l := list.New()
+	rq, err := l.ParseBifrostRequest("tx", []string{"clear", ";", "floadl", "0", "a", "a.mp3", ";", "sel", "0", "a"})
+	if err != nil {
+		t.Fatalf("couldn't parse: %v", err)
+	}
+	want := list.TransactionRequest{Ops: []interface{}{
+		list.ReplaceListRequest{Selection: -1},
+		list.AddItemRequest{Index: 0, Item: *list.NewTrack("a", "a.mp3")},
+		list.SetSelectRequest{Index: 0, Hash: "a"},
+	}}
+	if !reflect.DeepEqual(rq, want) {
+		t.Errorf("got %+v, want %+v", rq, want)
+	}
+
+	for _, args := range [][]string{{}, {"sel", "0", "a", ";"}, {"advance"}} {
+		if _, err := l.ParseBifrostRequest("tx", args); err == nil {
+			t.Errorf("%v: expected an error", args)
+		}
+	}
+}
+
+// TestClient_Transact tests that a Client hears about each failed operation, and that a successful transaction
+// makes one broadcast.
+func TestClient_Transact(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	ctl, root := controller.NewController(list.New())
+	go ctl.Run(ctx)
+
+	bcasts := make(chan interface{}, 16)
+	c := list.NewClient(root, func(r controller.Response) {
+		bcasts <- r.Body
+	})
+
+	failures, err := c.Transact(ctx,
+		list.AddItemRequest{Index: 0, Item: *list.NewTrack("a", "a.mp3")},
+		list.SetSelectRequest{Index: 0, Hash: "b"},
+	)
+	if err == nil || len(failures) != 1 || failures[0].Op != 1 {
+		t.Fatalf("got failures %v and error %v, want operation 1 to fail", failures, err)
+	}
+
+	if _, err := c.Transact(ctx, list.AddItemRequest{Index: 0, Item: *list.NewTrack("a", "a.mp3")}); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if r, ok := (<-bcasts).(list.ListReplacedResponse); !ok || len(r.Items) != 1 {
+		t.Errorf("got broadcast %v, want the new list", r)
+	}
+	if err := c.Shutdown(ctx); err != nil {
+		t.Errorf("couldn't shut down: %v", err)
+	}
+}
@@ -0,0 +1,66 @@
+package list
+
+// File transform.go contains Transform, the hook through which stations can normalise what goes into a List
+// centrally, rather than in every client.
+
+import (
+	"strings"
+	"unicode"
+)
+
+// Transform rewrites the payload of an item of type itype going into a List into its canonical form, for example by
+// fixing its casing.
+// It must be pure and deterministic, so that the same payload always ends up stored the same way.
+// Transforms only see textual payloads: binary items go in as they are.
+type Transform func(itype ItemType, payload string) string
+
+// SetTransforms sets the Transforms l runs, in order, on the payload of each item that Add, Update, or Replace would
+// put into it.
+// They run first, before any Validators (see SetValidators), so these judge items in their canonical form, and what l
+// stores, broadcasts and dumps is that form; Add and Update rewrite the item they are given in place.
+// The List never derives hashes from payloads (clients give them), so Transforms leave each item's hash, and with it
+// the hash guards of later requests, alone.
+// It should be called before l goes into a Controller; with no Transforms, which is the default, l stores payloads as
+// given.
+func (l *List) SetTransforms(ts ...Transform) {
+	l.transforms = ts
+}
+
+// transform runs l's Transforms on item, in place.
+func (l *List) transform(item *Item) {
+	if item.IsBinary() {
+		return
+	}
+	for _, t := range l.transforms {
+		item.payload = t(item.itype, item.payload)
+	}
+}
+
+// transformAll gets items with l's Transforms run on each of them, leaving items itself untouched.
+func (l *List) transformAll(items []Item) []Item {
+	if len(l.transforms) == 0 {
+		return items
+	}
+
+	out := append([]Item(nil), items...)
+	for i := range out {
+		l.transform(&out[i])
+	}
+	return out
+}
+
+// Normalize is a Transform that strips control characters from payloads, collapses each run of whitespace in them
+// into one space, and trims whitespace from either end.
+func Normalize(_ ItemType, payload string) string {
+	clean := strings.Map(func(r rune) rune {
+		switch {
+		case unicode.IsSpace(r):
+			return ' '
+		case unicode.IsControl(r):
+			return -1
+		default:
+			return r
+		}
+	}, payload)
+	return strings.Join(strings.Fields(clean), " ")
+}
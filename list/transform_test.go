@@ -0,0 +1,66 @@
+package list_test
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/UniversityRadioYork/baps3d/list"
+)
+
+// TestNormalize tests that Normalize strips control characters and tidies whitespace.
+func TestNormalize(t *testing.T) {
+	cases := map[string]string{
+		"  Artist - Title  ":     "Artist - Title",
+		"Artist\t-\n\nTitle":     "Artist - Title",
+		"Ar\x00tist\x1b - Title": "Artist - Title",
+		"":                       "",
+	}
+	for in, want := range cases {
+		if got := list.Normalize(list.ItemTrack, in); got != want {
+			t.Errorf("Normalize(%q): got %q, want %q", in, got, want)
+		}
+	}
+}
+
+// TestList_SetTransforms tests that Transforms run, in order, before Validators on everything going into the List,
+// leaving hashes and binary items alone.
+func TestList_SetTransforms(t *testing.T) {
+	l := list.New()
+	l.SetTransforms(list.Normalize, func(_ list.ItemType, payload string) string { return strings.ToUpper(payload) })
+	var validated []string
+	l.SetValidators(func(items []list.Item, index int) error {
+		validated = append(validated, items[index].Payload())
+		return nil
+	})
+
+	item := list.NewTrack(" abc ", "  some  song ")
+	if err := l.Add(item, 0); err != nil {
+		t.Fatalf("couldn't add: %v", err)
+	}
+	if got := l.ItemWithIndex(0); got.Payload() != "SOME SONG" || got.Hash() != " abc " {
+		t.Errorf("after adding: got %q with hash %q, want %q with hash %q", got.Payload(), got.Hash(), "SOME SONG",
+			" abc ")
+	}
+	if item.Payload() != "SOME SONG" {
+		t.Errorf("Add didn't rewrite its item: got %q", item.Payload())
+	}
+
+	if err := l.Update(0, " abc ", list.NewTrack("def", "other\tsong")); err != nil {
+		t.Fatalf("couldn't update: %v", err)
+	}
+	items := []list.Item{*list.NewText("ghi", "say  hi"), *list.NewBinaryItem(list.ItemTrack, "jkl", []byte(" x "))}
+	if err := l.Replace(items, -1); err != nil {
+		t.Fatalf("couldn't replace: %v", err)
+	}
+	if items[0].Payload() != "say  hi" {
+		t.Errorf("Replace changed its argument: got %q", items[0].Payload())
+	}
+
+	want := []string{"SOME SONG", "OTHER SONG", "SAY HI", ""}
+	if strings.Join(validated, "|") != strings.Join(want, "|") {
+		t.Errorf("validators saw %q, want %q", validated, want)
+	}
+	if got := l.ItemWithIndex(1).Data(); string(got) != " x " {
+		t.Errorf("binary item changed: got %q", got)
+	}
+}
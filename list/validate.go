@@ -0,0 +1,80 @@
+package list
+
+// File validate.go contains Validator, the hook through which stations can enforce their own rules about what goes
+// into a List.
+
+import "fmt"
+
+// Validator checks a change to a List against some content rule, such as 'no two sweepers back to back', before the
+// List makes the change.
+// It gets the items as the change would leave them, which it must not modify, and the index of the item the change
+// adds or replaces.
+// It returns an error, giving the reason, if the change breaks the rule.
+type Validator func(items []Item, index int) error
+
+// SetValidators sets the Validators l runs, in order, on each item that Add, Update, or Replace would put into it,
+// and on both items Swap would move.
+// The first Validator to reject an item fails the change, with an error wrapping the Validator's, and leaves l
+// untouched.
+// It should be called before l goes into a Controller; with no Validators, which is the default, l accepts anything.
+func (l *List) SetValidators(vs ...Validator) {
+	l.validators = vs
+}
+
+// validate runs l's Validators, for the operation op, on the items after would make, with the change at index.
+// after is only called if l has Validators.
+func (l *List) validate(op string, after func() []Item, index int) error {
+	if len(l.validators) == 0 {
+		return nil
+	}
+
+	if err := l.rejection(after(), index); err != nil {
+		return fmt.Errorf("%s: item %d rejected: %w", op, index, err)
+	}
+	return nil
+}
+
+// rejection runs l's Validators on items, as a change would leave them, with the change at index.
+// It returns the error of the first Validator to reject the item, or nil if none do.
+func (l *List) rejection(items []Item, index int) error {
+	for _, v := range l.validators {
+		if err := v(items, index); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// validateAll runs l's Validators on every one of items, as Replace would leave them, stopping at the first failure.
+func (l *List) validateAll(op string, items []Item) error {
+	after := func() []Item { return items }
+	for i := range items {
+		if err := l.validate(op, after, i); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// withInserted gets a copy of l's items with item inserted at index i, which must be in bounds.
+func (l *List) withInserted(item *Item, i int) []Item {
+	frozen := l.Freeze()
+	items := make([]Item, 0, len(frozen)+1)
+	items = append(items, frozen[:i]...)
+	items = append(items, *item)
+	return append(items, frozen[i:]...)
+}
+
+// withSwapped gets a copy of l's items with the items at indices i and j, which must be in bounds, swapped.
+func (l *List) withSwapped(i, j int) []Item {
+	items := l.Freeze()
+	items[i], items[j] = items[j], items[i]
+	return items
+}
+
+// withReplaced gets a copy of l's items with the item at index i, which must be in bounds, replaced by item.
+func (l *List) withReplaced(item *Item, i int) []Item {
+	items := l.Freeze()
+	items[i] = *item
+	return items
+}
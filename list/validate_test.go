@@ -0,0 +1,131 @@
+package list_test
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+
+	"github.com/UniversityRadioYork/bifrost-go/message"
+
+	"github.com/UniversityRadioYork/baps3d/list"
+)
+
+// errBackToBack is the error noTextBackToBack rejects items with.
+var errBackToBack = errors.New("two text items back to back")
+
+// noTextBackToBack is a sample Validator, rejecting text items next to other text items.
+func noTextBackToBack(items []list.Item, index int) error {
+	if items[index].Type() != list.ItemText {
+		return nil
+	}
+	for _, j := range []int{index - 1, index + 1} {
+		if 0 <= j && j < len(items) && items[j].Type() == list.ItemText {
+			return errBackToBack
+		}
+	}
+	return nil
+}
+
+// TestList_Validators tests that Validators run in order on adds and updates, and that the first rejection fails
+// the request, and leaves the List untouched.
+func TestList_Validators(t *testing.T) {
+	l := list.New()
+	var calls int
+	counter := func([]list.Item, int) error {
+		calls++
+		return nil
+	}
+	l.SetValidators(noTextBackToBack, counter)
+
+	var bcasts []interface{}
+	bcastCb := func(r interface{}) { bcasts = append(bcasts, r) }
+	handle := func(rbody interface{}) error {
+		return l.HandleRequest(func(interface{}) {}, bcastCb, rbody)
+	}
+
+	for i, item := range []*list.Item{list.NewTrack("a", "a.mp3"), list.NewText("b", "b"), list.NewTrack("c", "c.mp3")} {
+		if err := handle(list.AddItemRequest{Index: i, Item: *item}); err != nil {
+			t.Fatalf("adding %s: unexpected error: %v", item.Hash(), err)
+		}
+	}
+	if calls != 3 {
+		t.Errorf("got %d calls to the later validator, want 3", calls)
+	}
+
+	bcasts = nil
+	calls = 0
+	rejects := []interface{}{
+		list.AddItemRequest{Index: 2, Item: *list.NewText("d", "d")},
+		list.UpdateItemRequest{Index: 0, Hash: "a", Item: *list.NewText("a2", "a2")},
+		list.ReplaceListRequest{Items: []list.Item{*list.NewText("x", "x"), *list.NewText("y", "y")}, Selection: -1},
+	}
+	for _, rq := range rejects {
+		if err := handle(rq); !errors.Is(err, errBackToBack) {
+			t.Errorf("%T: got %v, want a rejection", rq, err)
+		}
+	}
+	if calls != 0 {
+		t.Errorf("later validator called %d times after rejections, want 0", calls)
+	}
+	if len(bcasts) != 0 {
+		t.Errorf("rejected changes broadcast %v", bcasts)
+	}
+
+	var hashes string
+	for _, item := range l.Freeze() {
+		hashes += item.Hash()
+	}
+	if hashes != "abc" {
+		t.Errorf("list after rejections holds %q, want \"abc\"", hashes)
+	}
+}
+
+// TestList_ReplaceSkipping tests that a skipping replace leaves out rejected and duplicate items, keeps the selection
+// on its item, and replies with a summary of what it left out.
+func TestList_ReplaceSkipping(t *testing.T) {
+	l := list.New()
+	l.SetValidators(noTextBackToBack)
+
+	items := []list.Item{
+		*list.NewTrack("a", "a.mp3"),
+		*list.NewText("b", "b"),
+		*list.NewText("c", "c"),
+		*list.NewTrack("c", "c.mp3"),
+		*list.NewTrack("d", "d.mp3"),
+	}
+	msgs := make(chan message.Message, 8)
+	replyCb := func(r interface{}) {
+		if err := l.EmitBifrostResponse("t", r, msgs); err != nil {
+			t.Fatalf("unexpected emit error: %v", err)
+		}
+	}
+	rq := list.ReplaceListRequest{Items: items, Selection: 4, SkipRejected: true}
+	if err := l.HandleRequest(replyCb, func(interface{}) {}, rq); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	close(msgs)
+
+	var got []string
+	for m := range msgs {
+		got = append(got, m.String())
+	}
+	want := []string{
+		"t SUMMARY 5 3 2\n",
+		"t REJECTED 1 b '" + errBackToBack.Error() + "'\n",
+		"t REJECTED 3 c 'duplicate hash c'\n",
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("got replies %q, want %q", got, want)
+	}
+	if got, want := hashes(l), []string{"a", "c", "d"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("got items %q, want %q", got, want)
+	}
+	if sel, _ := l.Selection(); sel != 2 {
+		t.Errorf("got selection %d, want 2, following d", sel)
+	}
+
+	sum, err := l.ReplaceSkipping(items[1:3], -1)
+	if err != nil || sum.Requested != 2 || sum.Applied != 1 || len(sum.Rejected) != 1 {
+		t.Errorf("got %+v, %v; want one of two items applied", sum, err)
+	}
+}
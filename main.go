@@ -2,12 +2,17 @@ package main
 
 import (
 	"context"
+	"crypto/tls"
+	"crypto/x509"
 	"fmt"
 	"io"
 	"io/ioutil"
 	"log"
+	"net"
 	"os"
 	"os/signal"
+	"syscall"
+	"time"
 
 	"github.com/UniversityRadioYork/baps3d/config"
 	"golang.org/x/sync/errgroup"
@@ -29,15 +34,248 @@ func makeLog(section string, enabled bool) *log.Logger {
 	return log.New(lw, "["+section+"] ", log.LstdFlags)
 }
 
-func runNet(ctx context.Context, rootClient *controller.Client, ncfg config.Net) error {
-	netClient, err := rootClient.Copy(ctx)
+// namedRoot is a list controller's root client, along with the configuration of its list.
+type namedRoot struct {
+	conf   config.List
+	client *controller.Client
+}
+
+func runNet(ctx context.Context, roots []namedRoot, ncfg config.Net) error {
+	channels := make([]netsrv.Channel, len(roots))
+	policies := make(map[string]netsrv.SendPolicy, len(roots))
+	outRates := make(map[string]int, len(roots))
+	budgets := make(map[string]int64, len(roots))
+	sequenced := make(map[string]bool, len(roots))
+	marked := make(map[string]bool, len(roots))
+	compressed := make(map[string]bool, len(roots))
+	compressedInput := make(map[string]bool, len(roots))
+	selectionOnly := make(map[string][]net.IP, len(roots))
+	playStateOnly := make(map[string][]net.IP, len(roots))
+	commandOnly := make(map[string][]net.IP, len(roots))
+	encodings := make(map[string]netsrv.Encoding, len(roots))
+	for i, r := range roots {
+		policy, err := netsrv.ParseSendPolicy(r.conf.SendPolicy)
+		if err != nil {
+			return fmt.Errorf("list %q: %w", r.conf.Name, err)
+		}
+		policies[r.conf.Name] = policy
+		outRates[r.conf.Name] = r.conf.OutputRate
+		budgets[r.conf.Name] = r.conf.ByteBudget
+		if encodings[r.conf.Name], err = netsrv.ParseEncoding(r.conf.Encoding); err != nil {
+			return fmt.Errorf("list %q: %w", r.conf.Name, err)
+		}
+		sequenced[r.conf.Name] = r.conf.Sequence
+		marked[r.conf.Name] = r.conf.MarkOwn
+		compressed[r.conf.Name] = r.conf.CompressDumps
+		compressedInput[r.conf.Name] = r.conf.CompressedInput
+		if selectionOnly[r.conf.Name], err = parseIPs("selection-only", r.conf.SelectionOnly); err != nil {
+			return fmt.Errorf("list %q: %w", r.conf.Name, err)
+		}
+		if playStateOnly[r.conf.Name], err = parseIPs("play-state-only", r.conf.PlayStateOnly); err != nil {
+			return fmt.Errorf("list %q: %w", r.conf.Name, err)
+		}
+		if commandOnly[r.conf.Name], err = parseIPs("command-only", r.conf.CommandOnly); err != nil {
+			return fmt.Errorf("list %q: %w", r.conf.Name, err)
+		}
+
+		netClient, err := r.client.Copy(ctx)
+		if err != nil {
+			return err
+		}
+
+		host := r.conf.Host
+		if host == "" {
+			host = ncfg.Host
+		}
+		channels[i] = netsrv.Channel{Name: r.conf.Name, Host: host, Network: ncfg.Network, Root: netClient}
+	}
+
+	netLog := makeLog("net", ncfg.Log)
+	netSrv := netsrv.NewMulti(netLog, channels)
+	netSrv.SocketActivation = ncfg.SocketActivation
+	netSrv.MaxWordLen = ncfg.MaxWordLen
+	netSrv.MaxClients = ncfg.MaxClients
+	netSrv.KeepAlive = time.Duration(ncfg.KeepAliveSecs) * time.Second
+	netSrv.HandshakeTimeout = time.Duration(ncfg.HandshakeTimeoutSecs) * time.Second
+	netSrv.IdleTimeout = time.Duration(ncfg.IdleTimeoutSecs) * time.Second
+	netSrv.Linger = time.Duration(ncfg.LingerMillis) * time.Millisecond
+	netSrv.WriteTimeout = time.Duration(ncfg.WriteTimeoutMillis) * time.Millisecond
+	netSrv.WriteRetries = ncfg.WriteRetries
+	netSrv.WriteRetryDelay = time.Duration(ncfg.WriteRetryDelayMillis) * time.Millisecond
+	netSrv.Input = netsrv.InputPolicy{Strict: ncfg.StrictInput, AllowTabs: ncfg.AllowTabs}
+	netSrv.SendBuffer = ncfg.SendBuffer
+	netSrv.BatchInput = ncfg.BatchInput
+	netSrv.ErrorHistory = ncfg.ErrorHistory
+	netSrv.MaxInflatedInput = ncfg.MaxInflatedInput
+	netSrv.RequestRate = ncfg.RequestRate
+	netSrv.RequestBurst = ncfg.RequestBurst
+	tc, err := tlsConfig(ncfg)
 	if err != nil {
 		return err
 	}
+	netSrv.TLSConfig = tc
+	netSrv.SendPolicy = func(channel string, _ net.Addr) netsrv.SendPolicy {
+		return policies[channel]
+	}
+	netSrv.OutputRate = func(channel string, _ net.Addr) int {
+		return outRates[channel]
+	}
+	netSrv.ByteBudget = func(channel string, _ net.Addr) int64 {
+		return budgets[channel]
+	}
+	netSrv.Sequence = func(channel string, _ net.Addr) bool {
+		return sequenced[channel]
+	}
+	netSrv.MarkOwn = func(channel string, _ net.Addr) bool {
+		return marked[channel]
+	}
+	netSrv.CompressDumps = func(channel string, _ net.Addr) bool {
+		return compressed[channel]
+	}
+	netSrv.CompressedInput = func(channel string, _ net.Addr) bool {
+		return compressedInput[channel]
+	}
+	netSrv.SelectionOnly = func(channel string, addr net.Addr) bool {
+		return containsIP(selectionOnly[channel], netsrv.RemoteIP(addr))
+	}
+	netSrv.PlayStateOnly = func(channel string, addr net.Addr) bool {
+		return containsIP(playStateOnly[channel], netsrv.RemoteIP(addr))
+	}
+	netSrv.CommandOnly = func(channel string, addr net.Addr) bool {
+		return containsIP(commandOnly[channel], netsrv.RemoteIP(addr))
+	}
+	netSrv.Encoding = func(channel string, _ net.Addr) netsrv.Encoding {
+		return encodings[channel]
+	}
+	return netSrv.Run(ctx)
+}
 
-	netLog := makeLog("net", ncfg.Log)
-	netSrv := netsrv.New(netLog, ncfg.Host, netClient)
-	netSrv.Run(ctx)
+// parseIPs parses the IP addresses addrs, given in the config field described by field.
+func parseIPs(field string, addrs []string) ([]net.IP, error) {
+	var ips []net.IP
+	for _, a := range addrs {
+		ip := net.ParseIP(a)
+		if ip == nil {
+			return nil, fmt.Errorf("bad %s address %q", field, a)
+		}
+		ips = append(ips, ip)
+	}
+	return ips, nil
+}
+
+// tlsConfig makes the net server's TLS configuration from ncfg, or returns nil if ncfg doesn't ask for TLS.
+func tlsConfig(ncfg config.Net) (*tls.Config, error) {
+	if ncfg.TLSCertFile == "" && ncfg.TLSKeyFile == "" {
+		return nil, nil
+	}
+	cert, err := tls.LoadX509KeyPair(ncfg.TLSCertFile, ncfg.TLSKeyFile)
+	if err != nil {
+		return nil, fmt.Errorf("couldn't load TLS certificate: %w", err)
+	}
+	tc := &tls.Config{Certificates: []tls.Certificate{cert}}
+
+	if ncfg.TLSClientCAFile == "" {
+		return tc, nil
+	}
+	pem, err := ioutil.ReadFile(ncfg.TLSClientCAFile)
+	if err != nil {
+		return nil, fmt.Errorf("couldn't read TLS client CAs: %w", err)
+	}
+	tc.ClientCAs = x509.NewCertPool()
+	if !tc.ClientCAs.AppendCertsFromPEM(pem) {
+		return nil, fmt.Errorf("no certificates in TLS client CA file %s", ncfg.TLSClientCAFile)
+	}
+	tc.ClientAuth = tls.RequireAndVerifyClientCert
+	return tc, nil
+}
+
+// containsIP gets whether ip is one of ips.
+func containsIP(ips []net.IP, ip net.IP) bool {
+	for _, i := range ips {
+		if i.Equal(ip) {
+			return true
+		}
+	}
+	return false
+}
+
+// checkLists checks that lists can be served side by side.
+func checkLists(lists []config.List) error {
+	if len(lists) == 0 {
+		return fmt.Errorf("must have at least one configured list")
+	}
+
+	names := make(map[string]struct{}, len(lists))
+	hosts := make(map[string]struct{}, len(lists))
+	for _, l := range lists {
+		if _, ok := names[l.Name]; ok {
+			return fmt.Errorf("duplicate list name %q", l.Name)
+		}
+		names[l.Name] = struct{}{}
+
+		if l.Host == "" && 1 < len(lists) {
+			return fmt.Errorf("list %q needs a host, as there is more than one list", l.Name)
+		}
+		if _, ok := hosts[l.Host]; ok {
+			return fmt.Errorf("duplicate list host %q", l.Host)
+		}
+		hosts[l.Host] = struct{}{}
+	}
+	return nil
+}
+
+// listSettings gets the settings, out of lconf, that a list can take while it runs.
+func listSettings(lconf config.List) list.Settings {
+	plays := lconf.PlayHistory
+	switch {
+	case plays == 0:
+		plays = list.DefaultPlayHistory
+	case plays < 0:
+		plays = 0
+	}
+	return list.Settings{
+		DumpAirTimes:    lconf.DumpAirTimes,
+		StaleHashes:     lconf.StaleHashes,
+		PlayHistory:     plays,
+		AdvanceOnPlayed: lconf.AdvanceOnPlayed,
+		SaveFile:        lconf.File,
+	}
+}
+
+// loadList creates the list described by lconf, loading its saved file if it has one.
+func loadList(lconf config.List) (*list.List, error) {
+	lst := list.New()
+	if lconf.Normalize {
+		lst.SetTransforms(list.Normalize)
+	}
+	if err := lst.Reload(listSettings(lconf)); err != nil {
+		return nil, err
+	}
+	if lconf.File == "" {
+		return lst, nil
+	}
+
+	// The list is empty, so reverting to the saved file just loads it.
+	if _, err := lst.Revert(); err != nil {
+		return nil, err
+	}
+	return lst, nil
+}
+
+// setDeadman sets up the deadman switch, if lconf has one, of con, whose state is lst.
+func setDeadman(con *controller.Controller, lst *list.List, lconf config.List) error {
+	if lconf.DeadmanSecs <= 0 {
+		return nil
+	}
+
+	var action interface{}
+	if 0 < len(lconf.DeadmanAction) {
+		var err error
+		if action, err = lst.ParseBifrostRequest(lconf.DeadmanAction[0], lconf.DeadmanAction[1:]); err != nil {
+			return err
+		}
+	}
+	con.SetDeadman(time.Duration(lconf.DeadmanSecs)*time.Second, action)
 	return nil
 }
 
@@ -59,8 +297,7 @@ func main() {
 
 	rootLog := makeLog("root", true)
 
-	cfile := "baps3d.toml"
-	conf, err := config.Parse(cfile)
+	conf, err := config.Parse(configFile)
 	if err != nil {
 		rootLog.Printf("couldn't open config: %v\n", err)
 		return
@@ -68,26 +305,41 @@ func main() {
 
 	interrupt := make(chan os.Signal, 1)
 	signal.Notify(interrupt, os.Interrupt)
+	hangup := make(chan os.Signal, 1)
+	signal.Notify(hangup, syscall.SIGHUP)
 
 	var errg errgroup.Group
 
-	if len(conf.Lists) != 1 {
-		rootLog.Printf("FIXME: must have precisely one configured list, got %d\n", len(conf.Lists))
+	if err := checkLists(conf.Lists); err != nil {
+		rootLog.Printf("bad list config: %v\n", err)
 		return
 	}
-	// lstConf := conf.Lists[0]
 
-	lst := list.New()
-	lstCon, rootClient := controller.NewController(lst)
-	errg.Go(func() error {
-		lstCon.Run(ctx)
-		rootLog.Println("list controller closing")
-		return nil
-	})
+	roots := make([]namedRoot, len(conf.Lists))
+	for i, lstConf := range conf.Lists {
+		lst, err := loadList(lstConf)
+		if err != nil {
+			rootLog.Printf("couldn't load list %q: %v\n", lstConf.Name, err)
+			return
+		}
+		lstCon, rootClient := controller.NewController(lst)
+		lstCon.SetDumpLimit(lstConf.DumpLimit)
+		if err := setDeadman(lstCon, lst, lstConf); err != nil {
+			rootLog.Printf("bad deadman config for list %q: %v\n", lstConf.Name, err)
+			return
+		}
+		name := lstConf.Name
+		errg.Go(func() error {
+			lstCon.Run(ctx)
+			rootLog.Printf("list controller %q closing\n", name)
+			return nil
+		})
+		roots[i] = namedRoot{conf: lstConf, client: rootClient}
+	}
 
 	if conf.Net.Enabled {
 		errg.Go(func() error {
-			err := runNet(ctx, rootClient, conf.Net)
+			err := runNet(ctx, roots, conf.Net)
 			if err != nil {
 				err = fmt.Errorf("netsrv error: %w", err)
 			}
@@ -96,9 +348,10 @@ func main() {
 		})
 	}
 
+	// TODO(@MattWindsor91): let the console switch between lists.
 	if conf.Console.Enabled {
 		errg.Go(func() error {
-			err := runConsole(ctx, rootClient, conf.Console)
+			err := runConsole(ctx, roots[0].client, conf.Console)
 			if err != nil {
 				err = fmt.Errorf("console error: %w", err)
 			}
@@ -107,7 +360,7 @@ func main() {
 		})
 	}
 
-	mainLoop(rootClient, interrupt, ctx, rootLog)
+	mainLoop(roots, interrupt, hangup, ctx, rootLog)
 	cancel()
 
 	rootLog.Println("Waiting for subsystems to shut down...")
@@ -117,18 +370,91 @@ func main() {
 	rootLog.Println("It's now safe to turn off your baps3d.")
 }
 
-func mainLoop(rootClient *controller.Client, interrupt chan os.Signal, ctx context.Context, rootLog *log.Logger) {
-	running := true
-	for running {
+// configFile is the path of the configuration file.
+const configFile = "baps3d.toml"
+
+// shutdownTimeout is the amount of time mainLoop waits for each controller to shut down.
+const shutdownTimeout = 5 * time.Second
+
+// reloadTimeout is the amount of time reloadLists waits for each controller to reload.
+const reloadTimeout = 5 * time.Second
+
+// reloadLists re-reads the configuration file, and reloads each list's settings in place from it.
+// Clients stay connected, and get told to resync.
+// Only the list settings in list.Settings reload: anything else, including lists added or removed since startup and
+// all of the net server's configuration, needs a restart.
+func reloadLists(ctx context.Context, roots []namedRoot, rootLog *log.Logger) {
+	conf, err := config.Parse(configFile)
+	if err != nil {
+		rootLog.Printf("couldn't reload config: %v\n", err)
+		return
+	}
+	lconfs := make(map[string]config.List, len(conf.Lists))
+	for _, l := range conf.Lists {
+		lconfs[l.Name] = l
+	}
+
+	rctx, cancel := context.WithTimeout(ctx, reloadTimeout)
+	defer cancel()
+	for _, r := range roots {
+		lconf, ok := lconfs[r.conf.Name]
+		if !ok {
+			rootLog.Printf("list %q is no longer configured; restart to remove it\n", r.conf.Name)
+			continue
+		}
+
+		cb := func(controller.Response) error {
+			return fmt.Errorf("got an unexpected response")
+		}
+		rq := controller.ReloadRequest{Settings: listSettings(lconf)}
+		if alive, err := r.client.SendAndProcessReplies(rctx, "", rq, cb); !alive {
+			rootLog.Printf("couldn't reload %q: controller not responding\n", r.conf.Name)
+		} else if err != nil {
+			rootLog.Printf("couldn't reload %q: %s\n", r.conf.Name, err)
+		} else {
+			rootLog.Printf("reloaded %q\n", r.conf.Name)
+		}
+	}
+}
+
+func mainLoop(roots []namedRoot, interrupt, hangup chan os.Signal, ctx context.Context, rootLog *log.Logger) {
+	// Accept, but ignore, all messages from the root clients.
+	// Start closing baps3d once any of them has closed.
+	anyClosed := make(chan struct{}, len(roots))
+	closed := make([]chan struct{}, len(roots))
+	for i, r := range roots {
+		closed[i] = make(chan struct{})
+		go func(c *controller.Client, done chan<- struct{}) {
+			for range c.Rx {
+			}
+			close(done)
+			anyClosed <- struct{}{}
+		}(r.client, closed[i])
+	}
+
+	for running := true; running; {
 		select {
-		case _, running = <-rootClient.Rx:
-			// Accept, but ignore, all messages from the root client.
-			// Start closing baps3d if the client has closed.
+		case <-anyClosed:
+			running = false
 		case <-interrupt:
 			// Ctrl-C, so gracefully shut down.
-			if err := rootClient.Shutdown(ctx); err != nil {
-				rootLog.Println("couldn't shut down gracefully:", err)
-			}
+			running = false
+		case <-hangup:
+			reloadLists(ctx, roots, rootLog)
+		}
+	}
+
+	sctx, cancel := context.WithTimeout(ctx, shutdownTimeout)
+	defer cancel()
+	for i, r := range roots {
+		select {
+		case <-closed[i]:
+			// Already shut down, and nobody would receive the request.
+			continue
+		default:
+		}
+		if err := r.client.Shutdown(sctx); err != nil {
+			rootLog.Printf("couldn't shut down %q gracefully: %s\n", r.conf.Name, err)
 		}
 	}
 }
@@ -0,0 +1,72 @@
+package netsrv
+
+// File activation.go contains socket activation: adopting listeners that a service manager, such as systemd, opened
+// before starting baps3d, rather than opening them itself.
+
+import (
+	"fmt"
+	"log"
+	"net"
+	"os"
+	"strconv"
+)
+
+const (
+	// ListenFDsStart is the first file descriptor on which a socket-activated Server expects a listener.
+	ListenFDsStart = 3
+
+	// ListenPIDEnv is the environment variable holding the ID of the process that inherited listeners are meant for.
+	ListenPIDEnv = "LISTEN_PID"
+
+	// ListenFDsEnv is the environment variable holding the number of inherited listeners.
+	ListenFDsEnv = "LISTEN_FDS"
+
+	// listenFDNamesEnv is the environment variable in which systemd names inherited listeners.
+	// The Server doesn't use the names, but clears them along with the other variables.
+	listenFDNamesEnv = "LISTEN_FDNAMES"
+)
+
+// activatedListeners adopts this process's n inherited listeners, as described at Server.SocketActivation.
+// Whether or not it succeeds, it clears the environment variables describing them, as systemd's sd_listen_fds does,
+// so that any child processes don't think the listeners are theirs.
+func activatedListeners(l *log.Logger, n int) ([]net.Listener, error) {
+	lns, err := inheritListeners(l, os.Getenv, os.Getpid(), ListenFDsStart, n)
+	for _, v := range []string{ListenPIDEnv, ListenFDsEnv, listenFDNamesEnv} {
+		if uerr := os.Unsetenv(v); uerr != nil {
+			l.Printf("couldn't clear %s: %v\n", v, uerr)
+		}
+	}
+	return lns, err
+}
+
+// inheritListeners adopts n listeners on the file descriptors from first onwards, if the variables that getenv reads
+// say they were passed to the process with ID pid.
+// If any fails, it closes the listeners it has already adopted, logging errors to l.
+func inheritListeners(l *log.Logger, getenv func(string) string, pid, first, n int) ([]net.Listener, error) {
+	if p := getenv(ListenPIDEnv); p != strconv.Itoa(pid) {
+		return nil, fmt.Errorf("socket activation: %s is %q, not this process (%d)", ListenPIDEnv, p, pid)
+	}
+	nfds, err := strconv.Atoi(getenv(ListenFDsEnv))
+	if err != nil {
+		return nil, fmt.Errorf("socket activation: bad %s: %w", ListenFDsEnv, err)
+	}
+	if nfds != n {
+		return nil, fmt.Errorf("socket activation: got %d sockets, want one for each of %d channels", nfds, n)
+	}
+
+	lns := make([]net.Listener, 0, n)
+	for fd := first; fd < first+n; fd++ {
+		f := os.NewFile(uintptr(fd), "listen-fd-"+strconv.Itoa(fd))
+		ln, err := net.FileListener(f)
+		// FileListener works on a duplicate of the descriptor, so the original isn't needed either way.
+		if cerr := f.Close(); cerr != nil {
+			l.Printf("couldn't close inherited fd %d: %v\n", fd, cerr)
+		}
+		if err != nil {
+			closeListeners(l, lns)
+			return nil, fmt.Errorf("socket activation: fd %d: %w", fd, err)
+		}
+		lns = append(lns, ln)
+	}
+	return lns, nil
+}
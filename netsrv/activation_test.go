@@ -0,0 +1,74 @@
+//go:build !windows
+// +build !windows
+
+package netsrv_test
+
+import (
+	"io/ioutil"
+	"log"
+	"net"
+	"strconv"
+	"syscall"
+	"testing"
+
+	"github.com/UniversityRadioYork/baps3d/netsrv"
+)
+
+// TestInheritListeners tests adopting an inherited listener, and refusing ones whose variables don't check out.
+func TestInheritListeners(t *testing.T) {
+	orig, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("couldn't listen: %v", err)
+	}
+	defer orig.Close()
+	// This stands in for the descriptor a service manager would pass on.
+	// Adopting it closes it, so it is a raw duplicate, not owned by an os.File that would close it again.
+	f, err := orig.(*net.TCPListener).File()
+	if err != nil {
+		t.Fatalf("couldn't get listener file: %v", err)
+	}
+	fd, err := syscall.Dup(int(f.Fd()))
+	f.Close()
+	if err != nil {
+		t.Fatalf("couldn't duplicate listener fd: %v", err)
+	}
+
+	const pid = 1234
+	quiet := log.New(ioutil.Discard, "", 0)
+	env := func(pid, fds string) func(string) string {
+		return func(v string) string {
+			return map[string]string{netsrv.ListenPIDEnv: pid, netsrv.ListenFDsEnv: fds}[v]
+		}
+	}
+	for name, getenv := range map[string]func(string) string{
+		"no variables":  env("", ""),
+		"other process": env("4321", "1"),
+		"bad count":     env(strconv.Itoa(pid), "one"),
+		"wrong count":   env(strconv.Itoa(pid), "2"),
+	} {
+		if _, err := netsrv.InheritListeners(quiet, getenv, pid, fd, 1); err == nil {
+			t.Errorf("%s: expected an error", name)
+		}
+	}
+
+	lns, err := netsrv.InheritListeners(quiet, env(strconv.Itoa(pid), "1"), pid, fd, 1)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	ln := lns[0]
+	defer ln.Close()
+	if got, want := ln.Addr().String(), orig.Addr().String(); got != want {
+		t.Errorf("adopted listener on %s, want %s", got, want)
+	}
+
+	conn, err := net.Dial("tcp", ln.Addr().String())
+	if err != nil {
+		t.Fatalf("couldn't dial: %v", err)
+	}
+	defer conn.Close()
+	ac, err := ln.Accept()
+	if err != nil {
+		t.Fatalf("couldn't accept on adopted listener: %v", err)
+	}
+	ac.Close()
+}
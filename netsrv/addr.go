@@ -0,0 +1,60 @@
+package netsrv
+
+// File addr.go contains helpers for dealing with client addresses, whether IPv4 or IPv6.
+
+import (
+	"net"
+	"strings"
+)
+
+// RemoteIP gets the IP address of the client at addr, for keying per-address state such as limits.
+//
+// The IP comes without any zone, so that a link-local client is the same client whichever interface it arrives
+// on, and IPv4 clients of a dual-stack listener (which arrive as IPv4-mapped IPv6 addresses) come back as plain
+// IPv4, as they would from an IPv4 listener.
+// RemoteIP returns nil if addr doesn't carry an IP address.
+func RemoteIP(addr net.Addr) net.IP {
+	var ip net.IP
+	switch a := addr.(type) {
+	case *net.TCPAddr:
+		ip = a.IP
+	case *net.UDPAddr:
+		ip = a.IP
+	case *net.IPAddr:
+		ip = a.IP
+	default:
+		if addr == nil {
+			return nil
+		}
+		ip = parseHostIP(addr.String())
+	}
+
+	if ip4 := ip.To4(); ip4 != nil {
+		return ip4
+	}
+	return ip
+}
+
+// parseHostIP parses the IP address out of a host:port string, dropping any zone.
+// It returns nil if there isn't one.
+func parseHostIP(hostport string) net.IP {
+	host, _, err := net.SplitHostPort(hostport)
+	if err != nil {
+		host = hostport
+	}
+	if i := strings.LastIndexByte(host, '%'); 0 <= i {
+		host = host[:i]
+	}
+	return net.ParseIP(host)
+}
+
+// connName gets the name by which the Server knows conn in logs, events, and stats: its remote address, if it has
+// one.
+// Clients of Unix domain sockets usually don't, showing as empty or, on Linux, '@', so they go by the socket they
+// connected to instead.
+func connName(conn net.Conn) string {
+	if a := conn.RemoteAddr(); a != nil && a.String() != "" && a.String() != "@" {
+		return a.String()
+	}
+	return "local:" + conn.LocalAddr().String()
+}
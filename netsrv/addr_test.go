@@ -0,0 +1,66 @@
+package netsrv_test
+
+import (
+	"net"
+	"testing"
+
+	"github.com/UniversityRadioYork/baps3d/list"
+	"github.com/UniversityRadioYork/baps3d/netsrv"
+)
+
+// TestRemoteIP tests that RemoteIP drops zones and unmaps IPv4-mapped addresses.
+func TestRemoteIP(t *testing.T) {
+	cases := []struct {
+		addr net.Addr
+		want string
+	}{
+		{&net.TCPAddr{IP: net.ParseIP("192.0.2.1"), Port: 1350}, "192.0.2.1"},
+		{&net.TCPAddr{IP: net.ParseIP("::ffff:192.0.2.1"), Port: 1350}, "192.0.2.1"},
+		{&net.TCPAddr{IP: net.ParseIP("2001:db8::1"), Port: 1350}, "2001:db8::1"},
+		{&net.TCPAddr{IP: net.ParseIP("fe80::1"), Port: 1350, Zone: "eth0"}, "fe80::1"},
+		{&net.UnixAddr{Name: "[fe80::1%eth0]:1350", Net: "unix"}, "fe80::1"},
+		{&net.UnixAddr{Name: "/tmp/baps3d.sock", Net: "unix"}, "<nil>"},
+	}
+
+	for _, c := range cases {
+		got := netsrv.RemoteIP(c.addr)
+		if got.String() != c.want {
+			t.Errorf("RemoteIP(%v): got %v, want %s", c.addr, got, c.want)
+		}
+		if ip4 := got.To4(); ip4 != nil && len(got) != net.IPv4len {
+			t.Errorf("RemoteIP(%v): got %d-byte IPv4 address, want %d bytes", c.addr, len(got), net.IPv4len)
+		}
+	}
+}
+
+// TestServer_Run_DualStack tests that a Server listening on the IPv6 wildcard accepts both IPv4 and IPv6 clients,
+// reporting each with a canonical address.
+func TestServer_Run_DualStack(t *testing.T) {
+	_, port, err := net.SplitHostPort(freeAddr(t))
+	if err != nil {
+		t.Fatalf("couldn't split free address: %v", err)
+	}
+	if ln, err := net.Listen("tcp", "[::1]:0"); err != nil {
+		t.Skipf("no IPv6 loopback: %v", err)
+	} else if err := ln.Close(); err != nil {
+		t.Fatalf("couldn't close IPv6 probe: %v", err)
+	}
+
+	events := make(chan netsrv.Event, 8)
+	ts := startServerOn(t, net.JoinHostPort("::", port), list.New(), func(s *netsrv.Server) { s.Events = events })
+	defer ts.Cancel()
+
+	for _, host := range []string{"127.0.0.1", "::1"} {
+		ts.Addr = net.JoinHostPort(host, port)
+		conn, _ := ts.dial(t)
+		defer conn.Close()
+
+		e := nextEvent(t, events)
+		if want := conn.LocalAddr().String(); e.RemoteAddr != want {
+			t.Errorf("%s: got address %s, want %s", host, e.RemoteAddr, want)
+		}
+		if !e.IP.Equal(net.ParseIP(host)) {
+			t.Errorf("%s: got IP %v", host, e.IP)
+		}
+	}
+}
@@ -0,0 +1,72 @@
+package netsrv_test
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"strings"
+	"testing"
+
+	"github.com/UniversityRadioYork/baps3d/netsrv"
+)
+
+// scriptLen is the number of lines in the script BenchmarkServer_Script sends.
+const scriptLen = 1000
+
+// makeScript makes a scriptLen-line script of status requests.
+func makeScript() []byte {
+	var sb strings.Builder
+	for i := 0; i < scriptLen; i++ {
+		fmt.Fprintf(&sb, "t%d status\n", i)
+	}
+	return []byte(sb.String())
+}
+
+// runScript sends script down w in one go, then reads from rd until every line is acknowledged.
+func runScript(t testing.TB, w io.Writer, rd *bufio.Reader, script []byte) {
+	t.Helper()
+
+	if _, err := w.Write(script); err != nil {
+		t.Fatalf("couldn't write script: %v", err)
+	}
+	for acks := 0; acks < scriptLen; {
+		line, err := rd.ReadString('\n')
+		if err != nil {
+			t.Fatalf("couldn't read line: %v", err)
+		}
+		if strings.Contains(line, " ACK ") {
+			acks++
+		}
+	}
+}
+
+// TestServer_Script tests that a Server keeps up with a client sending a long script in one go, so that the
+// Controller is still replying to earlier lines as the client's adapter passes it later ones.
+func TestServer_Script(t *testing.T) {
+	ts := startServer(t, nil)
+	defer ts.Cancel()
+
+	conn, rd := ts.dial(t)
+	defer conn.Close()
+	runScript(t, conn, rd, makeScript())
+}
+
+// BenchmarkServer_Script benchmarks a client sending a long script in one go, with and without batching.
+func BenchmarkServer_Script(b *testing.B) {
+	script := makeScript()
+	for _, batch := range []bool{false, true} {
+		batch := batch
+		b.Run(fmt.Sprintf("batch=%v", batch), func(b *testing.B) {
+			ts := startServer(b, func(s *netsrv.Server) { s.BatchInput = batch })
+			defer ts.Cancel()
+
+			conn, rd := ts.dial(b)
+			defer conn.Close()
+
+			b.ResetTimer()
+			for n := 0; n < b.N; n++ {
+				runScript(b, conn, rd, script)
+			}
+		})
+	}
+}
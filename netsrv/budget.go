@@ -0,0 +1,63 @@
+package netsrv
+
+// File budget.go contains per-connection byte accounting, and the lifetime byte budgets with which the Server stops
+// one long-lived client from using more than its share of a shared link; see Server.ByteBudget.
+
+import (
+	"errors"
+	"fmt"
+	"sync/atomic"
+)
+
+// ErrByteBudget is the error given when a connection has read and written as many bytes as its budget allows; see
+// Server.ByteBudget.
+var ErrByteBudget = errors.New("byte budget exhausted")
+
+// budgetReader reads from its endpoint's connection, counting the bytes read against the endpoint's budget.
+type budgetReader struct {
+	e *ioEndpoint
+}
+
+// Read reads from r's connection, never reading more than r's endpoint has left of its budget.
+// Bytes count as read whether or not the read also fails, so partial reads count exactly.
+// Once the budget is spent, Read fails with ErrByteBudget, without touching the connection.
+func (r budgetReader) Read(p []byte) (int, error) {
+	if 0 < r.e.byteBudget {
+		left := r.e.byteBudget - r.e.bytesUsed()
+		if left <= 0 {
+			return 0, fmt.Errorf("%w: %d bytes", ErrByteBudget, r.e.byteBudget)
+		}
+		if left < int64(len(p)) {
+			p = p[:left]
+		}
+	}
+	n, err := r.e.io.Read(p)
+	atomic.AddInt64(&r.e.bytesRead, int64(n))
+	return n, err
+}
+
+// spend checks that e's budget allows it to write n more bytes.
+// A message that would overrun the budget isn't written at all, rather than cut short.
+// The reader and writer spend the budget separately, so a read landing between the check and the write can take the
+// total over it by up to one read's worth; the counts themselves are always exact.
+func (e *ioEndpoint) spend(n int) error {
+	if e.byteBudget <= 0 || e.bytesUsed()+int64(n) <= e.byteBudget {
+		return nil
+	}
+	return fmt.Errorf("%w: %d bytes", ErrByteBudget, e.byteBudget)
+}
+
+// noteWritten records that n bytes were written to e's connection.
+func (e *ioEndpoint) noteWritten(n int) {
+	atomic.AddInt64(&e.bytesWritten, int64(n))
+}
+
+// byteCounts gets the number of bytes read from and written to e's connection.
+func (e *ioEndpoint) byteCounts() (read, written uint64) {
+	return uint64(atomic.LoadInt64(&e.bytesRead)), uint64(atomic.LoadInt64(&e.bytesWritten))
+}
+
+// bytesUsed gets the number of bytes read from and written to e's connection, together.
+func (e *ioEndpoint) bytesUsed() int64 {
+	return atomic.LoadInt64(&e.bytesRead) + atomic.LoadInt64(&e.bytesWritten)
+}
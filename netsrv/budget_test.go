@@ -0,0 +1,112 @@
+package netsrv_test
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"net"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/UniversityRadioYork/baps3d/netsrv"
+)
+
+// TestServer_ByteCounts tests that a connection's byte counts are exact, even when lines arrive split across reads.
+func TestServer_ByteCounts(t *testing.T) {
+	ts := startServer(t, nil)
+	defer ts.Cancel()
+
+	// ts.dial would eat the greeting, which we need to count.
+	var (
+		conn net.Conn
+		err  error
+	)
+	for deadline := time.Now().Add(testTimeout); ; time.Sleep(10 * time.Millisecond) {
+		if conn, err = net.Dial("tcp", ts.Addr); err == nil {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("couldn't connect: %v", err)
+		}
+	}
+	defer func() { _ = conn.Close() }()
+
+	sent := 0
+	for _, part := range []string{"t who", "ami\n"} {
+		n, err := fmt.Fprint(conn, part)
+		if err != nil {
+			t.Fatalf("couldn't send %q: %v", part, err)
+		}
+		sent += n
+		time.Sleep(10 * time.Millisecond)
+	}
+	rd := bufio.NewReader(conn)
+	got := 0
+	for {
+		line, err := rd.ReadString('\n')
+		if err != nil {
+			t.Fatalf("couldn't read line: %v", err)
+		}
+		got += len(line)
+		if strings.HasPrefix(line, "t ACK") {
+			break
+		}
+	}
+
+	// The count of written bytes follows each write, so may trail what the client has already read.
+	ctx, cancel := context.WithTimeout(context.Background(), testTimeout)
+	defer cancel()
+	var cs netsrv.ClientStats
+	for ctx.Err() == nil {
+		st, ok := ts.Server.Stats(ctx)
+		if !ok || len(st.Clients) != 1 {
+			t.Fatalf("got stats %+v, want one client", st)
+		}
+		if cs = st.Clients[0]; cs.BytesWritten == uint64(got) {
+			break
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	if cs.BytesRead != uint64(sent) || cs.BytesWritten != uint64(got) {
+		t.Errorf("got %d bytes read and %d written, want %d and %d", cs.BytesRead, cs.BytesWritten, sent, got)
+	}
+}
+
+// TestServer_ByteBudget tests that a connection that spends its byte budget is hung up with ReasonByteBudget.
+func TestServer_ByteBudget(t *testing.T) {
+	events := make(chan netsrv.Event, 8)
+	ts := startServer(t, func(s *netsrv.Server) {
+		s.Events = events
+		s.ByteBudget = func(string, net.Addr) int64 { return 512 }
+	})
+	defer ts.Cancel()
+
+	conn, rd := ts.dial(t)
+	defer func() { _ = conn.Close() }()
+	addr := conn.LocalAddr().String()
+	checkEvent(t, nextEvent(t, events), netsrv.EventConnect, 0, addr, "")
+
+	// Each whoami costs its line and its replies, so the budget runs out within a few dozen.
+	go func() {
+		for i := 0; i < 100; i++ {
+			if _, err := fmt.Fprintf(conn, "t%d whoami\n", i); err != nil {
+				return
+			}
+		}
+	}()
+	e := nextEvent(t, events)
+	checkEvent(t, e, netsrv.EventDisconnect, 0, addr, netsrv.ReasonByteBudget)
+
+	got := 0
+	for {
+		line, err := rd.ReadString('\n')
+		got += len(line)
+		if err != nil {
+			break
+		}
+	}
+	if 512 < got {
+		t.Errorf("got %d bytes, over the budget", got)
+	}
+}
@@ -3,8 +3,12 @@ package netsrv
 import (
 	"context"
 	"errors"
+	"fmt"
+	"io"
 	"log"
+	"net"
 	"sync"
+	"time"
 
 	"github.com/UniversityRadioYork/baps3d/controller"
 	"github.com/UniversityRadioYork/bifrost-go/comm"
@@ -12,29 +16,64 @@ import (
 
 // Client holds the server-side state of a baps3d Bifrost client.
 type Client struct {
+	// id holds the server-assigned identifier for the Client.
+	id uint64
+
 	// name holds a descriptive name for the Client.
 	name string
 
+	// nameMu guards identity.
+	nameMu sync.Mutex
+	// identity is the display name the Client gave itself, if any; see IdentifyWord.
+	identity string
+
+	// ip is the IP address of the Client's connection, as given by RemoteIP.
+	ip net.IP
+
+	// channel is the name of the Server channel the Client connected to.
+	channel string
+
 	// log holds the logger for this client.
 	log *log.Logger
 
+	// errLog rate-limits the logging of this client's connection errors.
+	errLog *ErrorLimiter
+
 	// conClient is the client's Client for the Controller for this
 	// server.
 	conClient *controller.Client
 
 	// ioClient is the underlying Bifrost-level client.
-	ioClient *comm.IoEndpoint
+	ioClient *ioEndpoint
+
+	// options describes the options the Server gave the Client's connection, for WHOAMI replies; see RsWhoami.
+	options []string
+
+	// keepAlive is the TCP keepalive period active on the client's connection, or 0 if keepalive is off.
+	keepAlive time.Duration
 }
 
-// Close closes the given client.
+// Close gracefully closes the given client.
+// The client stops taking requests, and its adapter and Controller client detach, but messages already on their way
+// to it still get written before its connection closes.
+// Close doesn't wait for this: Run returns once it is done, and nothing the client started is left running.
+// Close is idempotent, and safe to call from the hangup path.
+//
+// The Controller client detaches by hanging up, not with Shutdown, which would stop the Controller for every client.
 func (c *Client) Close() error {
-	// TODO(@MattWindsor91): disconnect client and bifrost
 	return c.ioClient.Close()
 }
 
+// Flush waits until every message queued for the client so far has been written to its connection.
+// It fails if the connection closes, or ctx finishes, first.
+func (c *Client) Flush(ctx context.Context) error {
+	return c.ioClient.Flush(ctx)
+}
+
 // Run spins up the client's receiver and transmitter loops.
-// It takes the server context, the client's Bifrost adapter, and the server's client hangup channel.
-func (c *Client) Run(ctx context.Context, bf *controller.Bifrost, hangUp chan<- *Client) {
+// It takes the server context, the client's Bifrost adapter, the server's client hangup channel, and the server's
+// done channel (which, once closed, means the server is no longer listening for hangups).
+func (c *Client) Run(ctx context.Context, bf *controller.Bifrost, hangUp chan<- hangUpRequest, done <-chan struct{}) {
 	var wg sync.WaitGroup
 	wg.Add(3)
 
@@ -46,31 +85,56 @@ func (c *Client) Run(ctx context.Context, bf *controller.Bifrost, hangUp chan<-
 	}()
 
 	go func() {
-		c.handleIoErrors(errCh, hangUp)
+		c.handleIoErrors(errCh, hangUp, done)
 		wg.Done()
 	}()
 
 	go func() {
-		bf.Run(ctx)
+		// The adapter detaches itself from the Controller if it panics (say, in its parser), so all that's left is
+		// to close the connection, which makes the transmitter loop hang the client up with the panic.
+		if err := catchPanic(func() { bf.Run(ctx) }); err != nil {
+			c.ioClient.failSend(err)
+		}
 		wg.Done()
 	}()
 
 	wg.Wait()
 }
 
+// hangUpRequest is the type of requests from a client to the server to hang it up.
+type hangUpRequest struct {
+	// client is the client to hang up.
+	client *Client
+	// err, if non-nil, is the connection error that caused the hangup.
+	err error
+}
+
 // handleIoErrors monitors errCh for errors, forwarding any hangup requests coming through to hangUp and logging all
 // other errors.
-func (c *Client) handleIoErrors(errCh <-chan error, hangUp chan<- *Client) {
+// Hangups carry the last error seen before them, unless that error was the connection closing cleanly.
+// If done closes, the server is already hanging everyone up, so hangup requests are dropped.
+func (c *Client) handleIoErrors(errCh <-chan error, hangUp chan<- hangUpRequest, done <-chan struct{}) {
+	var lastErr error
 	for err := range errCh {
 		if errors.Is(err, comm.HungUpError) {
-			hangUp <- c
+			rq := hangUpRequest{client: c}
+			if !errors.Is(lastErr, io.EOF) {
+				rq.err = lastErr
+			}
+
+			select {
+			case hangUp <- rq:
+			case <-done:
+			}
 		} else {
+			lastErr = err
 			c.outputError(err)
 		}
 	}
+	c.errLog.Flush()
 }
 
-// outputError logs a connection error for client c.
+// outputError logs a connection error for client c, coalescing repeats.
 func (c *Client) outputError(e error) {
-	c.log.Printf("connection error on %s: %s\n", c.name, e.Error())
+	c.errLog.Log(fmt.Sprintf("connection error on %s: %s", c.name, e.Error()))
 }
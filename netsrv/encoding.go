@@ -0,0 +1,85 @@
+package netsrv
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/UniversityRadioYork/bifrost-go/message"
+)
+
+// Encoding is the type of encodings the Server can write a client's messages in.
+type Encoding int
+
+const (
+	// EncodingLine writes each message as a packed Bifrost line.
+	EncodingLine Encoding = iota
+	// EncodingJSON writes each message as a JSON object on its own line, with the message's tag, word, and
+	// arguments as the fields "tag", "word", and "args"; see DecodeJSON.
+	// The object carries the same words as the packed line would, unescaped.
+	EncodingJSON
+)
+
+// String gets the configuration name of an Encoding.
+func (e Encoding) String() string {
+	switch e {
+	case EncodingLine:
+		return "line"
+	case EncodingJSON:
+		return "json"
+	default:
+		return "?unknown?"
+	}
+}
+
+// ParseEncoding tries to parse an Encoding from its configuration name.
+// The empty string parses as EncodingLine.
+func ParseEncoding(s string) (Encoding, error) {
+	switch strings.ToLower(s) {
+	case "", "line":
+		return EncodingLine, nil
+	case "json":
+		return EncodingJSON, nil
+	default:
+		return EncodingLine, fmt.Errorf("invalid encoding: %s", s)
+	}
+}
+
+// Encode encodes m, ready to write to a client.
+func (e Encoding) Encode(m message.Message) ([]byte, error) {
+	if e != EncodingJSON {
+		return m.Pack()
+	}
+
+	args := m.Args()
+	if args == nil {
+		// Always give an array, so that clients needn't check for null.
+		args = []string{}
+	}
+	b, err := json.Marshal(jsonMessage{Tag: m.Tag(), Word: m.Word(), Args: args})
+	if err != nil {
+		return nil, err
+	}
+	return append(b, '\n'), nil
+}
+
+// jsonMessage is the JSON form of a message.
+type jsonMessage struct {
+	Tag  string   `json:"tag"`
+	Word string   `json:"word"`
+	Args []string `json:"args"`
+}
+
+// DecodeJSON decodes a message from its EncodingJSON form b.
+// Decoding the JSON form of a message gives back the message that packed line would give.
+func DecodeJSON(b []byte) (*message.Message, error) {
+	var jm jsonMessage
+	if err := json.Unmarshal(b, &jm); err != nil {
+		return nil, err
+	}
+	if jm.Tag == "" || jm.Word == "" {
+		return nil, errors.New("message needs a tag and a word")
+	}
+	return message.New(jm.Tag, jm.Word).AddArgs(jm.Args...), nil
+}
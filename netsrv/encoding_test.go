@@ -0,0 +1,154 @@
+package netsrv_test
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"net"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/UniversityRadioYork/bifrost-go/message"
+
+	"github.com/UniversityRadioYork/baps3d/netsrv"
+)
+
+// allWords gets the tag, word, and arguments of m as one slice.
+func allWords(m *message.Message) []string {
+	return append([]string{m.Tag(), m.Word()}, m.Args()...)
+}
+
+// TestEncoding_RoundTrip tests that the line and JSON encodings of a message decode to the same message.
+func TestEncoding_RoundTrip(t *testing.T) {
+	msgs := []*message.Message{
+		message.New("!", "OHAI"),
+		message.New("t1", "ITEM").AddArgs("0", "abc", "track", "foo bar.mp3"),
+		// Empty arguments don't survive packing, so they can't be in here.
+		message.New("t2", "ACK").AddArgs("WHAT", `it's "quoted"`),
+		message.New("!", "SEL").AddArgs("-1", "(undefined)", `back\slash`, "tab\there"),
+	}
+
+	for _, m := range msgs {
+		lb, err := netsrv.EncodingLine.Encode(*m)
+		if err != nil {
+			t.Fatalf("%v: couldn't encode line: %v", m, err)
+		}
+		line, err := netsrv.NewTokeniser(bytes.NewReader(lb), 0).ReadLine()
+		if err != nil {
+			t.Fatalf("%v: couldn't read line: %v", m, err)
+		}
+		fromLine, err := netsrv.LineToMessage(line, netsrv.InputPolicy{})
+		if err != nil {
+			t.Fatalf("%v: couldn't decode line: %v", m, err)
+		}
+
+		jb, err := netsrv.EncodingJSON.Encode(*m)
+		if err != nil {
+			t.Fatalf("%v: couldn't encode JSON: %v", m, err)
+		}
+		if bytes.Count(jb, []byte("\n")) != 1 || !bytes.HasSuffix(jb, []byte("\n")) {
+			t.Errorf("%v: JSON %q isn't exactly one line", m, jb)
+		}
+		fromJSON, err := netsrv.DecodeJSON(jb)
+		if err != nil {
+			t.Fatalf("%v: couldn't decode JSON: %v", m, err)
+		}
+
+		want := allWords(m)
+		if got := allWords(fromLine); !reflect.DeepEqual(got, want) {
+			t.Errorf("line round trip: got %q, want %q", got, want)
+		}
+		if got := allWords(fromJSON); !reflect.DeepEqual(got, want) {
+			t.Errorf("JSON round trip: got %q, want %q", got, want)
+		}
+	}
+}
+
+// TestEncoding_JSON tests the JSON encoding of a message, including one with no arguments.
+func TestEncoding_JSON(t *testing.T) {
+	cases := []struct {
+		msg  *message.Message
+		want string
+	}{
+		{message.New("!", "OHAI"), `{"tag":"!","word":"OHAI","args":[]}` + "\n"},
+		{message.New("t1", "SEL").AddArgs("0", "a b"), `{"tag":"t1","word":"SEL","args":["0","a b"]}` + "\n"},
+	}
+	for _, c := range cases {
+		got, err := netsrv.EncodingJSON.Encode(*c.msg)
+		if err != nil {
+			t.Fatalf("%v: unexpected error: %v", c.msg, err)
+		}
+		if string(got) != c.want {
+			t.Errorf("%v: got %q, want %q", c.msg, got, c.want)
+		}
+	}
+}
+
+// TestDecodeJSON_Bad tests that DecodeJSON refuses objects that aren't messages.
+func TestDecodeJSON_Bad(t *testing.T) {
+	for _, in := range []string{`{"tag":"t1"}`, `{"word":"SEL"}`, `[]`, `{"tag":"t1","word":"SEL","args":[0]}`} {
+		if _, err := netsrv.DecodeJSON([]byte(in)); err == nil {
+			t.Errorf("%s: expected an error", in)
+		}
+	}
+}
+
+// TestParseEncoding tests parsing encodings from their configuration names.
+func TestParseEncoding(t *testing.T) {
+	for _, e := range []netsrv.Encoding{netsrv.EncodingLine, netsrv.EncodingJSON} {
+		if got, err := netsrv.ParseEncoding(strings.ToUpper(e.String())); err != nil || got != e {
+			t.Errorf("%v: got %v, %v", e, got, err)
+		}
+	}
+	if got, err := netsrv.ParseEncoding(""); err != nil || got != netsrv.EncodingLine {
+		t.Errorf("empty: got %v, %v; want line", got, err)
+	}
+	if _, err := netsrv.ParseEncoding("xml"); err == nil {
+		t.Error("xml: expected an error")
+	}
+}
+
+// TestServer_Encoding tests that a JSON connection gets the same replies as a line connection would, as JSON.
+func TestServer_Encoding(t *testing.T) {
+	ts := startServer(t, func(s *netsrv.Server) {
+		s.Encoding = func(string, net.Addr) netsrv.Encoding { return netsrv.EncodingJSON }
+	})
+	defer ts.Cancel()
+
+	conn, rd := ts.dial(t)
+	defer conn.Close()
+
+	if _, err := fmt.Fprint(conn, "t1 status\n"); err != nil {
+		t.Fatalf("couldn't write to server: %v", err)
+	}
+	got := readJSONUntilAck(t, rd, "t1")
+	if want := []string{"t1", "STATUS", "0", "-1", "(undefined)", "off", "1"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("got %q, want %q", got, want)
+	}
+}
+
+// readJSONUntilAck reads JSON messages from rd until one acknowledges tag, returning the last one with that tag
+// before it.
+func readJSONUntilAck(t *testing.T, rd *bufio.Reader, tag string) []string {
+	t.Helper()
+
+	var last []string
+	for {
+		b, err := rd.ReadBytes('\n')
+		if err != nil {
+			t.Fatalf("couldn't read line: %v", err)
+		}
+		m, err := netsrv.DecodeJSON(b)
+		if err != nil {
+			t.Fatalf("couldn't decode %q: %v", b, err)
+		}
+		if m.Tag() != tag {
+			continue
+		}
+		if m.Word() == "ACK" {
+			return last
+		}
+		last = allWords(m)
+	}
+}
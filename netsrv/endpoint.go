@@ -0,0 +1,552 @@
+package netsrv
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/UniversityRadioYork/bifrost-go/comm"
+	"github.com/UniversityRadioYork/bifrost-go/core"
+	"github.com/UniversityRadioYork/bifrost-go/message"
+
+	"github.com/UniversityRadioYork/baps3d/controller"
+)
+
+var (
+	// ErrHandshakeTimeout is the error given when a client doesn't send its first line within the Server's
+	// HandshakeTimeout.
+	ErrHandshakeTimeout = errors.New("timed out waiting for first line")
+	// ErrIdleTimeout is the error given when a client doesn't send a line within the Server's IdleTimeout.
+	ErrIdleTimeout = errors.New("timed out waiting for next line")
+	// ErrClosed is the error given when flushing a client whose connection closed with messages still unwritten.
+	ErrClosed = errors.New("client connection closed")
+)
+
+// flushTimeout is how long a closing endpoint keeps trying to write out its queued messages.
+const flushTimeout = 5 * time.Second
+
+// ioEndpoint is a Bifrost endpoint that sends and receives messages along a client connection.
+//
+// It mirrors bifrost-go's comm.IoEndpoint, but reads lines through our own Tokeniser, so that the Server's word
+// limit applies (and lines split across reads work).
+type ioEndpoint struct {
+	// lastWriteNs is the time of the last successful write to io, in Unix nanoseconds, or 0 if there hasn't been one.
+	// It is accessed atomically, so it comes first to keep it 64-bit aligned.
+	lastWriteNs int64
+	// linesBuffered is the number of lines read from io that are waiting to go to the adapter, and linesPeak the most
+	// there have been at once; both are accessed atomically.
+	linesBuffered int64
+	linesPeak     int64
+	// bytesRead and bytesWritten are the number of bytes read from and written to io; both are accessed atomically.
+	bytesRead    int64
+	bytesWritten int64
+
+	// byteBudget, if positive, is the most bytes e reads from and writes to io, together; see Server.ByteBudget.
+	byteBudget int64
+
+	// io holds the internal I/O connection.
+	io io.ReadWriteCloser
+
+	// endpoint holds the Bifrost channel pair used by the connection.
+	endpoint *comm.Endpoint
+
+	// maxWordLen, if positive, is the maximum length of any word read from io.
+	maxWordLen int
+
+	// restOfLine, if non-nil, says which messages read from io have a rest-of-line argument; see Tokeniser.
+	restOfLine func(word string) (fixed int, ok bool)
+
+	// input is the policy lines read from io are checked against.
+	input InputPolicy
+
+	// handshakeTimeout, if positive, is how long the client has to send its first line.
+	handshakeTimeout time.Duration
+	// idleTimeout, if positive, is how long the client may go without sending a line, after its first.
+	idleTimeout time.Duration
+
+	// writeTimeout, if positive, is how long each write to io may take before it is retried; see Server.WriteTimeout.
+	writeTimeout time.Duration
+	// writeRetries is how many times a timed-out write is retried before the endpoint gives up on io.
+	writeRetries int
+	// writeRetryDelay is how long the endpoint waits before each retry.
+	writeRetryDelay time.Duration
+
+	// linger, if positive, is how long the endpoint half-closes its connection for before closing it; see
+	// Server.Linger.
+	linger time.Duration
+
+	// clock is the source of time for rate limiting and write times; see Server.Clock.
+	clock controller.Clock
+
+	// limiter, if non-nil, limits the rate at which lines read from io become requests; see Server.RequestRate.
+	limiter *tokenBucket
+
+	// batch, if non-nil, sends several messages read from io to the endpoint's adapter at once; see Server.BatchInput.
+	batch func(ctx context.Context, msgs []message.Message) bool
+
+	// identify, if non-nil, records the display name the client gives itself; see IdentifyWord.
+	// If nil, identify requests go to the adapter like any other.
+	identify func(name string)
+
+	// self, if non-nil, gives the arguments of WHOAMI replies describing the connection; see WhoamiWord.
+	// If nil, whoami requests go to the adapter like any other.
+	self func() []string
+
+	// outLimiter, if non-nil, caps the rate at which e writes bytes to the client; see Server.OutputRate.
+	// Only the writer goroutine uses it.
+	outLimiter *tokenBucket
+
+	// maxInflated, if positive, is the most bytes each compressed block of lines the client sends can decompress to;
+	// see ZlinesWord.
+	// If zero, zlines requests go to the adapter like any other.
+	maxInflated int
+
+	// serverInfo, if non-nil, gets the arguments of SERVERINFO replies; see ServerInfoWord.
+	// If nil, serverinfo requests go to the adapter like any other.
+	serverInfo func() []string
+
+	// errors, if non-nil, holds the latest errors sent to the client; see ErrorsWord.
+	// If nil, errors requests go to the adapter like any other.
+	errors *errorRing
+
+	// limits, if non-nil, are the limits given in LIMIT replies; see LimitsWord.
+	// If nil, limits requests go to the adapter like any other.
+	limits []controller.Limit
+
+	// clients, if non-nil, counts the clients connected to the Server, for STATUS replies; see controller.RsStatus.
+	clients func() int
+
+	// encoding is the encoding in which messages are written to io.
+	encoding Encoding
+
+	// queue holds the messages waiting to be written to io.
+	queue *sendQueue
+
+	// writerDone closes once nothing more will be written to io.
+	writerDone chan struct{}
+
+	// closeMu guards closing, and changes to io's read deadline.
+	closeMu sync.Mutex
+	// closing is true once Close has been called.
+	closing bool
+
+	// closeOnce makes sure io is only closed once, whether by Close or by failSend.
+	closeOnce sync.Once
+	// closeErr is the error from closing io.
+	closeErr error
+
+	// failMu guards failure.
+	failMu sync.Mutex
+	// failure, if non-nil, is the reason the endpoint gave up sending to io.
+	failure error
+}
+
+// Close gracefully closes the endpoint.
+// It stops the endpoint reading requests, which detaches its adapter (and, with it, the Controller client); messages
+// already queued, or sent by the adapter before it detaches, are still written, for up to flushTimeout, and then the
+// connection closes.
+//
+// Close doesn't wait for any of this: Run returns once it is done.
+// It is idempotent, and safe to call from any goroutine, at any point in the endpoint's life; if the connection can't
+// take deadlines, or is already broken, Close closes it straight away, without flushing.
+func (e *ioEndpoint) Close() error {
+	e.closeMu.Lock()
+	defer e.closeMu.Unlock()
+	if e.closing {
+		return nil
+	}
+	e.closing = true
+
+	dl, ok := e.io.(interface {
+		SetReadDeadline(time.Time) error
+		SetWriteDeadline(time.Time) error
+	})
+	if !ok {
+		return e.closeIO()
+	}
+	now := time.Now()
+	if dl.SetReadDeadline(now) != nil || dl.SetWriteDeadline(now.Add(flushTimeout)) != nil {
+		return e.closeIO()
+	}
+	return nil
+}
+
+// isClosing checks whether Close has been called on e.
+func (e *ioEndpoint) isClosing() bool {
+	e.closeMu.Lock()
+	defer e.closeMu.Unlock()
+	return e.closing
+}
+
+// Flush waits until every message queued on e so far has been written to its connection, or dropped (see
+// SendDropOldest).
+// It fails with the reason the endpoint gave up sending, or ErrClosed, if the connection closes first, or the
+// context's error if ctx finishes first.
+func (e *ioEndpoint) Flush(ctx context.Context) error {
+	target := e.queue.mark()
+	for {
+		settled, progress := e.queue.settledTo(target)
+		if settled {
+			return nil
+		}
+
+		select {
+		case <-progress:
+		case <-e.writerDone:
+			if settled, _ := e.queue.settledTo(target); settled {
+				return nil
+			}
+			if err := e.sendFailure(); err != nil {
+				return err
+			}
+			return ErrClosed
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+}
+
+// closeIO closes e's connection, if it isn't already closed.
+func (e *ioEndpoint) closeIO() error {
+	e.closeOnce.Do(func() {
+		e.closeErr = e.io.Close()
+	})
+	return e.closeErr
+}
+
+// failSend gives up on sending to e's client because of err.
+// It closes the connection, so that the client hangs up with err as the reason.
+func (e *ioEndpoint) failSend(err error) {
+	e.failMu.Lock()
+	if e.failure == nil {
+		e.failure = err
+	}
+	e.failMu.Unlock()
+
+	_ = e.closeIO()
+}
+
+// sendFailure gets the reason e gave up sending to its client, if it has.
+func (e *ioEndpoint) sendFailure() error {
+	e.failMu.Lock()
+	defer e.failMu.Unlock()
+	return e.failure
+}
+
+// Run spins up the endpoint's receiver and transmitter loops.
+// It takes a channel to notify the caller asynchronously of any errors; once the transmitter loop stops, it sends
+// comm.HungUpError.
+// It closes errCh once both loops are done.
+//
+// A panic in either loop stops the transmitter loop with a PanicError.
+//
+// The transmitter loop owns the endpoint's transmission channel, and closes it on the way out, which tells the adapter
+// the client has gone.
+// Once the receiver loop is done, the adapter has gone, so the transmitter loop stops trying to send it anything.
+func (e *ioEndpoint) Run(ctx context.Context, errCh chan<- error) {
+	var wg sync.WaitGroup
+	wg.Add(2)
+
+	sendCtx, cancelSend := context.WithCancel(ctx)
+	defer cancelSend()
+
+	go func() {
+		if err := catchPanic(func() { e.runTx(ctx, sendCtx, errCh) }); err != nil {
+			e.sendError(ctx, errCh, err)
+		}
+		close(e.endpoint.Tx)
+		e.sendError(ctx, errCh, comm.HungUpError)
+		wg.Done()
+	}()
+
+	go func() {
+		e.runRx(ctx, errCh)
+		cancelSend()
+		wg.Done()
+	}()
+
+	wg.Wait()
+	close(errCh)
+}
+
+// runRx runs the endpoint's message receiver loop.
+// This queues messages for runWriter to write to the connection.
+// If the queue overflows, it gives up on the client, but keeps draining messages until the adapter stops sending
+// them, so that the adapter (and, through it, the Controller) never blocks on a client that has gone away.
+// Once the adapter has stopped, and runWriter has written everything left in the queue, runRx closes the connection.
+func (e *ioEndpoint) runRx(ctx context.Context, errCh chan<- error) {
+	go func() {
+		if err := catchPanic(func() { e.runWriter(ctx, errCh) }); err != nil {
+			e.failSend(err)
+		}
+		close(e.writerDone)
+	}()
+
+	if err := catchPanic(e.queueMessages); err != nil {
+		e.failSend(err)
+	}
+	e.queue.close()
+
+	for range e.endpoint.Rx {
+	}
+	<-e.writerDone
+	e.lingerIO()
+	_ = e.closeIO()
+}
+
+// lingerIO half-closes e's connection, if e lingers and the connection can half-close, and then discards anything
+// the client sends until it closes its side too, or e.linger passes.
+// Closing a TCP connection that still has unread input resets it, and the client may then lose the last messages
+// written to it; reading until the client's end of stream means there is no unread input left to cause that.
+func (e *ioEndpoint) lingerIO() {
+	if e.linger <= 0 || e.sendFailure() != nil {
+		return
+	}
+	hc, ok := e.io.(interface {
+		CloseWrite() error
+		SetReadDeadline(time.Time) error
+	})
+	if !ok || hc.CloseWrite() != nil {
+		return
+	}
+
+	// Marking e closing stops a late Close cutting the linger short.
+	e.closeMu.Lock()
+	e.closing = true
+	err := hc.SetReadDeadline(time.Now().Add(e.linger))
+	e.closeMu.Unlock()
+	if err == nil {
+		_, _ = io.Copy(ioutil.Discard, e.io)
+	}
+}
+
+// queueMessages queues messages from the endpoint's adapter until the adapter stops sending them, or e gives up on
+// the client.
+func (e *ioEndpoint) queueMessages() {
+	for m := range e.endpoint.Rx {
+		if e.sendFailure() != nil {
+			return
+		}
+		if e.clients != nil && m.Word() == controller.RsStatus && !controller.IsBroadcastTag(m.Tag()) {
+			m = withArg(m, strconv.Itoa(e.clients()))
+		}
+		e.noteError(m)
+		if err := e.queue.push(m); err != nil {
+			e.failSend(err)
+			return
+		}
+	}
+}
+
+// runWriter writes messages from the endpoint's queue to the connection until the queue closes or a write fails.
+func (e *ioEndpoint) runWriter(ctx context.Context, errCh chan<- error) {
+	for {
+		m, ok := e.queue.pop()
+		if !ok {
+			return
+		}
+
+		mbytes, err := e.encoding.Encode(m)
+		if err != nil {
+			e.sendError(ctx, errCh, err)
+			e.queue.settle()
+			continue
+		}
+
+		if !e.throttle(ctx, len(mbytes)) {
+			return
+		}
+		if err := e.write(ctx, mbytes); err != nil {
+			e.sendError(ctx, errCh, err)
+			e.failSend(err)
+			return
+		}
+		e.markWrite()
+		e.queue.settle()
+	}
+}
+
+// runTx runs the endpoint's message transmitter loop.
+// This reads messages from the connection, sending them to the adapter until sendCtx finishes.
+func (e *ioEndpoint) runTx(ctx, sendCtx context.Context, errCh chan<- error) {
+	t := NewTokeniser(budgetReader{e}, e.maxWordLen)
+	t.RestOfLine = e.restOfLine
+
+	for handshake := true; ; handshake = false {
+		timeoutErr := e.setReadTimeout(handshake)
+		if err := e.txLine(ctx, sendCtx, t, errCh); err != nil {
+			// If runRx gave up on the client, the read failed because it closed the connection, so report why.
+			if ferr := e.sendFailure(); ferr != nil {
+				err = ferr
+			} else if e.isClosing() {
+				// Close stopped the read, so there's nothing to report.
+				return
+			} else if isTimeout(err) {
+				err = timeoutErr
+			}
+			e.sendError(ctx, errCh, err)
+			return
+		}
+	}
+}
+
+// setReadTimeout sets the deadline for reading the client's next line, according to whether it is the first.
+// It returns the error to report if the deadline passes.
+// Once e is closing, it leaves the deadline Close set alone.
+func (e *ioEndpoint) setReadTimeout(handshake bool) error {
+	timeout, err := e.idleTimeout, ErrIdleTimeout
+	if handshake {
+		timeout, err = e.handshakeTimeout, ErrHandshakeTimeout
+	}
+
+	dl, ok := e.io.(interface{ SetReadDeadline(time.Time) error })
+	if !ok {
+		return err
+	}
+	var deadline time.Time
+	if 0 < timeout {
+		deadline = time.Now().Add(timeout)
+	}
+	e.closeMu.Lock()
+	if !e.closing {
+		// If this fails, the connection is broken, and the next read will say so.
+		_ = dl.SetReadDeadline(deadline)
+	}
+	e.closeMu.Unlock()
+	return fmt.Errorf("%w: no line in %s", err, timeout)
+}
+
+// isTimeout checks whether err is a network timeout.
+func isTimeout(err error) bool {
+	var nerr net.Error
+	return errors.As(err, &nerr) && nerr.Timeout()
+}
+
+// txLine transmits a line from the Tokeniser t.
+// If e batches input, it also transmits, in the same batch, any further lines t has already buffered.
+// Lines that break the input policy or the request rate limit, or that aren't messages (see ErrMalformedLine), are
+// rejected, with an error sent to errCh, but don't stop the loop; blank lines are ignored.
+// Only lines that can't be read at all, such as ones with overlong words, are fatal.
+// As rejections never reach the Controller, they may overtake replies to earlier lines; so may replies to identify,
+// whoami, limits, errors, and serverinfo requests, which e handles itself, and to compressed blocks of lines (see
+// ZlinesWord).
+// Lines go to the adapter until sendCtx finishes.
+func (e *ioEndpoint) txLine(ctx, sendCtx context.Context, t *Tokeniser, errCh chan<- error) error {
+	line, err := t.ReadLine()
+	if err != nil {
+		return err
+	}
+	e.noteBuffered(t.Buffered())
+
+	var msgs []message.Message
+	for ok := true; ok; {
+		if msgs, err = e.takeLine(ctx, line, errCh, msgs, true); err != nil {
+			return err
+		}
+
+		if e.batch == nil {
+			break
+		}
+		if line, ok, err = t.ReadBufferedLine(); err != nil {
+			// Send what we have, so that the client gets replies to the lines before the bad one.
+			_ = e.transmit(sendCtx, msgs)
+			return err
+		}
+		e.noteBuffered(t.Buffered())
+	}
+	return e.transmit(sendCtx, msgs)
+}
+
+// takeLine handles line, adding it to msgs if it is a message for the adapter, and returning msgs.
+// If outer, line came from the connection itself, rather than from a compressed block (see ZlinesWord), so may
+// itself be a compressed block.
+// It only fails on errors that should stop e reading lines.
+func (e *ioEndpoint) takeLine(ctx context.Context, line []string, errCh chan<- error, msgs []message.Message,
+	outer bool) ([]message.Message, error) {
+	msg, err := LineToMessage(line, e.input)
+	if err == nil && e.limiter != nil && !e.limiter.take(e.clock.Now()) {
+		err = ErrRateLimited
+	}
+	if len(line) == 0 {
+		// Blank lines carry no request, so there is nothing to reply to.
+	} else if errors.Is(err, ErrControlChar) || errors.Is(err, ErrMalformedLine) || errors.Is(err, ErrRateLimited) {
+		e.sendError(ctx, errCh, err)
+		return msgs, e.reject(line, err)
+	} else if err != nil {
+		return msgs, err
+	} else if e.identify != nil && msg.Word() == IdentifyWord {
+		return msgs, e.identifyAs(line, *msg)
+	} else if e.self != nil && msg.Word() == WhoamiWord {
+		return msgs, e.whoami(line, *msg)
+	} else if e.limits != nil && msg.Word() == LimitsWord {
+		return msgs, e.replyLimits(line, *msg)
+	} else if e.errors != nil && msg.Word() == ErrorsWord {
+		return msgs, e.replyErrors(line, *msg)
+	} else if e.serverInfo != nil && msg.Word() == ServerInfoWord {
+		return msgs, e.replyServerInfo(line, *msg)
+	} else if outer && 0 < e.maxInflated && msg.Word() == ZlinesWord {
+		return e.takeZlines(ctx, line, *msg, errCh, msgs)
+	} else {
+		msgs = append(msgs, *msg)
+	}
+	return msgs, nil
+}
+
+// transmit sends msgs to e's adapter, as a batch if there is more than one.
+func (e *ioEndpoint) transmit(ctx context.Context, msgs []message.Message) error {
+	var ok bool
+	switch len(msgs) {
+	case 0:
+		return nil
+	case 1:
+		ok = e.endpoint.Send(ctx, msgs[0])
+	default:
+		if e.batch != nil {
+			ok = e.batch(ctx, msgs)
+			break
+		}
+		// Without batching, there is only more than one message if they came in a compressed block.
+		for _, m := range msgs {
+			if ok = e.endpoint.Send(ctx, m); !ok {
+				break
+			}
+		}
+	}
+	if !ok {
+		return errors.New("client died while sending message")
+	}
+	return nil
+}
+
+// reject tells the client that sent line that it was rejected because of err.
+// The reply carries line's tag, as the Controller's own error replies do, but err is quoted rather than echoing line.
+// It goes through the send queue, so that it is sequenced along with everything else sent to the client.
+func (e *ioEndpoint) reject(line []string, err error) error {
+	tag := message.TagBcast
+	if 0 < len(line) && e.input.Check(line[:1]) == nil {
+		tag = line[0]
+	}
+
+	m := *message.New(tag, core.RsAck).AddArgs("WHAT", err.Error())
+	e.noteError(m)
+	if qerr := e.queue.push(m); qerr != nil {
+		e.failSend(qerr)
+		return qerr
+	}
+	return nil
+}
+
+// sendError tries to send an error err to the error channel errCh.
+// It silently fails if ctx is cancelled.
+func (e *ioEndpoint) sendError(ctx context.Context, errCh chan<- error, err error) {
+	select {
+	case errCh <- err:
+	case <-ctx.Done():
+	}
+}
@@ -0,0 +1,311 @@
+package netsrv_test
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"io"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/UniversityRadioYork/baps3d/controller"
+	"github.com/UniversityRadioYork/baps3d/netsrv"
+)
+
+// runEndpoint runs e in the background, discarding its errors.
+// It returns a channel that closes once e's Run returns.
+func runEndpoint(ctx context.Context, e netsrv.Endpoint) <-chan struct{} {
+	errCh := make(chan error)
+	go func() {
+		for range errCh {
+		}
+	}()
+
+	done := make(chan struct{})
+	go func() {
+		e.Run(ctx, errCh)
+		close(done)
+	}()
+	return done
+}
+
+// TestEndpoint_Close tests that closing an endpoint detaches its adapter, but writes out everything queued, and
+// everything the adapter sends before it goes, before closing the connection.
+func TestEndpoint_Close(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	conn, peer := net.Pipe()
+	defer peer.Close()
+	e, adapter := netsrv.NewEndpoint(conn)
+	done := runEndpoint(ctx, e)
+
+	// Nobody is reading the connection yet, so these stay queued.
+	for i := 0; i < 3; i++ {
+		adapter.Tx <- controller.NewMessage("!", "QUEUED")
+	}
+	fctx, fcancel := context.WithTimeout(ctx, 50*time.Millisecond)
+	defer fcancel()
+	if err := e.Flush(fctx); !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("flush with nobody reading: got %v, want %v", err, context.DeadlineExceeded)
+	}
+
+	for i := 0; i < 2; i++ {
+		if err := e.Close(); err != nil {
+			t.Fatalf("close %d: unexpected error: %v", i, err)
+		}
+	}
+
+	// Closing stops the endpoint sending requests, which is how the adapter knows to go.
+	go func() {
+		for range adapter.Rx {
+		}
+		adapter.Tx <- controller.NewMessage("!", "LAST")
+		close(adapter.Tx)
+	}()
+
+	rd := bufio.NewReader(peer)
+	for _, want := range []string{"! QUEUED\n", "! QUEUED\n", "! QUEUED\n", "! LAST\n"} {
+		line, err := rd.ReadString('\n')
+		if err != nil {
+			t.Fatalf("couldn't read %q: %v", want, err)
+		}
+		if line != want {
+			t.Errorf("got %q, want %q", line, want)
+		}
+	}
+	if _, err := rd.ReadString('\n'); err != io.EOF {
+		t.Errorf("got %v after the last message, want EOF", err)
+	}
+
+	waitFor(t, done, "endpoint to finish")
+	if err := e.Flush(ctx); err != nil {
+		t.Errorf("flush after closing: unexpected error: %v", err)
+	}
+	if err := e.Close(); err != nil {
+		t.Errorf("close after Run returned: unexpected error: %v", err)
+	}
+}
+
+// TestEndpoint_Flush tests that flushing waits for queued messages to be written, and fails if the connection breaks
+// first.
+func TestEndpoint_Flush(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	conn, peer := net.Pipe()
+	e, adapter := netsrv.NewEndpoint(conn)
+	done := runEndpoint(ctx, e)
+	go func() {
+		for range adapter.Rx {
+		}
+	}()
+
+	adapter.Tx <- controller.NewMessage("!", "ONE")
+	read := make(chan error, 1)
+	go func() {
+		_, err := bufio.NewReader(peer).ReadString('\n')
+		read <- err
+	}()
+	if err := e.Flush(ctx); err != nil {
+		t.Errorf("unexpected flush error: %v", err)
+	}
+	if err := <-read; err != nil {
+		t.Errorf("couldn't read flushed message: %v", err)
+	}
+
+	// The endpoint only takes the second message once it has queued the first.
+	adapter.Tx <- controller.NewMessage("!", "TWO")
+	adapter.Tx <- controller.NewMessage("!", "THREE")
+	if err := peer.Close(); err != nil {
+		t.Fatalf("couldn't close peer: %v", err)
+	}
+	if err := e.Flush(ctx); err == nil {
+		t.Error("flush over a broken connection: expected an error")
+	}
+
+	close(adapter.Tx)
+	waitFor(t, done, "endpoint to finish")
+}
+
+// tcpPair opens a loopback TCP connection, returning both ends.
+func tcpPair(t *testing.T) (net.Conn, net.Conn) {
+	t.Helper()
+
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("couldn't listen: %v", err)
+	}
+	defer ln.Close()
+
+	peer, err := net.Dial("tcp", ln.Addr().String())
+	if err != nil {
+		t.Fatalf("couldn't dial: %v", err)
+	}
+	conn, err := ln.Accept()
+	if err != nil {
+		peer.Close()
+		t.Fatalf("couldn't accept: %v", err)
+	}
+	return conn, peer
+}
+
+// TestEndpoint_Linger tests that a lingering endpoint half-closes its connection, and drains what the client sends
+// afterwards rather than resetting the connection, until the client hangs up.
+func TestEndpoint_Linger(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	conn, peer := tcpPair(t)
+	defer peer.Close()
+	e, adapter := netsrv.NewEndpoint(conn)
+	e.SetLinger(testTimeout)
+	done := runEndpoint(ctx, e)
+
+	go func() {
+		for range adapter.Rx {
+		}
+		adapter.Tx <- controller.NewMessage("!", "LAST")
+		close(adapter.Tx)
+	}()
+	if err := e.Close(); err != nil {
+		t.Fatalf("unexpected error closing: %v", err)
+	}
+
+	rd := bufio.NewReader(peer)
+	if line, err := rd.ReadString('\n'); err != nil || line != "! LAST\n" {
+		t.Fatalf("got (%q, %v), want the last message", line, err)
+	}
+	if _, err := rd.ReadString('\n'); err != io.EOF {
+		t.Fatalf("got %v after the last message, want EOF", err)
+	}
+
+	// The server's side is still open, so what we send now goes somewhere, and doesn't reset the connection.
+	if _, err := peer.Write([]byte("late request\n")); err != nil {
+		t.Fatalf("couldn't write after the half-close: %v", err)
+	}
+	select {
+	case <-done:
+		t.Fatal("endpoint closed before the client hung up")
+	case <-time.After(50 * time.Millisecond):
+	}
+	if _, err := rd.ReadString('\n'); err != io.EOF {
+		t.Errorf("got %v after writing to a lingering endpoint, want EOF", err)
+	}
+
+	peer.(*net.TCPConn).CloseWrite()
+	select {
+	case <-done:
+	case <-time.After(testTimeout / 2):
+		t.Fatal("endpoint still lingering after the client hung up")
+	}
+}
+
+// TestEndpoint_Buffered tests that an endpoint counts the lines waiting behind the one it is sending to its adapter.
+func TestEndpoint_Buffered(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	conn, peer := net.Pipe()
+	defer peer.Close()
+	e, adapter := netsrv.NewEndpoint(conn)
+	runEndpoint(ctx, e)
+
+	// A pipe hands the whole write to one read, so all three lines arrive together.
+	if _, err := io.WriteString(peer, "t1 a\nt2 b\nt3 c\n"); err != nil {
+		t.Fatalf("couldn't write: %v", err)
+	}
+
+	<-adapter.Rx
+	// The endpoint may already have taken the second line, so only the peak is certain here.
+	if _, peak := e.Buffered(); peak != 2 {
+		t.Errorf("after the first line: got peak %d, want 2", peak)
+	}
+	<-adapter.Rx
+	<-adapter.Rx
+	// The endpoint counts the lines left before sending each one, so nothing changes after the last.
+	if n, peak := e.Buffered(); n != 0 || peak != 2 {
+		t.Errorf("after the last line: got %d/%d buffered, want 0/2", n, peak)
+	}
+}
+
+// timeoutError is a net.Error that says it is a timeout.
+type timeoutError struct{}
+
+func (timeoutError) Error() string   { return "i/o timeout" }
+func (timeoutError) Timeout() bool   { return true }
+func (timeoutError) Temporary() bool { return true }
+
+// flakyConn is a net.Conn whose first few writes only get half-way, failing with err.
+type flakyConn struct {
+	net.Conn
+	fails int
+	err   error
+}
+
+func (c *flakyConn) Write(b []byte) (int, error) {
+	if c.fails == 0 {
+		return c.Conn.Write(b)
+	}
+	c.fails--
+	n, err := c.Conn.Write(b[:len(b)/2])
+	if err != nil {
+		return n, err
+	}
+	return n, c.err
+}
+
+// TestEndpoint_WriteRetries tests that an endpoint retries the rest of a timed-out write, but gives up once the
+// retries run out, and at once on any other error.
+func TestEndpoint_WriteRetries(t *testing.T) {
+	cases := []struct {
+		name  string
+		fails int
+		err   error
+		ok    bool
+	}{
+		{"recovers", 2, timeoutError{}, true},
+		{"too many timeouts", 3, timeoutError{}, false},
+		{"dead", 1, io.ErrClosedPipe, false},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			ctx, cancel := context.WithCancel(context.Background())
+			defer cancel()
+
+			conn, peer := net.Pipe()
+			defer peer.Close()
+			e, adapter := netsrv.NewEndpoint(&flakyConn{Conn: conn, fails: c.fails, err: c.err})
+			e.SetWriteRetries(time.Minute, 2, 0)
+			errCh := make(chan error, 8)
+			go e.Run(ctx, errCh)
+			go func() {
+				for range adapter.Rx {
+				}
+			}()
+
+			adapter.Tx <- controller.NewMessage("!", "HELLO", "world")
+			line, err := bufio.NewReader(peer).ReadString('\n')
+			if c.ok {
+				if err != nil || line != "! HELLO world\n" {
+					t.Errorf("got %q, %v, want the whole message", line, err)
+				}
+				return
+			}
+
+			if err == nil {
+				t.Errorf("got %q, want the connection to close", line)
+			}
+			select {
+			case err := <-errCh:
+				if !errors.Is(err, c.err) {
+					t.Errorf("got error %v, want %v", err, c.err)
+				}
+			case <-time.After(time.Second):
+				t.Error("no error reported")
+			}
+		})
+	}
+}
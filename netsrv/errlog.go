@@ -0,0 +1,79 @@
+package netsrv
+
+import (
+	"log"
+	"sync"
+	"time"
+
+	"github.com/UniversityRadioYork/baps3d/controller"
+)
+
+// errorQuietPeriod is how long a connection must go without a repeated error before ErrorLimiter stops coalescing.
+const errorQuietPeriod = 10 * time.Second
+
+// ErrorLimiter logs errors for one connection, coalescing runs of identical errors so that a misbehaving client
+// can't flood the log.
+//
+// The first occurrence of an error is always logged in full, as is any error different from the one before it.
+// Repeats of the previous error are counted instead of logged, until either a different error arrives, the
+// connection goes quiet for the quiet period, or Flush is called; then ErrorLimiter logs how many repeats it
+// swallowed.
+// A repeat arriving after the quiet period counts as a fresh error, and is logged in full.
+//
+// ErrorLimiter is safe for concurrent use.
+type ErrorLimiter struct {
+	log   *log.Logger
+	quiet time.Duration
+	clock controller.Clock
+
+	mu       sync.Mutex
+	last     string
+	lastTime time.Time
+	repeats  int
+}
+
+// NewErrorLimiter creates an ErrorLimiter logging to l, which stops coalescing after quiet passes without a repeat.
+func NewErrorLimiter(l *log.Logger, quiet time.Duration) *ErrorLimiter {
+	return &ErrorLimiter{log: l, quiet: quiet, clock: controller.SystemClock{}}
+}
+
+// SetClock sets the Clock e uses to time the quiet period; it defaults to controller.SystemClock.
+// It must be called before Log.
+func (e *ErrorLimiter) SetClock(clk controller.Clock) {
+	e.clock = clk
+}
+
+// Log logs, or counts, the error message msg.
+func (e *ErrorLimiter) Log(msg string) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	now := e.clock.Now()
+	if msg == e.last && now.Sub(e.lastTime) < e.quiet {
+		e.repeats++
+		e.lastTime = now
+		return
+	}
+
+	e.flush()
+	e.log.Println(msg)
+	e.last = msg
+	e.lastTime = now
+}
+
+// Flush logs how many repeats of the last error have been swallowed, if any.
+func (e *ErrorLimiter) Flush() {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	e.flush()
+}
+
+// flush does the work of Flush; e.mu must be held.
+func (e *ErrorLimiter) flush() {
+	if e.repeats == 0 {
+		return
+	}
+	e.log.Printf("(last error repeated %d more times)\n", e.repeats)
+	e.repeats = 0
+}
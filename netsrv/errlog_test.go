@@ -0,0 +1,100 @@
+package netsrv_test
+
+import (
+	"bytes"
+	"log"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/UniversityRadioYork/baps3d/controller"
+	"github.com/UniversityRadioYork/baps3d/netsrv"
+)
+
+// manualClock is a controller.Clock whose time only moves when told to.
+// Its timers and tickers are the system's.
+type manualClock struct {
+	controller.SystemClock
+
+	mu  sync.Mutex
+	now time.Time
+}
+
+func (c *manualClock) Now() time.Time {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.now
+}
+
+// Advance moves c's time on by d.
+func (c *manualClock) Advance(d time.Duration) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.now = c.now.Add(d)
+}
+
+// logLines splits the contents of buf into lines.
+func logLines(buf *bytes.Buffer) []string {
+	s := strings.TrimSpace(buf.String())
+	if s == "" {
+		return nil
+	}
+	return strings.Split(s, "\n")
+}
+
+// TestErrorLimiter_Coalesce tests that an ErrorLimiter logs the first error in full and counts identical repeats.
+func TestErrorLimiter_Coalesce(t *testing.T) {
+	var buf bytes.Buffer
+	el := netsrv.NewErrorLimiter(log.New(&buf, "", 0), time.Hour)
+
+	for i := 0; i < 100; i++ {
+		el.Log("bad line")
+	}
+	if got := logLines(&buf); len(got) != 1 || got[0] != "bad line" {
+		t.Fatalf("after a storm: got %q, want just the first error", got)
+	}
+
+	// A different error flushes the count and is logged straight away.
+	el.Log("other error")
+	el.Flush()
+	want := []string{"bad line", "(last error repeated 99 more times)", "other error"}
+	if got := logLines(&buf); strings.Join(got, "|") != strings.Join(want, "|") {
+		t.Errorf("got %q, want %q", got, want)
+	}
+}
+
+// TestErrorLimiter_Quiescence tests that an ErrorLimiter logs an error in full again after going quiet.
+func TestErrorLimiter_Quiescence(t *testing.T) {
+	var buf bytes.Buffer
+	el := netsrv.NewErrorLimiter(log.New(&buf, "", 0), 10*time.Millisecond)
+	clk := &manualClock{now: time.Unix(0, 0)}
+	el.SetClock(clk)
+
+	el.Log("bad line")
+	clk.Advance(9 * time.Millisecond)
+	el.Log("bad line")
+	clk.Advance(10 * time.Millisecond)
+	el.Log("bad line")
+
+	want := []string{"bad line", "(last error repeated 1 more times)", "bad line"}
+	if got := logLines(&buf); strings.Join(got, "|") != strings.Join(want, "|") {
+		t.Errorf("got %q, want %q", got, want)
+	}
+}
+
+// TestErrorLimiter_Sporadic tests that an ErrorLimiter logs distinct errors immediately.
+func TestErrorLimiter_Sporadic(t *testing.T) {
+	var buf bytes.Buffer
+	el := netsrv.NewErrorLimiter(log.New(&buf, "", 0), time.Hour)
+
+	el.Log("one")
+	el.Log("two")
+	el.Log("one")
+	el.Flush()
+
+	want := []string{"one", "two", "one"}
+	if got := logLines(&buf); strings.Join(got, "|") != strings.Join(want, "|") {
+		t.Errorf("got %q, want %q", got, want)
+	}
+}
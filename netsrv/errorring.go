@@ -0,0 +1,125 @@
+package netsrv
+
+// File errorring.go contains per-connection error histories, with which client developers can see why their
+// requests keep failing without access to the server's logs; see ErrorsWord.
+
+import (
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/UniversityRadioYork/bifrost-go/core"
+	"github.com/UniversityRadioYork/bifrost-go/message"
+
+	"github.com/UniversityRadioYork/baps3d/controller"
+)
+
+// ErrorsWord is the command word of requests a client sends to ask about its own recent errors, for example
+// 'tag errors'.
+// If the Server keeps error histories (see Server.ErrorHistory), it handles these itself, without passing them on to
+// the Controller, and replies with an ERROR message (see RsError) per error, oldest first, then an ACK.
+const ErrorsWord = "errors"
+
+// RsError is the response word of replies to errors requests.
+// The arguments are the time of the error, the tag of the request that caused it, its ACK status (such as 'WHAT' or
+// 'FAIL'), and its reason, cut to at most MaxErrorReasonLen bytes.
+const RsError = "ERROR"
+
+// MaxErrorReasonLen is the longest, in bytes, that the reason of an error in an error history can be.
+// Reasons can quote what the client sent, so this keeps a history of the errors from large requests small.
+const MaxErrorReasonLen = 256
+
+// connError is one error in a connection's error history.
+type connError struct {
+	// at is when the error was sent to the client.
+	at time.Time
+	// tag is the tag of the request that caused the error.
+	tag string
+	// status is the ACK status of the error.
+	status string
+	// reason is the error's reason, cut to MaxErrorReasonLen bytes.
+	reason string
+}
+
+// errorRing holds the latest errors sent to a client, up to a fixed number.
+// It is safe to use from several goroutines at once.
+type errorRing struct {
+	mu sync.Mutex
+	// errs holds the errors, as a ring buffer starting at next once it is full.
+	errs []connError
+	// next is the index in errs of the next error to overwrite, once errs is full.
+	next int
+	// max is the most errors the ring holds.
+	max int
+}
+
+// newErrorRing creates an errorRing holding the max latest errors.
+func newErrorRing(max int) *errorRing {
+	return &errorRing{max: max}
+}
+
+// note records m in r, if it is an ACK with an error, as sent at at.
+func (r *errorRing) note(m message.Message, at time.Time) {
+	args := m.Args()
+	if m.Word() != core.RsAck || len(args) < 2 || args[0] == "OK" {
+		return
+	}
+
+	// Copying the strings means the history doesn't hold on to the message, or anything the reason was sliced from.
+	ce := connError{at: at, tag: copyString(m.Tag()), status: copyString(args[0]), reason: copyString(args[1])}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if len(r.errs) < r.max {
+		r.errs = append(r.errs, ce)
+		return
+	}
+	r.errs[r.next] = ce
+	r.next = (r.next + 1) % r.max
+}
+
+// list gets the errors in r, oldest first.
+func (r *errorRing) list() []connError {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	out := make([]connError, 0, len(r.errs))
+	out = append(out, r.errs[r.next:]...)
+	return append(out, r.errs[:r.next]...)
+}
+
+// copyString copies s into new memory, cut to MaxErrorReasonLen bytes.
+func copyString(s string) string {
+	if MaxErrorReasonLen < len(s) {
+		s = s[:MaxErrorReasonLen]
+	}
+	return string([]byte(s))
+}
+
+// replyErrors handles the errors request m, which came from line.
+// Like rejections, the replies don't go through the Controller, so may overtake replies to earlier lines.
+func (e *ioEndpoint) replyErrors(line []string, m message.Message) error {
+	if len(m.Args()) != 0 {
+		return e.reject(line, fmt.Errorf("%s takes no arguments", ErrorsWord))
+	}
+
+	ces := e.errors.list()
+	rs := make([]message.Message, 0, len(ces)+1)
+	for _, ce := range ces {
+		rs = append(rs, controller.NewMessage(m.Tag(), RsError, controller.FormatTime(ce.at), ce.tag, ce.status, ce.reason))
+	}
+	rs = append(rs, controller.NewMessage(m.Tag(), core.RsAck, "OK", "success"))
+	for _, r := range rs {
+		if err := e.queue.push(r); err != nil {
+			e.failSend(err)
+			return err
+		}
+	}
+	return nil
+}
+
+// noteError records m in e's error history, if e keeps one and m is an error.
+func (e *ioEndpoint) noteError(m message.Message) {
+	if e.errors != nil {
+		e.errors.note(m, e.clock.Now())
+	}
+}
@@ -0,0 +1,48 @@
+package netsrv_test
+
+import (
+	"fmt"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/UniversityRadioYork/baps3d/netsrv"
+)
+
+// TestServer_ErrorHistory tests that an errors request gives the latest errors sent to the client, oldest first, and
+// no more than the Server's ErrorHistory.
+func TestServer_ErrorHistory(t *testing.T) {
+	ts := startServer(t, func(s *netsrv.Server) {
+		s.ErrorHistory = 2
+	})
+	defer ts.Cancel()
+
+	conn, rd := ts.dial(t)
+	defer func() { _ = conn.Close() }()
+
+	for _, tag := range []string{"a", "b", "c"} {
+		if _, err := fmt.Fprintf(conn, "%s nosuchword\n", tag); err != nil {
+			t.Fatalf("couldn't send line: %v", err)
+		}
+		readUntilAck(t, rd, tag)
+	}
+
+	if _, err := fmt.Fprintln(conn, "t errors"); err != nil {
+		t.Fatalf("couldn't send line: %v", err)
+	}
+	var tags []string
+	for _, line := range readUntilAck(t, rd, "t") {
+		fields := strings.Fields(line)
+		if len(fields) < 5 || fields[0] != "t" || fields[1] != netsrv.RsError {
+			continue
+		}
+		// The time of the error comes before its tag and status.
+		if fields[4] != "WHAT" {
+			t.Errorf("%q: got status %q, want WHAT", line, fields[4])
+		}
+		tags = append(tags, fields[3])
+	}
+	if want := []string{"b", "c"}; !reflect.DeepEqual(tags, want) {
+		t.Errorf("got errors for %v, want %v", tags, want)
+	}
+}
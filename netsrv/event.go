@@ -0,0 +1,111 @@
+package netsrv
+
+// File event.go contains the connection lifecycle events a Server can emit.
+
+import (
+	"net"
+	"time"
+)
+
+// EventKind is the type of kinds of connection lifecycle event.
+type EventKind int
+
+const (
+	// EventConnect is the kind of events sent when a new client has been set up.
+	EventConnect EventKind = iota
+	// EventDisconnect is the kind of events sent when a client has been hung up.
+	EventDisconnect
+)
+
+// String gets the descriptive name of an EventKind as a string.
+func (k EventKind) String() string {
+	switch k {
+	case EventConnect:
+		return "connect"
+	case EventDisconnect:
+		return "disconnect"
+	default:
+		return "?unknown?"
+	}
+}
+
+// Event is a machine-readable record of a connection lifecycle transition.
+type Event struct {
+	// Kind is the kind of transition.
+	Kind EventKind
+	// ClientID is the server-assigned identifier of the client.
+	// Identifiers are unique for the lifetime of a Server.
+	ClientID uint64
+	// RemoteAddr is the remote address of the client's connection.
+	// IPv6 addresses are in brackets, with any zone; clients of Unix domain sockets, which have no address of their
+	// own, show as 'local:' followed by the socket's path.
+	RemoteAddr string
+	// IP is the IP address of the client's connection, as given by RemoteIP.
+	IP net.IP
+	// Channel is the name of the Server channel the client connected to.
+	Channel string
+	// Name is the display name the client gave itself (see IdentifyWord), or RemoteAddr if it hasn't given one.
+	// Clients can only identify after connecting, so connect events always give RemoteAddr.
+	Name string
+	// Time is the time at which the transition happened.
+	Time time.Time
+	// Reason, for disconnects, describes why the client was hung up.
+	Reason string
+	// Err, for disconnects with ReasonConnectionError (or another reason caused by an error, such as ReasonTimeout),
+	// is the error that caused the disconnect.
+	Err error
+}
+
+// Reasons given in disconnect events.
+const (
+	// ReasonHungUp is the reason given when a client closed its own connection.
+	ReasonHungUp = "client hung up"
+	// ReasonConnectionError is the reason given when a client was hung up because of an error on its connection,
+	// such as a failed read or an unparseable line.
+	ReasonConnectionError = "connection error"
+	// ReasonTimeout is the reason given when a client was hung up for going quiet for longer than the Server's
+	// HandshakeTimeout or IdleTimeout; the event's Err is ErrHandshakeTimeout or ErrIdleTimeout.
+	// Unlike ReasonConnectionError, it means the connection itself was still working.
+	ReasonTimeout = "timed out"
+	// ReasonShutdown is the reason given when the server hangs up all clients to shut down.
+	ReasonShutdown = "server shutting down"
+	// ReasonPanic is the reason given when a client was hung up because of a panic in its setup or one of its
+	// goroutines; the event's Err is a PanicError.
+	// Panics in setup happen before the client connects, so their disconnect events come without connect events.
+	ReasonPanic = "panic"
+	// ReasonByteBudget is the reason given when a client was hung up for using up its byte budget; see
+	// Server.ByteBudget.
+	ReasonByteBudget = "byte budget exhausted"
+)
+
+// emit sends the event e to s's event channel, if it has one.
+// It never blocks: if nobody is ready to receive the event, it is dropped.
+func (s *Server) emit(e Event) {
+	if s.Events == nil {
+		return
+	}
+
+	select {
+	case s.Events <- e:
+	default:
+	}
+}
+
+// emitFor sends an event of kind k, for the client c and with reason reason and error err, to s's event channel.
+func (s *Server) emitFor(k EventKind, c *Client, reason string, err error) {
+	if s.Events == nil {
+		return
+	}
+
+	s.emit(Event{
+		Kind:       k,
+		ClientID:   c.id,
+		RemoteAddr: c.name,
+		IP:         c.ip,
+		Channel:    c.channel,
+		Name:       c.displayName(),
+		Time:       s.clock().Now(),
+		Reason:     reason,
+		Err:        err,
+	})
+}
@@ -0,0 +1,132 @@
+package netsrv_test
+
+import (
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/UniversityRadioYork/baps3d/netsrv"
+)
+
+// nextEvent receives the next event from events, failing the test if none arrives in time.
+func nextEvent(t *testing.T, events <-chan netsrv.Event) netsrv.Event {
+	t.Helper()
+
+	var e netsrv.Event
+	select {
+	case e = <-events:
+	case <-time.After(testTimeout):
+		t.Fatal("timed out waiting for event")
+	}
+	return e
+}
+
+// checkEvent checks that the event got has the given kind, client ID, remote address, and reason.
+func checkEvent(t *testing.T, got netsrv.Event, kind netsrv.EventKind, id uint64, addr, reason string) {
+	t.Helper()
+
+	if got.Kind != kind {
+		t.Errorf("event kind: got %v, want %v", got.Kind, kind)
+	}
+	if got.ClientID != id {
+		t.Errorf("%v event client ID: got %d, want %d", kind, got.ClientID, id)
+	}
+	if got.RemoteAddr != addr {
+		t.Errorf("%v event address: got %s, want %s", kind, got.RemoteAddr, addr)
+	}
+	if got.Reason != reason {
+		t.Errorf("%v event reason: got %q, want %q", kind, got.Reason, reason)
+	}
+	if got.Time.IsZero() {
+		t.Errorf("%v event has no timestamp", kind)
+	}
+}
+
+// TestServer_Events tests that connect and disconnect events arrive with the right details, and that clean
+// disconnects are told apart from error-driven ones.
+func TestServer_Events(t *testing.T) {
+	events := make(chan netsrv.Event, 8)
+	ts := startServer(t, func(s *netsrv.Server) {
+		s.Events = events
+		s.MaxWordLen = 8
+	})
+	defer ts.Cancel()
+
+	// Client 0 hangs up cleanly.
+	conn, _ := ts.dial(t)
+	addr := conn.LocalAddr().String()
+	checkEvent(t, nextEvent(t, events), netsrv.EventConnect, 0, addr, "")
+	if err := conn.Close(); err != nil {
+		t.Fatalf("couldn't close connection: %v", err)
+	}
+	e := nextEvent(t, events)
+	checkEvent(t, e, netsrv.EventDisconnect, 0, addr, netsrv.ReasonHungUp)
+	if e.Err != nil {
+		t.Errorf("clean disconnect has error: %v", e.Err)
+	}
+
+	// Client 1 sends a line that can't be read, as it has an overlong word.
+	conn, _ = ts.dial(t)
+	defer func() { _ = conn.Close() }()
+	addr = conn.LocalAddr().String()
+	checkEvent(t, nextEvent(t, events), netsrv.EventConnect, 1, addr, "")
+	if _, err := fmt.Fprintln(conn, "t1 dump 123456789"); err != nil {
+		t.Fatalf("couldn't send line: %v", err)
+	}
+	e = nextEvent(t, events)
+	checkEvent(t, e, netsrv.EventDisconnect, 1, addr, netsrv.ReasonConnectionError)
+	if e.Err == nil {
+		t.Error("error-driven disconnect has no error")
+	}
+}
+
+// TestServer_Events_NonBlocking tests that an events channel nobody reads never stalls the server.
+func TestServer_Events_NonBlocking(t *testing.T) {
+	cases := map[string]chan netsrv.Event{
+		"unbuffered": make(chan netsrv.Event),
+		"full":       make(chan netsrv.Event, 1),
+	}
+	cases["full"] <- netsrv.Event{}
+
+	for name, events := range cases {
+		events := events
+		t.Run(name, func(t *testing.T) {
+			ts := startServer(t, func(s *netsrv.Server) { s.Events = events })
+			defer ts.Cancel()
+
+			for i := 0; i < 3; i++ {
+				conn, _ := ts.dial(t)
+				if err := conn.Close(); err != nil {
+					t.Fatalf("couldn't close connection: %v", err)
+				}
+			}
+
+			ts.Cancel()
+			waitFor(t, ts.Done, "server to stop")
+		})
+	}
+}
+
+// TestServer_Events_Clock tests that events take their times from the Server's Clock.
+func TestServer_Events_Clock(t *testing.T) {
+	events := make(chan netsrv.Event, 8)
+	clk := &manualClock{now: time.Date(2020, time.January, 1, 12, 0, 0, 0, time.UTC)}
+	ts := startServer(t, func(s *netsrv.Server) {
+		s.Events = events
+		s.Clock = clk
+	})
+	defer ts.Cancel()
+
+	conn, _ := ts.dial(t)
+	if e := nextEvent(t, events); !e.Time.Equal(clk.Now()) {
+		t.Errorf("connect event time: got %v, want %v", e.Time, clk.Now())
+	}
+
+	clk.Advance(time.Hour)
+	if err := conn.Close(); err != nil {
+		t.Fatalf("couldn't close connection: %v", err)
+	}
+	if e := nextEvent(t, events); !e.Time.Equal(clk.Now()) {
+		t.Errorf("disconnect event time: got %v, want %v", e.Time, clk.Now())
+	}
+}
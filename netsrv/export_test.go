@@ -0,0 +1,148 @@
+package netsrv
+
+import (
+	"context"
+	"crypto/tls"
+	"log"
+	"net"
+	"time"
+
+	"github.com/UniversityRadioYork/bifrost-go/comm"
+	"github.com/UniversityRadioYork/bifrost-go/message"
+
+	"github.com/UniversityRadioYork/baps3d/controller"
+)
+
+// This file exposes internals to the external netsrv_test package.
+
+// SendQueue is sendQueue, for testing.
+type SendQueue struct {
+	q *sendQueue
+}
+
+// NewSendQueue creates a SendQueue, for testing.
+func NewSendQueue(max int, policy SendPolicy, latestWins func(word string) bool) SendQueue {
+	return SendQueue{newSendQueue(max, policy, latestWins)}
+}
+
+// Push pushes m onto q.
+func (q SendQueue) Push(m message.Message) error {
+	return q.q.push(m)
+}
+
+// Drain closes q and pops every message left on it.
+func (q SendQueue) Drain() []message.Message {
+	q.q.close()
+
+	var msgs []message.Message
+	for {
+		m, ok := q.q.pop()
+		if !ok {
+			return msgs
+		}
+		msgs = append(msgs, m)
+	}
+}
+
+// Dropped gets the number of messages q has dropped.
+func (q SendQueue) Dropped() uint64 {
+	return q.q.droppedCount()
+}
+
+// Depth gets the number of messages on q, the most there have been, and the most there can be.
+func (q SendQueue) Depth() (queued, peak, max int) {
+	return q.q.depth()
+}
+
+// TokenBucket is tokenBucket, for testing.
+type TokenBucket struct {
+	b *tokenBucket
+}
+
+// NewTokenBucket creates a TokenBucket, for testing.
+func NewTokenBucket(rate float64, burst int, now time.Time) TokenBucket {
+	return TokenBucket{newTokenBucket(rate, burst, now)}
+}
+
+// Take tries to take a token from b at now.
+func (b TokenBucket) Take(now time.Time) bool {
+	return b.b.take(now)
+}
+
+// Delay takes n tokens from b at now, returning how long to wait before using them.
+func (b TokenBucket) Delay(n int, now time.Time) time.Duration {
+	return b.b.delay(n, now)
+}
+
+// Endpoint is ioEndpoint, for testing.
+type Endpoint struct {
+	e *ioEndpoint
+}
+
+// NewEndpoint creates an Endpoint over conn, with a default send queue.
+// It also returns the adapter's side of the endpoint's channels, for the test to play the adapter.
+func NewEndpoint(conn net.Conn) (Endpoint, *comm.Endpoint) {
+	end, adapter := comm.NewEndpointPair()
+	e := ioEndpoint{
+		io:         conn,
+		endpoint:   end,
+		queue:      newSendQueue(0, SendDisconnect, nil),
+		writerDone: make(chan struct{}),
+		clock:      controller.SystemClock{},
+	}
+	return Endpoint{&e}, adapter
+}
+
+// NewTLSEndpoint is NewEndpoint, but with the endpoint's side of conn speaking TLS as a server configured by config.
+func NewTLSEndpoint(conn net.Conn, config *tls.Config) (Endpoint, *comm.Endpoint) {
+	return NewEndpoint(tlsConn{Conn: tls.Server(conn, config), raw: conn})
+}
+
+// SetLinger sets how long e lingers before closing its connection; see Server.Linger.
+// It must be called before Run.
+func (e Endpoint) SetLinger(d time.Duration) {
+	e.e.linger = d
+}
+
+// SetWriteRetries sets e's write timeout, and how it retries writes that time out; see Server.WriteTimeout.
+// It must be called before Run.
+func (e Endpoint) SetWriteRetries(timeout time.Duration, retries int, delay time.Duration) {
+	e.e.writeTimeout, e.e.writeRetries, e.e.writeRetryDelay = timeout, retries, delay
+}
+
+// Run runs e, sending errors to errCh.
+func (e Endpoint) Run(ctx context.Context, errCh chan<- error) {
+	e.e.Run(ctx, errCh)
+}
+
+// Close gracefully closes e.
+func (e Endpoint) Close() error {
+	return e.e.Close()
+}
+
+// Flush waits for e's queued messages to be written.
+func (e Endpoint) Flush(ctx context.Context) error {
+	return e.e.Flush(ctx)
+}
+
+// Buffered gets the number of lines read on e that are waiting to go to its adapter, and the most there have been.
+func (e Endpoint) Buffered() (n, peak int) {
+	return e.e.buffered()
+}
+
+// InheritListeners is inheritListeners, for testing.
+func InheritListeners(l *log.Logger, getenv func(string) string, pid, first, n int) ([]net.Listener, error) {
+	return inheritListeners(l, getenv, pid, first, n)
+}
+
+// SetLog makes s log to l.
+// It must be called before Run.
+func (s *Server) SetLog(l *log.Logger) {
+	s.log = l
+}
+
+// UseListeners makes s accept connections on lns, one per channel in order, rather than listening itself.
+// It must be called before Run.
+func (s *Server) UseListeners(lns ...net.Listener) {
+	s.listeners = lns
+}
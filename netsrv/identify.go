@@ -0,0 +1,78 @@
+package netsrv
+
+// File identify.go contains client self-identification; see IdentifyWord.
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/UniversityRadioYork/bifrost-go/core"
+	"github.com/UniversityRadioYork/bifrost-go/message"
+
+	"github.com/UniversityRadioYork/baps3d/controller"
+)
+
+// IdentifyWord is the command word of requests a client sends to give itself a display name, such as
+// "studio-1 playout", for example 'tag identify <name>'.
+// The Server handles these itself, without passing them on to the Controller, and replies with an ACK.
+// The name is purely informational: it shows up in logs, Events, and Stats, and changes nothing else.
+// A client can identify again to change its name; until it first identifies, its name is its remote address.
+const IdentifyWord = "identify"
+
+// MaxNameLen is the longest display name, in bytes, a client can give itself.
+const MaxNameLen = 64
+
+// ErrBadName is the error with which the Server rejects an identify request whose name is empty, too long, or has
+// control characters in it.
+var ErrBadName = errors.New("bad display name")
+
+// identifyAs handles the identify request m, which came from line.
+// Like rejections, the reply doesn't go through the Controller, so may overtake replies to earlier lines.
+func (e *ioEndpoint) identifyAs(line []string, m message.Message) error {
+	args := m.Args()
+	if len(args) != 1 {
+		return e.reject(line, fmt.Errorf("%w: %s takes one argument", ErrBadName, IdentifyWord))
+	}
+	if name := args[0]; name == "" || MaxNameLen < len(name) {
+		return e.reject(line, fmt.Errorf("%w: must be 1 to %d bytes", ErrBadName, MaxNameLen))
+	}
+	// Names end up in logs, so however lax the input policy, they mustn't be able to forge log lines.
+	if err := (InputPolicy{Strict: true}).Check(args); err != nil {
+		return e.reject(line, fmt.Errorf("%w: %v", ErrBadName, err))
+	}
+
+	e.identify(args[0])
+	if err := e.queue.push(controller.NewMessage(m.Tag(), core.RsAck, "OK", "success")); err != nil {
+		e.failSend(err)
+		return err
+	}
+	return nil
+}
+
+// identify records that c has identified itself as name.
+func (c *Client) identify(name string) {
+	c.nameMu.Lock()
+	c.identity = name
+	c.nameMu.Unlock()
+
+	c.log.Printf("client %d (%s) identified as %q\n", c.id, c.name, name)
+}
+
+// describe describes c for logs: its display name, followed by its remote address if that isn't the same.
+func (c *Client) describe() string {
+	if dn := c.displayName(); dn != c.name {
+		return fmt.Sprintf("%s (%s)", dn, c.name)
+	}
+	return c.name
+}
+
+// displayName gets the name c identified itself with, or its remote address if it hasn't.
+// It is safe to call from any goroutine.
+func (c *Client) displayName() string {
+	c.nameMu.Lock()
+	defer c.nameMu.Unlock()
+	if c.identity == "" {
+		return c.name
+	}
+	return c.identity
+}
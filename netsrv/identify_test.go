@@ -0,0 +1,76 @@
+package netsrv_test
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/UniversityRadioYork/baps3d/netsrv"
+)
+
+// TestServer_Identify tests that a client's display name defaults to its address, that identifying changes it in
+// Stats and disconnect events, and that bad names are rejected without disconnecting the client.
+func TestServer_Identify(t *testing.T) {
+	events := make(chan netsrv.Event, 8)
+	ts := startServer(t, func(s *netsrv.Server) { s.Events = events })
+	defer ts.Cancel()
+
+	conn, rd := ts.dial(t)
+	defer func() { _ = conn.Close() }()
+	addr := conn.LocalAddr().String()
+	if e := nextEvent(t, events); e.Name != addr {
+		t.Errorf("connect event name: got %q, want %q", e.Name, addr)
+	}
+
+	name := func() string {
+		t.Helper()
+
+		ctx, cancel := context.WithTimeout(context.Background(), testTimeout)
+		defer cancel()
+		st, ok := ts.Server.Stats(ctx)
+		if !ok || len(st.Clients) != 1 {
+			t.Fatalf("couldn't get stats for one client: %+v", st)
+		}
+		return st.Clients[0].Name
+	}
+	if got := name(); got != addr {
+		t.Errorf("name before identifying: got %q, want %q", got, addr)
+	}
+
+	cases := []struct {
+		line, ack, name string
+	}{
+		{"t1 identify 'studio-1 playout'", "t1 ACK OK", "studio-1 playout"},
+		{"t2 identify " + strings.Repeat("x", netsrv.MaxNameLen+1), "t2 ACK WHAT", "studio-1 playout"},
+		{"t3 identify a b", "t3 ACK WHAT", "studio-1 playout"},
+		{"t4 identify web-dashboard", "t4 ACK OK", "web-dashboard"},
+		{"t5 identify 'web\x1b[2Jforged'", "t5 ACK WHAT", "web-dashboard"},
+	}
+	for _, c := range cases {
+		if _, err := fmt.Fprintln(conn, c.line); err != nil {
+			t.Fatalf("couldn't send line: %v", err)
+		}
+		// The dump the server sends on connecting may still be arriving.
+		var ack string
+		for !strings.HasPrefix(ack, c.ack[:3]) {
+			var err error
+			if ack, err = rd.ReadString('\n'); err != nil {
+				t.Fatalf("%s: couldn't read reply: %v", c.line, err)
+			}
+		}
+		if !strings.HasPrefix(ack, c.ack) {
+			t.Errorf("%s: got reply %q, want %q", c.line, ack, c.ack)
+		}
+		if got := name(); got != c.name {
+			t.Errorf("%s: got name %q, want %q", c.line, got, c.name)
+		}
+	}
+
+	if err := conn.Close(); err != nil {
+		t.Fatalf("couldn't close connection: %v", err)
+	}
+	if e := nextEvent(t, events); e.Kind != netsrv.EventDisconnect || e.Name != "web-dashboard" {
+		t.Errorf("got %v event named %q, want a disconnect named %q", e.Kind, e.Name, "web-dashboard")
+	}
+}
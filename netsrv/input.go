@@ -0,0 +1,69 @@
+package netsrv
+
+import (
+	"errors"
+	"fmt"
+	"unicode"
+	"unicode/utf8"
+
+	"github.com/UniversityRadioYork/bifrost-go/message"
+)
+
+// ErrControlChar is the error returned when a client sends a word with a control character in it, under a strict
+// InputPolicy.
+var ErrControlChar = errors.New("control character in word")
+
+// ErrMalformedLine is the error returned when a client sends a line that tokenises, but isn't a message, as it has
+// too few words.
+// Unlike requests with words the Controller doesn't understand (see controller.ErrUnknownWord), these never reach
+// the Controller.
+var ErrMalformedLine = errors.New("malformed line")
+
+// InputPolicy says what a Server accepts in the words its clients send.
+//
+// Tokenising already splits words on unquoted whitespace, so this only concerns characters that end up inside words,
+// such as a NUL or an escape sequence slipped into a quoted word.
+type InputPolicy struct {
+	// Strict, if true, rejects lines with control characters in any of their words.
+	Strict bool
+	// AllowTabs, if true, lets words contain tabs even when Strict is on.
+	AllowTabs bool
+}
+
+// Check checks each word in line against p.
+// Under a strict policy, it fails with a ParseError wrapping ErrControlChar on the first control character it finds.
+func (p InputPolicy) Check(line []string) error {
+	if !p.Strict {
+		return nil
+	}
+
+	for i, word := range line {
+		for j, r := range word {
+			// Bytes that aren't valid UTF-8 decode as RuneError, which isn't a control character, so look at them too.
+			if r == utf8.RuneError {
+				r = rune(word[j])
+			}
+			if r == '\t' && p.AllowTabs {
+				continue
+			}
+			if unicode.IsControl(r) {
+				return &ParseError{Word: i, WordOffset: j, Offset: -1, Err: fmt.Errorf("%w: %U", ErrControlChar, r)}
+			}
+		}
+	}
+	return nil
+}
+
+// LineToMessage converts a tokenised line into a message, after checking it against p.
+// Its errors are ParseErrors, but only know which word, and where in that word, parsing failed.
+func LineToMessage(line []string, p InputPolicy) (*message.Message, error) {
+	if err := p.Check(line); err != nil {
+		return nil, err
+	}
+
+	msg, err := message.NewFromLine(line)
+	if err != nil {
+		return nil, &ParseError{Word: len(line), Offset: -1, Err: fmt.Errorf("%w: %v", ErrMalformedLine, err)}
+	}
+	return msg, nil
+}
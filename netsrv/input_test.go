@@ -0,0 +1,123 @@
+package netsrv_test
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/UniversityRadioYork/baps3d/netsrv"
+)
+
+// TestInputPolicy_Check tests which words each input policy lets through.
+func TestInputPolicy_Check(t *testing.T) {
+	strict := netsrv.InputPolicy{Strict: true}
+	tabs := netsrv.InputPolicy{Strict: true, AllowTabs: true}
+
+	cases := []struct {
+		name   string
+		policy netsrv.InputPolicy
+		line   []string
+		ok     bool
+	}{
+		{"plain", strict, []string{"t1", "tloadl", "0", "abc", "Hello, world"}, true},
+		{"unicode", strict, []string{"t1", "tloadl", "0", "abc", "Café ☕"}, true},
+		{"NUL", strict, []string{"t1", "tloadl", "0", "abc", "a\x00b"}, false},
+		{"escape", strict, []string{"t1", "tloadl", "0", "abc", "\x1b[31mred"}, false},
+		{"DEL", strict, []string{"t1", "tloadl", "0", "abc", "a\x7f"}, false},
+		{"C1", strict, []string{"t1", "tloadl", "0", "abc", "a\u009bb"}, false},
+		{"raw C1 byte", strict, []string{"t1", "tloadl", "0", "abc", "a\x9bb"}, false},
+		{"tab", strict, []string{"t1", "tloadl", "0", "abc", "a\tb"}, false},
+		{"allowed tab", tabs, []string{"t1", "tloadl", "0", "abc", "a\tb"}, true},
+		{"newline with tabs allowed", tabs, []string{"t1", "tloadl", "0", "abc", "a\nb"}, false},
+		{"lax", netsrv.InputPolicy{}, []string{"t1", "tloadl", "0", "abc", "a\x00\x1b"}, true},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			err := c.policy.Check(c.line)
+			if c.ok && err != nil {
+				t.Errorf("unexpected error: %v", err)
+			}
+			if !c.ok && !errors.Is(err, netsrv.ErrControlChar) {
+				t.Errorf("got error %v, want ErrControlChar", err)
+			}
+		})
+	}
+}
+
+// readAck reads lines from rd until it reads the ACK for the tag tag, returning it.
+func readAck(t *testing.T, rd *bufio.Reader, tag string) string {
+	t.Helper()
+
+	for {
+		line, err := rd.ReadString('\n')
+		if err != nil {
+			t.Fatalf("couldn't read line: %v", err)
+		}
+		if strings.HasPrefix(line, tag+" ACK") {
+			return strings.TrimSpace(line)
+		}
+	}
+}
+
+// TestServer_Input_Strict tests that a strict Server rejects lines with control characters without disconnecting the
+// client, and without echoing the characters back.
+func TestServer_Input_Strict(t *testing.T) {
+	ts := startServer(t, func(s *netsrv.Server) {
+		s.Input = netsrv.InputPolicy{Strict: true, AllowTabs: true}
+	})
+	defer ts.Cancel()
+	// Adding an item broadcasts to every client of the controller, including the test's root client.
+	go func() {
+		for range ts.Root.Rx {
+		}
+	}()
+
+	conn, rd := ts.dial(t)
+	defer conn.Close()
+
+	if _, err := fmt.Fprint(conn, "t1 tloadl 0 abc 'a\x1b[2Jb'\n"); err != nil {
+		t.Fatalf("couldn't write to server: %v", err)
+	}
+	ack := readAck(t, rd, "t1")
+	if !strings.HasPrefix(ack, "t1 ACK WHAT") || strings.ContainsRune(ack, '\x1b') {
+		t.Errorf("got %q, want an escape-free ACK WHAT", ack)
+	}
+
+	if _, err := fmt.Fprint(conn, "t2 tloadl 0 abc 'a\tb'\n"); err != nil {
+		t.Fatalf("couldn't write to server: %v", err)
+	}
+	if ack := readAck(t, rd, "t2"); !strings.HasPrefix(ack, "t2 ACK OK") {
+		t.Errorf("got %q, want ACK OK", ack)
+	}
+}
+
+// TestLineToMessage_ParseError tests that LineToMessage errors say which word, and where in it, they happened.
+func TestLineToMessage_ParseError(t *testing.T) {
+	strict := netsrv.InputPolicy{Strict: true}
+	cases := []struct {
+		name       string
+		line       []string
+		word       int
+		wordOffset int
+	}{
+		{"control character", []string{"t1", "tloadl", "0", "abc", "ab\x00"}, 4, 2},
+		{"too few words", []string{"t1"}, 1, 0},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			_, err := netsrv.LineToMessage(c.line, strict)
+			var perr *netsrv.ParseError
+			if !errors.As(err, &perr) {
+				t.Fatalf("got error %v, want a ParseError", err)
+			}
+			if perr.Word != c.word || perr.WordOffset != c.wordOffset || perr.Offset != -1 {
+				t.Errorf("got position %d/%d/%d, want %d/%d/-1", perr.Word, perr.WordOffset, perr.Offset,
+					c.word, c.wordOffset)
+			}
+		})
+	}
+}
@@ -0,0 +1,102 @@
+package netsrv
+
+// File limits.go contains limit reports, with which clients find out the limits the Server enforces; see LimitsWord.
+
+import (
+	"fmt"
+	"strconv"
+	"time"
+
+	"github.com/UniversityRadioYork/bifrost-go/core"
+	"github.com/UniversityRadioYork/bifrost-go/message"
+
+	"github.com/UniversityRadioYork/baps3d/controller"
+)
+
+// LimitsWord is the command word of requests a client sends to ask about the limits it is under, for example
+// 'tag limits'; dashboards can use it to show the server's settings.
+// The Server handles these itself, without passing them on to the Controller, and replies with a LIMIT message (see
+// RsLimit) per limit, then an ACK.
+const LimitsWord = "limits"
+
+// RsLimit is the response word of replies to limits requests.
+// The arguments are the name of the limit and its effective value, after defaults, or 'none' if there is no limit.
+// First come the Server's limits: 'maxwordlen', in bytes (see Server.MaxWordLen); 'sendbuffer', in messages (see
+// Server.SendBuffer); 'requestrate', in requests per second, and 'requestburst', in requests (see
+// Server.RequestRate); and 'handshaketimeout', 'idletimeout', and 'writetimeout', in milliseconds (see
+// Server.HandshakeTimeout, Server.IdleTimeout, and Server.WriteTimeout); 'maxinflated', in bytes (see
+// Server.MaxInflatedInput); 'outputrate', in bytes per second (see Server.OutputRate); 'maxclients', in clients
+// over all channels (see Server.MaxClients); and 'bytebudget', in bytes (see Server.ByteBudget).
+// Then come the limits of the channel's Controller, if its Bifrost parser reports any; see controller.LimitsParser.
+const RsLimit = "LIMIT"
+
+// noLimit is the value of limits that don't apply.
+const noLimit = "none"
+
+// replyLimits handles the limits request m, which came from line.
+// Like rejections, the replies don't go through the Controller, so may overtake replies to earlier lines.
+func (e *ioEndpoint) replyLimits(line []string, m message.Message) error {
+	if len(m.Args()) != 0 {
+		return e.reject(line, fmt.Errorf("%s takes no arguments", LimitsWord))
+	}
+
+	rs := make([]message.Message, 0, len(e.limits)+1)
+	for _, l := range e.limits {
+		rs = append(rs, controller.NewMessage(m.Tag(), RsLimit, l.Name, l.Value))
+	}
+	rs = append(rs, controller.NewMessage(m.Tag(), core.RsAck, "OK", "success"))
+	for _, r := range rs {
+		if err := e.queue.push(r); err != nil {
+			e.failSend(err)
+			return err
+		}
+	}
+	return nil
+}
+
+// limits gets the effective values of the limits s puts on a client whose output rate cap is outRate and whose byte
+// budget is budget, as given in LIMIT replies.
+func (s *Server) limits(outRate int, budget int64) []controller.Limit {
+	rate, burst := noLimit, noLimit
+	if 0 < s.RequestRate {
+		rate = strconv.FormatFloat(s.RequestRate, 'g', -1, 64)
+		burst = positiveOr(s.RequestBurst, defaultRequestBurst)
+	}
+	spend := noLimit
+	if 0 < budget {
+		spend = strconv.FormatInt(budget, 10)
+	}
+	return []controller.Limit{
+		{Name: "maxwordlen", Value: positiveOr(s.MaxWordLen, 0)},
+		{Name: "sendbuffer", Value: positiveOr(s.SendBuffer, defaultSendBuffer)},
+		{Name: "requestrate", Value: rate},
+		{Name: "requestburst", Value: burst},
+		{Name: "handshaketimeout", Value: timeoutLimit(s.HandshakeTimeout)},
+		{Name: "idletimeout", Value: timeoutLimit(s.IdleTimeout)},
+		{Name: "writetimeout", Value: timeoutLimit(s.WriteTimeout)},
+		{Name: "maxinflated", Value: positiveOr(s.MaxInflatedInput, defaultMaxInflatedInput)},
+		{Name: "outputrate", Value: positiveOr(outRate, 0)},
+		{Name: "maxclients", Value: positiveOr(s.MaxClients, 0)},
+		{Name: "bytebudget", Value: spend},
+	}
+}
+
+// positiveOr formats n as a limit, or, if n isn't positive, def, the limit that applies instead; a def of 0 means
+// there is then no limit.
+func positiveOr(n, def int) string {
+	if n <= 0 {
+		n = def
+	}
+	if n <= 0 {
+		return noLimit
+	}
+	return strconv.Itoa(n)
+}
+
+// timeoutLimit formats the timeout d, which applies only if positive, as a limit.
+func timeoutLimit(d time.Duration) string {
+	if d <= 0 {
+		return noLimit
+	}
+	return controller.FormatMillis(d)
+}
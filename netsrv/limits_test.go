@@ -0,0 +1,56 @@
+package netsrv_test
+
+import (
+	"fmt"
+	"net"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/UniversityRadioYork/baps3d/netsrv"
+)
+
+// TestServer_Limits tests that a limits request gives the Server's effective limits, defaults included, followed by
+// those of the channel's Controller.
+func TestServer_Limits(t *testing.T) {
+	ts := startServer(t, func(s *netsrv.Server) {
+		s.MaxWordLen = 512
+		s.RequestRate = 2.5
+		s.HandshakeTimeout = 3 * time.Second
+		s.MaxClients = 8
+		s.ByteBudget = func(string, net.Addr) int64 { return 1 << 20 }
+	})
+	defer ts.Cancel()
+
+	conn, rd := ts.dial(t)
+	defer func() { _ = conn.Close() }()
+
+	if _, err := fmt.Fprintln(conn, "t limits"); err != nil {
+		t.Fatalf("couldn't send line: %v", err)
+	}
+	// Only what the Server sent for t counts: the greeting dump can still be on its way.
+	var lines []string
+	for _, line := range readUntilAck(t, rd, "t") {
+		if strings.HasPrefix(line, "t ") {
+			lines = append(lines, line)
+		}
+	}
+	want := []string{
+		"t LIMIT maxwordlen 512",
+		"t LIMIT sendbuffer 1024",
+		"t LIMIT requestrate 2.5",
+		"t LIMIT requestburst 1024",
+		"t LIMIT handshaketimeout 3000",
+		"t LIMIT idletimeout none",
+		"t LIMIT writetimeout none",
+		"t LIMIT maxinflated 1048576",
+		"t LIMIT outputrate none",
+		"t LIMIT maxclients 8",
+		"t LIMIT bytebudget 1048576",
+		"t LIMIT maxreplace 10000",
+	}
+	if len(lines) < len(want) || !reflect.DeepEqual(lines[:len(want)], want) {
+		t.Errorf("got lines %q, want them to start with %q", lines, want)
+	}
+}
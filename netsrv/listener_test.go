@@ -0,0 +1,120 @@
+package netsrv_test
+
+import (
+	"context"
+	"errors"
+	"io"
+	"io/ioutil"
+	"net"
+	"sync"
+	"testing"
+
+	"github.com/UniversityRadioYork/baps3d/list"
+	"github.com/UniversityRadioYork/baps3d/netsrv"
+)
+
+// errFakeClosed is the error a closed fakeListener's Accept returns.
+var errFakeClosed = errors.New("fake listener closed")
+
+// fakeAddr is the address of a fakeListener.
+type fakeAddr struct{}
+
+func (fakeAddr) Network() string { return "fake" }
+func (fakeAddr) String() string  { return "fake" }
+
+// fakeListener is a net.Listener whose Accept returns whatever connections and errors the test feeds it.
+type fakeListener struct {
+	// conns takes the connections for Accept to return.
+	conns chan net.Conn
+	// errs takes the errors for Accept to return.
+	errs chan error
+	// late, if non-nil, is returned by the first Accept once the listener has closed, as if it arrived just as the
+	// server shut down.
+	late net.Conn
+
+	closeOnce sync.Once
+	closed    chan struct{}
+}
+
+// newFakeListener makes a fakeListener.
+func newFakeListener() *fakeListener {
+	return &fakeListener{conns: make(chan net.Conn), errs: make(chan error), closed: make(chan struct{})}
+}
+
+func (l *fakeListener) Accept() (net.Conn, error) {
+	select {
+	case c := <-l.conns:
+		return c, nil
+	case err := <-l.errs:
+		return nil, err
+	case <-l.closed:
+		if c := l.late; c != nil {
+			l.late = nil
+			return c, nil
+		}
+		return nil, errFakeClosed
+	}
+}
+
+func (l *fakeListener) Close() error {
+	l.closeOnce.Do(func() { close(l.closed) })
+	return nil
+}
+
+func (l *fakeListener) Addr() net.Addr {
+	return fakeAddr{}
+}
+
+// drainUntilClosed reads from conn in the background, returning a channel that closes once conn's other end closes.
+func drainUntilClosed(conn net.Conn) <-chan struct{} {
+	closed := make(chan struct{})
+	go func() {
+		_, _ = io.Copy(ioutil.Discard, conn)
+		close(closed)
+	}()
+	return closed
+}
+
+// TestServer_AcceptError tests that an error accepting connections is fatal: Run returns, hanging up clients that
+// were already connected.
+func TestServer_AcceptError(t *testing.T) {
+	fl := newFakeListener()
+	ts := startServerOn(t, "", list.New(), func(s *netsrv.Server) { s.UseListeners(fl) })
+	defer ts.Cancel()
+
+	conn, peer := net.Pipe()
+	defer func() { _ = peer.Close() }()
+	fl.conns <- conn
+	hungUp := drainUntilClosed(peer)
+
+	fl.errs <- errors.New("too many open files")
+	waitFor(t, ts.Done, "server to stop after an accept error")
+	if *ts.RunErr != nil {
+		t.Errorf("got Run error %v, want nil", *ts.RunErr)
+	}
+	waitFor(t, hungUp, "connected client to be hung up")
+	waitFor(t, ts.ControllerDone, "controller to stop after an accept error")
+}
+
+// TestServer_AcceptDuringShutdown tests that a connection accepted as the server shuts down is closed, rather than
+// left hanging or set up after the main loop has gone.
+func TestServer_AcceptDuringShutdown(t *testing.T) {
+	fl := newFakeListener()
+	conn, peer := net.Pipe()
+	defer func() { _ = peer.Close() }()
+	fl.late = conn
+
+	ts := startServerOn(t, "", list.New(), func(s *netsrv.Server) { s.UseListeners(fl) })
+	defer ts.Cancel()
+	hungUp := drainUntilClosed(peer)
+
+	// The server must be in its main loop, so that cancelling it goes through the full teardown.
+	ctx, cancel := context.WithTimeout(context.Background(), testTimeout)
+	defer cancel()
+	if _, ok := ts.Server.Stats(ctx); !ok {
+		t.Fatal("server didn't start")
+	}
+	ts.Cancel()
+	waitFor(t, ts.Done, "server to stop after cancellation")
+	waitFor(t, hungUp, "late connection to be closed")
+}
@@ -0,0 +1,42 @@
+package netsrv
+
+// File outrate.go contains output rate caps, with which the Server spreads out what it sends to clients on metered
+// or slow links, rather than overwhelming them with bursts of broadcasts; see Server.OutputRate.
+
+import (
+	"context"
+	"time"
+)
+
+// delay takes n tokens from b at time now, going into debt if it hasn't got them, and returns how long the taker
+// must wait for b to get out of debt.
+// Unlike take, delay never refuses: it lets a taker use up to one burst at once, and spreads out anything more at
+// b's rate, even if n alone is bigger than a burst.
+func (b *tokenBucket) delay(n int, now time.Time) time.Duration {
+	b.refill(now)
+	b.tokens -= float64(n)
+	if 0 <= b.tokens {
+		return 0
+	}
+	return time.Duration(-b.tokens / b.rate * float64(time.Second))
+}
+
+// throttle waits, by e's clock, until e's output rate cap lets it write n more bytes; see Server.OutputRate.
+// It returns false if ctx finishes first.
+func (e *ioEndpoint) throttle(ctx context.Context, n int) bool {
+	if e.outLimiter == nil {
+		return true
+	}
+	d := e.outLimiter.delay(n, e.clock.Now())
+	if d <= 0 {
+		return true
+	}
+	t := e.clock.NewTimer(d)
+	defer t.Stop()
+	select {
+	case <-t.C():
+		return true
+	case <-ctx.Done():
+		return false
+	}
+}
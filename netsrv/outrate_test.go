@@ -0,0 +1,62 @@
+package netsrv_test
+
+import (
+	"fmt"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/UniversityRadioYork/baps3d/netsrv"
+)
+
+// TestTokenBucket_Delay tests that taking bytes from a token bucket is free up to its burst, then costs time at its
+// rate, even for takes bigger than the burst.
+func TestTokenBucket_Delay(t *testing.T) {
+	start := time.Unix(0, 0)
+	b := netsrv.NewTokenBucket(1000, 1000, start)
+
+	steps := []struct {
+		at   time.Duration
+		n    int
+		want time.Duration
+	}{
+		{0, 600, 0},
+		{0, 400, 0},
+		{0, 500, 500 * time.Millisecond},
+		// Having waited out the debt, the bucket is empty.
+		{500 * time.Millisecond, 100, 100 * time.Millisecond},
+		{time.Minute, 3000, 2 * time.Second},
+	}
+	for i, s := range steps {
+		if got := b.Delay(s.n, start.Add(s.at)); got != s.want {
+			t.Errorf("step %d (%d bytes at %s): got %s, want %s", i, s.n, s.at, got, s.want)
+		}
+	}
+}
+
+// TestServer_OutputRate tests that a Server spreads out what it writes to a connection with an output rate cap.
+func TestServer_OutputRate(t *testing.T) {
+	const rate = 4000
+	ts := startServer(t, func(s *netsrv.Server) {
+		s.OutputRate = func(string, net.Addr) int { return rate }
+	})
+	defer ts.Cancel()
+
+	conn, rd := ts.dial(t)
+	defer func() { _ = conn.Close() }()
+	start := time.Now()
+	var n int
+	for i := 0; i < 20; i++ {
+		tag := fmt.Sprintf("t%d", i)
+		if _, err := fmt.Fprintf(conn, "%s limits\n", tag); err != nil {
+			t.Fatalf("couldn't send line: %v", err)
+		}
+		for _, line := range readUntilAck(t, rd, tag) {
+			n += len(line) + 1
+		}
+	}
+	// The first second's worth is free; the ACKs and the greeting aren't counted, so this is an underestimate.
+	if min := time.Duration(float64(n-rate) / rate * float64(time.Second)); time.Since(start) < min {
+		t.Errorf("got %d bytes in %s, want it to take at least %s", n, time.Since(start), min)
+	}
+}
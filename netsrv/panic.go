@@ -0,0 +1,44 @@
+package netsrv
+
+// File panic.go contains the Server's handling of panics in connection setup and client goroutines, so that a bug
+// tripped by one client hangs up only that client.
+
+import (
+	"context"
+	"fmt"
+	"runtime/debug"
+
+	"github.com/UniversityRadioYork/baps3d/controller"
+)
+
+// PanicError is the error given when a client is hung up because of a panic in its setup or one of its goroutines.
+type PanicError struct {
+	// Value is the value the code panicked with.
+	Value interface{}
+	// Stack is the stack trace of the panicking goroutine.
+	Stack []byte
+}
+
+// Error gets the error message of a PanicError, which doesn't include the stack trace.
+func (e *PanicError) Error() string {
+	return fmt.Sprintf("panic: %v", e.Value)
+}
+
+// catchPanic runs f, returning a PanicError if it panics, or nil otherwise.
+func catchPanic(f func()) (err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			err = &PanicError{Value: r, Stack: debug.Stack()}
+		}
+	}()
+	f()
+	return nil
+}
+
+// abandonClient hangs up cc, a controller client nothing is going to run, discarding anything the Controller sends
+// it until the Controller notices, so that the Controller never blocks on it.
+func abandonClient(cc *controller.Client) {
+	go func() {
+		_ = cc.HangUp(context.Background())
+	}()
+}
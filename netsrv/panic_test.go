@@ -0,0 +1,125 @@
+package netsrv_test
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/UniversityRadioYork/baps3d/list"
+	"github.com/UniversityRadioYork/baps3d/netsrv"
+)
+
+// panicParser is a List whose Bifrost parser panics on the word 'boom'.
+type panicParser struct {
+	*list.List
+}
+
+func (p panicParser) ParseBifrostRequest(word string, args []string) (interface{}, error) {
+	if word == "boom" {
+		panic("boom")
+	}
+	return p.List.ParseBifrostRequest(word, args)
+}
+
+// checkPanicEvent checks that e is a disconnect for a panic with value want.
+func checkPanicEvent(t *testing.T, e netsrv.Event, want interface{}) {
+	t.Helper()
+
+	if e.Kind != netsrv.EventDisconnect || e.Reason != netsrv.ReasonPanic {
+		t.Fatalf("got %v event with reason %q, want a disconnect for a panic", e.Kind, e.Reason)
+	}
+	var perr *netsrv.PanicError
+	if !errors.As(e.Err, &perr) || perr.Value != want || len(perr.Stack) == 0 {
+		t.Errorf("got error %#v, want a PanicError for %v with a stack", e.Err, want)
+	}
+}
+
+// checkHungUp checks that the server has closed the connection conn, read by rd.
+func checkHungUp(t *testing.T, conn net.Conn, rd *bufio.Reader) {
+	t.Helper()
+
+	if err := conn.SetReadDeadline(time.Now().Add(testTimeout)); err != nil {
+		t.Fatalf("couldn't set deadline: %v", err)
+	}
+	for {
+		if _, err := rd.ReadString('\n'); err != nil {
+			if err != io.EOF {
+				t.Errorf("got error %v, want EOF", err)
+			}
+			return
+		}
+	}
+}
+
+// TestServer_PanicInParser tests that a panic in a client's parser hangs up only that client, and leaves the
+// controller serving everyone else.
+func TestServer_PanicInParser(t *testing.T) {
+	events := make(chan netsrv.Event, 8)
+	ts := startServerOn(t, freeAddr(t), panicParser{list.New()}, func(s *netsrv.Server) { s.Events = events })
+	defer ts.Cancel()
+	go func() {
+		for range ts.Root.Rx {
+		}
+	}()
+
+	conn, rd := ts.dial(t)
+	defer conn.Close()
+	nextEvent(t, events)
+	conn2, rd2 := ts.dial(t)
+	defer conn2.Close()
+	nextEvent(t, events)
+
+	if _, err := fmt.Fprint(conn, "t1 boom\n"); err != nil {
+		t.Fatalf("couldn't write to server: %v", err)
+	}
+	checkPanicEvent(t, nextEvent(t, events), "boom")
+	checkHungUp(t, conn, rd)
+
+	// This broadcasts, so it would hang if the controller were still trying to talk to the panicked client.
+	if _, err := fmt.Fprint(conn2, "t2 auto next\n"); err != nil {
+		t.Fatalf("couldn't write to server: %v", err)
+	}
+	readUntilAck(t, rd2, "t2")
+}
+
+// TestServer_PanicInSetup tests that a panic in setting up a connection refuses only that connection, and doesn't
+// leave the controller waiting on a client nobody runs.
+func TestServer_PanicInSetup(t *testing.T) {
+	events := make(chan netsrv.Event, 8)
+	first := true
+	ts := startServer(t, func(s *netsrv.Server) {
+		s.Events = events
+		// This runs on the server's main loop, so there's no race on first.
+		s.Sequence = func(string, net.Addr) bool {
+			if first {
+				first = false
+				panic("setup")
+			}
+			return false
+		}
+	})
+	defer ts.Cancel()
+	go func() {
+		for range ts.Root.Rx {
+		}
+	}()
+
+	conn, rd := ts.dial(t)
+	defer conn.Close()
+	checkPanicEvent(t, nextEvent(t, events), "setup")
+	checkHungUp(t, conn, rd)
+
+	conn2, rd2 := ts.dial(t)
+	defer conn2.Close()
+	if e := nextEvent(t, events); e.Kind != netsrv.EventConnect || e.ClientID != 1 {
+		t.Errorf("got %v event for client %d, want a connect for client 1", e.Kind, e.ClientID)
+	}
+	if _, err := fmt.Fprint(conn2, "t2 auto next\n"); err != nil {
+		t.Fatalf("couldn't write to server: %v", err)
+	}
+	readUntilAck(t, rd2, "t2")
+}
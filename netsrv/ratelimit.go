@@ -0,0 +1,53 @@
+package netsrv
+
+import (
+	"errors"
+	"time"
+)
+
+// ErrRateLimited is the error given when a client's request is rejected for going over the Server's RequestRate.
+var ErrRateLimited = errors.New("slow down: request rate limit exceeded")
+
+// defaultRequestBurst is the number of requests a client may send in one burst if Server.RequestBurst isn't
+// positive.
+// It is big enough for a bulk load of a few hundred items, sent as fast as the client can write it.
+const defaultRequestBurst = 1024
+
+// tokenBucket is a token bucket rate limiter.
+// It holds up to burst tokens, and gains rate tokens per second; each request takes one.
+type tokenBucket struct {
+	rate   float64
+	burst  float64
+	tokens float64
+	last   time.Time
+}
+
+// newTokenBucket creates a full tokenBucket gaining rate tokens per second, up to burst (or defaultRequestBurst if
+// burst isn't positive), as of now.
+func newTokenBucket(rate float64, burst int, now time.Time) *tokenBucket {
+	if burst <= 0 {
+		burst = defaultRequestBurst
+	}
+	return &tokenBucket{rate: rate, burst: float64(burst), tokens: float64(burst), last: now}
+}
+
+// take tries to take a token from b at time now, returning false if there isn't one.
+func (b *tokenBucket) take(now time.Time) bool {
+	b.refill(now)
+	if b.tokens < 1 {
+		return false
+	}
+	b.tokens--
+	return true
+}
+
+// refill gives b the tokens it has gained between its last refill and now.
+func (b *tokenBucket) refill(now time.Time) {
+	if elapsed := now.Sub(b.last); 0 < elapsed {
+		b.tokens += elapsed.Seconds() * b.rate
+		if b.burst < b.tokens {
+			b.tokens = b.burst
+		}
+		b.last = now
+	}
+}
@@ -0,0 +1,80 @@
+package netsrv_test
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/UniversityRadioYork/baps3d/netsrv"
+)
+
+// TestTokenBucket_Take tests that a token bucket lets through a full burst, then refills at its rate up to the burst.
+func TestTokenBucket_Take(t *testing.T) {
+	start := time.Unix(0, 0)
+	b := netsrv.NewTokenBucket(2, 3, start)
+
+	steps := []struct {
+		at   time.Duration
+		want bool
+	}{
+		{0, true},
+		{0, true},
+		{0, true},
+		{0, false},
+		{250 * time.Millisecond, false},
+		{500 * time.Millisecond, true},
+		{500 * time.Millisecond, false},
+		// A long wait refills only up to the burst.
+		{time.Minute, true},
+		{time.Minute, true},
+		{time.Minute, true},
+		{time.Minute, false},
+	}
+	for i, s := range steps {
+		if got := b.Take(start.Add(s.at)); got != s.want {
+			t.Errorf("step %d (at %s): got %v, want %v", i, s.at, got, s.want)
+		}
+	}
+}
+
+// TestServer_RequestRate tests that a rate-limited Server rejects requests over its burst, without disconnecting.
+func TestServer_RequestRate(t *testing.T) {
+	ts := startServer(t, func(s *netsrv.Server) {
+		// Slow enough that the bucket can't refill during the test.
+		s.RequestRate = 0.001
+		s.RequestBurst = 3
+	})
+	defer ts.Cancel()
+
+	conn, rd := ts.dial(t)
+	defer conn.Close()
+
+	if _, err := fmt.Fprint(conn, "a status\nb status\nc status\nd status\ne status\n"); err != nil {
+		t.Fatalf("couldn't write to server: %v", err)
+	}
+
+	// Rejections don't go through the Controller, so they can overtake earlier acknowledgements.
+	var acks []string
+	for len(acks) < 5 {
+		line, err := rd.ReadString('\n')
+		if err != nil {
+			t.Fatalf("couldn't read line: %v", err)
+		}
+		fields := strings.Fields(line)
+		if len(fields) < 3 || fields[1] != "ACK" {
+			continue
+		}
+		if fields[2] == "WHAT" && !strings.Contains(line, "slow down") {
+			t.Errorf("rejection %q doesn't tell the client to slow down", line)
+		}
+		acks = append(acks, strings.Join(fields[:3], " "))
+	}
+	sort.Strings(acks)
+
+	want := "a ACK OK,b ACK OK,c ACK OK,d ACK WHAT,e ACK WHAT"
+	if got := strings.Join(acks, ","); got != want {
+		t.Errorf("got %q, want %q", got, want)
+	}
+}
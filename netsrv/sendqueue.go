@@ -0,0 +1,261 @@
+package netsrv
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/UniversityRadioYork/baps3d/controller"
+	"github.com/UniversityRadioYork/bifrost-go/message"
+)
+
+// ErrSendBufferFull is the error given when a client is disconnected for falling too far behind on its messages.
+var ErrSendBufferFull = errors.New("client send buffer full")
+
+// defaultSendBuffer is the number of outbound messages a client's queue holds if Server.SendBuffer isn't positive.
+const defaultSendBuffer = 1024
+
+// SendPolicy is the type of policies on what happens when a client's outbound message queue fills up.
+type SendPolicy int
+
+const (
+	// SendDisconnect disconnects the client with ErrSendBufferFull.
+	// No message is ever dropped.
+	SendDisconnect SendPolicy = iota
+	// SendDropOldest drops the oldest queued broadcast that a later queued broadcast supersedes, if there is one; see
+	// controller.LatestWinsParser.
+	// Other messages, such as item announcements or acknowledgements, are never dropped: if nothing can be dropped,
+	// the client is disconnected as with SendDisconnect.
+	// A client whose request caused a dropped broadcast only hears about the change, in the superseding broadcast,
+	// after the request's ACK.
+	SendDropOldest
+)
+
+// String gets the configuration name of a SendPolicy.
+func (p SendPolicy) String() string {
+	switch p {
+	case SendDisconnect:
+		return "disconnect"
+	case SendDropOldest:
+		return "drop-oldest"
+	default:
+		return "?unknown?"
+	}
+}
+
+// ParseSendPolicy tries to parse a SendPolicy from its configuration name.
+// The empty string parses as SendDisconnect.
+func ParseSendPolicy(s string) (SendPolicy, error) {
+	switch strings.ToLower(s) {
+	case "", "disconnect":
+		return SendDisconnect, nil
+	case "drop-oldest":
+		return SendDropOldest, nil
+	default:
+		return SendDisconnect, fmt.Errorf("invalid send policy: %s", s)
+	}
+}
+
+// sendQueue is a bounded queue of messages waiting to be written to a client.
+type sendQueue struct {
+	mu sync.Mutex
+	// msgs holds the queued messages, oldest first.
+	msgs []message.Message
+	// max is the most messages the queue holds.
+	max int
+	// peak is the most messages the queue has held at once.
+	peak int
+	// policy is what the queue does when it is full.
+	policy SendPolicy
+	// latestWins, if non-nil, says which broadcast words are superseded by later broadcasts with the same word.
+	latestWins func(word string) bool
+	// sequenced is true if each message pushed onto the queue gets a sequence number; see Server.Sequence.
+	// It must be set before the first push.
+	sequenced bool
+	// nextSeq is the sequence number the next message pushed will get, if sequenced.
+	nextSeq uint64
+	// dropped counts the messages the queue has dropped.
+	dropped uint64
+	// pushed counts the messages pushed onto the queue.
+	pushed uint64
+	// settled counts the messages that have left the queue for good, by being written or dropped.
+	settled uint64
+	// progress closes, and is replaced, whenever settled goes up.
+	progress chan struct{}
+	// closed is true once no more messages will be pushed.
+	closed bool
+	// ready receives a value whenever the queue gains a message or closes.
+	ready chan struct{}
+}
+
+// newSendQueue creates a sendQueue holding up to max messages, or defaultSendBuffer if max isn't positive.
+func newSendQueue(max int, policy SendPolicy, latestWins func(word string) bool) *sendQueue {
+	if max <= 0 {
+		max = defaultSendBuffer
+	}
+	return &sendQueue{
+		max:        max,
+		policy:     policy,
+		latestWins: latestWins,
+		ready:      make(chan struct{}, 1),
+		progress:   make(chan struct{}),
+	}
+}
+
+// push adds m to the queue, making room for it according to the queue's policy.
+// It fails with ErrSendBufferFull if there's no room.
+//
+// If the queue is sequenced, m gets the next sequence number as its last argument, even if it is later dropped;
+// this is what lets the client see the gap.
+func (q *sendQueue) push(m message.Message) error {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	if q.max <= len(q.msgs) && !q.dropSuperseded(m) {
+		return fmt.Errorf("%w: %d messages queued", ErrSendBufferFull, len(q.msgs))
+	}
+
+	if q.sequenced {
+		m = withArg(m, strconv.FormatUint(q.nextSeq, 10))
+		q.nextSeq++
+	}
+	q.msgs = append(q.msgs, m)
+	if q.peak < len(q.msgs) {
+		q.peak = len(q.msgs)
+	}
+	q.pushed++
+	q.signal()
+	return nil
+}
+
+// dropSuperseded tries, under the drop-oldest policy, to drop the oldest queued message superseded by a later one,
+// counting next, the message about to be queued.
+// It returns whether it dropped anything; q's mutex must be held.
+func (q *sendQueue) dropSuperseded(next message.Message) bool {
+	if q.policy != SendDropOldest || q.latestWins == nil {
+		return false
+	}
+
+	// Walk backwards, so that we know which words have a later broadcast by the time we reach each message.
+	later := make(map[string]struct{})
+	if q.droppable(next) {
+		later[next.Word()] = struct{}{}
+	}
+	oldest := -1
+	for i := len(q.msgs) - 1; 0 <= i; i-- {
+		m := q.msgs[i]
+		if !q.droppable(m) {
+			continue
+		}
+		if _, ok := later[m.Word()]; ok {
+			oldest = i
+		}
+		later[m.Word()] = struct{}{}
+	}
+
+	if oldest == -1 {
+		return false
+	}
+	q.msgs = append(q.msgs[:oldest], q.msgs[oldest+1:]...)
+	q.dropped++
+	q.settleLocked()
+	return true
+}
+
+// withArg copies m with arg added as its last argument.
+// Broadcasts share their argument slice between every client, so m's own arguments can't be appended to.
+func withArg(m message.Message, arg string) message.Message {
+	args := m.Args()
+	nargs := make([]string, len(args), len(args)+1)
+	copy(nargs, args)
+	return *message.New(m.Tag(), m.Word()).AddArgs(append(nargs, arg)...)
+}
+
+// droppable checks whether m is a latest-wins broadcast.
+// Replies to a specific tag are for whoever sent the request, so are never superseded.
+func (q *sendQueue) droppable(m message.Message) bool {
+	return controller.IsBroadcastTag(m.Tag()) && q.latestWins(m.Word())
+}
+
+// pop waits for a message and takes it off the queue.
+// It returns false if the queue has closed and no messages remain.
+func (q *sendQueue) pop() (message.Message, bool) {
+	for {
+		q.mu.Lock()
+		if 0 < len(q.msgs) {
+			m := q.msgs[0]
+			q.msgs[0] = message.Message{}
+			q.msgs = q.msgs[1:]
+			q.mu.Unlock()
+			return m, true
+		}
+		closed := q.closed
+		q.mu.Unlock()
+
+		if closed {
+			return message.Message{}, false
+		}
+		<-q.ready
+	}
+}
+
+// close tells the queue that no more messages will be pushed.
+func (q *sendQueue) close() {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	q.closed = true
+	q.signal()
+}
+
+// settle records that the writer is done with the last message it popped off q.
+func (q *sendQueue) settle() {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	q.settleLocked()
+}
+
+// settleLocked records that a message has left q for good; q's mutex must be held.
+func (q *sendQueue) settleLocked() {
+	q.settled++
+	close(q.progress)
+	q.progress = make(chan struct{})
+}
+
+// mark gets the number of messages pushed onto q so far, for use with settledTo.
+func (q *sendQueue) mark() uint64 {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	return q.pushed
+}
+
+// settledTo checks whether the first n messages pushed onto q have all left it for good.
+// If not, it also returns a channel that closes when another message does.
+func (q *sendQueue) settledTo(n uint64) (bool, <-chan struct{}) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	return n <= q.settled, q.progress
+}
+
+// droppedCount gets the number of messages q has dropped.
+func (q *sendQueue) droppedCount() uint64 {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	return q.dropped
+}
+
+// depth gets the number of messages waiting on q, the most it has held at once, and the most it can hold.
+func (q *sendQueue) depth() (queued, peak, max int) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	return len(q.msgs), q.peak, q.max
+}
+
+// signal wakes up any waiting pop; q's mutex must be held.
+func (q *sendQueue) signal() {
+	select {
+	case q.ready <- struct{}{}:
+	default:
+	}
+}
@@ -0,0 +1,168 @@
+package netsrv_test
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/UniversityRadioYork/bifrost-go/message"
+
+	"github.com/UniversityRadioYork/baps3d/controller"
+	"github.com/UniversityRadioYork/baps3d/list"
+	"github.com/UniversityRadioYork/baps3d/netsrv"
+)
+
+// selLatestWins treats SEL as the only latest-wins word.
+func selLatestWins(word string) bool {
+	return word == "SEL"
+}
+
+// pushAll pushes each of msgs onto q, returning the first error.
+func pushAll(q netsrv.SendQueue, msgs ...*message.Message) error {
+	for _, m := range msgs {
+		if err := q.Push(*m); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// words gets the word and first argument of each of msgs.
+func words(msgs []message.Message) []string {
+	ws := make([]string, len(msgs))
+	for i, m := range msgs {
+		ws[i] = m.Word()
+		if args := m.Args(); 0 < len(args) {
+			ws[i] += " " + args[0]
+		}
+	}
+	return ws
+}
+
+// TestSendQueue_Disconnect tests that the disconnect policy fails once the queue is full, without dropping anything.
+func TestSendQueue_Disconnect(t *testing.T) {
+	q := netsrv.NewSendQueue(2, netsrv.SendDisconnect, selLatestWins)
+	err := pushAll(q,
+		message.New(message.TagBcast, "SEL").AddArgs("0"),
+		message.New(message.TagBcast, "SEL").AddArgs("1"),
+		message.New(message.TagBcast, "SEL").AddArgs("2"),
+	)
+	if !errors.Is(err, netsrv.ErrSendBufferFull) {
+		t.Fatalf("got error %v, want ErrSendBufferFull", err)
+	}
+	if got, want := words(q.Drain()), []string{"SEL 0", "SEL 1"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("got %v, want %v", got, want)
+	}
+}
+
+// TestSendQueue_DropOldest tests that the drop-oldest policy only drops broadcasts superseded by later ones.
+func TestSendQueue_DropOldest(t *testing.T) {
+	q := netsrv.NewSendQueue(3, netsrv.SendDropOldest, selLatestWins)
+	err := pushAll(q,
+		message.New(message.TagBcast, "SEL").AddArgs("0"),
+		message.New(message.TagBcast, "FLOADL").AddArgs("0"),
+		message.New("t1", "SEL").AddArgs("1"),
+		// The queue is full, but this supersedes SEL 0.
+		message.New(message.TagBcast, "SEL").AddArgs("2"),
+		// This supersedes SEL 2.
+		message.New(message.TagBcast, "SEL").AddArgs("3"),
+	)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	// Nothing queued is superseded by an item announcement, and the tagged SEL is a reply, so this can't fit.
+	if err := q.Push(*message.New(message.TagBcast, "FLOADL").AddArgs("1")); !errors.Is(err, netsrv.ErrSendBufferFull) {
+		t.Fatalf("got error %v, want ErrSendBufferFull", err)
+	}
+
+	if got, want := words(q.Drain()), []string{"FLOADL 0", "SEL 1", "SEL 3"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("got %v, want %v", got, want)
+	}
+	if q.Dropped() != 2 {
+		t.Errorf("got %d dropped, want 2", q.Dropped())
+	}
+}
+
+// TestSendQueue_Depth tests that a queue reports its depth, its peak depth, and its capacity.
+func TestSendQueue_Depth(t *testing.T) {
+	q := netsrv.NewSendQueue(4, netsrv.SendDisconnect, nil)
+	err := pushAll(q,
+		message.New(message.TagBcast, "SEL").AddArgs("0"),
+		message.New(message.TagBcast, "SEL").AddArgs("1"),
+		message.New(message.TagBcast, "SEL").AddArgs("2"),
+	)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if queued, peak, max := q.Depth(); queued != 3 || peak != 3 || max != 4 {
+		t.Errorf("got depth %d/%d/%d, want 3/3/4", queued, peak, max)
+	}
+
+	q.Drain()
+	if queued, peak, max := q.Depth(); queued != 0 || peak != 3 || max != 4 {
+		t.Errorf("after draining: got depth %d/%d/%d, want 0/3/4", queued, peak, max)
+	}
+}
+
+// TestParseSendPolicy tests that send policies round-trip through their names.
+func TestParseSendPolicy(t *testing.T) {
+	for _, p := range []netsrv.SendPolicy{netsrv.SendDisconnect, netsrv.SendDropOldest} {
+		got, err := netsrv.ParseSendPolicy(p.String())
+		if err != nil || got != p {
+			t.Errorf("%v: got (%v, %v)", p, got, err)
+		}
+	}
+	if _, err := netsrv.ParseSendPolicy("drop-newest"); err == nil {
+		t.Error("expected an error on an unknown policy")
+	}
+}
+
+// TestServer_SendBufferFull_MidDump tests that a client falling behind part-way through a large dump is disconnected,
+// and that the rest of the dump doesn't stall the controller.
+func TestServer_SendBufferFull_MidDump(t *testing.T) {
+	const nitems = 2000
+	events := make(chan netsrv.Event, 8)
+	ts := startServer(t, func(s *netsrv.Server) {
+		s.Events = events
+		s.SendBuffer = 4
+	})
+	defer ts.Cancel()
+	go func() {
+		for range ts.Root.Rx {
+		}
+	}()
+
+	ctx, cancel := context.WithTimeout(context.Background(), testTimeout)
+	defer cancel()
+	add := func(i int) {
+		t.Helper()
+		rbody := list.AddItemRequest{Index: i, Item: *list.NewText(strconv.Itoa(i), strings.Repeat("x", 8192))}
+		ok, err := ts.Root.SendAndProcessReplies(ctx, "", rbody, func(controller.Response) error { return nil })
+		if !ok || err != nil {
+			t.Fatalf("couldn't add item %d: ok=%v, err=%v", i, ok, err)
+		}
+	}
+	for i := 0; i < nitems; i++ {
+		add(i)
+	}
+
+	// Connecting starts a dump of every item, but this client never reads past the OHAI.
+	conn, _ := ts.dial(t)
+	defer conn.Close()
+	nextEvent(t, events)
+
+	e := nextEvent(t, events)
+	if e.Kind != netsrv.EventDisconnect || !errors.Is(e.Err, netsrv.ErrSendBufferFull) {
+		t.Fatalf("got %v event with error %v, want a disconnect with ErrSendBufferFull", e.Kind, e.Err)
+	}
+
+	// Adding broadcasts to every client, so this would block if the controller were still waiting on the dead one.
+	add(nitems)
+	if err := ts.Root.CheckAlive(ctx); err != nil {
+		t.Fatalf("controller stalled after disconnect: %v", err)
+	}
+}
@@ -2,49 +2,339 @@ package netsrv
 
 import (
 	"context"
+	"crypto/tls"
+	"errors"
+	"fmt"
 	"log"
 	"net"
 	"sync"
+	"sync/atomic"
+	"time"
 
-	"github.com/UniversityRadioYork/bifrost-go/comm"
+	"github.com/UniversityRadioYork/bifrost-go/core"
+	"github.com/UniversityRadioYork/bifrost-go/message"
 
 	"github.com/UniversityRadioYork/baps3d/controller"
 )
 
+// Channel is a named controller served by a Server, along with where the Server listens for its clients.
+//
+// Each connection belongs to exactly one channel: the one whose Host it connected to.
+// To switch channels, a client disconnects and reconnects to the other channel's Host.
+// Since each connection talks to a clone of its own channel's root client, broadcasts never cross channels.
+type Channel struct {
+	// Name identifies the channel in logs and events.
+	Name string
+	// Host is the TCP host:port string on which the channel listens, or, if Network is "unix", the path of its
+	// socket.
+	Host string
+	// Network is the kind of socket the channel listens on: "tcp" (the default, if empty) or "unix", for Unix domain
+	// sockets.
+	// The Server removes a Unix domain socket's file when it closes the listener, including on shutdown.
+	Network string
+	// Root is a controller Client the Server can clone for use by the channel's incoming connections.
+	// The Server takes ownership of it.
+	Root *controller.Client
+}
+
+// network gets the network c listens on, for net.Listen.
+func (c Channel) network() string {
+	if c.Network == "" {
+		return "tcp"
+	}
+	return c.Network
+}
+
 // Server holds the internal state of a baps3d TCP server.
 type Server struct {
+	// nclients is the number of connected clients, for STATUS replies.
+	// It is accessed atomically, so it comes first to keep it 64-bit aligned.
+	nclients int64
+
+	// Events, if non-nil, receives an Event for each connection lifecycle transition.
+	// The Server never blocks on this channel: events that can't be received immediately are dropped, so it
+	// should be buffered.
+	// It must be set before Run.
+	Events chan<- Event
+
+	// RootBroadcasts, if non-nil, is called with each broadcast that reaches a channel's root client, given the name
+	// of the channel, so that the broadcasts can be monitored.
+	// If nil, the Server logs them instead.
+	// Either way, the Server logs the errors some broadcasts carry, such as those of failed schedules.
+	// It is called from the goroutine draining the root client, and the controller waits for it, so it mustn't block
+	// for long.
+	// It must be set before Run.
+	RootBroadcasts func(channel string, rs controller.Response)
+
+	// Clock, if non-nil, is the Server's source of time for Event times, write times in Stats, error log coalescing,
+	// request rate limits, and write retry delays.
+	// Connection deadlines, such as HandshakeTimeout and IdleTimeout, are kept by the network stack, so always use
+	// the system's time.
+	// If nil, the Server uses controller.SystemClock.
+	// It must be set before Run.
+	Clock controller.Clock
+
+	// MaxWordLen, if positive, is the maximum length in bytes of any single word a client may send.
+	// Clients sending longer words are disconnected with ErrWordTooLong; see Tokeniser.
+	// It must be set before Run.
+	MaxWordLen int
+
+	// MaxClients, if positive, is the most clients the Server serves at once, over all of its channels.
+	// Connections over the limit are refused with ErrTooManyClients and closed, before they reach any controller,
+	// so that a pile-up of connections can't swamp the controllers; they may retry once other clients hang up.
+	// It must be set before Run.
+	MaxClients int
+
+	// KeepAlive is the TCP keepalive period for client connections.
+	// If zero, it defaults to 15 seconds; if negative, keepalive is disabled.
+	// It must be set before Run.
+	KeepAlive time.Duration
+
+	// HandshakeTimeout, if positive, is how long a client has to send its first line after connecting.
+	// Clients that don't are disconnected with ErrHandshakeTimeout (and ReasonTimeout), so that half-open connections
+	// can't hold on to server resources.
+	// It must be set before Run.
+	HandshakeTimeout time.Duration
+
+	// IdleTimeout, if positive, is how long a client may go without sending a line, once it has sent its first.
+	// Clients that don't are disconnected with ErrIdleTimeout (and ReasonTimeout), so that dead clients that keep
+	// their sockets open don't hold on to a goroutine and a Controller client forever.
+	// If zero, established clients may stay idle indefinitely.
+	// It must be set before Run.
+	IdleTimeout time.Duration
+
+	// Linger, if positive, is how long each connection lingers, half-closed, once the Server has written the last
+	// message to it.
+	// The Server shuts down its side of the connection, then discards anything the client still sends until it hangs
+	// up too, or Linger passes, and only then closes the connection.
+	// Without this, closing a connection while the client's requests are still arriving resets it, and the client
+	// can lose final messages (such as the replies to those requests) that it hadn't yet read.
+	// If zero, connections close as soon as the last message is written.
+	// It must be set before Run.
+	Linger time.Duration
+
+	// WriteTimeout, if positive, is how long each write to a client may take.
+	// A write that times out, on a congested link say, is retried from where it stopped, up to WriteRetries times,
+	// after WriteRetryDelay each time; once the retries run out, the client is disconnected.
+	// Other write errors mean the connection is dead, so they disconnect the client straight away.
+	// If zero, writes can take as long as the network stack lets them, and are never retried.
+	// It must be set before Run.
+	WriteTimeout time.Duration
+	// WriteRetries is the number of times a timed-out write is retried; see WriteTimeout.
+	// Writes to TLS connections (see TLSConfig) are never retried, as crypto/tls fails every write after one that
+	// times out, so their clients are disconnected after the first timeout.
+	// It must be set before Run.
+	WriteRetries int
+	// WriteRetryDelay is how long a connection waits, by Clock, before retrying a timed-out write; see WriteTimeout.
+	// It must be set before Run.
+	WriteRetryDelay time.Duration
+
+	// SendBuffer, if positive, is the number of outbound messages each client's queue holds.
+	// It defaults to 1024.
+	// It must be set before Run.
+	SendBuffer int
+
+	// SendPolicy, if non-nil, chooses the SendPolicy for each connection as it is established, given the name of the
+	// channel it connected to and its remote address.
+	// If nil, every connection uses SendDisconnect.
+	// It must be set before Run.
+	SendPolicy func(channel string, addr net.Addr) SendPolicy
+
+	// OutputRate, if non-nil, chooses the most bytes per second the Server writes to each connection, on average, as
+	// it is established, given the name of the channel it connected to and its remote address; if it isn't positive,
+	// there is no cap.
+	// Capped connections can take up to one second's worth at once, then get the rest spread out; messages waiting
+	// their turn stay in the connection's queue, so if the cap can't keep up, the connection's SendPolicy kicks in
+	// once the queue fills (see SendBuffer).
+	// If nil, no connection is capped.
+	// It must be set before Run.
+	OutputRate func(channel string, addr net.Addr) int
+
+	// ByteBudget, if non-nil, chooses the most bytes each connection may read and write, together, over its lifetime,
+	// as it is established, given the name of the channel it connected to and its remote address; if it isn't
+	// positive, there is no budget.
+	// A connection that spends its budget is hung up with ReasonByteBudget; a message that would overrun the budget
+	// isn't written at all.
+	// Every connection's byte counts show up in Stats, budget or not.
+	// If nil, no connection has a budget.
+	// It must be set before Run.
+	ByteBudget func(channel string, addr net.Addr) int64
+
+	// TLSConfig, if non-nil, makes every connection speak TLS, configured by it, which must hold at least one
+	// certificate.
+	// This covers adopted listeners (see SocketActivation) as well as the Server's own.
+	// For mutual TLS, set its ClientAuth to tls.RequireAndVerifyClientCert, and its ClientCAs to the pool of
+	// authorities whose client certificates the Server trusts.
+	// A write that times out breaks a TLS connection, so WriteRetries doesn't apply to them; see WriteTimeout.
+	// If nil, connections are plain TCP.
+	// It must be set before Run.
+	TLSConfig *tls.Config
+
+	// ListenConfig, if non-nil, is used to open each channel's listener, for instance to set socket options through
+	// its Control function.
+	// Its KeepAlive field is ignored, as keepalive is configured on each connection according to KeepAlive above.
+	// If nil, the Server listens with the net package's defaults.
+	// It must be set before Run.
+	ListenConfig *net.ListenConfig
+
+	// SocketActivation, if true, makes the Server adopt listeners that the process inherited from a service manager,
+	// such as systemd, rather than opening its own; channels' Hosts and ListenConfig are then ignored.
+	// It follows systemd's LISTEN_FDS convention: ListenPIDEnv must hold the process's ID, ListenFDsEnv must hold
+	// the number of listeners, which must be the number of channels, and the listeners are on consecutive file
+	// descriptors from ListenFDsStart, in the same order as the channels.
+	// Run clears those variables once it has adopted the listeners, or failed to.
+	// It must be set before Run.
+	SocketActivation bool
+
+	// Sequence, if non-nil, chooses whether each connection is sequenced as it is established, given the name of the
+	// channel it connected to and its remote address.
+	// Every message sent on a sequenced connection has a sequence number appended as its last argument.
+	// Numbers start at 0 for each connection, and go up by one per message, so a gap means the client missed
+	// messages (for instance, because SendDropOldest dropped them) and should ask for a dump.
+	// If nil, no connection is sequenced.
+	// It must be set before Run.
+	Sequence func(channel string, addr net.Addr) bool
+
+	// MarkOwn, if non-nil, chooses whether each connection marks its own broadcasts as it is established, given the
+	// name of the channel it connected to and its remote address.
+	// A marking connection gets broadcasts caused by its own requests tagged controller.TagOwnBcast, rather than
+	// message.TagBcast, so its client can tell its own changes from other clients'; see controller.Bifrost.MarkOwn.
+	// If nil, no connection marks its own broadcasts.
+	// It must be set before Run.
+	MarkOwn func(channel string, addr net.Addr) bool
+
+	// CompressDumps, if non-nil, chooses whether each connection gets its dumps compressed as it is established, given
+	// the name of the channel it connected to and its remote address.
+	// A compressing connection gets each dump, including the one after OHAI, as a single ZDUMP message; see
+	// controller.Bifrost.CompressDumps.
+	// If nil, no connection compresses its dumps.
+	// It must be set before Run.
+	CompressDumps func(channel string, addr net.Addr) bool
+
+	// SelectionOnly, if non-nil, chooses whether each connection is selection-only as it is established, given the
+	// name of the channel it connected to and its remote address.
+	// A selection-only connection gets compact notifications of the selection in place of the usual messages, for
+	// displays that only show what is on air; see controller.Bifrost.SelectionOnly.
+	// If nil, no connection is selection-only.
+	// It must be set before Run.
+	SelectionOnly func(channel string, addr net.Addr) bool
+
+	// PlayStateOnly, if non-nil, chooses whether each connection is play-state-only as it is established, given the
+	// name of the channel it connected to and its remote address.
+	// A play-state-only connection gets only notifications of whether the selection is cued, playing, or finished,
+	// for on-air lights and the like; see controller.Bifrost.PlayStateOnly.
+	// If nil, no connection is play-state-only.
+	// It must be set before Run.
+	PlayStateOnly func(channel string, addr net.Addr) bool
+
+	// CommandOnly, if non-nil, chooses whether each connection is command-only as it is established, given the name
+	// of the channel it connected to and its remote address.
+	// A command-only connection gets no broadcasts, nor the dump after OHAI, but still gets replies to its own
+	// requests, for clients that only send commands; see controller.Bifrost.CommandOnly.
+	// If nil, no connection is command-only.
+	// It must be set before Run.
+	CommandOnly func(channel string, addr net.Addr) bool
+
+	// CompressedInput, if non-nil, chooses whether each connection accepts compressed blocks of lines as it is
+	// established, given the name of the channel it connected to and its remote address; see ZlinesWord.
+	// If nil, no connection does.
+	// It must be set before Run.
+	CompressedInput func(channel string, addr net.Addr) bool
+
+	// MaxInflatedInput, if positive, is the most bytes any compressed block of lines can decompress to.
+	// This stops small blocks that decompress to huge ones from exhausting the Server's memory.
+	// If zero, the limit is 1MiB.
+	// It must be set before Run.
+	MaxInflatedInput int
+
+	// Encoding, if non-nil, chooses the Encoding for each connection as it is established, given the name of the
+	// channel it connected to and its remote address.
+	// This only affects what the Server writes: clients always send packed lines.
+	// If nil, every connection uses EncodingLine.
+	// It must be set before Run.
+	Encoding func(channel string, addr net.Addr) Encoding
+
+	// BatchInput, if true, makes each connection pass the Controller every line that has already arrived from its
+	// client in one batch, rather than one line at a time; see controller.Bifrost.SendBatch.
+	// This cuts the overhead of clients that send many lines at once, such as scripts.
+	// Each line still gets its own replies, in order.
+	// It must be set before Run.
+	BatchInput bool
+
+	// RequestRate, if positive, is the number of requests per second each client may send, on average.
+	// Lines over the limit are rejected with ErrRateLimited, without reaching the Controller, but don't disconnect
+	// the client.
+	// The limit is a token bucket of RequestBurst requests, so clients may go over RequestRate in short bursts.
+	// If zero, there is no limit.
+	// It must be set before Run.
+	RequestRate float64
+
+	// RequestBurst, if positive, is the number of requests a client may send in one burst, under RequestRate.
+	// It defaults to 1024, which lets through bulk loads of several hundred items, however fast they are sent.
+	// It must be set before Run.
+	RequestBurst int
+
+	// ErrorHistory, if positive, is the number of errors each connection remembers, so that its client can ask for
+	// them with an errors request (see ErrorsWord).
+	// This covers every error reply the client gets: rejections by the Server, and failures from the Controller.
+	// If zero, connections remember no errors, and pass errors requests to the Controller like any other.
+	// It must be set before Run.
+	ErrorHistory int
+
+	// Input is the policy on what clients may put in the words they send.
+	// Lines breaking it are rejected, but don't disconnect the client.
+	// It must be set before Run.
+	Input InputPolicy
+
 	// log is the Server's logger.
 	log *log.Logger
 
-	// host is the Server's host:port string.
-	host string
+	// channels holds the Server's channels, in the order they were given.
+	channels []Channel
 
-	// rootClient is a controller Client the Server can clone for
-	// use by incoming connections.
-	rootClient *controller.Client
+	// roots maps the name of each channel whose controller is still running to its root client.
+	roots map[string]*controller.Client
 
 	// clients is a map containing all connected clients.
-	clients map[Client]struct{}
+	clients map[*Client]struct{}
+
+	// started is the time, according to the Server's Clock, at which Run started.
+	// It is set before Run accepts any connections, and never changes after.
+	started time.Time
+
+	// nextID is the identifier that will be given to the next client to connect.
+	nextID uint64
 
-	// accConn is a channel used by the acceptor goroutine to send new
+	// listeners, if non-nil, holds one listener per channel, in order, that Run uses in place of opening its own.
+	// Tests set it to drive the accept loop without real sockets.
+	listeners []net.Listener
+
+	// accConn is a channel used by the acceptor goroutines to send new
 	// connections to the main goroutine.
-	accConn chan net.Conn
+	accConn chan acceptedConn
 
-	// accErr is a channel used by the acceptor goroutine to send errors
+	// accErr is a channel used by the acceptor goroutines to send errors
 	// to the main goroutine.
 	// Errors landing from accErr are considered fatal.
 	accErr chan error
 
+	// rootDone is a channel used by the root draining goroutines to tell the main goroutine that a channel's
+	// controller has shut down.
+	rootDone chan string
+
 	// clientHangUp is a channel used by client goroutines to send
 	// disconnections to the main goroutine.
-	// It sends a pointer to the client to disconnect.
-	clientHangUp chan *Client
+	// It sends the client to disconnect, and any error that caused the disconnection.
+	clientHangUp chan hangUpRequest
 
 	// clientErr is a channel used by client goroutines to send
 	// errors to the main goroutine.
 	// The client will send a hangup request if the error is fatal.
 	clientErr chan error
 
+	// statsReq is a channel used by Stats to ask the main goroutine for a snapshot.
+	statsReq chan chan Stats
+
 	// done is a channel closed when the main loop terminates.
 	// This is used to signal all goroutines to close, if they haven't
 	// already.
@@ -55,109 +345,428 @@ type Server struct {
 	wg sync.WaitGroup
 }
 
-// New creates a new network server for a baps3d instance.
+// acceptedConn is a connection accepted on one of a Server's channels.
+type acceptedConn struct {
+	// conn is the connection itself.
+	conn net.Conn
+	// channel is the name of the channel on which conn was accepted.
+	channel string
+}
+
+// New creates a new network server for a baps3d instance with one controller.
+// The server listens on host, and its single channel has an empty name.
 func New(l *log.Logger, host string, rc *controller.Client) *Server {
+	return NewMulti(l, []Channel{{Name: "", Host: host, Root: rc}})
+}
+
+// NewMulti creates a new network server hosting each of the given channels.
+// Channel names and hosts should be unique.
+func NewMulti(l *log.Logger, channels []Channel) *Server {
+	roots := make(map[string]*controller.Client, len(channels))
+	for _, c := range channels {
+		roots[c.Name] = c.Root
+	}
+
 	return &Server{
 		log:          l,
-		host:         host,
-		rootClient:   rc,
-		accConn:      make(chan net.Conn),
+		channels:     channels,
+		roots:        roots,
+		accConn:      make(chan acceptedConn),
 		accErr:       make(chan error),
-		clientHangUp: make(chan *Client),
+		rootDone:     make(chan string),
+		clientHangUp: make(chan hangUpRequest),
 		clientErr:    make(chan error),
+		statsReq:     make(chan chan Stats),
 		done:         make(chan struct{}),
-		clients:      make(map[Client]struct{}),
+		clients:      make(map[*Client]struct{}),
 	}
 }
 
-func (s *Server) shutdownController(ctx context.Context) {
+// shutdownTimeout is the amount of time the server waits for its controllers to shut down.
+const shutdownTimeout = 5 * time.Second
+
+// shutdownControllers asks each of s's controllers that are still running to shut down.
+// It uses its own context, as the server's context may well be the reason we're shutting down.
+func (s *Server) shutdownControllers() {
 	s.log.Println("shutting down")
-	if err := s.rootClient.Shutdown(ctx); err != nil {
-		s.log.Println("couldn't shut down gracefully:", err)
+
+	ctx, cancel := context.WithTimeout(context.Background(), shutdownTimeout)
+	defer cancel()
+
+	// The root draining goroutines are still running, so the controllers can't block broadcasting to us before they
+	// get to our shutdown requests.
+	for name, root := range s.roots {
+		if err := root.Shutdown(ctx); err != nil {
+			s.log.Printf("couldn't shut down %q gracefully: %s\n", name, err)
+		} else if ctx.Err() != nil {
+			s.log.Printf("couldn't shut down %q gracefully: timed out\n", name)
+		}
 	}
 }
 
-// newConnection sets up the server s to handle incoming connection c.
+// drainRoot handles any messages sent to the root client root of channel name, until the controller closes it.
+// It then tells the main loop, if it is still listening.
+func (s *Server) drainRoot(name string, root *controller.Client) {
+	for rs := range root.Rx {
+		s.handleRootResponse(name, rs)
+	}
+
+	select {
+	case s.rootDone <- name:
+	case <-s.done:
+	}
+}
+
+// handleRootResponse handles the response rs, sent to the root client of channel name.
+//
+// The root client sends nothing but the Server's own requests, whose replies don't come this way, so everything
+// that does is a broadcast, which goes to RootBroadcasts (or, without it, the log).
+// The only errors a Controller broadcasts are those of the requests it makes for itself, from schedules and the
+// deadman switch; these don't stop the controller, so handleRootResponse logs them, and carries on.
+func (s *Server) handleRootResponse(name string, rs controller.Response) {
+	switch b := rs.Body.(type) {
+	case controller.ScheduleFiredResponse:
+		if b.Err != nil {
+			s.log.Printf("schedule %d (%s) on %q failed: %s\n", b.ID, b.Label, name, b.Err)
+		}
+	case controller.DeadmanResponse:
+		if b.Err != nil {
+			s.log.Printf("deadman action on %q failed: %s\n", name, b.Err)
+		}
+	}
+
+	if s.RootBroadcasts != nil {
+		s.RootBroadcasts(name, rs)
+		return
+	}
+	s.log.Printf("broadcast on %q: %T%+v\n", name, rs.Body, rs.Body)
+}
+
+// newConnectionSafely is newConnection, but recovers from panics in it, returning them as PanicErrors.
+// The would-be client's ID is used up, and a disconnect event sent for it, so that monitoring hears about the panic.
+func (s *Server) newConnectionSafely(ctx context.Context, c net.Conn, channel string) (err error) {
+	if perr := catchPanic(func() { err = s.newConnection(ctx, c, channel) }); perr != nil {
+		p := perr.(*PanicError)
+		s.log.Printf("setting up client %d (%s) panicked: %v\n%s", s.nextID, connName(c), p.Value, p.Stack)
+		stub := Client{id: s.nextID, name: connName(c), ip: RemoteIP(c.RemoteAddr()), channel: channel}
+		s.emitFor(EventDisconnect, &stub, ReasonPanic, perr)
+		s.nextID++
+		return perr
+	}
+	return err
+}
+
+// ErrTooManyClients is the error with which the Server refuses connections once it has MaxClients clients.
+var ErrTooManyClients = errors.New("too many clients")
+
+// newConnection sets up the server s to handle incoming connection c on the channel named channel.
 // It does not close c on error.
-func (s *Server) newConnection(ctx context.Context, c net.Conn) error {
-	cname := c.RemoteAddr().String()
-	s.log.Println("new connection:", cname)
+func (s *Server) newConnection(ctx context.Context, c net.Conn, channel string) (err error) {
+	cname := connName(c)
+	s.log.Printf("new connection on %q: %s\n", channel, cname)
 
-	conClient, err := s.rootClient.Copy(ctx)
+	if 0 < s.MaxClients && s.MaxClients <= len(s.clients) {
+		return fmt.Errorf("%w: limit is %d", ErrTooManyClients, s.MaxClients)
+	}
+
+	// If the controller has gone away, these would never return, so don't wait too long for them.
+	sctx, cancel := context.WithTimeout(ctx, healthCheckTimeout)
+	defer cancel()
+
+	conClient, err := s.roots[channel].Copy(sctx)
 	if err != nil {
 		return err
 	}
+	// From here on, the Controller is talking to conClient, so it mustn't be left behind if we fail (or panic).
+	registered := false
+	defer func() {
+		if !registered {
+			abandonClient(conClient)
+		}
+	}()
 
-	conBifrost, conBifrostClient, err := conClient.Bifrost(ctx)
+	conBifrost, conBifrostClient, err := conClient.Bifrost(sctx)
 	if err != nil {
 		return err
 	}
 
-	ioClient := comm.IoEndpoint{
-		Io:       c,
-		Endpoint: conBifrostClient,
+	conBifrost.MarkOwn = s.MarkOwn != nil && s.MarkOwn(channel, c.RemoteAddr())
+	conBifrost.CompressDumps = s.CompressDumps != nil && s.CompressDumps(channel, c.RemoteAddr())
+	conBifrost.SelectionOnly = s.SelectionOnly != nil && s.SelectionOnly(channel, c.RemoteAddr())
+	conBifrost.PlayStateOnly = s.PlayStateOnly != nil && s.PlayStateOnly(channel, c.RemoteAddr())
+	conBifrost.CommandOnly = s.CommandOnly != nil && s.CommandOnly(channel, c.RemoteAddr())
+
+	policy := SendDisconnect
+	if s.SendPolicy != nil {
+		policy = s.SendPolicy(channel, c.RemoteAddr())
 	}
 
-	cli := Client{
+	outRate := 0
+	if s.OutputRate != nil {
+		outRate = s.OutputRate(channel, c.RemoteAddr())
+	}
+	var budget int64
+	if s.ByteBudget != nil {
+		budget = s.ByteBudget(channel, c.RemoteAddr())
+	}
+
+	queue := newSendQueue(s.SendBuffer, policy, conBifrost.IsLatestWins)
+	queue.sequenced = s.Sequence != nil && s.Sequence(channel, c.RemoteAddr())
+
+	encoding := EncodingLine
+	if s.Encoding != nil {
+		encoding = s.Encoding(channel, c.RemoteAddr())
+	}
+
+	ioClient := ioEndpoint{
+		queue:      queue,
+		writerDone: make(chan struct{}),
+		io:         c,
+		endpoint:   conBifrostClient,
+		byteBudget: budget,
+		maxWordLen: s.MaxWordLen,
+		restOfLine: conBifrost.RestOfLine,
+		input:      s.Input,
+		encoding:   encoding,
+
+		limits:           append(s.limits(outRate, budget), conBifrost.Limits()...),
+		clients:          s.clientCount,
+		handshakeTimeout: s.HandshakeTimeout,
+		idleTimeout:      s.IdleTimeout,
+		linger:           s.Linger,
+		writeTimeout:     s.WriteTimeout,
+		writeRetries:     s.WriteRetries,
+		writeRetryDelay:  s.WriteRetryDelay,
+		clock:            s.clock(),
+	}
+	if s.BatchInput {
+		ioClient.batch = conBifrost.SendBatch
+	}
+	if s.CompressedInput != nil && s.CompressedInput(channel, c.RemoteAddr()) {
+		ioClient.maxInflated = s.maxInflatedInput()
+	}
+	if 0 < outRate {
+		ioClient.outLimiter = newTokenBucket(float64(outRate), outRate, s.clock().Now())
+	}
+	if 0 < s.ErrorHistory {
+		ioClient.errors = newErrorRing(s.ErrorHistory)
+	}
+	if 0 < s.RequestRate {
+		ioClient.limiter = newTokenBucket(s.RequestRate, s.RequestBurst, s.clock().Now())
+	}
+	keepAlive := s.setKeepAlive(c)
+
+	options := connectionOptions(&ioClient, conBifrost)
+
+	errLog := NewErrorLimiter(s.log, errorQuietPeriod)
+	errLog.SetClock(s.clock())
+	cli := &Client{
+		id:        s.nextID,
 		name:      cname,
+		ip:        RemoteIP(c.RemoteAddr()),
+		channel:   channel,
 		ioClient:  &ioClient,
 		conClient: conClient,
 		log:       s.log,
+		errLog:    errLog,
+		keepAlive: keepAlive,
+		options:   options,
 	}
 
+	ioClient.identify = cli.identify
+	ioClient.self = cli.whoamiArgs
+	ioClient.serverInfo = s.serverInfoArgs
+
+	registered = true
+	s.nextID++
 	s.clients[cli] = struct{}{}
+	atomic.StoreInt64(&s.nclients, int64(len(s.clients)))
+	s.emitFor(EventConnect, cli, "", nil)
 
 	s.wg.Add(1)
 	go func() {
-		cli.Run(ctx, conBifrost, s.clientHangUp)
+		cli.Run(ctx, conBifrost, s.clientHangUp, s.done)
 		s.wg.Done()
 	}()
 
 	return nil
 }
 
+// maxInflatedInput gets the most bytes a compressed block of lines sent to s can decompress to.
+func (s *Server) maxInflatedInput() int {
+	if s.MaxInflatedInput <= 0 {
+		return defaultMaxInflatedInput
+	}
+	return s.MaxInflatedInput
+}
+
+// clock gets s's Clock.
+func (s *Server) clock() controller.Clock {
+	if s.Clock == nil {
+		return controller.SystemClock{}
+	}
+	return s.Clock
+}
+
+// clientCount gets the number of clients connected to s.
+// Unlike most of s's state, it is safe to call from any goroutine.
+func (s *Server) clientCount() int {
+	return int(atomic.LoadInt64(&s.nclients))
+}
+
 // hangUpAllClients gracefully closes all connected clients on s.
 func (s *Server) hangUpAllClients() {
 	for c := range s.clients {
-		s.hangUpClient(&c)
+		s.hangUpClient(c, ReasonShutdown, nil)
 	}
 }
 
-// hangUpClient closes the client pointed to by c.
-func (s *Server) hangUpClient(c *Client) {
-	s.log.Println("hanging up:", c.name)
+// hangUpClient closes the client pointed to by c, giving reason (and the causing error err, if any) as the reason.
+func (s *Server) hangUpClient(c *Client, reason string, err error) {
+	if _, ok := s.clients[c]; !ok {
+		// Already hung up.
+		return
+	}
+
+	name := c.describe()
+	s.log.Println("hanging up:", name)
 	if err := c.Close(); err != nil {
-		s.log.Printf("couldn't gracefully close %s: %s\n", c.name, err.Error())
+		s.log.Printf("couldn't gracefully close %s: %s\n", name, err.Error())
+	}
+	delete(s.clients, c)
+	atomic.StoreInt64(&s.nclients, int64(len(s.clients)))
+	s.emitFor(EventDisconnect, c, reason, err)
+}
+
+// listen opens a listener for each of s's channels, using s.ListenConfig if set, or adopts them if s uses socket
+// activation.
+// ctx is the context Run was given, so cancelling the server abandons any listens still in progress.
+// If any fails, it closes the listeners it has already opened.
+// If s already has its listeners, listen just hands them over.
+func (s *Server) listen(ctx context.Context) ([]net.Listener, error) {
+	if s.listeners != nil {
+		return s.listeners, nil
+	}
+	if s.SocketActivation {
+		lns, err := activatedListeners(s.log, len(s.channels))
+		for i, ln := range lns {
+			s.log.Printf("channel %q adopted listener on %s\n", s.channels[i].Name, ln.Addr())
+		}
+		return lns, err
+	}
+
+	var lc net.ListenConfig
+	if s.ListenConfig != nil {
+		lc = *s.ListenConfig
+	}
+	// Keepalive is set on each connection as it arrives, so that the Server knows what it is.
+	lc.KeepAlive = -1
+
+	lns := make([]net.Listener, 0, len(s.channels))
+	for _, c := range s.channels {
+		ln, err := lc.Listen(ctx, c.network(), c.Host)
+		if err != nil {
+			closeListeners(s.log, lns)
+			return nil, err
+		}
+		// The listener's own address is canonical, so this shows, for instance, IPv6 hosts in brackets.
+		s.log.Printf("channel %q now listening on %s\n", c.Name, ln.Addr())
+		lns = append(lns, ln)
+	}
+	return lns, nil
+}
+
+// closeListeners closes every listener in lns, logging errors to l.
+func closeListeners(l *log.Logger, lns []net.Listener) {
+	for _, ln := range lns {
+		if err := ln.Close(); err != nil {
+			l.Println("error closing listener:", err)
+		}
+	}
+}
+
+// checkControllers checks that each of s's controllers is alive, removing any that aren't.
+// It returns an error mentioning the first dead controller, if any.
+func (s *Server) checkControllers(ctx context.Context) error {
+	var firstErr error
+	for _, c := range s.channels {
+		root, ok := s.roots[c.Name]
+		if !ok {
+			continue
+		}
+
+		if err := checkAlive(ctx, root); err != nil {
+			delete(s.roots, c.Name)
+			if firstErr == nil {
+				firstErr = fmt.Errorf("controller for channel %q isn't running: %w", c.Name, err)
+			}
+		}
 	}
-	delete(s.clients, *c)
+	return firstErr
+}
+
+// healthCheckTimeout is the amount of time the server gives a controller to answer a health check.
+const healthCheckTimeout = time.Second
+
+// checkAlive checks that the controller behind client c is alive, giving up after healthCheckTimeout.
+func checkAlive(ctx context.Context, c *controller.Client) error {
+	hctx, cancel := context.WithTimeout(ctx, healthCheckTimeout)
+	defer cancel()
+	return c.CheckAlive(hctx)
 }
 
 // Run prepares and runs the net server main loop.
-func (s *Server) Run(ctx context.Context) {
-	defer s.wg.Wait()
-	defer s.shutdownController(ctx)
+//
+// Before listening, Run checks that every channel's controller is alive; if any isn't, Run fails straight away with
+// an error.
+// The same happens if Run can't listen on every channel's host.
+//
+// Otherwise, Run returns nil when ctx is cancelled, when any of the server's controllers shut down, or when the
+// server stops being able to accept connections.
+// All three cases go through the same teardown: the listeners close, all clients hang up, and Run waits for every
+// server goroutine to finish.
+// Any controllers still running at that point are shut down too, so channels live and die together.
+func (s *Server) Run(ctx context.Context) error {
+	s.started = s.clock().Now()
+	for name, root := range s.roots {
+		go s.drainRoot(name, root)
+	}
 
-	ln, err := net.Listen("tcp", s.host)
+	if err := s.checkControllers(ctx); err != nil {
+		s.stopEarly()
+		return err
+	}
+
+	lns, err := s.listen(ctx)
 	if err != nil {
-		s.log.Println("couldn't open server:", err)
-		return
+		s.stopEarly()
+		return fmt.Errorf("couldn't open server: %w", err)
 	}
 
-	s.log.Println("now listening on", s.host)
-	s.wg.Add(1)
-	go func() {
-		s.acceptClients(ln)
-		s.wg.Done()
-	}()
+	for i, ln := range lns {
+		s.wg.Add(1)
+		go func(ln net.Listener, channel string) {
+			s.acceptClients(ln, channel)
+			s.wg.Done()
+		}(s.secured(ln), s.channels[i].Name)
+	}
 
 	s.mainLoop(ctx)
 
 	close(s.done)
 	s.hangUpAllClients()
-	if err := ln.Close(); err != nil {
-		s.log.Println("error closing listener:", err)
-	}
-	s.log.Println("closed listener")
+	closeListeners(s.log, lns)
+	s.log.Println("closed listeners")
+
+	s.shutdownControllers()
+	s.wg.Wait()
+	return nil
+}
+
+// stopEarly tears down s when Run fails before the main loop starts.
+func (s *Server) stopEarly() {
+	close(s.done)
+	s.shutdownControllers()
 }
 
 // mainLoop is the server's main connection handling loop.
@@ -168,29 +777,68 @@ func (s *Server) mainLoop(ctx context.Context) {
 		case err := <-s.accErr:
 			s.log.Println("error accepting connections:", err)
 			return
-		case conn := <-s.accConn:
-			cname := conn.RemoteAddr().String()
-			if err := s.newConnection(ctx, conn); err != nil {
+		case ac := <-s.accConn:
+			cname := connName(ac.conn)
+			if err := s.newConnectionSafely(ctx, ac.conn, ac.channel); err != nil {
 				s.log.Printf("error registering connection %s: %s\n", cname, err.Error())
-				if cerr := conn.Close(); err != nil {
-					s.log.Printf("further error closing connection %s: %s\n", cname, cerr.Error())
-				}
+				s.wg.Add(1)
+				go func() {
+					s.refuse(ac.conn, cname, err)
+					s.wg.Done()
+				}()
+			}
+		case rq := <-s.clientHangUp:
+			var perr *PanicError
+			switch {
+			case rq.err == nil:
+				s.hangUpClient(rq.client, ReasonHungUp, nil)
+			case errors.As(rq.err, &perr):
+				s.log.Printf("client %d (%s) panicked: %v\n%s", rq.client.id, rq.client.name, perr.Value, perr.Stack)
+				s.hangUpClient(rq.client, ReasonPanic, rq.err)
+			case errors.Is(rq.err, ErrHandshakeTimeout), errors.Is(rq.err, ErrIdleTimeout):
+				s.hangUpClient(rq.client, ReasonTimeout, rq.err)
+			case errors.Is(rq.err, ErrByteBudget):
+				s.hangUpClient(rq.client, ReasonByteBudget, rq.err)
+			default:
+				s.hangUpClient(rq.client, ReasonConnectionError, rq.err)
 			}
-		case c := <-s.clientHangUp:
-			s.hangUpClient(c)
-		case <-s.rootClient.Rx:
-			// Drain any messages sent to the root client.
+		case reply := <-s.statsReq:
+			reply <- s.stats()
+		case name := <-s.rootDone:
+			// The root client closing means the controller has gone away.
+			s.log.Printf("received controller shutdown on %q\n", name)
+			delete(s.roots, name)
+			return
 		case <-done:
-			s.log.Println("received controller shutdown")
+			s.log.Println("server context cancelled")
 			return
 		}
 	}
 }
 
-// acceptClients keeps spinning, accepting clients on ln and sending them to
-// connCh, until ln closes.
-// It then sends the error on errCh and closes both channels.
-func (s *Server) acceptClients(ln net.Listener) {
+// refuseTimeout is the amount of time the server spends trying to tell a refused connection why.
+const refuseTimeout = time.Second
+
+// refuse tells the client on the other end of conn, named cname, that the server couldn't set it up, because of err,
+// then closes conn.
+// This is a best-effort banner, sent in place of OHAI: any error writing it is ignored.
+// It runs on its own goroutine, as writing to a TLS connection first reads the client's half of the handshake, and
+// the deadline bounds both, so a client that connects and sends nothing can't hold anything up for long.
+func (s *Server) refuse(conn net.Conn, cname string, err error) {
+	msg := message.New(message.TagBcast, core.RsAck).AddArgs("FAIL", fmt.Sprintf("connection refused: %s", err))
+	if bs, perr := msg.Pack(); perr == nil {
+		_ = conn.SetDeadline(time.Now().Add(refuseTimeout))
+		_, _ = conn.Write(bs)
+	}
+	if cerr := conn.Close(); cerr != nil {
+		s.log.Printf("further error closing connection %s: %s\n", cname, cerr.Error())
+	}
+}
+
+// acceptClients keeps spinning, accepting clients on ln for the channel named channel and sending them to the main
+// loop, until ln closes.
+// It then sends the error to the main loop, if it is still listening.
+func (s *Server) acceptClients(ln net.Listener, channel string) {
 	for {
 		conn, err := ln.Accept()
 		if err != nil {
@@ -199,14 +847,12 @@ func (s *Server) acceptClients(ln net.Listener) {
 			case s.accErr <- err:
 			case <-s.done:
 			}
-			close(s.accErr)
-			close(s.accConn)
 			return
 		}
 
 		// Only forward connections if the main loop actually wants them
 		select {
-		case s.accConn <- conn:
+		case s.accConn <- acceptedConn{conn: conn, channel: channel}:
 		case <-s.done:
 			// TODO(@MattWindsor91): necessary?
 			_ = conn.Close()
@@ -0,0 +1,922 @@
+package netsrv_test
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"log"
+	"net"
+	"reflect"
+	"runtime"
+	"strconv"
+	"strings"
+	"syscall"
+	"testing"
+	"time"
+
+	"github.com/UniversityRadioYork/baps3d/controller"
+	"github.com/UniversityRadioYork/baps3d/list"
+	"github.com/UniversityRadioYork/baps3d/netsrv"
+)
+
+// testTimeout is the amount of time tests wait for things that should happen promptly.
+const testTimeout = 5 * time.Second
+
+/*
+Test helpers
+*/
+
+// freeAddr finds a local TCP address that is, at time of asking, free to listen on.
+func freeAddr(t testing.TB) string {
+	t.Helper()
+
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("couldn't find a free port: %v", err)
+	}
+	addr := ln.Addr().String()
+	if err := ln.Close(); err != nil {
+		t.Fatalf("couldn't close port finder: %v", err)
+	}
+	return addr
+}
+
+// testServer holds the moving parts of a Server under test.
+type testServer struct {
+	// Server is the server itself.
+	Server *netsrv.Server
+	// Addr is the address the server is listening on.
+	Addr string
+	// Root is the root client of the server's controller, kept apart from the server's own client.
+	Root *controller.Client
+	// Cancel cancels the context the server and controller are running in.
+	Cancel context.CancelFunc
+	// Done closes when the server's Run returns.
+	Done <-chan struct{}
+	// RunErr is the error the server's Run returned; it is only valid once Done has closed.
+	RunErr *error
+	// ControllerDone closes when the controller's Run returns.
+	ControllerDone <-chan struct{}
+}
+
+// startServer sets up and runs a Server over a list controller, on a free IPv4 loopback port.
+// If setup is non-nil, it is called on the Server before it starts running.
+func startServer(t testing.TB, setup func(*netsrv.Server)) *testServer {
+	t.Helper()
+	return startServerOn(t, freeAddr(t), list.New(), setup)
+}
+
+// startServerOn is like startServer, but listens on addr, and serves a controller over state.
+func startServerOn(t testing.TB, addr string, state controller.Controllable, setup func(*netsrv.Server)) *testServer {
+	t.Helper()
+
+	ctx, cancel := context.WithCancel(context.Background())
+
+	ctl, root := controller.NewController(state)
+	cdone := make(chan struct{})
+	go func() {
+		ctl.Run(ctx)
+		close(cdone)
+	}()
+
+	netClient, err := root.Copy(ctx)
+	if err != nil {
+		cancel()
+		t.Fatalf("couldn't copy root client: %v", err)
+	}
+
+	srv := netsrv.New(log.New(ioutil.Discard, "", 0), addr, netClient)
+	if setup != nil {
+		setup(srv)
+	}
+
+	done := make(chan struct{})
+	var runErr error
+	go func() {
+		runErr = srv.Run(ctx)
+		close(done)
+	}()
+
+	return &testServer{Server: srv, Addr: addr, Root: root, Cancel: cancel, Done: done, RunErr: &runErr, ControllerDone: cdone}
+}
+
+// dial connects to ts, retrying until the server starts listening.
+// It returns the connection and a reader over it, having read the server's first (OHAI) line.
+func (ts *testServer) dial(t testing.TB) (net.Conn, *bufio.Reader) {
+	t.Helper()
+
+	deadline := time.Now().Add(testTimeout)
+	for {
+		conn, err := net.Dial("tcp", ts.Addr)
+		if err == nil {
+			rd := bufio.NewReader(conn)
+			if _, err := rd.ReadString('\n'); err != nil {
+				t.Fatalf("couldn't read greeting line: %v", err)
+			}
+			return conn, rd
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("couldn't connect to server: %v", err)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+// waitFor waits for ch to close, failing the test with what if it doesn't in time.
+func waitFor(t *testing.T, ch <-chan struct{}, what string) {
+	t.Helper()
+
+	select {
+	case <-ch:
+	case <-time.After(testTimeout):
+		t.Fatalf("timed out waiting for %s", what)
+	}
+}
+
+/*
+Test functions
+*/
+
+// TestServer_Run_ContextCancel tests that cancelling the server's context, with a client connected, tears the server
+// down and shuts its controller down.
+func TestServer_Run_ContextCancel(t *testing.T) {
+	ts := startServer(t, nil)
+	defer ts.Cancel()
+
+	conn, _ := ts.dial(t)
+	defer func() { _ = conn.Close() }()
+
+	ts.Cancel()
+	waitFor(t, ts.Done, "server to stop after cancellation")
+	waitFor(t, ts.ControllerDone, "controller to stop after cancellation")
+}
+
+// TestServer_Run_ControllerShutdown tests that shutting down the server's controller, with a client connected, tears
+// the server down.
+func TestServer_Run_ControllerShutdown(t *testing.T) {
+	ts := startServer(t, nil)
+	defer ts.Cancel()
+
+	conn, _ := ts.dial(t)
+	defer func() { _ = conn.Close() }()
+
+	go func() {
+		for range ts.Root.Rx {
+		}
+	}()
+	if err := ts.Root.Shutdown(context.Background()); err != nil {
+		t.Fatalf("couldn't shut down controller: %v", err)
+	}
+
+	waitFor(t, ts.ControllerDone, "controller to stop")
+	waitFor(t, ts.Done, "server to stop after controller shutdown")
+}
+
+// readUntilAck reads lines from rd until it reads the ACK for the tag tag, returning the lines before it.
+func readUntilAck(t *testing.T, rd *bufio.Reader, tag string) []string {
+	t.Helper()
+
+	var lines []string
+	for {
+		line, err := rd.ReadString('\n')
+		if err != nil {
+			t.Fatalf("couldn't read line: %v", err)
+		}
+		if strings.HasPrefix(line, tag+" ACK") {
+			return lines
+		}
+		lines = append(lines, strings.TrimSpace(line))
+	}
+}
+
+// TestServer_Run_MultiChannel tests that a Server with two channels routes each connection to the channel it
+// connected to, and keeps broadcasts within a channel.
+func TestServer_Run_MultiChannel(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	names := []string{"main", "sustainer"}
+	channels := make([]netsrv.Channel, len(names))
+	for i, name := range names {
+		ctl, root := controller.NewController(list.New())
+		go ctl.Run(ctx)
+		channels[i] = netsrv.Channel{Name: name, Host: freeAddr(t), Root: root}
+	}
+
+	events := make(chan netsrv.Event, 8)
+	srv := netsrv.NewMulti(log.New(ioutil.Discard, "", 0), channels)
+	srv.Events = events
+	done := make(chan struct{})
+	go func() {
+		srv.Run(ctx)
+		close(done)
+	}()
+	defer waitFor(t, done, "server to stop")
+	defer cancel()
+
+	var (
+		conns [2]net.Conn
+		rds   [2]*bufio.Reader
+	)
+	for i, c := range channels {
+		ts := testServer{Addr: c.Host}
+		conns[i], rds[i] = ts.dial(t)
+		defer conns[i].Close()
+
+		if e := nextEvent(t, events); e.Channel != c.Name {
+			t.Errorf("connect event channel: got %q, want %q", e.Channel, c.Name)
+		}
+	}
+
+	if _, err := fmt.Fprintln(conns[0], "t1 auto next"); err != nil {
+		t.Fatalf("couldn't write to main: %v", err)
+	}
+	if !containsLine(readUntilAck(t, rds[0], "t1"), "! AUTO next") {
+		t.Error("main didn't receive its own broadcast")
+	}
+
+	// This doesn't change the sustainer's automode, so it doesn't broadcast anything itself.
+	if _, err := fmt.Fprintln(conns[1], "t2 auto off"); err != nil {
+		t.Fatalf("couldn't write to sustainer: %v", err)
+	}
+	if containsLine(readUntilAck(t, rds[1], "t2"), "! AUTO next") {
+		t.Error("sustainer received main's broadcast")
+	}
+}
+
+// containsLine checks whether lines contains want.
+func containsLine(lines []string, want string) bool {
+	for _, l := range lines {
+		if l == want {
+			return true
+		}
+	}
+	return false
+}
+
+// TestServer_Run_DeadController tests that a Server whose controller isn't running fails fast, without listening.
+func TestServer_Run_DeadController(t *testing.T) {
+	// The controller never runs, so it never picks up requests.
+	_, root := controller.NewController(list.New())
+
+	addr := freeAddr(t)
+	srv := netsrv.New(log.New(ioutil.Discard, "", 0), addr, root)
+
+	errCh := make(chan error, 1)
+	go func() { errCh <- srv.Run(context.Background()) }()
+
+	select {
+	case err := <-errCh:
+		if !errors.Is(err, controller.ErrControllerShutDown) {
+			t.Errorf("got error %v, want ErrControllerShutDown", err)
+		}
+	case <-time.After(testTimeout):
+		t.Fatal("timed out waiting for server to fail")
+	}
+
+	if conn, err := net.Dial("tcp", addr); err == nil {
+		_ = conn.Close()
+		t.Error("server listened despite a dead controller")
+	}
+}
+
+// TestServer_Run_ListenConfig tests that the Server listens through its ListenConfig, if set.
+func TestServer_Run_ListenConfig(t *testing.T) {
+	controlled := make(chan string, 1)
+	ts := startServer(t, func(s *netsrv.Server) {
+		s.ListenConfig = &net.ListenConfig{
+			Control: func(network, address string, _ syscall.RawConn) error {
+				controlled <- address
+				return nil
+			},
+		}
+	})
+	defer ts.Cancel()
+
+	conn, _ := ts.dial(t)
+	defer func() { _ = conn.Close() }()
+
+	select {
+	case addr := <-controlled:
+		if addr != ts.Addr {
+			t.Errorf("control function got address %q, want %q", addr, ts.Addr)
+		}
+	default:
+		t.Fatal("control function wasn't called")
+	}
+}
+
+// TestServer_Run_ListenConfigError tests that Run fails if its ListenConfig refuses to listen.
+func TestServer_Run_ListenConfigError(t *testing.T) {
+	errRefused := errors.New("refused")
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	ctl, root := controller.NewController(list.New())
+	go ctl.Run(ctx)
+	go func() {
+		for range root.Rx {
+		}
+	}()
+	netClient, err := root.Copy(ctx)
+	if err != nil {
+		t.Fatalf("couldn't copy root client: %v", err)
+	}
+
+	srv := netsrv.New(log.New(ioutil.Discard, "", 0), freeAddr(t), netClient)
+	srv.ListenConfig = &net.ListenConfig{
+		Control: func(string, string, syscall.RawConn) error { return errRefused },
+	}
+	if err := srv.Run(ctx); !errors.Is(err, errRefused) {
+		t.Errorf("got error %v, want %v", err, errRefused)
+	}
+}
+
+// TestServer_Timeouts tests that the handshake and idle timeouts disconnect clients that go quiet before and after
+// their first line respectively.
+func TestServer_Timeouts(t *testing.T) {
+	const short = 100 * time.Millisecond
+
+	cases := []struct {
+		name      string
+		handshake time.Duration
+		idle      time.Duration
+		first     bool
+		want      error
+	}{
+		{"handshake", short, 0, false, netsrv.ErrHandshakeTimeout},
+		{"idle", 0, short, true, netsrv.ErrIdleTimeout},
+		{"idle after quick handshake", short, 3 * short, true, netsrv.ErrIdleTimeout},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			events := make(chan netsrv.Event, 8)
+			ts := startServer(t, func(s *netsrv.Server) {
+				s.Events = events
+				s.HandshakeTimeout = c.handshake
+				s.IdleTimeout = c.idle
+			})
+			defer ts.Cancel()
+
+			conn, rd := ts.dial(t)
+			defer conn.Close()
+			nextEvent(t, events)
+
+			start := time.Now()
+			if c.first {
+				if _, err := fmt.Fprint(conn, "t1 dump\n"); err != nil {
+					t.Fatalf("couldn't write to server: %v", err)
+				}
+				readUntilAck(t, rd, "t1")
+			}
+
+			e := nextEvent(t, events)
+			checkEvent(t, e, netsrv.EventDisconnect, 0, conn.LocalAddr().String(), netsrv.ReasonTimeout)
+			if !errors.Is(e.Err, c.want) {
+				t.Errorf("disconnect error: got %v, want %v", e.Err, c.want)
+			}
+			// The idle timeout, not the shorter handshake one, applies once the first line is in.
+			if c.first && time.Since(start) < c.idle {
+				t.Errorf("disconnected after %v, before the idle timeout of %v", time.Since(start), c.idle)
+			}
+		})
+	}
+}
+
+// TestServer_MaxClients tests that connections over MaxClients are told why they were refused and closed, and that
+// a client hanging up frees its slot.
+func TestServer_MaxClients(t *testing.T) {
+	const limit = 2
+
+	events := make(chan netsrv.Event, 8)
+	ts := startServer(t, func(s *netsrv.Server) {
+		s.Events = events
+		s.MaxClients = limit
+	})
+	defer ts.Cancel()
+
+	conns := make([]net.Conn, limit)
+	for i := range conns {
+		conn, _ := ts.dial(t)
+		defer conn.Close()
+		conns[i] = conn
+		nextEvent(t, events)
+	}
+
+	over, err := net.Dial("tcp", ts.Addr)
+	if err != nil {
+		t.Fatalf("couldn't connect to server: %v", err)
+	}
+	defer over.Close()
+	rd := bufio.NewReader(over)
+	if err := over.SetReadDeadline(time.Now().Add(testTimeout)); err != nil {
+		t.Fatalf("couldn't set deadline: %v", err)
+	}
+	line, err := rd.ReadString('\n')
+	if err != nil {
+		t.Fatalf("couldn't read refusal: %v", err)
+	}
+	if !strings.Contains(line, "ACK FAIL") || !strings.Contains(line, netsrv.ErrTooManyClients.Error()) {
+		t.Errorf("got line %q, want a refusal for too many clients", line)
+	}
+	checkHungUp(t, over, rd)
+
+	if err := conns[0].Close(); err != nil {
+		t.Fatalf("couldn't hang up: %v", err)
+	}
+	checkEvent(t, nextEvent(t, events), netsrv.EventDisconnect, 0, conns[0].LocalAddr().String(), netsrv.ReasonHungUp)
+
+	conn, rd := ts.dial(t)
+	defer conn.Close()
+	if e := nextEvent(t, events); e.Kind != netsrv.EventConnect || e.ClientID != limit {
+		t.Errorf("got %v event for client %d, want a connect for client %d", e.Kind, e.ClientID, limit)
+	}
+	if _, err := fmt.Fprint(conn, "t1 dump\n"); err != nil {
+		t.Fatalf("couldn't write to server: %v", err)
+	}
+	readUntilAck(t, rd, "t1")
+}
+
+// TestServer_HandshakeTimeoutCleared tests that, with no idle timeout, the handshake timeout stops applying once the
+// first line is in.
+func TestServer_HandshakeTimeoutCleared(t *testing.T) {
+	const short = 50 * time.Millisecond
+
+	ts := startServer(t, func(s *netsrv.Server) {
+		s.HandshakeTimeout = short
+	})
+	defer ts.Cancel()
+
+	conn, rd := ts.dial(t)
+	defer conn.Close()
+	for _, tag := range []string{"t1", "t2"} {
+		if _, err := fmt.Fprintf(conn, "%s dump\n", tag); err != nil {
+			t.Fatalf("couldn't write to server: %v", err)
+		}
+		readUntilAck(t, rd, tag)
+		time.Sleep(3 * short)
+	}
+}
+
+// TestServer_Sequence tests that a sequenced connection numbers every message it sends, from 0, including lines it
+// rejects itself.
+func TestServer_Sequence(t *testing.T) {
+	ts := startServer(t, func(s *netsrv.Server) {
+		s.Input = netsrv.InputPolicy{Strict: true}
+		s.Sequence = func(string, net.Addr) bool { return true }
+	})
+	defer ts.Cancel()
+
+	// dial reads the OHAI, which is message 0.
+	conn, rd := ts.dial(t)
+	defer conn.Close()
+
+	if _, err := fmt.Fprint(conn, "t1 'bad\x01' dump\nt2 dump\n"); err != nil {
+		t.Fatalf("couldn't write to server: %v", err)
+	}
+
+	want := 1
+	acks := 0
+	for acks < 2 {
+		line, err := rd.ReadString('\n')
+		if err != nil {
+			t.Fatalf("couldn't read line: %v", err)
+		}
+		words := strings.Fields(line)
+		if got := words[len(words)-1]; got != strconv.Itoa(want) {
+			t.Fatalf("line %q: got sequence number %s, want %d", line, got, want)
+		}
+		if words[1] == "ACK" {
+			acks++
+		}
+		want++
+	}
+}
+
+// TestServer_Status tests that the Server adds its client count to STATUS replies.
+func TestServer_Status(t *testing.T) {
+	ts := startServer(t, nil)
+	defer ts.Cancel()
+
+	conn, rd := ts.dial(t)
+	defer conn.Close()
+	conn2, _ := ts.dial(t)
+	defer conn2.Close()
+
+	if _, err := fmt.Fprint(conn, "t1 status\n"); err != nil {
+		t.Fatalf("couldn't write to server: %v", err)
+	}
+	lines := readUntilAck(t, rd, "t1")
+	if want := "t1 STATUS 0 -1 (undefined) off 2"; !containsLine(lines, want) {
+		t.Errorf("got %q, want a line %q", lines, want)
+	}
+}
+
+// TestServer_BatchInput tests that a batching Server acknowledges lines sent in one go in order, including lines that
+// fail to parse, and still rejects lines that break the input policy.
+func TestServer_BatchInput(t *testing.T) {
+	ts := startServer(t, func(s *netsrv.Server) {
+		s.BatchInput = true
+		s.Input = netsrv.InputPolicy{Strict: true}
+	})
+	defer ts.Cancel()
+
+	conn, rd := ts.dial(t)
+	defer conn.Close()
+
+	if _, err := fmt.Fprint(conn, "a status\nb bogus\nc x\x01y\nd status\n"); err != nil {
+		t.Fatalf("couldn't write to server: %v", err)
+	}
+
+	// Rejections don't go through the Controller, so c's can come at any point.
+	var acks []string
+	rejection := ""
+	for len(acks) < 3 || rejection == "" {
+		line, err := rd.ReadString('\n')
+		if err != nil {
+			t.Fatalf("couldn't read line: %v", err)
+		}
+		fields := strings.Fields(line)
+		if len(fields) < 3 || fields[1] != "ACK" {
+			continue
+		}
+		ack := strings.Join(fields[:3], " ")
+		if fields[0] == "c" {
+			rejection = ack
+			continue
+		}
+		acks = append(acks, ack)
+	}
+
+	if rejection != "c ACK WHAT" {
+		t.Errorf("got %q for the bad line, want a rejection", rejection)
+	}
+	want := []string{"a ACK OK", "b ACK WHAT", "d ACK OK"}
+	if !reflect.DeepEqual(acks, want) {
+		t.Errorf("got %q, want %q", acks, want)
+	}
+}
+
+// TestServer_UnknownAndMalformed tests that unknown words and malformed lines are rejected, with different errors,
+// without disconnecting the client, and that blank lines are ignored.
+func TestServer_UnknownAndMalformed(t *testing.T) {
+	ts := startServer(t, nil)
+	defer ts.Cancel()
+
+	conn, rd := ts.dial(t)
+	defer conn.Close()
+
+	if _, err := fmt.Fprint(conn, "\nt1\nt2 bogus\nt3 status\n"); err != nil {
+		t.Fatalf("couldn't write to server: %v", err)
+	}
+	lines := readUntilAck(t, rd, "t3")
+	for _, want := range []string{"t1 ACK WHAT 'word 1, byte 0: malformed line", "t2 ACK WHAT 'unknown word: bogus'"} {
+		found := false
+		for _, l := range lines {
+			found = found || strings.HasPrefix(l, want)
+		}
+		if !found {
+			t.Errorf("got %q, want a line starting %q", lines, want)
+		}
+	}
+}
+
+// TestServer_Ping tests that a ping gets a PONG with the controller's time and the token echoed verbatim.
+func TestServer_Ping(t *testing.T) {
+	ts := startServer(t, nil)
+	defer ts.Cancel()
+
+	conn, rd := ts.dial(t)
+	defer conn.Close()
+
+	before := time.Now()
+	for _, c := range []struct{ send, token string }{{"t1 ping\n", ""}, {"t2 ping 'a \"b\"'\n", `a "b"`}} {
+		tag := strings.Fields(c.send)[0]
+		if _, err := fmt.Fprint(conn, c.send); err != nil {
+			t.Fatalf("couldn't write to server: %v", err)
+		}
+		var pongs []string
+		for _, l := range readUntilAck(t, rd, tag) {
+			if strings.HasPrefix(l, tag+" PONG ") {
+				pongs = append(pongs, l)
+			}
+		}
+		if len(pongs) != 1 {
+			t.Fatalf("%s: got %q, want one PONG", tag, pongs)
+		}
+
+		words, err := netsrv.NewTokeniser(strings.NewReader(pongs[0]+"\n"), 0).ReadLine()
+		if err != nil {
+			t.Fatalf("%s: couldn't tokenise %q: %v", tag, pongs[0], err)
+		}
+		want := []string{tag, "PONG", words[2]}
+		if c.token != "" {
+			want = append(want, c.token)
+		}
+		if !reflect.DeepEqual(words, want) {
+			t.Errorf("%s: got %q, want %q", tag, words, want)
+		}
+		// Times go out to the millisecond, so the PONG can look earlier than before by up to a millisecond.
+		if at, err := controller.ParseTime(words[2]); err != nil || at.Before(before.Truncate(time.Millisecond)) {
+			t.Errorf("%s: got time %q (%v), want one after %v", tag, words[2], err, before)
+		}
+	}
+}
+
+// TestServer_SelectBeforeAck tests that, with several connections selecting at once, each connection gets the SEL
+// TestServer_StateChangedFails tests that a request whose hash guard no longer holds gets ACK FAIL, not ACK WHAT.
+func TestServer_StateChangedFails(t *testing.T) {
+	l := list.New()
+	if err := l.Add(list.NewTrack("a", "a.mp3"), 0); err != nil {
+		t.Fatalf("couldn't add item: %v", err)
+	}
+	ts := startServerOn(t, freeAddr(t), l, nil)
+	defer ts.Cancel()
+	go func() {
+		for range ts.Root.Rx {
+		}
+	}()
+
+	conn, rd := ts.dial(t)
+	defer conn.Close()
+	// readUntilAck eats the ACK, so we read it by hand.
+	if _, err := fmt.Fprintln(conn, "b sel 0 stale"); err != nil {
+		t.Fatalf("couldn't write to server: %v", err)
+	}
+	for {
+		line, err := rd.ReadString('\n')
+		if err != nil {
+			t.Fatalf("couldn't read line: %v", err)
+		}
+		if strings.HasPrefix(line, "b ACK") {
+			if !strings.HasPrefix(line, "b ACK FAIL") {
+				t.Errorf("got %q, want ACK FAIL", line)
+			}
+			return
+		}
+	}
+}
+
+// TestServer_MarkOwn tests that marking connections get their own broadcasts tagged '!!', and others' tagged '!'.
+func TestServer_MarkOwn(t *testing.T) {
+	l := list.New()
+	for i := 0; i < 2; i++ {
+		h := "h" + strconv.Itoa(i)
+		if err := l.Add(list.NewTrack(h, h+".mp3"), i); err != nil {
+			t.Fatalf("couldn't add item: %v", err)
+		}
+	}
+	ts := startServerOn(t, freeAddr(t), l, func(s *netsrv.Server) {
+		s.MarkOwn = func(string, net.Addr) bool { return true }
+	})
+	defer ts.Cancel()
+	go func() {
+		for range ts.Root.Rx {
+		}
+	}()
+
+	connA, rdA := ts.dial(t)
+	defer connA.Close()
+	connB, rdB := ts.dial(t)
+	defer connB.Close()
+
+	if _, err := fmt.Fprintln(connA, "a sel 0 h0"); err != nil {
+		t.Fatalf("couldn't write to server: %v", err)
+	}
+	if lines := readUntilAck(t, rdA, "a"); !containsLine(lines, "!! SEL 0 h0") {
+		t.Errorf("sender got %q, want its own selection tagged '!!'", lines)
+	}
+
+	if _, err := fmt.Fprintln(connB, "b sel 1 h1"); err != nil {
+		t.Fatalf("couldn't write to server: %v", err)
+	}
+	lines := readUntilAck(t, rdB, "b")
+	if !containsLine(lines, "! SEL 0 h0") {
+		t.Errorf("other client got %q, want the first selection tagged '!'", lines)
+	}
+	if !containsLine(lines, "!! SEL 1 h1") {
+		t.Errorf("other client got %q, want its own selection tagged '!!'", lines)
+	}
+}
+
+// broadcast its request caused immediately before the request's ACK, with no other SEL in between.
+func TestServer_SelectBeforeAck(t *testing.T) {
+	const (
+		nConns    = 4
+		nRequests = 20
+	)
+
+	l := list.New()
+	for i := 0; i < 2*nConns; i++ {
+		h := "h" + strconv.Itoa(i)
+		if err := l.Add(list.NewTrack(h, h+".mp3"), i); err != nil {
+			t.Fatalf("couldn't add item: %v", err)
+		}
+	}
+	ts := startServerOn(t, freeAddr(t), l, nil)
+	defer ts.Cancel()
+	go func() {
+		for range ts.Root.Rx {
+		}
+	}()
+
+	errs := make(chan error, nConns)
+	for c := 0; c < nConns; c++ {
+		conn, rd := ts.dial(t)
+		defer conn.Close()
+
+		go func(c int, conn net.Conn, rd *bufio.Reader) {
+			// Each connection flips between its own two items, so every request changes the selection.
+			var script strings.Builder
+			sels := make(map[string]string, nRequests)
+			for r := 0; r < nRequests; r++ {
+				idx := 2*c + r%2
+				tag := fmt.Sprintf("c%d-%d", c, r)
+				fmt.Fprintf(&script, "%s sel %d h%d\n", tag, idx, idx)
+				sels[tag] = fmt.Sprintf("! SEL %d h%d", idx, idx)
+			}
+			if _, err := fmt.Fprint(conn, script.String()); err != nil {
+				errs <- fmt.Errorf("couldn't write to server: %w", err)
+				return
+			}
+
+			lastSel := ""
+			for acked := 0; acked < nRequests; {
+				line, err := rd.ReadString('\n')
+				if err != nil {
+					errs <- fmt.Errorf("couldn't read line: %w", err)
+					return
+				}
+				line = strings.TrimSpace(line)
+				fields := strings.Fields(line)
+				switch {
+				case len(fields) == 4 && fields[1] == "SEL":
+					lastSel = line
+				case 3 <= len(fields) && fields[1] == "ACK":
+					if fields[2] != "OK" {
+						errs <- fmt.Errorf("got %q, want OK", line)
+						return
+					}
+					if want := sels[fields[0]]; lastSel != want {
+						errs <- fmt.Errorf("%s: got %q before the ACK, want %q", fields[0], lastSel, want)
+						return
+					}
+					acked++
+				}
+			}
+			errs <- nil
+		}(c, conn, rd)
+	}
+
+	for c := 0; c < nConns; c++ {
+		select {
+		case err := <-errs:
+			if err != nil {
+				t.Error(err)
+			}
+		case <-time.After(testTimeout):
+			t.Fatal("timed out waiting for connections")
+		}
+	}
+}
+
+// waitForNoClients waits for ts to have no connected clients.
+func (ts *testServer) waitForNoClients(t *testing.T) {
+	t.Helper()
+
+	deadline := time.Now().Add(testTimeout)
+	for {
+		st, ok := ts.Server.Stats(context.Background())
+		if !ok {
+			t.Fatal("server stopped while waiting for clients to go")
+		}
+		if len(st.Clients) == 0 {
+			return
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("timed out waiting for clients to go: %d left", len(st.Clients))
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+// TestServer_NoGoroutineLeak tests that connecting and disconnecting many clients, whether they hang up or the server
+// does, leaves no goroutines behind.
+func TestServer_NoGoroutineLeak(t *testing.T) {
+	ts := startServer(t, func(s *netsrv.Server) {
+		s.HandshakeTimeout = 20 * time.Millisecond
+	})
+	defer ts.Cancel()
+
+	// The first connection starts anything that lives as long as the server, so it goes before the baseline.
+	conn, _ := ts.dial(t)
+	conn.Close()
+	ts.waitForNoClients(t)
+	baseline := runtime.NumGoroutine()
+
+	for i := 0; i < 20; i++ {
+		conn, rd := ts.dial(t)
+		if i%2 == 0 {
+			conn.Close()
+			continue
+		}
+		// Saying nothing makes the server hang up.
+		for {
+			if _, err := rd.ReadString('\n'); err != nil {
+				break
+			}
+		}
+		conn.Close()
+	}
+	ts.waitForNoClients(t)
+
+	deadline := time.Now().Add(testTimeout)
+	for {
+		n := runtime.NumGoroutine()
+		if n <= baseline {
+			return
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("got %d goroutines after the connections closed, want at most %d", n, baseline)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+// logFeed is an io.Writer that sends each line logged to it down its channel, dropping lines if the channel is full.
+type logFeed chan string
+
+func (l logFeed) Write(p []byte) (int, error) {
+	select {
+	case l <- string(p):
+	default:
+	}
+	return len(p), nil
+}
+
+// TestServer_RootResponses tests that the Server passes broadcasts to its root client on to RootBroadcasts.
+func TestServer_RootResponses(t *testing.T) {
+	bcasts := make(chan controller.Response, 16)
+	ts := startServer(t, func(s *netsrv.Server) {
+		s.RootBroadcasts = func(channel string, rs controller.Response) {
+			if channel == "" {
+				bcasts <- rs
+			}
+		}
+	})
+	defer ts.Cancel()
+	go func() {
+		for range ts.Root.Rx {
+		}
+	}()
+
+	conn, rd := ts.dial(t)
+	defer conn.Close()
+	if _, err := fmt.Fprintln(conn, "a auto next"); err != nil {
+		t.Fatalf("couldn't write to server: %v", err)
+	}
+	readUntilAck(t, rd, "a")
+	select {
+	case rs := <-bcasts:
+		if want := (list.AutoModeResponse{AutoMode: list.AutoNext}); rs.Body != want {
+			t.Errorf("got root broadcast %+v, want %+v", rs.Body, want)
+		}
+	case <-time.After(testTimeout):
+		t.Fatal("timed out waiting for root broadcast")
+	}
+}
+
+// TestServer_RootResponses_Logged tests that, without RootBroadcasts, the Server logs broadcasts to its root client
+// instead of dropping them.
+func TestServer_RootResponses_Logged(t *testing.T) {
+	lines := make(logFeed, 256)
+	ts := startServer(t, func(s *netsrv.Server) { s.SetLog(log.New(lines, "", 0)) })
+	defer ts.Cancel()
+	go func() {
+		for range ts.Root.Rx {
+		}
+	}()
+
+	conn, rd := ts.dial(t)
+	defer conn.Close()
+	if _, err := fmt.Fprintln(conn, "a auto next"); err != nil {
+		t.Fatalf("couldn't write to server: %v", err)
+	}
+	readUntilAck(t, rd, "a")
+
+	timeout := time.After(testTimeout)
+	for {
+		select {
+		case line := <-lines:
+			if strings.Contains(line, "AutoModeResponse") {
+				return
+			}
+		case <-timeout:
+			t.Fatal("timed out waiting for the root broadcast to be logged")
+		}
+	}
+}
@@ -0,0 +1,51 @@
+package netsrv
+
+// File serverinfo.go contains server self-description; see ServerInfoWord.
+
+import (
+	"fmt"
+
+	"github.com/UniversityRadioYork/bifrost-go/core"
+	"github.com/UniversityRadioYork/bifrost-go/message"
+
+	"github.com/UniversityRadioYork/baps3d/controller"
+)
+
+// ServerInfoWord is the command word of requests a client sends to ask about the server process itself, for example
+// 'tag serverinfo'; monitoring dashboards can use it to show how long the server has been up, and which build it is.
+// The Server handles these itself, without passing them on to the Controller, and replies with a SERVERINFO message
+// (see RsServerInfo) and an ACK.
+const ServerInfoWord = "serverinfo"
+
+// RsServerInfo is the response word of replies to serverinfo requests.
+// The arguments are the time the Server started running, and its uptime since then, in milliseconds, both by its
+// Clock; the server version (see controller.Version); and the Bifrost protocol version it speaks.
+// Unlike WHOAMI, nothing in it depends on the connection, and unlike LIMIT, nothing in it depends on the settings.
+const RsServerInfo = "SERVERINFO"
+
+// replyServerInfo handles the serverinfo request m, which came from line.
+// Like rejections, the reply doesn't go through the Controller, so may overtake replies to earlier lines.
+func (e *ioEndpoint) replyServerInfo(line []string, m message.Message) error {
+	if len(m.Args()) != 0 {
+		return e.reject(line, fmt.Errorf("%s takes no arguments", ServerInfoWord))
+	}
+
+	for _, r := range []message.Message{
+		controller.NewMessage(m.Tag(), RsServerInfo, e.serverInfo()...),
+		controller.NewMessage(m.Tag(), core.RsAck, "OK", "success"),
+	} {
+		if err := e.queue.push(r); err != nil {
+			e.failSend(err)
+			return err
+		}
+	}
+	return nil
+}
+
+// serverInfoArgs gets the arguments of a SERVERINFO reply describing s.
+// It is safe to call from any goroutine once s is running.
+func (s *Server) serverInfoArgs() []string {
+	uptime := s.clock().Now().Sub(s.started)
+	return []string{controller.FormatTime(s.started), controller.FormatMillis(uptime), controller.Version,
+		core.ThisProtocolVer}
+}
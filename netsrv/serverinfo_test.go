@@ -0,0 +1,43 @@
+package netsrv_test
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/UniversityRadioYork/bifrost-go/core"
+
+	"github.com/UniversityRadioYork/baps3d/controller"
+	"github.com/UniversityRadioYork/baps3d/netsrv"
+)
+
+// TestServer_ServerInfo tests that a serverinfo request gives the Server's start time and uptime, by its Clock, and
+// its versions.
+func TestServer_ServerInfo(t *testing.T) {
+	start := time.Date(2020, 1, 1, 12, 0, 0, 0, time.UTC)
+	clk := &manualClock{now: start}
+	ts := startServer(t, func(s *netsrv.Server) {
+		s.Clock = clk
+	})
+	defer ts.Cancel()
+
+	conn, rd := ts.dial(t)
+	defer func() { _ = conn.Close() }()
+	clk.Advance(90 * time.Second)
+
+	if _, err := fmt.Fprintln(conn, "t serverinfo"); err != nil {
+		t.Fatalf("couldn't send line: %v", err)
+	}
+	var got []string
+	for _, line := range readUntilAck(t, rd, "t") {
+		if strings.HasPrefix(line, "t ") {
+			got = append(got, line)
+		}
+	}
+	want := fmt.Sprintf("t SERVERINFO %s 90000 %s %s", controller.FormatTime(start), controller.Version,
+		core.ThisProtocolVer)
+	if len(got) != 1 || got[0] != want {
+		t.Errorf("got %q, want %q", got, want)
+	}
+}
@@ -0,0 +1,166 @@
+package netsrv
+
+// File stats.go contains read-only snapshots of a Server's connections.
+
+import (
+	"context"
+	"net"
+	"sync/atomic"
+	"time"
+)
+
+// defaultKeepAlive is the TCP keepalive period used when Server.KeepAlive is zero.
+// It matches the net package's own default for accepted connections.
+const defaultKeepAlive = 15 * time.Second
+
+// Stats is a snapshot of a Server's state.
+type Stats struct {
+	// Clients holds a snapshot of each connected client.
+	Clients []ClientStats
+}
+
+// ClientStats is a snapshot of one client connection.
+type ClientStats struct {
+	// ID is the server-assigned identifier of the client, as in Event.
+	ID uint64
+	// RemoteAddr is the remote address of the client's connection.
+	// IPv6 addresses are in brackets, with any zone; clients of Unix domain sockets, which have no address of their
+	// own, show as 'local:' followed by the socket's path.
+	RemoteAddr string
+	// IP is the IP address of the client's connection, as given by RemoteIP.
+	IP net.IP
+	// Channel is the name of the Server channel the client connected to.
+	Channel string
+	// Name is the display name the client gave itself (see IdentifyWord), or RemoteAddr if it hasn't given one.
+	Name string
+	// KeepAlive is true if TCP keepalive is active on the connection.
+	KeepAlive bool
+	// KeepAliveInterval, if KeepAlive is true, is the configured keepalive period.
+	KeepAliveInterval time.Duration
+	// LastWrite is the time of the last successful write to the connection, or the zero time if there hasn't been one.
+	LastWrite time.Time
+	// Dropped is the number of superseded messages dropped because the client fell behind; see SendDropOldest.
+	Dropped uint64
+	// BytesRead is the number of bytes read from the connection since the client connected.
+	BytesRead uint64
+	// BytesWritten is the number of bytes written to the connection since the client connected, including the parts
+	// of writes that were cut short.
+	BytesWritten uint64
+
+	// The depths below cover the only places a connection buffers messages: the channels between it and its
+	// Controller are unbuffered, so have no depth of their own.
+	// Peaks are the most there have been at once since the client connected.
+
+	// SendQueued is the number of messages waiting to be written to the client.
+	SendQueued int
+	// SendQueuePeak is the peak of SendQueued.
+	SendQueuePeak int
+	// SendQueueCap is the most messages that can wait to be written to the client; see Server.SendBuffer.
+	SendQueueCap int
+	// LinesBuffered is the number of lines read from the client that are waiting, behind the one being handled, to go to
+	// its Controller.
+	LinesBuffered int
+	// LinesBufferedPeak is the peak of LinesBuffered.
+	LinesBufferedPeak int
+}
+
+// Stats takes a snapshot of s's state.
+// It returns false if s isn't running, or ctx finishes first.
+//
+// Taking a snapshot only reads state the Server already keeps: it never touches the connections themselves.
+func (s *Server) Stats(ctx context.Context) (Stats, bool) {
+	reply := make(chan Stats, 1)
+	select {
+	case s.statsReq <- reply:
+	case <-s.done:
+		return Stats{}, false
+	case <-ctx.Done():
+		return Stats{}, false
+	}
+	return <-reply, true
+}
+
+// stats builds a snapshot of s's state; it must be called from the main loop.
+func (s *Server) stats() Stats {
+	st := Stats{Clients: make([]ClientStats, 0, len(s.clients))}
+	for c := range s.clients {
+		st.Clients = append(st.Clients, c.stats())
+	}
+	return st
+}
+
+// stats builds a snapshot of c.
+func (c *Client) stats() ClientStats {
+	cs := ClientStats{
+		ID:                c.id,
+		RemoteAddr:        c.name,
+		IP:                c.ip,
+		Channel:           c.channel,
+		Name:              c.displayName(),
+		KeepAlive:         0 < c.keepAlive,
+		KeepAliveInterval: c.keepAlive,
+		Dropped:           c.ioClient.queue.droppedCount(),
+	}
+	if lw := c.ioClient.lastWrite(); lw != 0 {
+		cs.LastWrite = time.Unix(0, lw)
+	}
+	cs.SendQueued, cs.SendQueuePeak, cs.SendQueueCap = c.ioClient.queue.depth()
+	cs.LinesBuffered, cs.LinesBufferedPeak = c.ioClient.buffered()
+	cs.BytesRead, cs.BytesWritten = c.ioClient.byteCounts()
+	return cs
+}
+
+// setKeepAlive configures TCP keepalive on conn (or, if it speaks TLS, the connection underneath) according to
+// s.KeepAlive.
+// It returns the keepalive period now active on conn, or 0 if keepalive is off.
+func (s *Server) setKeepAlive(conn net.Conn) time.Duration {
+	tc, ok := rawConn(conn).(*net.TCPConn)
+	if !ok {
+		return 0
+	}
+
+	period := s.KeepAlive
+	if period == 0 {
+		period = defaultKeepAlive
+	}
+	if period < 0 {
+		if err := tc.SetKeepAlive(false); err != nil {
+			s.log.Println("couldn't disable keepalive:", err)
+		}
+		return 0
+	}
+
+	if err := tc.SetKeepAlive(true); err != nil {
+		s.log.Println("couldn't enable keepalive:", err)
+		return 0
+	}
+	if err := tc.SetKeepAlivePeriod(period); err != nil {
+		s.log.Println("couldn't set keepalive period:", err)
+		return 0
+	}
+	return period
+}
+
+// lastWrite gets the time of the last successful write on e, in Unix nanoseconds, or 0 if there hasn't been one.
+func (e *ioEndpoint) lastWrite() int64 {
+	return atomic.LoadInt64(&e.lastWriteNs)
+}
+
+// markWrite records that a write on e just succeeded.
+func (e *ioEndpoint) markWrite() {
+	atomic.StoreInt64(&e.lastWriteNs, e.clock.Now().UnixNano())
+}
+
+// buffered gets the number of lines read on e that are waiting to go to its adapter, and the most there have been.
+func (e *ioEndpoint) buffered() (n, peak int) {
+	return int(atomic.LoadInt64(&e.linesBuffered)), int(atomic.LoadInt64(&e.linesPeak))
+}
+
+// noteBuffered records that n lines read on e are waiting to go to its adapter.
+// Only runTx calls it, so the peak needs no compare-and-swap.
+func (e *ioEndpoint) noteBuffered(n int) {
+	atomic.StoreInt64(&e.linesBuffered, int64(n))
+	if atomic.LoadInt64(&e.linesPeak) < int64(n) {
+		atomic.StoreInt64(&e.linesPeak, int64(n))
+	}
+}
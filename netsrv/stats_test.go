@@ -0,0 +1,105 @@
+package netsrv_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/UniversityRadioYork/baps3d/netsrv"
+)
+
+// TestServer_Stats_KeepAlive tests that Stats reports each connection's keepalive configuration and last write.
+func TestServer_Stats_KeepAlive(t *testing.T) {
+	cases := []struct {
+		name      string
+		keepAlive time.Duration
+		active    bool
+		interval  time.Duration
+	}{
+		{"default", 0, true, 15 * time.Second},
+		{"custom", 30 * time.Second, true, 30 * time.Second},
+		{"disabled", -1, false, 0},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			events := make(chan netsrv.Event, 8)
+			ts := startServer(t, func(s *netsrv.Server) {
+				s.Events = events
+				s.KeepAlive = c.keepAlive
+			})
+			defer ts.Cancel()
+
+			before := time.Now()
+			// dial reads the OHAI, so at least one write has happened by the time it returns.
+			conn, _ := ts.dial(t)
+			defer conn.Close()
+			nextEvent(t, events)
+
+			ctx, cancel := context.WithTimeout(context.Background(), testTimeout)
+			defer cancel()
+			st, ok := ts.Server.Stats(ctx)
+			if !ok {
+				t.Fatal("couldn't get stats")
+			}
+			if len(st.Clients) != 1 {
+				t.Fatalf("got %d clients, want 1", len(st.Clients))
+			}
+
+			cs := st.Clients[0]
+			if cs.RemoteAddr != conn.LocalAddr().String() {
+				t.Errorf("remote address: got %q, want %q", cs.RemoteAddr, conn.LocalAddr().String())
+			}
+			if cs.KeepAlive != c.active || cs.KeepAliveInterval != c.interval {
+				t.Errorf("keepalive: got %v/%v, want %v/%v", cs.KeepAlive, cs.KeepAliveInterval, c.active, c.interval)
+			}
+			if cs.LastWrite.Before(before) {
+				t.Errorf("last write: got %v, want something after %v", cs.LastWrite, before)
+			}
+		})
+	}
+}
+
+// TestServer_Stats_Depths tests that Stats reports the capacity of each connection's send queue, and that the OHAI
+// passed through it.
+func TestServer_Stats_Depths(t *testing.T) {
+	ts := startServer(t, func(s *netsrv.Server) {
+		s.SendBuffer = 16
+	})
+	defer ts.Cancel()
+
+	conn, _ := ts.dial(t)
+	defer conn.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), testTimeout)
+	defer cancel()
+	st, ok := ts.Server.Stats(ctx)
+	if !ok {
+		t.Fatal("couldn't get stats")
+	}
+	if len(st.Clients) != 1 {
+		t.Fatalf("got %d clients, want 1", len(st.Clients))
+	}
+
+	cs := st.Clients[0]
+	if cs.SendQueueCap != 16 {
+		t.Errorf("send queue capacity: got %d, want 16", cs.SendQueueCap)
+	}
+	if cs.SendQueuePeak < 1 {
+		t.Errorf("send queue peak: got %d, want at least 1", cs.SendQueuePeak)
+	}
+	if cs.SendQueued > cs.SendQueuePeak || cs.LinesBuffered > cs.LinesBufferedPeak {
+		t.Errorf("depths above their peaks: %+v", cs)
+	}
+}
+
+// TestServer_Stats_Stopped tests that Stats fails once the Server has stopped.
+func TestServer_Stats_Stopped(t *testing.T) {
+	ts := startServer(t, nil)
+	ts.Cancel()
+	<-ts.Done
+
+	if _, ok := ts.Server.Stats(context.Background()); ok {
+		t.Error("got stats from a stopped server")
+	}
+}
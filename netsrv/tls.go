@@ -0,0 +1,58 @@
+package netsrv
+
+// File tls.go contains TLS, with which the Server encrypts its connections on shared networks; see Server.TLSConfig.
+
+import (
+	"crypto/tls"
+	"io"
+	"net"
+)
+
+// tlsListener is a listener whose connections speak TLS over those of the listener it wraps.
+type tlsListener struct {
+	net.Listener
+	// config is the TLS configuration of each connection.
+	config *tls.Config
+}
+
+// Accept waits for the next connection to l, and wraps it in TLS.
+// The handshake happens on the connection's first read or write, so a slow client can't hold up the accept loop.
+func (l tlsListener) Accept() (net.Conn, error) {
+	c, err := l.Listener.Accept()
+	if err != nil {
+		return nil, err
+	}
+	return tlsConn{Conn: tls.Server(c, l.config), raw: c}, nil
+}
+
+// tlsConn is a TLS connection that remembers the connection underneath it, so that the Server can still configure
+// TCP keepalive on it.
+type tlsConn struct {
+	*tls.Conn
+	// raw is the connection the TLS connection runs over.
+	raw net.Conn
+}
+
+// retriesWrites gets whether writes to conn that time out are worth retrying.
+// They aren't on TLS connections: a write timing out leaves crypto/tls unsure how much of a record went out, so it
+// fails every later write with the same error, and retrying would only put off the hangup.
+func retriesWrites(conn io.Writer) bool {
+	_, isTLS := conn.(tlsConn)
+	return !isTLS
+}
+
+// secured wraps ln in TLS, if s has a TLS configuration.
+func (s *Server) secured(ln net.Listener) net.Listener {
+	if s.TLSConfig == nil {
+		return ln
+	}
+	return tlsListener{Listener: ln, config: s.TLSConfig}
+}
+
+// rawConn gets the connection underneath conn, if it is a TLS connection, or conn itself otherwise.
+func rawConn(conn net.Conn) net.Conn {
+	if tc, ok := conn.(tlsConn); ok {
+		return tc.raw
+	}
+	return conn
+}
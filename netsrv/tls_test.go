@@ -0,0 +1,206 @@
+package netsrv_test
+
+import (
+	"bufio"
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"errors"
+	"fmt"
+	"math/big"
+	"net"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/UniversityRadioYork/baps3d/controller"
+	"github.com/UniversityRadioYork/baps3d/netsrv"
+)
+
+// selfSigned makes a self-signed certificate for 127.0.0.1, good for both servers and clients, along with a pool
+// trusting it.
+func selfSigned(t *testing.T, name string) (tls.Certificate, *x509.CertPool) {
+	t.Helper()
+
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatalf("couldn't generate key: %v", err)
+	}
+	tmpl := &x509.Certificate{
+		SerialNumber:          big.NewInt(1),
+		Subject:               pkix.Name{CommonName: name},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(time.Hour),
+		KeyUsage:              x509.KeyUsageDigitalSignature | x509.KeyUsageCertSign,
+		ExtKeyUsage:           []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth, x509.ExtKeyUsageClientAuth},
+		BasicConstraintsValid: true,
+		IsCA:                  true,
+		IPAddresses:           []net.IP{net.IPv4(127, 0, 0, 1)},
+	}
+	der, err := x509.CreateCertificate(rand.Reader, tmpl, tmpl, &key.PublicKey, key)
+	if err != nil {
+		t.Fatalf("couldn't make certificate: %v", err)
+	}
+	cert, err := x509.ParseCertificate(der)
+	if err != nil {
+		t.Fatalf("couldn't parse certificate: %v", err)
+	}
+	pool := x509.NewCertPool()
+	pool.AddCert(cert)
+	return tls.Certificate{Certificate: [][]byte{der}, PrivateKey: key, Leaf: cert}, pool
+}
+
+// TestServer_TLS tests that a Server with mutual TLS exchanges messages with a client presenting a trusted
+// certificate, and hangs up on one presenting none.
+func TestServer_TLS(t *testing.T) {
+	serverCert, serverPool := selfSigned(t, "server")
+	clientCert, clientPool := selfSigned(t, "client")
+	ts := startServer(t, func(s *netsrv.Server) {
+		s.TLSConfig = &tls.Config{
+			Certificates: []tls.Certificate{serverCert},
+			ClientAuth:   tls.RequireAndVerifyClientCert,
+			ClientCAs:    clientPool,
+		}
+	})
+	defer ts.Cancel()
+
+	dial := func(certs []tls.Certificate) (*tls.Conn, error) {
+		d := &net.Dialer{Timeout: testTimeout}
+		return tls.DialWithDialer(d, "tcp", ts.Addr, &tls.Config{RootCAs: serverPool, Certificates: certs})
+	}
+
+	conn, err := dial([]tls.Certificate{clientCert})
+	for deadline := time.Now().Add(testTimeout); err != nil && time.Now().Before(deadline); {
+		time.Sleep(10 * time.Millisecond)
+		conn, err = dial([]tls.Certificate{clientCert})
+	}
+	if err != nil {
+		t.Fatalf("couldn't connect: %v", err)
+	}
+	defer func() { _ = conn.Close() }()
+
+	rd := bufio.NewReader(conn)
+	if greeting, err := rd.ReadString('\n'); err != nil || !strings.Contains(greeting, "OHAI") {
+		t.Fatalf("got greeting %q and error %v, want OHAI", greeting, err)
+	}
+	if _, err := fmt.Fprintln(conn, "t whoami"); err != nil {
+		t.Fatalf("couldn't send line: %v", err)
+	}
+	var whoami string
+	for _, line := range readUntilAck(t, rd, "t") {
+		if strings.HasPrefix(line, "t "+netsrv.RsWhoami) {
+			whoami = line
+		}
+	}
+	if whoami == "" {
+		t.Error("got no WHOAMI over TLS")
+	}
+
+	// TLS 1.3 clients finish their side of the handshake before the server checks their certificate, so the
+	// refusal shows up on the first read.
+	bare, err := dial(nil)
+	if err == nil {
+		defer func() { _ = bare.Close() }()
+		_ = bare.SetReadDeadline(time.Now().Add(testTimeout))
+		_, err = bufio.NewReader(bare).ReadString('\n')
+	}
+	if err == nil {
+		t.Error("expected a client without a certificate to be refused")
+	}
+}
+
+// TestServer_MaxClients_TLS tests that a TLS client that connects to a full Server, and never starts its handshake,
+// doesn't stop the Server hanging up and taking on other clients.
+func TestServer_MaxClients_TLS(t *testing.T) {
+	serverCert, serverPool := selfSigned(t, "server")
+	events := make(chan netsrv.Event, 8)
+	ts := startServer(t, func(s *netsrv.Server) {
+		s.Events = events
+		s.MaxClients = 1
+		s.TLSConfig = &tls.Config{Certificates: []tls.Certificate{serverCert}}
+	})
+	defer ts.Cancel()
+
+	dial := func() (*tls.Conn, error) {
+		d := &net.Dialer{Timeout: testTimeout}
+		return tls.DialWithDialer(d, "tcp", ts.Addr, &tls.Config{RootCAs: serverPool})
+	}
+	conn, err := dial()
+	for deadline := time.Now().Add(testTimeout); err != nil && time.Now().Before(deadline); {
+		time.Sleep(10 * time.Millisecond)
+		conn, err = dial()
+	}
+	if err != nil {
+		t.Fatalf("couldn't connect: %v", err)
+	}
+	defer func() { _ = conn.Close() }()
+	nextEvent(t, events)
+
+	silent, err := net.Dial("tcp", ts.Addr)
+	if err != nil {
+		t.Fatalf("couldn't connect: %v", err)
+	}
+	defer func() { _ = silent.Close() }()
+	// Give the server time to refuse the silent client before the first one hangs up.
+	time.Sleep(50 * time.Millisecond)
+
+	if err := conn.Close(); err != nil {
+		t.Fatalf("couldn't hang up: %v", err)
+	}
+	if e := nextEvent(t, events); e.Kind != netsrv.EventDisconnect {
+		t.Errorf("got %v event, want a disconnect", e.Kind)
+	}
+	conn2, err := dial()
+	if err != nil {
+		t.Fatalf("couldn't reconnect: %v", err)
+	}
+	defer func() { _ = conn2.Close() }()
+	if e := nextEvent(t, events); e.Kind != netsrv.EventConnect {
+		t.Errorf("got %v event, want a connect", e.Kind)
+	}
+}
+
+// TestEndpoint_WriteRetries_TLS tests that an endpoint gives up on a TLS connection at its first write timeout, as
+// crypto/tls would fail every retry anyway.
+func TestEndpoint_WriteRetries_TLS(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	cert, pool := selfSigned(t, "server")
+	conn, peer := net.Pipe()
+	defer peer.Close()
+	e, adapter := netsrv.NewTLSEndpoint(conn, &tls.Config{Certificates: []tls.Certificate{cert}})
+	// Were the endpoint to retry, it would take minutes to give up.
+	e.SetWriteRetries(50*time.Millisecond, 2, time.Minute)
+	errCh := make(chan error, 8)
+	go e.Run(ctx, errCh)
+	go func() {
+		for range adapter.Rx {
+		}
+	}()
+
+	client := tls.Client(peer, &tls.Config{RootCAs: pool, ServerName: "127.0.0.1"})
+	go func() { adapter.Tx <- controller.NewMessage("!", "HELLO", "world") }()
+	if err := client.SetReadDeadline(time.Now().Add(testTimeout)); err != nil {
+		t.Fatalf("couldn't set deadline: %v", err)
+	}
+	if line, err := bufio.NewReader(client).ReadString('\n'); err != nil || line != "! HELLO world\n" {
+		t.Fatalf("got %q, %v, want the first message", line, err)
+	}
+
+	// Nothing reads the pipe from here on, so the next write times out.
+	adapter.Tx <- controller.NewMessage("!", "HELLO", "again")
+	select {
+	case err := <-errCh:
+		var nerr net.Error
+		if !errors.As(err, &nerr) || !nerr.Timeout() {
+			t.Errorf("got error %v, want a timeout", err)
+		}
+	case <-time.After(testTimeout):
+		t.Error("timed out waiting for the endpoint to give up")
+	}
+}
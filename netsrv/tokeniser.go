@@ -0,0 +1,453 @@
+package netsrv
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"unicode"
+
+	"github.com/UniversityRadioYork/bifrost-go/message"
+)
+
+// ErrWordTooLong is the error returned when a client sends a word longer than the Server's MaxWordLen.
+var ErrWordTooLong = errors.New("word too long")
+
+// ParseError is an error in parsing a client's line, along with where in the line it happened.
+type ParseError struct {
+	// Word is the index of the word, within the line, at which parsing failed.
+	// If the line had too few words, it is the index of the first missing word.
+	Word int
+	// WordOffset is the byte offset, within word Word, at which parsing failed.
+	// It counts the word's bytes after unquoting and unescaping.
+	WordOffset int
+	// Offset is the byte offset, within the raw line, at which parsing failed.
+	// It is -1 if the error came from an already tokenised line, in which case only Word and WordOffset are known.
+	Offset int
+	// Err is the underlying error.
+	Err error
+}
+
+// Error gets the error message of e, including its position.
+func (e *ParseError) Error() string {
+	if e.Offset < 0 {
+		return fmt.Sprintf("word %d, byte %d: %v", e.Word, e.WordOffset, e.Err)
+	}
+	return fmt.Sprintf("byte %d (word %d, byte %d): %v", e.Offset, e.Word, e.WordOffset, e.Err)
+}
+
+// Unwrap gets the underlying error of e.
+func (e *ParseError) Unwrap() error {
+	return e.Err
+}
+
+// Values of Parser.restAt that don't give a position.
+const (
+	restUnknown = -1
+	restNone    = -2
+)
+
+// maxCommandLen is the longest command word a Parser will look up for rest-of-line handling.
+// No real command word is anywhere near this long, so this just stops the lookup buffer growing without bound.
+const maxCommandLen = 64
+
+// Parser is a push-style Bifrost line parser, optionally capping the length of each word.
+// Bytes go in through Write, in pieces split anywhere, even mid-word; finished lines come out through Line.
+// It holds on to any partial line between Writes, so it suits transports, and event-loop clients, that get bytes as
+// they come rather than by blocking on a Reader; Tokeniser does the blocking for those that do.
+//
+// It wraps bifrost-go's byte-level Tokeniser, which keeps its word buffer private, so the word limit is checked as
+// each byte goes in, before the byte-level Tokeniser gets the chance to store it.
+type Parser struct {
+	// RestOfLine, if non-nil, opts messages into having a rest-of-line argument.
+	// Once the command word of a line (the word after the tag) has been read, the Parser calls RestOfLine with it.
+	// If RestOfLine returns ok, then after fixed more words, the remainder of the line (without leading whitespace
+	// or any trailing carriage return) becomes one final word, verbatim: quotes, backslashes and spaces in it are
+	// kept as they are.
+	// Lines whose command word isn't opted in are tokenised as usual.
+	// It must be set before the first Write.
+	RestOfLine func(word string) (fixed int, ok bool)
+
+	tok *message.Tokeniser
+
+	// maxWordLen, if positive, is the maximum length of any one word.
+	maxWordLen int
+	// scan tracks the words of the line being tokenised.
+	scan lineScanner
+	// restAt, if non-negative, is the number of words after which the current line's rest-of-line word starts.
+	// It is restUnknown until the line's command word has been looked up, and restNone if it isn't opted in.
+	restAt int
+	// rest, if inRest, holds the rest-of-line word read so far.
+	rest []byte
+	// inRest is true if the Parser is reading a rest-of-line word.
+	inRest bool
+	// lineOffset is the number of bytes of the current line read so far.
+	lineOffset int
+	// lines holds the finished lines Line hasn't yet taken, oldest first.
+	lines [][]string
+	// err, if non-nil, is a previous ErrWordTooLong; once a word is too long, the rest of the stream is suspect.
+	err error
+}
+
+// NewParser creates and returns a new, empty Parser.
+// If maxWordLen is positive, Write fails with ErrWordTooLong as soon as any word exceeds maxWordLen bytes;
+// otherwise, words can be of any length.
+func NewParser(maxWordLen int) *Parser {
+	return &Parser{
+		tok:        message.NewTokeniser(),
+		maxWordLen: maxWordLen,
+		restAt:     restUnknown,
+	}
+}
+
+// Write feeds the bytes in b to p, keeping any lines they finish for Line.
+// It fails if a word is too long, returning the number of bytes before the one past the limit; errors from
+// too-long words are ParseErrors, giving the position of that byte.
+// Lines finished before the error can still be taken with Line, but every later Write fails with the same error.
+func (p *Parser) Write(b []byte) (int, error) {
+	if p.err != nil {
+		return 0, p.err
+	}
+
+	for i, c := range b {
+		line, ok, err := p.tokeniseByte(c)
+		if err != nil {
+			return i, err
+		}
+		if ok {
+			p.lines = append(p.lines, line)
+		}
+	}
+	return len(b), nil
+}
+
+// Line takes the oldest finished line p holds, returning false if there isn't one.
+func (p *Parser) Line() ([]string, bool) {
+	if len(p.lines) == 0 {
+		return nil, false
+	}
+	line := p.lines[0]
+	p.lines[0] = nil
+	p.lines = p.lines[1:]
+	return line, true
+}
+
+// Buffered gets the number of finished lines p holds that Line hasn't yet taken.
+func (p *Parser) Buffered() int {
+	return len(p.lines)
+}
+
+// End tells p that its input has ended, finishing any line left part-way through as if it had ended with a newline.
+// The exception is a last line that ends inside quotes or just after a backslash: p can't know what the rest of its
+// last word would have been, so End discards the line, failing with a ParseError wrapping io.ErrUnexpectedEOF.
+// A last line that is only whitespace is dropped silently, as there is nothing in it to deliver.
+func (p *Parser) End() error {
+	if p.err != nil {
+		return p.err
+	}
+	if p.inRest {
+		p.lineOffset = 0
+		p.lines = append(p.lines, p.endRest())
+		return nil
+	}
+	if p.scan.escape || p.scan.quote != quoteNone {
+		return &ParseError{
+			Word:       p.scan.words,
+			WordOffset: p.scan.wordLen,
+			Offset:     p.lineOffset,
+			Err:        io.ErrUnexpectedEOF,
+		}
+	}
+	if p.scan.words == 0 && !p.scan.inWord {
+		return nil
+	}
+	_, err := p.Write([]byte{'\n'})
+	return err
+}
+
+// Tokeniser reads tokenised Bifrost lines from a Reader, optionally capping the length of each word.
+// It is a Parser, fed from the Reader as ReadLine needs more bytes.
+//
+// It does its own buffering, rather than using bifrost-go's ReaderTokeniser, because ReaderTokeniser never advances
+// past a read that ends part-way through a line, so it spins (and grows its word buffer without bound) on any line
+// split across reads.
+type Tokeniser struct {
+	Parser
+
+	reader io.Reader
+	buf    [4096]byte
+}
+
+// NewTokeniser creates and returns a new, empty Tokeniser reading from reader.
+// If maxWordLen is positive, ReadLine fails with ErrWordTooLong as soon as any word exceeds maxWordLen bytes;
+// otherwise, words can be of any length.
+func NewTokeniser(reader io.Reader, maxWordLen int) *Tokeniser {
+	return &Tokeniser{Parser: *NewParser(maxWordLen), reader: reader}
+}
+
+// ReadLine reads a tokenised line from the Reader.
+// ReadLine may return an error if the Reader chokes, or if a word is too long.
+// Errors from too-long words are ParseErrors, giving the position of the first byte past the limit.
+//
+// Lines can arrive over any number of reads, split anywhere, even mid-word.
+// If the Reader ends part-way through a line, ReadLine delivers that last line as in Parser.End, then returns io.EOF
+// on the next call.
+func (t *Tokeniser) ReadLine() ([]string, error) {
+	for {
+		line, ok, err := t.ReadBufferedLine()
+		if err != nil || ok {
+			return line, err
+		}
+
+		if err := t.fill(); err != nil {
+			if errors.Is(err, io.EOF) {
+				if eerr := t.End(); eerr != nil {
+					return []string{}, eerr
+				}
+				if line, ok := t.Line(); ok {
+					return line, nil
+				}
+			}
+			return []string{}, err
+		}
+	}
+}
+
+// ReadBufferedLine is like ReadLine, but never reads from the Reader: it only returns lines from bytes the Tokeniser
+// has already read.
+// If those bytes finished a line, it returns the line and true; otherwise, it returns false.
+// This lets callers pick up lines that arrived alongside the last one ReadLine returned, without blocking.
+func (t *Tokeniser) ReadBufferedLine() ([]string, bool, error) {
+	if line, ok := t.Line(); ok {
+		return line, true, nil
+	}
+	if t.err != nil {
+		return []string{}, false, t.err
+	}
+	return nil, false, nil
+}
+
+// fill reads more bytes from t's reader, and feeds them to its Parser.
+// It can fail with errors from the reader; errors from the Parser show up in ReadBufferedLine, once the lines before
+// them have gone.
+func (t *Tokeniser) fill() error {
+	n, err := t.reader.Read(t.buf[:])
+	// The Reader contract allows bytes to come back alongside an error; we'd rather keep the bytes and pick up the
+	// error (again) on the next read.
+	if 0 < n {
+		_, _ = t.Write(t.buf[:n])
+		return nil
+	}
+	return err
+}
+
+// tokeniseByte tokenises the byte b, returning a line if b finished one.
+func (p *Parser) tokeniseByte(b byte) ([]string, bool, error) {
+	offset := p.lineOffset
+	p.lineOffset++
+
+	if p.inRest {
+		if b == '\n' {
+			p.lineOffset = 0
+			return p.endRest(), true, nil
+		}
+		p.rest = append(p.rest, b)
+		return nil, false, p.checkWordLen(p.restAt, len(p.rest), offset)
+	}
+
+	if p.startsRest(b) {
+		p.inRest = true
+		p.rest = append(p.rest[:0], b)
+		return nil, false, p.checkWordLen(p.restAt, len(p.rest), offset)
+	}
+
+	if err := p.checkWordLen(p.scan.words, p.scan.scan(b), offset); err != nil {
+		return nil, false, err
+	}
+	p.lookUpRest()
+
+	// Feeding one byte at a time means the byte-level Tokeniser never sees a byte past the word limit.
+	_, lineok, line := p.tok.TokeniseBytes([]byte{b})
+	if lineok {
+		p.restAt = restUnknown
+		p.lineOffset = 0
+	}
+	return line, lineok, nil
+}
+
+// startsRest checks whether b is the first byte of the current line's rest-of-line word.
+func (p *Parser) startsRest(b byte) bool {
+	if p.restAt < 0 || p.scan.words != p.restAt || !p.scan.betweenWords() {
+		return false
+	}
+	// Whitespace separating the rest from the fixed words isn't part of it, and a newline means there is no rest.
+	return b != '\n' && !unicode.IsSpace(rune(b))
+}
+
+// lookUpRest works out, once the current line's command word is complete, where its rest-of-line word starts.
+func (p *Parser) lookUpRest() {
+	if p.restAt != restUnknown || p.scan.words != 2 || !p.scan.betweenWords() {
+		return
+	}
+
+	p.restAt = restNone
+	if p.RestOfLine == nil {
+		return
+	}
+	if fixed, ok := p.RestOfLine(string(p.scan.command)); ok && 0 <= fixed {
+		p.restAt = 2 + fixed
+	}
+}
+
+// endRest finishes the current line, whose rest-of-line word is in p.rest, and returns it.
+func (p *Parser) endRest() []string {
+	// The fixed words are still in the byte-level Tokeniser; a newline gets them out.
+	_, _, line := p.tok.TokeniseBytes([]byte{'\n'})
+
+	rest := p.rest
+	if n := len(rest); 0 < n && rest[n-1] == '\r' {
+		rest = rest[:n-1]
+	}
+	line = append(line, string(rest))
+
+	p.scan.scan('\n')
+	p.inRest = false
+	p.rest = p.rest[:0]
+	p.restAt = restUnknown
+	return line
+}
+
+// checkWordLen checks the length n of the current word, word number word, against the word length limit, if any.
+// offset is the offset within the line of the byte just read.
+func (p *Parser) checkWordLen(word, n, offset int) error {
+	if p.maxWordLen <= 0 || n <= p.maxWordLen {
+		return nil
+	}
+	p.err = &ParseError{
+		Word:       word,
+		WordOffset: n - 1,
+		Offset:     offset,
+		Err:        fmt.Errorf("%w: over %d bytes", ErrWordTooLong, p.maxWordLen),
+	}
+	return p.err
+}
+
+// wordQuote is the kind of quoting a lineScanner is currently inside.
+type wordQuote int
+
+const (
+	quoteNone wordQuote = iota
+	quoteSingle
+	quoteDouble
+)
+
+// lineScanner follows the same quoting and escaping rules as the Bifrost tokeniser, but only tracks where words
+// begin and end, and how long the current one is, rather than storing them.
+// The exception is the command word (the second word of the line), which it keeps for rest-of-line lookups.
+type lineScanner struct {
+	// words is the number of words finished so far on this line.
+	words int
+	// wordLen is the length of the current word.
+	wordLen int
+	// inWord is true if a word has started and not yet finished.
+	inWord bool
+	// command holds the command word, once (and while) it is read, up to maxCommandLen bytes.
+	command []byte
+
+	escape bool
+	quote  wordQuote
+}
+
+// scan updates the scanner with byte b, returning the length of the current word afterwards.
+func (s *lineScanner) scan(b byte) int {
+	if s.escape {
+		s.escape = false
+		s.put(b)
+		return s.wordLen
+	}
+
+	switch s.quote {
+	case quoteSingle:
+		s.scanSingleQuoted(b)
+	case quoteDouble:
+		s.scanDoubleQuoted(b)
+	default:
+		s.scanUnquoted(b)
+	}
+	return s.wordLen
+}
+
+// betweenWords is true if the scanner isn't in the middle of a word.
+func (s *lineScanner) betweenWords() bool {
+	return !s.inWord && !s.escape && s.quote == quoteNone
+}
+
+// scanUnquoted handles byte b outside quotes.
+func (s *lineScanner) scanUnquoted(b byte) {
+	switch b {
+	case '\'':
+		s.start()
+		s.quote = quoteSingle
+	case '"':
+		s.start()
+		s.quote = quoteDouble
+	case '\\':
+		s.escape = true
+	case '\n':
+		s.endWord()
+		s.words = 0
+	default:
+		// As in the tokeniser, this only catches ASCII whitespace.
+		if unicode.IsSpace(rune(b)) {
+			s.endWord()
+		} else {
+			s.put(b)
+		}
+	}
+}
+
+// scanSingleQuoted handles byte b inside single quotes.
+func (s *lineScanner) scanSingleQuoted(b byte) {
+	if b == '\'' {
+		s.quote = quoteNone
+	} else {
+		s.put(b)
+	}
+}
+
+// scanDoubleQuoted handles byte b inside double quotes.
+func (s *lineScanner) scanDoubleQuoted(b byte) {
+	switch b {
+	case '"':
+		s.quote = quoteNone
+	case '\\':
+		s.escape = true
+	default:
+		s.put(b)
+	}
+}
+
+// start starts a word, if one hasn't already started.
+func (s *lineScanner) start() {
+	if !s.inWord && s.words == 1 {
+		s.command = s.command[:0]
+	}
+	s.inWord = true
+}
+
+// put counts b as part of the current word.
+func (s *lineScanner) put(b byte) {
+	s.start()
+	s.wordLen++
+	if s.words == 1 && len(s.command) < maxCommandLen {
+		s.command = append(s.command, b)
+	}
+}
+
+// endWord finishes the current word, if there is one.
+func (s *lineScanner) endWord() {
+	if s.inWord {
+		s.words++
+	}
+	s.inWord = false
+	s.wordLen = 0
+}
@@ -0,0 +1,393 @@
+package netsrv_test
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"reflect"
+	"strings"
+	"testing"
+	"testing/iotest"
+
+	"github.com/UniversityRadioYork/bifrost-go/message"
+
+	"github.com/UniversityRadioYork/baps3d/netsrv"
+)
+
+// countingReader is a reader that counts how many bytes have been read from it.
+type countingReader struct {
+	r io.Reader
+	n int
+}
+
+func (c *countingReader) Read(p []byte) (int, error) {
+	n, err := c.r.Read(p)
+	c.n += n
+	return n, err
+}
+
+// TestTokeniser_ReadLine_HugeWord tests that a Tokeniser with a word limit fails on one huge word among small ones,
+// after the lines before it come through intact, and without reading much of the huge word.
+func TestTokeniser_ReadLine_HugeWord(t *testing.T) {
+	const max = 8
+	huge := strings.Repeat("x", 1024*1024)
+	input := fmt.Sprintf("ok 12345678 'a b c d'\nfoo %s bar\nbaz\n", huge)
+
+	cr := countingReader{r: strings.NewReader(input)}
+	tok := netsrv.NewTokeniser(&cr, max)
+
+	line, err := tok.ReadLine()
+	if err != nil {
+		t.Fatalf("unexpected error on first line: %v", err)
+	}
+	if want := []string{"ok", "12345678", "a b c d"}; !reflect.DeepEqual(line, want) {
+		t.Fatalf("first line: got %q, want %q", line, want)
+	}
+
+	if _, err = tok.ReadLine(); !errors.Is(err, netsrv.ErrWordTooLong) {
+		t.Fatalf("second line: got error %v, want ErrWordTooLong", err)
+	}
+	// The Tokeniser reads in 4096-byte chunks, so it shouldn't get much further than the first chunk.
+	if 2*4096 < cr.n {
+		t.Errorf("read %d bytes before failing; should have stopped shortly after the huge word started", cr.n)
+	}
+
+	// The error sticks: we can't recover mid-word.
+	if _, err = tok.ReadLine(); !errors.Is(err, netsrv.ErrWordTooLong) {
+		t.Errorf("third line: got error %v, want ErrWordTooLong", err)
+	}
+}
+
+// TestTokeniser_ReadLine_Quoting tests that quoting and escaping count towards word lengths the way the tokeniser
+// reads them.
+func TestTokeniser_ReadLine_Quoting(t *testing.T) {
+	cases := []struct {
+		name  string
+		input string
+		ok    bool
+	}{
+		{"quotes don't count", `"abcd"'efgh'` + "\n", true},
+		{"escapes don't count", `a\b\c\d\e\f\g\h` + "\n", true},
+		{"escaped space is in the word", `abcd\ efgh` + "\n", false},
+		{"quoted space is in the word", `'abcd efgh'` + "\n", false},
+		{"quoted newline is in the word", "\"abcd\nefgh\"\n", false},
+		{"whitespace splits words", "abcdefgh\tabcdefgh abcdefgh\r\n", true},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			_, err := netsrv.NewTokeniser(strings.NewReader(c.input), 8).ReadLine()
+			if c.ok && err != nil {
+				t.Errorf("unexpected error: %v", err)
+			}
+			if !c.ok && !errors.Is(err, netsrv.ErrWordTooLong) {
+				t.Errorf("got error %v, want ErrWordTooLong", err)
+			}
+		})
+	}
+}
+
+// TestTokeniser_ReadLine_Unlimited tests that a Tokeniser without a word limit reads long words, even when lines
+// arrive split across many reads.
+func TestTokeniser_ReadLine_Unlimited(t *testing.T) {
+	huge := strings.Repeat("x", 10000)
+	input := fmt.Sprintf("foo %s bar\nbaz\n", huge)
+
+	for _, max := range []int{0, -1} {
+		tok := netsrv.NewTokeniser(iotest.OneByteReader(strings.NewReader(input)), max)
+		for _, want := range [][]string{{"foo", huge, "bar"}, {"baz"}} {
+			line, err := tok.ReadLine()
+			if err != nil {
+				t.Fatalf("limit %d: unexpected error: %v", max, err)
+			}
+			if !reflect.DeepEqual(line, want) {
+				t.Fatalf("limit %d: got %d-word line, want %d words", max, len(line), len(want))
+			}
+		}
+		if _, err := tok.ReadLine(); err != io.EOF {
+			t.Errorf("limit %d: got error %v at end, want EOF", max, err)
+		}
+	}
+}
+
+// TestTokeniser_ReadBufferedLine tests that ReadBufferedLine returns only lines already buffered, without reading
+// more, and that ReadLine picks up a partial line it leaves behind.
+func TestTokeniser_ReadBufferedLine(t *testing.T) {
+	cr := countingReader{r: io.MultiReader(strings.NewReader("a\nb\nc"), strings.NewReader("d\n"))}
+	tok := netsrv.NewTokeniser(&cr, 0)
+
+	if line, err := tok.ReadLine(); err != nil || !reflect.DeepEqual(line, []string{"a"}) {
+		t.Fatalf("ReadLine: got %q, %v; want [a]", line, err)
+	}
+	if line, ok, err := tok.ReadBufferedLine(); err != nil || !ok || !reflect.DeepEqual(line, []string{"b"}) {
+		t.Fatalf("first ReadBufferedLine: got %q, %v, %v; want [b], true", line, ok, err)
+	}
+	if line, ok, err := tok.ReadBufferedLine(); err != nil || ok {
+		t.Fatalf("second ReadBufferedLine: got %q, %v, %v; want no line", line, ok, err)
+	}
+	if cr.n != 5 {
+		t.Errorf("read %d bytes, want only the first 5", cr.n)
+	}
+	if line, err := tok.ReadLine(); err != nil || !reflect.DeepEqual(line, []string{"cd"}) {
+		t.Errorf("final ReadLine: got %q, %v; want [cd]", line, err)
+	}
+}
+
+// TestParser_Write tests that a Parser gives out lines as Writes finish them, holding on to partial lines, and that
+// End finishes the last one.
+func TestParser_Write(t *testing.T) {
+	p := netsrv.NewParser(0)
+
+	write := func(s string) {
+		t.Helper()
+		if n, err := p.Write([]byte(s)); n != len(s) || err != nil {
+			t.Fatalf("Write(%q): got %d, %v; want %d, nil", s, n, err, len(s))
+		}
+	}
+	lines := func() [][]string {
+		var got [][]string
+		for line, ok := p.Line(); ok; line, ok = p.Line() {
+			got = append(got, line)
+		}
+		return got
+	}
+
+	write("t1 pl")
+	if got := lines(); len(got) != 0 {
+		t.Fatalf("got lines %q before any finished", got)
+	}
+	write("ay 'a b\n")
+	write("c'\nt2 stop\nt3 du")
+	if got, want := lines(), [][]string{{"t1", "play", "a b\nc"}, {"t2", "stop"}}; !reflect.DeepEqual(got, want) {
+		t.Errorf("got lines %q, want %q", got, want)
+	}
+
+	if err := p.End(); err != nil {
+		t.Fatalf("unexpected End error: %v", err)
+	}
+	if got, want := lines(), [][]string{{"t3", "du"}}; !reflect.DeepEqual(got, want) {
+		t.Errorf("after End, got lines %q, want %q", got, want)
+	}
+}
+
+// TestParser_Write_TooLong tests that a Parser keeps the lines before an overlong word, and fails every Write after.
+func TestParser_Write_TooLong(t *testing.T) {
+	p := netsrv.NewParser(4)
+
+	n, err := p.Write([]byte("t1 ok\nt2 toolong\n"))
+	if !errors.Is(err, netsrv.ErrWordTooLong) || n != 13 {
+		t.Errorf("got %d, %v; want 13, ErrWordTooLong", n, err)
+	}
+	if line, ok := p.Line(); !ok || !reflect.DeepEqual(line, []string{"t1", "ok"}) {
+		t.Errorf("got line %q, %v; want the line before the error", line, ok)
+	}
+	if _, err := p.Write([]byte("t3 ok\n")); !errors.Is(err, netsrv.ErrWordTooLong) {
+		t.Errorf("later Write: got %v, want ErrWordTooLong", err)
+	}
+}
+
+// TestServer_MaxWordLen tests that a Server with a word limit disconnects a client sending an overlong word.
+func TestServer_MaxWordLen(t *testing.T) {
+	events := make(chan netsrv.Event, 8)
+	ts := startServer(t, func(s *netsrv.Server) {
+		s.Events = events
+		s.MaxWordLen = 16
+	})
+	defer ts.Cancel()
+
+	conn, _ := ts.dial(t)
+	defer conn.Close()
+	nextEvent(t, events)
+
+	if _, err := fmt.Fprintf(conn, "t1 dump\nt2 %s dump\n", strings.Repeat("x", 17)); err != nil {
+		t.Fatalf("couldn't write to server: %v", err)
+	}
+
+	e := nextEvent(t, events)
+	checkEvent(t, e, netsrv.EventDisconnect, 0, conn.LocalAddr().String(), netsrv.ReasonConnectionError)
+	if !errors.Is(e.Err, netsrv.ErrWordTooLong) {
+		t.Errorf("disconnect error: got %v, want ErrWordTooLong", e.Err)
+	}
+}
+
+// sayRestOfLine opts 'say' messages, with one fixed argument, into rest-of-line handling.
+func sayRestOfLine(word string) (int, bool) {
+	return 1, word == "say"
+}
+
+// TestTokeniser_ReadLine_RestOfLine tests that opted-in messages have their last argument taken verbatim from the
+// rest of the line, and that other messages are tokenised as usual.
+func TestTokeniser_ReadLine_RestOfLine(t *testing.T) {
+	input := strings.Join([]string{
+		`t1 say 0   hello 'world'  "a b"\ ` + "\r",
+		`t2 other 'a b' c`,
+		`t3 say 0`,
+		`t4 'say' 1 it's`,
+		`t5 say 'x y' z`,
+	}, "\n") + "\n"
+	want := [][]string{
+		{"t1", "say", "0", `hello 'world'  "a b"\ `},
+		{"t2", "other", "a b", "c"},
+		{"t3", "say", "0"},
+		{"t4", "say", "1", "it's"},
+		{"t5", "say", "x y", "z"},
+	}
+
+	tok := netsrv.NewTokeniser(iotest.OneByteReader(strings.NewReader(input)), 0)
+	tok.RestOfLine = sayRestOfLine
+	for i, w := range want {
+		line, err := tok.ReadLine()
+		if err != nil {
+			t.Fatalf("line %d: unexpected error: %v", i, err)
+		}
+		if !reflect.DeepEqual(line, w) {
+			t.Errorf("line %d: got %q, want %q", i, line, w)
+		}
+	}
+}
+
+// TestTokeniser_ReadLine_RestOfLinePack tests that packing a message with a rest-of-line argument, then tokenising it
+// strictly, gets the argument back in one piece.
+func TestTokeniser_ReadLine_RestOfLinePack(t *testing.T) {
+	tok := netsrv.NewTokeniser(strings.NewReader(`t1 say 0 it's "quoted" and \spaced`+"\n"), 0)
+	tok.RestOfLine = sayRestOfLine
+	line, err := tok.ReadLine()
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	msg, err := message.NewFromLine(line)
+	if err != nil {
+		t.Fatalf("couldn't make message: %v", err)
+	}
+	packed, err := msg.Pack()
+	if err != nil {
+		t.Fatalf("couldn't pack message: %v", err)
+	}
+
+	reread, err := netsrv.NewTokeniser(bytes.NewReader(packed), 0).ReadLine()
+	if err != nil {
+		t.Fatalf("couldn't re-read packed message: %v", err)
+	}
+	if !reflect.DeepEqual(reread, line) {
+		t.Errorf("got %q after packing, want %q", reread, line)
+	}
+}
+
+// TestTokeniser_ReadLine_RestOfLineLimit tests that the word limit applies to rest-of-line arguments.
+func TestTokeniser_ReadLine_RestOfLineLimit(t *testing.T) {
+	tok := netsrv.NewTokeniser(strings.NewReader("t1 say 0 a b c d e f g h\n"), 8)
+	tok.RestOfLine = sayRestOfLine
+	if _, err := tok.ReadLine(); !errors.Is(err, netsrv.ErrWordTooLong) {
+		t.Errorf("got error %v, want ErrWordTooLong", err)
+	}
+}
+
+// TestTokeniser_ReadLine_ParseError tests that word-length errors say where in the line they happened.
+func TestTokeniser_ReadLine_ParseError(t *testing.T) {
+	cases := []struct {
+		name  string
+		input string
+		want  netsrv.ParseError
+	}{
+		{"unquoted", "ok\nt1 dump 123456789\n", netsrv.ParseError{Word: 2, WordOffset: 8, Offset: 16}},
+		{"quoted", "t1 'dump 12345'\n", netsrv.ParseError{Word: 1, WordOffset: 8, Offset: 12}},
+		{"rest of line", "t1 say x hello world\n", netsrv.ParseError{Word: 3, WordOffset: 8, Offset: 17}},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			tok := netsrv.NewTokeniser(strings.NewReader(c.input), 8)
+			tok.RestOfLine = sayRestOfLine
+
+			var err error
+			for err == nil {
+				_, err = tok.ReadLine()
+			}
+
+			var perr *netsrv.ParseError
+			if !errors.As(err, &perr) {
+				t.Fatalf("got error %v, want a ParseError", err)
+			}
+			if !errors.Is(err, netsrv.ErrWordTooLong) {
+				t.Errorf("got error %v, want ErrWordTooLong", err)
+			}
+			if perr.Word != c.want.Word || perr.WordOffset != c.want.WordOffset || perr.Offset != c.want.Offset {
+				t.Errorf("got position %d/%d/%d, want %d/%d/%d", perr.Word, perr.WordOffset, perr.Offset,
+					c.want.Word, c.want.WordOffset, c.want.Offset)
+			}
+		})
+	}
+}
+
+// TestTokeniser_ReadLine_Split tests that lines come out the same wherever the reads split them, including mid-word,
+// mid-quote, and between a carriage return and its newline.
+func TestTokeniser_ReadLine_Split(t *testing.T) {
+	input := "t1 'dump it' now\r\nt2 say 0 hello \"world\"\nt3 sel\\ ect 42\n"
+	want := [][]string{
+		{"t1", "dump it", "now"},
+		{"t2", "say", "0", `hello "world"`},
+		{"t3", "sel ect", "42"},
+	}
+
+	for at := 1; at < len(input); at++ {
+		r := io.MultiReader(strings.NewReader(input[:at]), strings.NewReader(input[at:]))
+		tok := netsrv.NewTokeniser(r, 0)
+		tok.RestOfLine = sayRestOfLine
+		for i, w := range want {
+			line, err := tok.ReadLine()
+			if err != nil {
+				t.Fatalf("split at %d, line %d: unexpected error: %v", at, i, err)
+			}
+			if !reflect.DeepEqual(line, w) {
+				t.Errorf("split at %d, line %d: got %q, want %q", at, i, line, w)
+			}
+		}
+		if _, err := tok.ReadLine(); !errors.Is(err, io.EOF) {
+			t.Errorf("split at %d: got error %v at end, want EOF", at, err)
+		}
+	}
+}
+
+// TestTokeniser_ReadLine_EOF tests what happens to a last line that the Reader ends without a newline.
+func TestTokeniser_ReadLine_EOF(t *testing.T) {
+	cases := []struct {
+		name  string
+		input string
+		// want is the last line expected, or nil if there shouldn't be one.
+		want []string
+		// wantErr is the error expected after the last line.
+		wantErr error
+	}{
+		{"newline", "t1 dump\n", []string{"t1", "dump"}, io.EOF},
+		{"no newline", "t1 dump", []string{"t1", "dump"}, io.EOF},
+		{"trailing space", "t1 dump  ", []string{"t1", "dump"}, io.EOF},
+		{"closed quote", "t1 'dump it'", []string{"t1", "dump it"}, io.EOF},
+		{"rest of line", "t1 say 0 hello 'world'\r", []string{"t1", "say", "0", "hello 'world'"}, io.EOF},
+		{"whitespace only", "t1 dump\n  \r", []string{"t1", "dump"}, io.EOF},
+		{"open quote", "t1 'dump it", nil, io.ErrUnexpectedEOF},
+		{"open escape", "t1 dump\\", nil, io.ErrUnexpectedEOF},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			tok := netsrv.NewTokeniser(iotest.OneByteReader(strings.NewReader(c.input)), 0)
+			tok.RestOfLine = sayRestOfLine
+
+			if c.want != nil {
+				line, err := tok.ReadLine()
+				if err != nil {
+					t.Fatalf("unexpected error: %v", err)
+				}
+				if !reflect.DeepEqual(line, c.want) {
+					t.Errorf("got %q, want %q", line, c.want)
+				}
+			}
+
+			if _, err := tok.ReadLine(); !errors.Is(err, c.wantErr) {
+				t.Errorf("got error %v, want %v", err, c.wantErr)
+			}
+		})
+	}
+}
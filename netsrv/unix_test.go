@@ -0,0 +1,80 @@
+package netsrv_test
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"io/ioutil"
+	"log"
+	"net"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/UniversityRadioYork/baps3d/controller"
+	"github.com/UniversityRadioYork/baps3d/list"
+	"github.com/UniversityRadioYork/baps3d/netsrv"
+)
+
+// TestServer_Run_Unix tests that a Server can serve a channel on a Unix domain socket, naming its clients after the
+// socket, and removes the socket's file once it shuts down.
+func TestServer_Run_Unix(t *testing.T) {
+	dir, err := ioutil.TempDir("", "baps3d")
+	if err != nil {
+		t.Fatalf("couldn't make temporary directory: %v", err)
+	}
+	defer func() { _ = os.RemoveAll(dir) }()
+	path := filepath.Join(dir, "baps3d.sock")
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	ctl, root := controller.NewController(list.New())
+	go ctl.Run(ctx)
+	netClient, err := root.Copy(ctx)
+	if err != nil {
+		t.Fatalf("couldn't copy root client: %v", err)
+	}
+
+	events := make(chan netsrv.Event, 8)
+	srv := netsrv.NewMulti(log.New(ioutil.Discard, "", 0), []netsrv.Channel{
+		{Name: "local", Host: path, Network: "unix", Root: netClient},
+	})
+	srv.Events = events
+	done := make(chan struct{})
+	go func() {
+		_ = srv.Run(ctx)
+		close(done)
+	}()
+
+	var conn net.Conn
+	for deadline := time.Now().Add(testTimeout); ; time.Sleep(10 * time.Millisecond) {
+		if conn, err = net.Dial("unix", path); err == nil {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("couldn't connect: %v", err)
+		}
+	}
+	defer func() { _ = conn.Close() }()
+
+	if e := nextEvent(t, events); e.RemoteAddr != "local:"+path {
+		t.Errorf("got client address %q, want the socket's", e.RemoteAddr)
+	}
+	rd := bufio.NewReader(conn)
+	if _, err := rd.ReadString('\n'); err != nil {
+		t.Fatalf("couldn't read greeting line: %v", err)
+	}
+	if _, err := fmt.Fprintln(conn, "t whoami"); err != nil {
+		t.Fatalf("couldn't send line: %v", err)
+	}
+	if lines := readUntilAck(t, rd, "t"); len(lines) == 0 {
+		t.Error("got no WHOAMI over the socket")
+	}
+
+	cancel()
+	waitFor(t, done, "server to stop")
+	if _, err := os.Stat(path); !os.IsNotExist(err) {
+		t.Errorf("socket file still there after shutdown (stat error %v)", err)
+	}
+}
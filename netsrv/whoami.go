@@ -0,0 +1,79 @@
+package netsrv
+
+// File whoami.go contains connection self-description; see WhoamiWord.
+
+import (
+	"fmt"
+	"strconv"
+
+	"github.com/UniversityRadioYork/bifrost-go/core"
+	"github.com/UniversityRadioYork/bifrost-go/message"
+
+	"github.com/UniversityRadioYork/baps3d/controller"
+)
+
+// WhoamiWord is the command word of requests a client sends to ask about its own connection, for example
+// 'tag whoami'; clients that have lost track of their session, for instance after reloading, can use it to catch up.
+// The Server handles these itself, without passing them on to the Controller, and replies with a WHOAMI message
+// (see RsWhoami) and an ACK.
+const WhoamiWord = "whoami"
+
+// RsWhoami is the response word of replies to whoami requests.
+// The arguments are the connection's server-assigned ID, as in Event; its display name, as of the request (see
+// IdentifyWord); and the Bifrost protocol version it speaks.
+// The channel isn't included, as a client's channel is the one whose Host it connected to, and single-channel
+// servers leave it unnamed.
+// Then come its options, which the Server fixes when it connects: its Encoding and SendPolicy, by name, followed by
+// whichever of 'sequenced', 'markown', 'zdump', 'selection', 'playstate', 'commandonly', 'batch', and 'zlines' apply
+// (see Server.Sequence, Server.MarkOwn, Server.CompressDumps, Server.SelectionOnly, Server.PlayStateOnly,
+// Server.CommandOnly, Server.BatchInput, and Server.CompressedInput).
+const RsWhoami = "WHOAMI"
+
+// whoami handles the whoami request m, which came from line.
+// Like rejections, the reply doesn't go through the Controller, so may overtake replies to earlier lines.
+func (e *ioEndpoint) whoami(line []string, m message.Message) error {
+	if len(m.Args()) != 0 {
+		return e.reject(line, fmt.Errorf("%s takes no arguments", WhoamiWord))
+	}
+
+	for _, r := range []message.Message{
+		controller.NewMessage(m.Tag(), RsWhoami, e.self()...),
+		controller.NewMessage(m.Tag(), core.RsAck, "OK", "success"),
+	} {
+		if err := e.queue.push(r); err != nil {
+			e.failSend(err)
+			return err
+		}
+	}
+	return nil
+}
+
+// whoamiArgs gets the arguments of a WHOAMI reply describing c's connection.
+// It is safe to call from any goroutine.
+func (c *Client) whoamiArgs() []string {
+	args := []string{strconv.FormatUint(c.id, 10), c.displayName(), core.ThisProtocolVer}
+	return append(args, c.options...)
+}
+
+// connectionOptions gets the options part of a WHOAMI reply, for a connection with endpoint e over the adapter b.
+func connectionOptions(e *ioEndpoint, b *controller.Bifrost) []string {
+	opts := []string{e.encoding.String(), e.queue.policy.String()}
+	for _, o := range []struct {
+		name string
+		on   bool
+	}{
+		{"sequenced", e.queue.sequenced},
+		{"markown", b.MarkOwn},
+		{"zdump", b.CompressDumps},
+		{"selection", b.SelectionOnly},
+		{"playstate", b.PlayStateOnly},
+		{"commandonly", b.CommandOnly},
+		{"batch", e.batch != nil},
+		{"zlines", 0 < e.maxInflated},
+	} {
+		if o.on {
+			opts = append(opts, o.name)
+		}
+	}
+	return opts
+}
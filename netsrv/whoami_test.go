@@ -0,0 +1,71 @@
+package netsrv_test
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/UniversityRadioYork/bifrost-go/core"
+
+	"github.com/UniversityRadioYork/baps3d/netsrv"
+)
+
+// TestServer_Whoami tests that a whoami request describes the connection, including the name it identified with
+// most recently, and its options.
+func TestServer_Whoami(t *testing.T) {
+	ts := startServer(t, func(s *netsrv.Server) {
+		s.MarkOwn = func(string, net.Addr) bool { return true }
+		s.BatchInput = true
+	})
+	defer ts.Cancel()
+
+	conn, rd := ts.dial(t)
+	defer func() { _ = conn.Close() }()
+
+	ctx, cancel := context.WithTimeout(context.Background(), testTimeout)
+	defer cancel()
+	st, ok := ts.Server.Stats(ctx)
+	if !ok || len(st.Clients) != 1 {
+		t.Fatalf("couldn't get stats for one client: %+v", st)
+	}
+	id := strconv.FormatUint(st.Clients[0].ID, 10)
+
+	whoami := func(name string) {
+		t.Helper()
+
+		if _, err := fmt.Fprintln(conn, "t whoami"); err != nil {
+			t.Fatalf("couldn't send line: %v", err)
+		}
+		lines := readUntilAck(t, rd, "t")
+		want := fmt.Sprintf("t WHOAMI %s %s %s line disconnect markown batch", id, name, core.ThisProtocolVer)
+		if len(lines) == 0 || lines[len(lines)-1] != want {
+			t.Errorf("got lines %q, want %q before the ACK", lines, want)
+		}
+	}
+
+	whoami(conn.LocalAddr().String())
+	if _, err := fmt.Fprintln(conn, "i identify studio-1"); err != nil {
+		t.Fatalf("couldn't send line: %v", err)
+	}
+	readUntilAck(t, rd, "i")
+	whoami("studio-1")
+
+	if _, err := fmt.Fprintln(conn, "t2 whoami now"); err != nil {
+		t.Fatalf("couldn't send line: %v", err)
+	}
+	for {
+		line, err := rd.ReadString('\n')
+		if err != nil {
+			t.Fatalf("couldn't read reply: %v", err)
+		}
+		if strings.HasPrefix(line, "t2 ") {
+			if !strings.HasPrefix(line, "t2 ACK WHAT") {
+				t.Errorf("got %q for a whoami with arguments, want a WHAT ACK", line)
+			}
+			break
+		}
+	}
+}
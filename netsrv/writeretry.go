@@ -0,0 +1,75 @@
+package netsrv
+
+// File writeretry.go contains write timeouts and retries, with which connections ride out short bouts of congestion
+// on marginal links rather than dropping their clients; see Server.WriteTimeout.
+
+import (
+	"context"
+	"fmt"
+	"time"
+)
+
+// write writes b to e's connection, retrying whatever is left of it after a write timeout up to e.writeRetries times.
+// Only timeouts get retries: any other error means the connection is dead, so write returns it at once, as it does
+// once e is closing, when the deadline is Close's.
+// TLS connections never get retries either, as a timed-out write breaks them for good (see retriesWrites).
+// It also fails, writing nothing, if b doesn't fit in what is left of e's byte budget (see ErrByteBudget).
+func (e *ioEndpoint) write(ctx context.Context, b []byte) error {
+	if err := e.spend(len(b)); err != nil {
+		return err
+	}
+	for tries := 0; ; tries++ {
+		e.setWriteTimeout()
+		n, err := e.io.Write(b)
+		e.noteWritten(n)
+		if err == nil {
+			return nil
+		}
+		if !isTimeout(err) || e.isClosing() {
+			return err
+		}
+		if e.writeRetries <= tries || !retriesWrites(e.io) {
+			return fmt.Errorf("write timed out %d times: %w", tries+1, err)
+		}
+
+		// The bytes that did go out went out, so only the rest need retrying.
+		b = b[n:]
+		if !e.waitRetry(ctx) {
+			return err
+		}
+	}
+}
+
+// setWriteTimeout sets the deadline for e's next write, if e has a write timeout.
+// Once e is closing, it leaves the deadline Close set alone.
+func (e *ioEndpoint) setWriteTimeout() {
+	if e.writeTimeout <= 0 {
+		return
+	}
+	dl, ok := e.io.(interface{ SetWriteDeadline(time.Time) error })
+	if !ok {
+		return
+	}
+	e.closeMu.Lock()
+	if !e.closing {
+		// If this fails, the connection is broken, and the write will say so.
+		_ = dl.SetWriteDeadline(time.Now().Add(e.writeTimeout))
+	}
+	e.closeMu.Unlock()
+}
+
+// waitRetry waits e.writeRetryDelay before a write retry, by e's clock.
+// It returns false if ctx finishes first.
+func (e *ioEndpoint) waitRetry(ctx context.Context) bool {
+	if e.writeRetryDelay <= 0 {
+		return ctx.Err() == nil
+	}
+	t := e.clock.NewTimer(e.writeRetryDelay)
+	defer t.Stop()
+	select {
+	case <-t.C():
+		return true
+	case <-ctx.Done():
+		return false
+	}
+}
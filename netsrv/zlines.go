@@ -0,0 +1,123 @@
+package netsrv
+
+// File zlines.go contains compressed input, with which clients on slow links send many lines at once as one gzipped
+// block, symmetrically with compressed dumps (see controller.RsZdump); see ZlinesWord.
+
+import (
+	"bytes"
+	"compress/gzip"
+	"context"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+
+	"github.com/UniversityRadioYork/bifrost-go/core"
+	"github.com/UniversityRadioYork/bifrost-go/message"
+
+	"github.com/UniversityRadioYork/baps3d/controller"
+)
+
+// ZlinesWord is the command word of compressed blocks of lines, for example 'tag zlines H4sI...'.
+// The one argument is the lines, packed as they would have been sent, gzipped, and base64-encoded; see
+// CompressLines.
+//
+// On connections that accept compressed input (see Server.CompressedInput), the Server decompresses each block, then
+// handles the lines in it, in order, as if they had arrived on their own in place of the block: each gets its own
+// replies, under its own tag, and counts towards the request rate.
+// The Controller never sees the block itself.
+// Once the lines are on their way, the block gets an ACK of its own, which may overtake their replies.
+// Blocks that don't decompress, that decompress to too much (see Server.MaxInflatedInput), or that hold lines that
+// can't be read, are rejected whole, leaving the connection open; blocks can't nest.
+const ZlinesWord = "zlines"
+
+// defaultMaxInflatedInput is the most bytes a compressed block of lines can decompress to, if the Server doesn't set
+// MaxInflatedInput.
+const defaultMaxInflatedInput = 1 << 20
+
+// ErrInflatedTooLong is the error given when a compressed block of lines decompresses to more than the Server allows.
+var ErrInflatedTooLong = errors.New("compressed lines too long")
+
+// CompressLines compresses lines, a run of packed lines, into the argument of a zlines message.
+func CompressLines(lines []byte) (string, error) {
+	var buf bytes.Buffer
+	zw := gzip.NewWriter(&buf)
+	if _, err := zw.Write(lines); err != nil {
+		return "", err
+	}
+	if err := zw.Close(); err != nil {
+		return "", err
+	}
+	return base64.StdEncoding.EncodeToString(buf.Bytes()), nil
+}
+
+// inflateLines decompresses data, the argument of a zlines message, to at most max bytes.
+// It reads no more than one byte past max, so a block that decompresses to far more costs no more than that.
+func inflateLines(data string, max int) ([]byte, error) {
+	gz, err := base64.StdEncoding.DecodeString(data)
+	if err != nil {
+		return nil, fmt.Errorf("bad compressed lines: %w", err)
+	}
+	zr, err := gzip.NewReader(bytes.NewReader(gz))
+	if err != nil {
+		return nil, fmt.Errorf("bad compressed lines: %w", err)
+	}
+	defer zr.Close()
+
+	bs, err := ioutil.ReadAll(io.LimitReader(zr, int64(max)+1))
+	if err != nil {
+		return nil, fmt.Errorf("bad compressed lines: %w", err)
+	}
+	if max < len(bs) {
+		return nil, fmt.Errorf("%w: over %d bytes", ErrInflatedTooLong, max)
+	}
+	return bs, nil
+}
+
+// readZlines decompresses the zlines request m, then tokenises the lines in it as e's Tokeniser would.
+func (e *ioEndpoint) readZlines(m message.Message) ([][]string, error) {
+	args := m.Args()
+	if len(args) != 1 {
+		return nil, fmt.Errorf("%s takes one argument", ZlinesWord)
+	}
+	bs, err := inflateLines(args[0], e.maxInflated)
+	if err != nil {
+		return nil, err
+	}
+
+	p := NewParser(e.maxWordLen)
+	p.RestOfLine = e.restOfLine
+	if _, err := p.Write(bs); err != nil {
+		return nil, err
+	}
+	if err := p.End(); err != nil {
+		return nil, err
+	}
+	var lines [][]string
+	for line, ok := p.Line(); ok; line, ok = p.Line() {
+		lines = append(lines, line)
+	}
+	return lines, nil
+}
+
+// takeZlines handles the zlines request m, which came from line, adding the messages in it to msgs.
+func (e *ioEndpoint) takeZlines(ctx context.Context, line []string, m message.Message, errCh chan<- error,
+	msgs []message.Message) ([]message.Message, error) {
+	inner, err := e.readZlines(m)
+	if err != nil {
+		e.sendError(ctx, errCh, err)
+		return msgs, e.reject(line, err)
+	}
+
+	for _, l := range inner {
+		if msgs, err = e.takeLine(ctx, l, errCh, msgs, false); err != nil {
+			return msgs, err
+		}
+	}
+	if err := e.queue.push(controller.NewMessage(m.Tag(), core.RsAck, "OK", "success")); err != nil {
+		e.failSend(err)
+		return msgs, err
+	}
+	return msgs, nil
+}
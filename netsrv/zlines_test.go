@@ -0,0 +1,138 @@
+package netsrv_test
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"net"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/UniversityRadioYork/bifrost-go/message"
+
+	"github.com/UniversityRadioYork/baps3d/netsrv"
+)
+
+// bulkAdd packs n lines, with tags a0, a1, ..., adding tracks with awkward paths to the end of a list.
+func bulkAdd(t *testing.T, n int) []byte {
+	t.Helper()
+
+	var buf bytes.Buffer
+	for i := 0; i < n; i++ {
+		is := strconv.Itoa(i)
+		bs, err := message.New("a"+is, "floadl").AddArgs(is, "h"+is, "/music/"+is+" 'quoted' \"track\".mp3").Pack()
+		if err != nil {
+			t.Fatalf("couldn't pack line: %v", err)
+		}
+		buf.Write(bs)
+	}
+	return buf.Bytes()
+}
+
+// drainRoot discards the broadcasts to ts's root client, so that the list can change.
+func drainRoot(ts *testServer) {
+	go func() {
+		for range ts.Root.Rx {
+		}
+	}()
+}
+
+// dumpOf gets the dump of the list behind the connection conn, read through rd, ignoring broadcasts.
+func dumpOf(t *testing.T, conn net.Conn, rd *bufio.Reader) []string {
+	t.Helper()
+
+	if _, err := fmt.Fprintln(conn, "d dump"); err != nil {
+		t.Fatalf("couldn't send line: %v", err)
+	}
+	var lines []string
+	for _, line := range readUntilAck(t, rd, "d") {
+		if strings.HasPrefix(line, "d ") {
+			lines = append(lines, line)
+		}
+	}
+	return lines
+}
+
+// TestServer_CompressedInput tests that a bulk add sent as a compressed block leaves the list exactly as the same
+// lines sent uncompressed do.
+func TestServer_CompressedInput(t *testing.T) {
+	const n = 50
+	lines := bulkAdd(t, n)
+
+	plain := startServer(t, nil)
+	defer plain.Cancel()
+	drainRoot(plain)
+	pconn, prd := plain.dial(t)
+	defer func() { _ = pconn.Close() }()
+	if _, err := pconn.Write(lines); err != nil {
+		t.Fatalf("couldn't send lines: %v", err)
+	}
+	readUntilAck(t, prd, "a"+strconv.Itoa(n-1))
+
+	zipped := startServer(t, func(s *netsrv.Server) {
+		s.CompressedInput = func(string, net.Addr) bool { return true }
+	})
+	defer zipped.Cancel()
+	drainRoot(zipped)
+	zconn, zrd := zipped.dial(t)
+	defer func() { _ = zconn.Close() }()
+	data, err := netsrv.CompressLines(lines)
+	if err != nil {
+		t.Fatalf("couldn't compress lines: %v", err)
+	}
+	if _, err := fmt.Fprintf(zconn, "z %s %s\n", netsrv.ZlinesWord, data); err != nil {
+		t.Fatalf("couldn't send block: %v", err)
+	}
+	readUntilAck(t, zrd, "a"+strconv.Itoa(n-1))
+
+	pdump, zdump := dumpOf(t, pconn, prd), dumpOf(t, zconn, zrd)
+	if len(pdump) < n {
+		t.Fatalf("dump too short: %q", pdump)
+	}
+	if !reflect.DeepEqual(pdump, zdump) {
+		t.Errorf("compressed dump differs:\ngot  %q\nwant %q", zdump, pdump)
+	}
+}
+
+// TestServer_CompressedInput_Bomb tests that a compressed block decompressing to more than MaxInflatedInput is
+// rejected whole, without disconnecting the client.
+func TestServer_CompressedInput_Bomb(t *testing.T) {
+	ts := startServer(t, func(s *netsrv.Server) {
+		s.CompressedInput = func(string, net.Addr) bool { return true }
+		s.MaxInflatedInput = 1024
+	})
+	defer ts.Cancel()
+	conn, rd := ts.dial(t)
+	defer func() { _ = conn.Close() }()
+
+	bomb, err := netsrv.CompressLines(bytes.Repeat([]byte("x status\n"), 1<<16))
+	if err != nil {
+		t.Fatalf("couldn't compress lines: %v", err)
+	}
+	small, err := netsrv.CompressLines([]byte("y status\n"))
+	if err != nil {
+		t.Fatalf("couldn't compress lines: %v", err)
+	}
+	if _, err := fmt.Fprintf(conn, "b zlines %s\ns zlines %s\n", bomb, small); err != nil {
+		t.Fatalf("couldn't send blocks: %v", err)
+	}
+
+	var acks []string
+	for len(acks) < 3 {
+		line, err := rd.ReadString('\n')
+		if err != nil {
+			t.Fatalf("couldn't read line: %v", err)
+		}
+		if f := strings.Fields(line); 2 < len(f) && f[1] == "ACK" {
+			acks = append(acks, f[0]+" "+f[2])
+		}
+	}
+	// The small block's own ACK can overtake that of the line in it.
+	sort.Strings(acks[1:])
+	if want := []string{"b WHAT", "s OK", "y OK"}; !reflect.DeepEqual(acks, want) {
+		t.Errorf("got ACKs %q, want %q in some order", acks, want)
+	}
+}